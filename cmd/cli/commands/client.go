@@ -23,6 +23,9 @@ const (
 	FwServerURLKey    = "forwarding-server-url"
 	FwLocalPortKey    = "forwarding-local-port"
 	IdentityFileKey   = "identity-file"
+	CACertKey         = "ca-cert"
+	ClientCertKey     = "client-cert"
+	ClientKeyKey      = "client-key"
 )
 
 // ClientByCLIContext creates a new Database Lab API client.
@@ -41,6 +44,9 @@ func ClientByCLIContext(cliCtx *cli.Context) (*dblabapi.Client, error) {
 		VerificationToken: cliCtx.String(TokenKey),
 		Insecure:          cliCtx.Bool(InsecureKey),
 		RequestTimeout:    cliCtx.Duration(RequestTimeoutKey),
+		CACertFile:        cliCtx.String(CACertKey),
+		ClientCertFile:    cliCtx.String(ClientCertKey),
+		ClientKeyFile:     cliCtx.String(ClientKeyKey),
 	}
 
 	// TODO(akartasov): Init and use logger.
@@ -72,6 +72,7 @@ func create(cliCtx *cli.Context) error {
 	}
 
 	cloneRequest.ExtraConf = splitFlags(cliCtx.StringSlice("extra-config"))
+	cloneRequest.AllowEgress = cliCtx.StringSlice("allow-egress")
 
 	var clone *models.Clone
 
@@ -95,6 +96,39 @@ func create(cliCtx *cli.Context) error {
 	return err
 }
 
+// fork runs a request to fork clone.
+func fork(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	forkRequest := types.CloneForkRequest{
+		ID:        cliCtx.String("id"),
+		Protected: cliCtx.Bool("protected"),
+		DB: &types.DatabaseRequest{
+			Username:   cliCtx.String("username"),
+			Password:   cliCtx.String("password"),
+			Restricted: cliCtx.Bool("restricted"),
+			DBName:     cliCtx.String("db-name"),
+		},
+	}
+
+	clone, err := dblabClient.ForkClone(cliCtx.Context, cliCtx.Args().First(), forkRequest)
+	if err != nil {
+		return err
+	}
+
+	commandResponse, err := json.MarshalIndent(clone, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cliCtx.App.Writer, string(commandResponse))
+
+	return err
+}
+
 // status runs a request to get clone info.
 func status() func(*cli.Context) error {
 	return func(cliCtx *cli.Context) error {
@@ -179,6 +213,245 @@ func reset() func(*cli.Context) error {
 	}
 }
 
+// hibernate runs a request to hibernate clone.
+func hibernate(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	cloneID := cliCtx.Args().First()
+
+	if err := dblabClient.HibernateClone(cliCtx.Context, cloneID); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(cliCtx.App.Writer, "The clone has been successfully hibernated: %s\n", cloneID)
+
+	return err
+}
+
+// resume runs a request to resume clone.
+func resume(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	cloneID := cliCtx.Args().First()
+
+	if err := dblabClient.ResumeClone(cliCtx.Context, cloneID); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(cliCtx.App.Writer, "The clone has been successfully resumed: %s\n", cloneID)
+
+	return err
+}
+
+// createSavepoint runs a request to create a savepoint of clone.
+func createSavepoint(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	savepoint, err := dblabClient.CreateSavepoint(cliCtx.Context, cliCtx.Args().First(), cliCtx.String("name"))
+	if err != nil {
+		return err
+	}
+
+	commandResponse, err := json.MarshalIndent(savepoint, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cliCtx.App.Writer, string(commandResponse))
+
+	return err
+}
+
+// listSavepoints runs a request to list savepoints of clone.
+func listSavepoints(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	savepoints, err := dblabClient.ListSavepoints(cliCtx.Context, cliCtx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	commandResponse, err := json.MarshalIndent(savepoints, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cliCtx.App.Writer, string(commandResponse))
+
+	return err
+}
+
+// revertSavepoint runs a request to revert clone to its savepoint.
+func revertSavepoint(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	cloneID, name := cliCtx.Args().Get(0), cliCtx.Args().Get(1)
+
+	if err := dblabClient.RevertSavepoint(cliCtx.Context, cloneID, name); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(cliCtx.App.Writer, "The clone %s has been successfully reverted to savepoint: %s\n", cloneID, name)
+
+	return err
+}
+
+// startExport runs a request to export clone data.
+func startExport(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	exportRequest := types.CloneExportRequest{
+		Format: cliCtx.String("format"),
+		DBName: cliCtx.String("db-name"),
+		Jobs:   cliCtx.Uint("jobs"),
+	}
+
+	export, err := dblabClient.ExportClone(cliCtx.Context, cliCtx.Args().First(), exportRequest)
+	if err != nil {
+		return err
+	}
+
+	commandResponse, err := json.MarshalIndent(export, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cliCtx.App.Writer, string(commandResponse))
+
+	return err
+}
+
+// exportStatus runs a request to get the latest export of clone.
+func exportStatus(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	export, err := dblabClient.GetCloneExport(cliCtx.Context, cliCtx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	commandResponse, err := json.MarshalIndent(export, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cliCtx.App.Writer, string(commandResponse))
+
+	return err
+}
+
+// downloadExport downloads the file of the finished export of clone.
+func downloadExport(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	outputPath := cliCtx.String("output")
+
+	body, err := dblabClient.DownloadCloneExport(cliCtx.Context, cliCtx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := body.Close(); err != nil {
+			log.Err(err)
+		}
+	}()
+
+	exportFile, err := os.Create(outputPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create file %s", outputPath)
+	}
+
+	defer func() { _ = exportFile.Close() }()
+
+	if _, err := io.Copy(exportFile, body); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(cliCtx.App.Writer, "The file has been successfully downloaded: %s\n", outputPath)
+
+	return err
+}
+
+// runQuery runs a request to run query in clone.
+func runQuery(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	queryRequest := types.QueryRequest{
+		Query:  cliCtx.String("query"),
+		Commit: cliCtx.Bool("commit"),
+	}
+
+	result, err := dblabClient.RunQuery(cliCtx.Context, cliCtx.Args().First(), queryRequest)
+	if err != nil {
+		return err
+	}
+
+	commandResponse, err := json.MarshalIndent(result, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cliCtx.App.Writer, string(commandResponse))
+
+	return err
+}
+
+// adviseIndexes runs a request to compare hypothetical indexes for query in clone.
+func adviseIndexes(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	adviceRequest := types.IndexAdviceRequest{
+		Query:   cliCtx.String("query"),
+		Indexes: cliCtx.StringSlice("index"),
+		Build:   cliCtx.Bool("build"),
+	}
+
+	advice, err := dblabClient.AdviseIndexes(cliCtx.Context, cliCtx.Args().First(), adviceRequest)
+	if err != nil {
+		return err
+	}
+
+	commandResponse, err := json.MarshalIndent(advice, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cliCtx.App.Writer, string(commandResponse))
+
+	return err
+}
+
 // destroy runs a request to destroy clone.
 func destroy() func(*cli.Context) error {
 	return func(cliCtx *cli.Context) error {
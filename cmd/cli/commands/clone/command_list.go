@@ -8,6 +8,7 @@ import (
 	"github.com/urfave/cli/v2"
 
 	"gitlab.com/postgres-ai/database-lab/v2/cmd/cli/commands"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
 )
 
 const (
@@ -78,6 +79,46 @@ func CommandList() []*cli.Command {
 						Name:  "extra-config",
 						Usage: "set an extra database configuration for the clone. An example: statement_timeout='1s'",
 					},
+					&cli.StringSliceFlag{
+						Name:  "allow-egress",
+						Usage: "allow the isolated clone to connect to the destination. An example: 10.0.0.5:5432",
+					},
+				},
+			},
+			{
+				Name:      "fork",
+				Usage:     "create new clone with the current data of existing clone",
+				ArgsUsage: "CLONE_ID",
+				Before:    checkCloneIDBefore,
+				Action:    fork,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "username",
+						Usage:    "database username",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "password",
+						Usage:    "database password",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "restricted",
+						Usage: "create a user with restricted permissions",
+					},
+					&cli.StringFlag{
+						Name:  "db-name",
+						Usage: "database available to the user with restricted permissions",
+					},
+					&cli.StringFlag{
+						Name:  "id",
+						Usage: "fork ID (optional)",
+					},
+					&cli.BoolFlag{
+						Name:    "protected",
+						Usage:   "mark instance as protected from deletion",
+						Aliases: []string{"p"},
+					},
 				},
 			},
 			{
@@ -116,6 +157,144 @@ func CommandList() []*cli.Command {
 					},
 				},
 			},
+			{
+				Name:      "hibernate",
+				Usage:     "stop clone's container keeping its data and port",
+				ArgsUsage: "CLONE_ID",
+				Before:    checkCloneIDBefore,
+				Action:    hibernate,
+			},
+			{
+				Name:      "resume",
+				Usage:     "start container of hibernated clone",
+				ArgsUsage: "CLONE_ID",
+				Before:    checkCloneIDBefore,
+				Action:    resume,
+			},
+			{
+				Name:  "savepoint",
+				Usage: "manage savepoints of clone",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "create",
+						Usage:     "snapshot clone to revert it to its current state later",
+						ArgsUsage: "CLONE_ID",
+						Before:    checkCloneIDBefore,
+						Action:    createSavepoint,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "name",
+								Usage: "savepoint name; it is generated from the current time if not specified",
+							},
+						},
+					},
+					{
+						Name:      "list",
+						Usage:     "list savepoints of clone",
+						ArgsUsage: "CLONE_ID",
+						Before:    checkCloneIDBefore,
+						Action:    listSavepoints,
+					},
+					{
+						Name:      "revert",
+						Usage:     "restart clone with the data of savepoint",
+						ArgsUsage: "CLONE_ID SAVEPOINT",
+						Before:    checkSavepointBefore,
+						Action:    revertSavepoint,
+					},
+				},
+			},
+			{
+				Name:  "export",
+				Usage: "export clone data to a file",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "start",
+						Usage:     "start writing clone data to a file on the server",
+						ArgsUsage: "CLONE_ID",
+						Before:    checkCloneIDBefore,
+						Action:    startExport,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "format",
+								Usage: `export format: "custom" or "directory" dump of pg_dump, or "zfs" stream`,
+								Value: models.ExportFormatCustom,
+							},
+							&cli.StringFlag{
+								Name:  "db-name",
+								Usage: "database to dump; the database of clone is used if not specified",
+							},
+							&cli.UintFlag{
+								Name:  "jobs",
+								Usage: "number of parallel pg_dump jobs in the directory format",
+							},
+						},
+					},
+					{
+						Name:      "status",
+						Usage:     "display the status of the latest export of clone",
+						ArgsUsage: "CLONE_ID",
+						Before:    checkCloneIDBefore,
+						Action:    exportStatus,
+					},
+					{
+						Name:      "download",
+						Usage:     "download the file of the finished export of clone",
+						ArgsUsage: "CLONE_ID",
+						Before:    checkCloneIDBefore,
+						Action:    downloadExport,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "output",
+								Usage:    "write the export to file",
+								Required: true,
+								Aliases:  []string{"o"},
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:      "query",
+				Usage:     "run query with EXPLAIN ANALYZE in clone",
+				ArgsUsage: "CLONE_ID",
+				Before:    checkCloneIDBefore,
+				Action:    runQuery,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "query",
+						Usage:    "query to run",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "commit",
+						Usage: "commit the transaction of the query instead of rolling it back",
+					},
+				},
+			},
+			{
+				Name:      "index-advice",
+				Usage:     "compare hypothetical indexes for query in clone",
+				ArgsUsage: "CLONE_ID",
+				Before:    checkCloneIDBefore,
+				Action:    adviseIndexes,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "query",
+						Usage:    "query to explain",
+						Required: true,
+					},
+					&cli.StringSliceFlag{
+						Name:     "index",
+						Usage:    "definition of candidate index (e.g. \"create index on users (email)\"), can be set multiple times",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "build",
+						Usage: "create the best index in clone and measure query execution time before and after",
+					},
+				},
+			},
 			{
 				Name:      "destroy",
 				Usage:     "destroy clone",
@@ -244,3 +423,11 @@ func checkCloneIDBefore(c *cli.Context) error {
 
 	return nil
 }
+
+func checkSavepointBefore(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return commands.NewActionError("CLONE_ID and SAVEPOINT arguments are required")
+	}
+
+	return nil
+}
@@ -53,6 +53,18 @@ func CommandList() []*cli.Command {
 							Name:  "identity-file",
 							Usage: "select a file from which the identity (private key) for public key authentication is read",
 						},
+						&cli.StringFlag{
+							Name:  "ca-cert",
+							Usage: "path to CA certificates verifying the server instead of the system ones",
+						},
+						&cli.StringFlag{
+							Name:  "client-cert",
+							Usage: "path to a client certificate if the server requires client certificates",
+						},
+						&cli.StringFlag{
+							Name:  "client-key",
+							Usage: "path to the private key of the client certificate",
+						},
 					},
 				},
 				{
@@ -89,6 +101,18 @@ func CommandList() []*cli.Command {
 							Name:  "identity-file",
 							Usage: "select a file from which the identity (private key) for public key authentication is read",
 						},
+						&cli.StringFlag{
+							Name:  "ca-cert",
+							Usage: "path to CA certificates verifying the server instead of the system ones",
+						},
+						&cli.StringFlag{
+							Name:  "client-cert",
+							Usage: "path to a client certificate if the server requires client certificates",
+						},
+						&cli.StringFlag{
+							Name:  "client-key",
+							Usage: "path to the private key of the client certificate",
+						},
 					},
 				},
 				{
@@ -25,6 +25,7 @@ type Environment struct {
 	Insecure       bool       `yaml:"insecure" json:"insecure"`
 	RequestTimeout Duration   `yaml:"request_timeout,omitempty" json:"request_timeout,omitempty"`
 	Forwarding     Forwarding `yaml:"forwarding" json:"forwarding"`
+	TLS            TLS        `yaml:"tls,omitempty" json:"tls,omitempty"`
 }
 
 // Forwarding defines configuration for port forwarding.
@@ -34,6 +35,13 @@ type Forwarding struct {
 	IdentityFile string `yaml:"identity_file" json:"identity_file"`
 }
 
+// TLS defines certificates used to connect to the server.
+type TLS struct {
+	CACert     string `yaml:"ca_cert,omitempty" json:"ca_cert,omitempty"`
+	ClientCert string `yaml:"client_cert,omitempty" json:"client_cert,omitempty"`
+	ClientKey  string `yaml:"client_key,omitempty" json:"client_key,omitempty"`
+}
+
 // AddEnvironmentToConfig adds a new environment to CLIConfig.
 func AddEnvironmentToConfig(c *cli.Context, cfg *CLIConfig, environmentID string) error {
 	if environmentID == "" {
@@ -54,6 +62,11 @@ func AddEnvironmentToConfig(c *cli.Context, cfg *CLIConfig, environmentID string
 			LocalPort:    c.String(commands.FwLocalPortKey),
 			IdentityFile: c.String(commands.IdentityFileKey),
 		},
+		TLS: TLS{
+			CACert:     c.String(commands.CACertKey),
+			ClientCert: c.String(commands.ClientCertKey),
+			ClientKey:  c.String(commands.ClientKeyKey),
+		},
 	}
 
 	if cfg.Environments == nil {
@@ -111,6 +124,18 @@ func updateEnvironmentInConfig(c *cli.Context, cfg *CLIConfig, environmentID str
 		newEnvironment.Forwarding.IdentityFile = c.String(commands.IdentityFileKey)
 	}
 
+	if c.IsSet(commands.CACertKey) {
+		newEnvironment.TLS.CACert = c.String(commands.CACertKey)
+	}
+
+	if c.IsSet(commands.ClientCertKey) {
+		newEnvironment.TLS.ClientCert = c.String(commands.ClientCertKey)
+	}
+
+	if c.IsSet(commands.ClientKeyKey) {
+		newEnvironment.TLS.ClientKey = c.String(commands.ClientKeyKey)
+	}
+
 	if newEnvironment == environment {
 		return errors.New("config unchanged. Set different option values to update.") // nolint
 	}
@@ -75,6 +75,21 @@ func main() {
 				Usage:   "select a file from which the identity (private key) for public key authentication is read",
 				EnvVars: []string{"DBLAB_CLI_IDENTITY_FILE"},
 			},
+			&cli.StringFlag{
+				Name:    "ca-cert",
+				Usage:   "path to CA certificates verifying the server instead of the system ones",
+				EnvVars: []string{"DBLAB_CA_CERT"},
+			},
+			&cli.StringFlag{
+				Name:    "client-cert",
+				Usage:   "path to a client certificate if the server requires client certificates",
+				EnvVars: []string{"DBLAB_CLIENT_CERT"},
+			},
+			&cli.StringFlag{
+				Name:    "client-key",
+				Usage:   "path to the private key of the client certificate",
+				EnvVars: []string{"DBLAB_CLIENT_KEY"},
+			},
 			&cli.BoolFlag{
 				Name:    "debug",
 				Usage:   "run in debug mode",
@@ -149,6 +164,24 @@ func loadEnvironmentParams(c *cli.Context) error {
 				return err
 			}
 		}
+
+		if !c.IsSet(commands.CACertKey) {
+			if err := c.Set(commands.CACertKey, env.TLS.CACert); err != nil {
+				return err
+			}
+		}
+
+		if !c.IsSet(commands.ClientCertKey) {
+			if err := c.Set(commands.ClientCertKey, env.TLS.ClientCert); err != nil {
+				return err
+			}
+		}
+
+		if !c.IsSet(commands.ClientKeyKey) {
+			if err := c.Set(commands.ClientKeyKey, env.TLS.ClientKey); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
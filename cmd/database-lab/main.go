@@ -33,6 +33,7 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/pool"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/runners"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/proxy"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/srv"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/util/networks"
 	"gitlab.com/postgres-ai/database-lab/v2/version"
@@ -129,12 +130,22 @@ func main() {
 		return
 	}
 
+	if cfg.Proxy.Enabled {
+		proxySvc := proxy.New(cfg.Proxy, cloningSvc, cloningSvc)
+
+		go func() {
+			if err := proxySvc.Run(ctx); err != nil {
+				log.Err(err)
+			}
+		}()
+	}
+
 	obs := observer.NewObserver(dockerCLI, &cfg.Observer, platformSvc.Client, pm)
 	est := estimator.NewEstimator(&cfg.Estimator)
 
 	go removeObservingClones(obsCh, obs)
 
-	server := srv.NewServer(&cfg.Server, &cfg.Global, obs, cloningSvc, platformSvc, dockerCLI, est, pm)
+	server := srv.NewServer(&cfg.Server, &cfg.Global, obs, cloningSvc, platformSvc, dockerCLI, est, pm, retrievalSvc)
 	shutdownCh := setShutdownListener()
 
 	go setReloadListener(ctx, instanceID, provisionSvc, retrievalSvc, pm, cloningSvc, platformSvc, est, server)
@@ -176,6 +187,10 @@ func reloadConfig(ctx context.Context, instanceID string, provisionSvc *provisio
 		return err
 	}
 
+	if err := cloning.IsValidConfig(cfg.Cloning); err != nil {
+		return err
+	}
+
 	newPlatformSvc, err := platform.New(ctx, cfg.Platform)
 	if err != nil {
 		return err
@@ -8,9 +8,11 @@ package dblabapi
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -42,11 +44,15 @@ type Client struct {
 }
 
 // Options describes options of a Database Lab API client.
+// CACertFile replaces system CA certificates to verify the server, a client certificate is used if the server requires it.
 type Options struct {
 	Host              string
 	VerificationToken string
 	Insecure          bool
 	RequestTimeout    time.Duration
+	CACertFile        string
+	ClientCertFile    string
+	ClientKeyFile     string
 }
 
 const (
@@ -63,8 +69,13 @@ func NewClient(options Options) (*Client, error) {
 
 	u.Path = strings.TrimRight(u.Path, "/")
 
+	tlsConfig, err := buildTLSConfig(options)
+	if err != nil {
+		return nil, err
+	}
+
 	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: options.Insecure},
+		TLSClientConfig: tlsConfig,
 	}
 
 	if options.RequestTimeout == 0 {
@@ -80,6 +91,35 @@ func NewClient(options Options) (*Client, error) {
 	}, nil
 }
 
+func buildTLSConfig(options Options) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: options.Insecure}
+
+	if options.CACertFile != "" {
+		pemCerts, err := ioutil.ReadFile(options.CACertFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read CA certificates")
+		}
+
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(pemCerts) {
+			return nil, errors.Errorf("no certificates found in %s", options.CACertFile)
+		}
+
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if options.ClientCertFile != "" || options.ClientKeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(options.ClientCertFile, options.ClientKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load the client certificate")
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
+
 // URL builds URL for a specific endpoint.
 func (c *Client) URL(endpoint string) *url.URL {
 	p := path.Join(c.url.Path, endpoint)
@@ -1,7 +1,9 @@
 package dblabapi
 
 import (
+	"io/ioutil"
 	"net/http"
+	"path"
 	"testing"
 	"time"
 
@@ -49,3 +51,23 @@ func TestClientURL(t *testing.T) {
 
 	assert.Equal(t, "https://example.com/test-url", c.URL("test-url").String())
 }
+
+func TestNewClientTLSOptions(t *testing.T) {
+	_, err := NewClient(Options{Host: "https://example.com", CACertFile: "/nonexistent/ca.crt"})
+	assert.Error(t, err)
+
+	emptyFile := path.Join(t.TempDir(), "empty.crt")
+	require.NoError(t, ioutil.WriteFile(emptyFile, []byte{}, 0600))
+
+	_, err = NewClient(Options{Host: "https://example.com", CACertFile: emptyFile})
+	assert.EqualError(t, err, "no certificates found in "+emptyFile)
+
+	_, err = NewClient(Options{Host: "https://example.com", ClientCertFile: emptyFile, ClientKeyFile: emptyFile})
+	assert.Error(t, err)
+
+	tlsConfig, err := buildTLSConfig(Options{Insecure: true})
+	require.NoError(t, err)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+	assert.Nil(t, tlsConfig.RootCAs)
+	assert.Empty(t, tlsConfig.Certificates)
+}
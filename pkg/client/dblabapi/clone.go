@@ -73,13 +73,26 @@ func (c *Client) GetClone(ctx context.Context, cloneID string) (*models.Clone, e
 
 // CreateClone creates a new Database Lab clone.
 func (c *Client) CreateClone(ctx context.Context, cloneRequest types.CloneCreateRequest) (*models.Clone, error) {
-	u := c.URL("/clone")
-
 	body := bytes.NewBuffer(nil)
 	if err := json.NewEncoder(body).Encode(cloneRequest); err != nil {
 		return nil, errors.Wrap(err, "failed to encode CloneCreateRequest")
 	}
 
+	return c.createClone(ctx, c.URL("/clone"), body)
+}
+
+// ForkClone creates a new Database Lab clone with the current data of a running clone.
+func (c *Client) ForkClone(ctx context.Context, cloneID string, forkRequest types.CloneForkRequest) (*models.Clone, error) {
+	body := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(body).Encode(forkRequest); err != nil {
+		return nil, errors.Wrap(err, "failed to encode CloneForkRequest")
+	}
+
+	return c.createClone(ctx, c.URL(fmt.Sprintf("/clone/%s/fork", cloneID)), body)
+}
+
+// createClone sends the request creating a clone and waits until the clone is ready.
+func (c *Client) createClone(ctx context.Context, u *url.URL, body io.Reader) (*models.Clone, error) {
 	request, err := http.NewRequest(http.MethodPost, u.String(), body)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to make a request")
@@ -303,6 +316,185 @@ func (c *Client) DestroyCloneAsync(ctx context.Context, cloneID string) error {
 	return nil
 }
 
+// HibernateClone stops the container of a Database Lab clone keeping its data and port.
+func (c *Client) HibernateClone(ctx context.Context, cloneID string) error {
+	return c.changeCloneState(ctx, cloneID, "hibernate", models.StatusHibernating, models.StatusHibernated)
+}
+
+// ResumeClone starts the container of a hibernated Database Lab clone.
+func (c *Client) ResumeClone(ctx context.Context, cloneID string) error {
+	return c.changeCloneState(ctx, cloneID, "resume", models.StatusResuming, models.StatusOK)
+}
+
+// CreateSavepoint snapshots a running Database Lab clone, so the clone can be reverted to its current state.
+func (c *Client) CreateSavepoint(ctx context.Context, cloneID, name string) (*models.Savepoint, error) {
+	u := c.URL(fmt.Sprintf("/clone/%s/savepoints", cloneID))
+
+	body := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(body).Encode(types.SavepointCreateRequest{Name: name}); err != nil {
+		return nil, errors.Wrap(err, "failed to encode SavepointCreateRequest")
+	}
+
+	request, err := http.NewRequest(http.MethodPost, u.String(), body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	var savepoint models.Savepoint
+
+	if err := json.NewDecoder(response.Body).Decode(&savepoint); err != nil {
+		return nil, errors.Wrap(err, "failed to decode a response body")
+	}
+
+	return &savepoint, nil
+}
+
+// ListSavepoints provides a list of savepoints of a Database Lab clone.
+func (c *Client) ListSavepoints(ctx context.Context, cloneID string) ([]models.Savepoint, error) {
+	u := c.URL(fmt.Sprintf("/clone/%s/savepoints", cloneID))
+
+	request, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	var savepoints []models.Savepoint
+
+	if err := json.NewDecoder(response.Body).Decode(&savepoints); err != nil {
+		return nil, errors.Wrap(err, "failed to decode a response body")
+	}
+
+	return savepoints, nil
+}
+
+// RevertSavepoint restarts a Database Lab clone with the data of its savepoint.
+func (c *Client) RevertSavepoint(ctx context.Context, cloneID, name string) error {
+	return c.changeCloneState(ctx, cloneID, fmt.Sprintf("savepoints/%s/revert", name), models.StatusResetting, models.StatusOK)
+}
+
+// ExportClone starts an export of a Database Lab clone to a file.
+func (c *Client) ExportClone(ctx context.Context, cloneID string, exportRequest types.CloneExportRequest) (*models.CloneExport, error) {
+	u := c.URL(fmt.Sprintf("/clone/%s/export", cloneID))
+
+	var export models.CloneExport
+
+	if err := c.request(ctx, u, exportRequest, &export); err != nil {
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+// GetCloneExport provides the latest export of a Database Lab clone.
+func (c *Client) GetCloneExport(ctx context.Context, cloneID string) (*models.CloneExport, error) {
+	u := c.URL(fmt.Sprintf("/clone/%s/export", cloneID))
+
+	request, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	var export models.CloneExport
+
+	if err := json.NewDecoder(response.Body).Decode(&export); err != nil {
+		return nil, errors.Wrap(err, "failed to decode a response body")
+	}
+
+	return &export, nil
+}
+
+// DownloadCloneExport downloads the file of the finished export of a Database Lab clone.
+func (c *Client) DownloadCloneExport(ctx context.Context, cloneID string) (io.ReadCloser, error) {
+	u := c.URL(fmt.Sprintf("/clone/%s/export/download", cloneID))
+
+	request, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	return response.Body, nil
+}
+
+// RunQuery runs a query with EXPLAIN ANALYZE in a Database Lab clone.
+func (c *Client) RunQuery(ctx context.Context, cloneID string, queryRequest types.QueryRequest) (*models.QueryResult, error) {
+	u := c.URL(fmt.Sprintf("/clone/%s/query", cloneID))
+
+	var result models.QueryResult
+
+	if err := c.request(ctx, u, queryRequest, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// AdviseIndexes compares hypothetical indexes for a query in a Database Lab clone.
+func (c *Client) AdviseIndexes(ctx context.Context, cloneID string, adviceRequest types.IndexAdviceRequest) (*models.IndexAdvice, error) {
+	u := c.URL(fmt.Sprintf("/clone/%s/index-advice", cloneID))
+
+	var advice models.IndexAdvice
+
+	if err := c.request(ctx, u, adviceRequest, &advice); err != nil {
+		return nil, err
+	}
+
+	return &advice, nil
+}
+
+// changeCloneState runs the clone action and waits until the clone leaves the transitional status.
+func (c *Client) changeCloneState(ctx context.Context, cloneID, action string, transitionalStatus, expectedStatus models.StatusCode) error {
+	u := c.URL(fmt.Sprintf("/clone/%s/%s", cloneID, action))
+
+	request, err := http.NewRequest(http.MethodPost, u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return errors.Wrap(err, "failed to get response")
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	clone, err := c.watchCloneStatus(ctx, cloneID, transitionalStatus)
+	if err != nil {
+		return errors.Wrap(err, "failed to watch the clone status")
+	}
+
+	if clone.Status.Code == expectedStatus {
+		return nil
+	}
+
+	return errors.Errorf("unexpected clone status given: %v", clone.Status)
+}
+
 // StartObservation starts a new clone observation.
 func (c *Client) StartObservation(ctx context.Context, startRequest types.StartObservationRequest) (*observer.Session, error) {
 	u := c.URL("/observation/start")
@@ -614,3 +614,363 @@ func TestClientResetCloneWithFailedRequest(t *testing.T) {
 	err = c.ResetClone(context.Background(), "testCloneID", types.ResetCloneRequest{Latest: true, SnapshotID: "test"})
 	assert.EqualError(t, err, `failed to get response: Check your verification token.`)
 }
+
+func TestClientHibernateClone(t *testing.T) {
+	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		var responseBody []byte
+
+		if r.Method == http.MethodPost {
+			assert.Equal(t, r.URL.String(), "https://example.com/clone/testCloneID/hibernate")
+		} else {
+			assert.Equal(t, r.URL.String(), "https://example.com/clone/testCloneID")
+
+			clone := models.Clone{
+				ID: "testCloneID",
+				Status: models.Status{
+					Code:    models.StatusHibernated,
+					Message: models.CloneMessageHibernated,
+				},
+			}
+
+			var err error
+			responseBody, err = json.Marshal(clone)
+			require.NoError(t, err)
+		}
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBuffer(responseBody)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "token",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+	c.pollingInterval = time.Millisecond
+
+	err = c.HibernateClone(context.Background(), "testCloneID")
+	require.NoError(t, err)
+}
+
+func TestClientCreateSavepoint(t *testing.T) {
+	expectedSavepoint := models.Savepoint{
+		Name:      "before_migration",
+		CreatedAt: "2021-10-19 12:00:00 UTC",
+	}
+
+	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		assert.Equal(t, r.URL.String(), "https://example.com/clone/testCloneID/savepoints")
+		assert.Equal(t, r.Method, http.MethodPost)
+
+		requestBody, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		savepointRequest := types.SavepointCreateRequest{}
+		err = json.Unmarshal(requestBody, &savepointRequest)
+		require.NoError(t, err)
+		assert.Equal(t, "before_migration", savepointRequest.Name)
+
+		responseBody, err := json.Marshal(expectedSavepoint)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: 201,
+			Body:       io.NopCloser(bytes.NewBuffer(responseBody)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "token",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	savepoint, err := c.CreateSavepoint(context.Background(), "testCloneID", "before_migration")
+	require.NoError(t, err)
+	assert.Equal(t, expectedSavepoint, *savepoint)
+}
+
+func TestClientRevertSavepoint(t *testing.T) {
+	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		var responseBody []byte
+
+		if r.Method == http.MethodPost {
+			assert.Equal(t, r.URL.String(), "https://example.com/clone/testCloneID/savepoints/before_migration/revert")
+		} else {
+			assert.Equal(t, r.URL.String(), "https://example.com/clone/testCloneID")
+
+			clone := models.Clone{
+				ID: "testCloneID",
+				Status: models.Status{
+					Code:    models.StatusOK,
+					Message: models.CloneMessageOK,
+				},
+			}
+
+			var err error
+			responseBody, err = json.Marshal(clone)
+			require.NoError(t, err)
+		}
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBuffer(responseBody)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "token",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+	c.pollingInterval = time.Millisecond
+
+	err = c.RevertSavepoint(context.Background(), "testCloneID", "before_migration")
+	require.NoError(t, err)
+}
+
+func TestClientForkClone(t *testing.T) {
+	expectedClone := models.Clone{
+		ID:     "testForkID",
+		Parent: "testCloneID",
+		Status: models.Status{
+			Code:    models.StatusOK,
+			Message: models.CloneMessageOK,
+		},
+		DB: models.Database{
+			Username: "john",
+		},
+	}
+
+	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		clone := expectedClone
+
+		if r.Method == http.MethodPost {
+			assert.Equal(t, r.URL.String(), "https://example.com/clone/testCloneID/fork")
+
+			requestBody, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			forkRequest := types.CloneForkRequest{}
+			err = json.Unmarshal(requestBody, &forkRequest)
+			require.NoError(t, err)
+			assert.Equal(t, "testForkID", forkRequest.ID)
+
+			clone.Status = models.Status{
+				Code:    models.StatusCreating,
+				Message: models.CloneMessageCreating,
+			}
+		} else {
+			assert.Equal(t, r.URL.String(), "https://example.com/clone/testForkID")
+		}
+
+		responseBody, err := json.Marshal(clone)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBuffer(responseBody)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "token",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+	c.pollingInterval = time.Millisecond
+
+	fork, err := c.ForkClone(context.Background(), "testCloneID", types.CloneForkRequest{
+		ID: "testForkID",
+		DB: &types.DatabaseRequest{
+			Username: "john",
+			Password: "doe",
+		},
+	})
+	require.NoError(t, err)
+
+	assert.EqualValues(t, expectedClone, *fork)
+}
+
+func TestClientExportClone(t *testing.T) {
+	expectedExport := models.CloneExport{
+		ID:        "c5ocm0qq2sfadq6ekqr0",
+		CloneID:   "testCloneID",
+		Format:    models.ExportFormatDirectory,
+		DBName:    "test",
+		Status:    models.ExportStatusInProgress,
+		StartedAt: "2021-10-19 12:00:00 UTC",
+	}
+
+	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		assert.Equal(t, r.URL.String(), "https://example.com/clone/testCloneID/export")
+		assert.Equal(t, r.Method, http.MethodPost)
+
+		requestBody, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		exportRequest := types.CloneExportRequest{}
+		err = json.Unmarshal(requestBody, &exportRequest)
+		require.NoError(t, err)
+		assert.Equal(t, types.CloneExportRequest{Format: models.ExportFormatDirectory, DBName: "test", Jobs: 2}, exportRequest)
+
+		responseBody, err := json.Marshal(expectedExport)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: 202,
+			Body:       io.NopCloser(bytes.NewBuffer(responseBody)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "token",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	export, err := c.ExportClone(context.Background(), "testCloneID",
+		types.CloneExportRequest{Format: models.ExportFormatDirectory, DBName: "test", Jobs: 2})
+	require.NoError(t, err)
+	assert.Equal(t, expectedExport, *export)
+}
+
+func TestClientDownloadCloneExport(t *testing.T) {
+	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		assert.Equal(t, r.URL.String(), "https://example.com/clone/testCloneID/export/download")
+		assert.Equal(t, r.Method, http.MethodGet)
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBufferString("PGDMP")),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "token",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	body, err := c.DownloadCloneExport(context.Background(), "testCloneID")
+	require.NoError(t, err)
+
+	defer func() { _ = body.Close() }()
+
+	content, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "PGDMP", string(content))
+}
+
+func TestClientRunQuery(t *testing.T) {
+	expectedResult := models.QueryResult{
+		Plan:          json.RawMessage(`[{"Plan":{"Node Type":"Result"},"Planning Time":0.02,"Execution Time":0.01}]`),
+		PlanningTime:  0.02,
+		ExecutionTime: 0.01,
+		TotalTime:     0.5,
+		Profile:       models.QueryProfile{WaitEvents: map[string]float64{"Running": 100}},
+	}
+
+	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		assert.Equal(t, r.URL.String(), "https://example.com/clone/testCloneID/query")
+		assert.Equal(t, r.Method, http.MethodPost)
+
+		requestBody, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		queryRequest := types.QueryRequest{}
+		err = json.Unmarshal(requestBody, &queryRequest)
+		require.NoError(t, err)
+		assert.Equal(t, types.QueryRequest{Query: "select 1"}, queryRequest)
+
+		responseBody, err := json.Marshal(expectedResult)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBuffer(responseBody)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "token",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	result, err := c.RunQuery(context.Background(), "testCloneID", types.QueryRequest{Query: "select 1"})
+	require.NoError(t, err)
+	assert.Equal(t, expectedResult, *result)
+}
+
+func TestClientAdviseIndexes(t *testing.T) {
+	adviceRequest := types.IndexAdviceRequest{
+		Query:   "select * from users where email = 'user@example.com'",
+		Indexes: []string{"create index on users (email)"},
+	}
+
+	expectedAdvice := models.IndexAdvice{
+		Cost: 1000,
+		Candidates: []models.IndexCandidate{
+			{Definition: "create index on users (email)", Cost: 8.3, CostReduction: 99.17, Used: true},
+		},
+		BestIndex: "create index on users (email)",
+	}
+
+	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		assert.Equal(t, r.URL.String(), "https://example.com/clone/testCloneID/index-advice")
+		assert.Equal(t, r.Method, http.MethodPost)
+
+		requestBody, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		request := types.IndexAdviceRequest{}
+		err = json.Unmarshal(requestBody, &request)
+		require.NoError(t, err)
+		assert.Equal(t, adviceRequest, request)
+
+		responseBody, err := json.Marshal(expectedAdvice)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBuffer(responseBody)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "token",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	advice, err := c.AdviseIndexes(context.Background(), "testCloneID", adviceRequest)
+	require.NoError(t, err)
+	assert.Equal(t, expectedAdvice, *advice)
+}
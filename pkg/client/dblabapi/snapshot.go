@@ -7,7 +7,9 @@ package dblabapi
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/pkg/errors"
 
@@ -38,3 +40,30 @@ func (c *Client) ListSnapshots(ctx context.Context) ([]*models.Snapshot, error)
 
 	return snapshots, nil
 }
+
+// ExportSnapshot requests a ZFS replication stream of the snapshot. The stream is incremental if the base snapshot is defined.
+// The caller must close the returned stream.
+func (c *Client) ExportSnapshot(ctx context.Context, snapshotID, baseSnapshotID string) (io.ReadCloser, error) {
+	u := c.URL("/snapshots/export")
+
+	values := url.Values{}
+	values.Set("snapshot_id", snapshotID)
+
+	if baseSnapshotID != "" {
+		values.Set("base_snapshot_id", baseSnapshotID)
+	}
+
+	u.RawQuery = values.Encode()
+
+	request, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	return response.Body, nil
+}
@@ -75,3 +75,33 @@ func TestClientListSnapshotsWithFailedRequest(t *testing.T) {
 	require.EqualError(t, err, "failed to get response: EOF")
 	require.Nil(t, snapshots)
 }
+
+func TestClientExportSnapshot(t *testing.T) {
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		assert.Equal(t, "https://example.com/snapshots/export?base_snapshot_id=pool%40snapshot_1&snapshot_id=pool%40snapshot_2",
+			req.URL.String())
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBufferString("stream")),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "testVerify",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	stream, err := c.ExportSnapshot(context.Background(), "pool@snapshot_2", "pool@snapshot_1")
+	require.NoError(t, err)
+
+	defer func() { _ = stream.Close() }()
+
+	content, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, "stream", string(content))
+}
@@ -7,11 +7,19 @@ package types
 
 // CloneCreateRequest represents clone params of a create request.
 type CloneCreateRequest struct {
-	ID        string                     `json:"id"`
-	Protected bool                       `json:"protected"`
-	DB        *DatabaseRequest           `json:"db"`
-	Snapshot  *SnapshotCloneFieldRequest `json:"snapshot"`
-	ExtraConf map[string]string          `json:"extra_conf"`
+	ID          string                     `json:"id"`
+	Protected   bool                       `json:"protected"`
+	DB          *DatabaseRequest           `json:"db"`
+	Snapshot    *SnapshotCloneFieldRequest `json:"snapshot"`
+	ExtraConf   map[string]string          `json:"extra_conf"`
+	AllowEgress []string                   `json:"allow_egress"`
+}
+
+// CloneForkRequest represents params of a fork request.
+type CloneForkRequest struct {
+	ID        string           `json:"id"`
+	Protected bool             `json:"protected"`
+	DB        *DatabaseRequest `json:"db"`
 }
 
 // CloneUpdateRequest represents params of an update request.
@@ -37,3 +45,28 @@ type ResetCloneRequest struct {
 	SnapshotID string `json:"snapshotID"`
 	Latest     bool   `json:"latest"`
 }
+
+// SavepointCreateRequest represents params of a savepoint request.
+type SavepointCreateRequest struct {
+	Name string `json:"name"`
+}
+
+// CloneExportRequest represents params of an export request.
+type CloneExportRequest struct {
+	Format string `json:"format"`
+	DBName string `json:"dbName"`
+	Jobs   uint   `json:"jobs"`
+}
+
+// QueryRequest represents params of a query request.
+type QueryRequest struct {
+	Query  string `json:"query"`
+	Commit bool   `json:"commit"`
+}
+
+// IndexAdviceRequest represents params of an index advice request.
+type IndexAdviceRequest struct {
+	Query   string   `json:"query"`
+	Indexes []string `json:"indexes"`
+	Build   bool     `json:"build"`
+}
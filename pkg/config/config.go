@@ -20,6 +20,7 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/platform"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/pool"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/proxy"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/srv"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/util"
 )
@@ -39,6 +40,7 @@ type Config struct {
 	Observer    observer.Config  `yaml:"observer"`
 	Estimator   estimator.Config `yaml:"estimator"`
 	PoolManager pool.Config      `yaml:"poolManager"`
+	Proxy       proxy.Config     `yaml:"proxy"`
 }
 
 // LoadConfiguration instances a new application configuration.
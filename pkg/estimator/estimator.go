@@ -52,6 +52,11 @@ func (e *Estimator) Config() Config {
 	return *e.cfg
 }
 
+// CanEstimate checks if the ratios allow estimating query timing for a production environment.
+func (cfg Config) CanEstimate() bool {
+	return shouldEstimate(cfg.ReadRatio, cfg.WriteRatio)
+}
+
 // shouldEstimate checks ratios to determine whether to skip an estimation.
 func shouldEstimate(readRatio, writeRatio float64) bool {
 	return (readRatio != 0 || writeRatio != 0) && (readRatio != 1 || writeRatio != 1)
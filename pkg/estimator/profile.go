@@ -71,6 +71,7 @@ type TraceOptions struct {
 	SampleThreshold int
 	ReadRatio       float64
 	WriteRatio      float64
+	StopOnQueryEnd  bool // Stop profiling when the first profiled query finishes keeping its stats.
 }
 
 // Result represents results of estimation session.
@@ -172,6 +173,14 @@ func (p *Profiler) Start(ctx context.Context) {
 				break
 			}
 
+			// The caller cancels profiling once it does not need samples anymore, e.g., a fast query finished unnoticed.
+			if ctx.Err() != nil {
+				p.printStat()
+				log.Dbg("Profiling is canceled. Stop profiling")
+
+				break
+			}
+
 			log.Err(fmt.Sprintf("failed to scan row: %s\n", err))
 
 			break
@@ -206,6 +215,12 @@ func (p *Profiler) Start(ctx context.Context) {
 			// transition from active state -- query finished -- print collected stats and reset it
 			if prev.state.String == "active" {
 				p.printStat()
+
+				if p.opts.StopOnQueryEnd {
+					log.Dbg("Profiled query finished. Stop profiling")
+					break
+				}
+
 				p.resetCounters()
 			}
 		} else {
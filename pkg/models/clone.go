@@ -6,14 +6,16 @@ package models
 
 // Clone defines a clone model.
 type Clone struct {
-	ID        string        `json:"id"`
-	Snapshot  *Snapshot     `json:"snapshot"`
-	Protected bool          `json:"protected"`
-	DeleteAt  string        `json:"deleteAt"`
-	CreatedAt string        `json:"createdAt"`
-	Status    Status        `json:"status"`
-	DB        Database      `json:"db"`
-	Metadata  CloneMetadata `json:"metadata"`
+	ID          string        `json:"id"`
+	Snapshot    *Snapshot     `json:"snapshot"`
+	Protected   bool          `json:"protected"`
+	DeleteAt    string        `json:"deleteAt"`
+	CreatedAt   string        `json:"createdAt"`
+	Status      Status        `json:"status"`
+	DB          Database      `json:"db"`
+	Metadata    CloneMetadata `json:"metadata"`
+	AllowEgress []string      `json:"allowEgress,omitempty"`
+	Parent      string        `json:"parent,omitempty"`
 }
 
 // CloneMetadata contains fields describing a clone model.
@@ -23,3 +25,36 @@ type CloneMetadata struct {
 	CloningTime     float64 `json:"cloningTime"`
 	MaxIdleMinutes  uint    `json:"maxIdleMinutes"`
 }
+
+// Savepoint describes a snapshot of a clone which the clone can be reverted to.
+type Savepoint struct {
+	Name      string `json:"name"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// CloneExport describes an export of clone data to a file.
+type CloneExport struct {
+	ID           string `json:"id"`
+	CloneID      string `json:"cloneId"`
+	Format       string `json:"format"`
+	DBName       string `json:"dbName,omitempty"`
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+	BytesWritten uint64 `json:"bytesWritten"`
+	StartedAt    string `json:"startedAt"`
+	FinishedAt   string `json:"finishedAt,omitempty"`
+}
+
+// Formats of clone exports.
+const (
+	ExportFormatCustom    = "custom"
+	ExportFormatDirectory = "directory"
+	ExportFormatZFS       = "zfs"
+)
+
+// Statuses of clone exports.
+const (
+	ExportStatusInProgress = "in_progress"
+	ExportStatusFinished   = "finished"
+	ExportStatusFailed     = "failed"
+)
@@ -11,4 +11,5 @@ type Database struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	DBName   string `json:"db_name"`
+	CACert   string `json:"caCert,omitempty"`
 }
@@ -12,6 +12,7 @@ type InstanceStatus struct {
 	ExpectedCloningTime float64     `json:"expectedCloningTime"`
 	NumClones           uint64      `json:"numClones"`
 	Clones              []*Clone    `json:"clones"`
+	Retrieving          *Retrieving `json:"retrieving,omitempty"`
 }
 
 // Health represents a response for heath-check requests.
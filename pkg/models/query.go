@@ -0,0 +1,59 @@
+/*
+2021 © Postgres.ai
+*/
+
+package models
+
+import (
+	"encoding/json"
+)
+
+// QueryResult describes a query executed in a clone.
+// Times are in milliseconds: planning and execution times are reported by EXPLAIN ANALYZE,
+// the total time is measured by Database Lab Engine.
+type QueryResult struct {
+	Plan          json.RawMessage `json:"plan"`
+	PlanningTime  float64         `json:"planningTime"`
+	ExecutionTime float64         `json:"executionTime"`
+	TotalTime     float64         `json:"totalTime"`
+	Committed     bool            `json:"committed"`
+	Profile       QueryProfile    `json:"profile"`
+}
+
+// QueryProfile describes wait events of a query sampled while the query is running.
+// WaitEvents contains percentages of the profiled time, the profiled time is in seconds.
+type QueryProfile struct {
+	IsEnoughStat  bool               `json:"isEnoughStat"`
+	SampleCounter int                `json:"sampleCounter"`
+	TotalTime     float64            `json:"totalTime"`
+	EstTime       string             `json:"estTime,omitempty"`
+	WaitEvents    map[string]float64 `json:"waitEvents"`
+	RenderedStat  string             `json:"renderedStat"`
+}
+
+// IndexAdvice describes plan costs of a query with hypothetical indexes.
+// The cost of the query without candidate indexes is in Cost. BestIndex is the candidate reducing the cost the most.
+type IndexAdvice struct {
+	Cost       float64          `json:"cost"`
+	Candidates []IndexCandidate `json:"candidates"`
+	BestIndex  string           `json:"bestIndex,omitempty"`
+	Build      *IndexBuild      `json:"build,omitempty"`
+}
+
+// IndexCandidate describes the plan cost of a query with a hypothetical index.
+// CostReduction is a percentage of the cost of the query without the index.
+type IndexCandidate struct {
+	Definition    string  `json:"definition"`
+	Cost          float64 `json:"cost"`
+	CostReduction float64 `json:"costReduction"`
+	Used          bool    `json:"used"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// IndexBuild describes an index built in a clone. Times are in milliseconds.
+type IndexBuild struct {
+	Definition          string  `json:"definition"`
+	BuildTime           float64 `json:"buildTime"`
+	ExecutionTimeBefore float64 `json:"executionTimeBefore"`
+	ExecutionTimeAfter  float64 `json:"executionTimeAfter"`
+}
@@ -0,0 +1,41 @@
+/*
+2021 © Postgres.ai
+*/
+
+package models
+
+// Retrieving describes the state of data retrieval.
+type Retrieving struct {
+	Sync *Sync `json:"sync,omitempty"`
+}
+
+// Sync describes the state of continuous synchronization with the source.
+// LSN fields are reported by physical replication only.
+type Sync struct {
+	Status        SyncStatusCode      `json:"status"`
+	LagSeconds    int64               `json:"lagSeconds"`
+	LagBytes      int64               `json:"lagBytes,omitempty"`
+	ReceivedLSN   string              `json:"receivedLSN,omitempty"`
+	ReplayLSN     string              `json:"replayLSN,omitempty"`
+	Subscriptions []*SyncSubscription `json:"subscriptions,omitempty"`
+}
+
+// SyncSubscription describes the state of a logical replication subscription.
+type SyncSubscription struct {
+	Name          string `json:"name"`
+	Database      string `json:"database"`
+	Active        bool   `json:"active"`
+	ReceivedLSN   string `json:"receivedLSN"`
+	LatestEndTime string `json:"latestEndTime"`
+	LagSeconds    int64  `json:"lagSeconds"`
+}
+
+// SyncStatusCode defines the status code of synchronization.
+type SyncStatusCode string
+
+// Constants declares available synchronization status codes.
+const (
+	SyncStatusActive   SyncStatusCode = "ACTIVE"
+	SyncStatusDegraded SyncStatusCode = "DEGRADED"
+	SyncStatusDown     SyncStatusCode = "DOWN"
+)
@@ -0,0 +1,30 @@
+/*
+2021 © Postgres.ai
+*/
+
+package models
+
+// SchemaDiff describes changes of the schema of a clone database since the snapshot the clone is created from.
+// DDL contains statements applying the changes to the snapshot schema.
+type SchemaDiff struct {
+	CloneID     string             `json:"cloneId"`
+	SnapshotID  string             `json:"snapshotId"`
+	DBName      string             `json:"dbName"`
+	Tables      []SchemaObjectDiff `json:"tables"`
+	Columns     []SchemaObjectDiff `json:"columns"`
+	Indexes     []SchemaObjectDiff `json:"indexes"`
+	Constraints []SchemaObjectDiff `json:"constraints"`
+	Functions   []SchemaObjectDiff `json:"functions"`
+	DDL         []string           `json:"ddl"`
+}
+
+// SchemaObjectDiff describes a created, removed, or modified schema object.
+// From and To contain definitions of the object in the snapshot and in the clone.
+type SchemaObjectDiff struct {
+	Change string `json:"change"`
+	Schema string `json:"schema"`
+	Table  string `json:"table,omitempty"`
+	Name   string `json:"name"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+}
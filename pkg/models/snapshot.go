@@ -4,8 +4,60 @@
 
 package models
 
+const (
+	// SnapshotReady defines a snapshot which passed validation and can be used for cloning.
+	SnapshotReady = "ready"
+
+	// SnapshotQuarantined defines a snapshot which failed validation. It is skipped when the latest snapshot is chosen.
+	SnapshotQuarantined = "quarantined"
+
+	// SnapshotLagging defines a snapshot taken while the sync instance lagged behind the source. It can be used for cloning.
+	SnapshotLagging = "lagging"
+
+	// SnapshotNotValidated defines a snapshot created without validation or before validation was introduced.
+	// It can be used for cloning.
+	SnapshotNotValidated = "not_validated"
+)
+
 type Snapshot struct {
 	ID          string `json:"id"`
 	CreatedAt   string `json:"createdAt"`
 	DataStateAt string `json:"dataStateAt"`
+	Status      string `json:"status,omitempty"`
+	Pool        string `json:"pool,omitempty"`
+}
+
+// IsQuarantined checks if the snapshot failed validation.
+func (s Snapshot) IsQuarantined() bool {
+	return s.Status == SnapshotQuarantined
 }
+
+// SnapshotDiff describes relations changed between two snapshots.
+type SnapshotDiff struct {
+	From      string         `json:"from"`
+	To        string         `json:"to"`
+	SizeDelta int64          `json:"sizeDelta"`
+	Relations []RelationDiff `json:"relations"`
+}
+
+// RelationDiff describes changes of a relation between two snapshots.
+// Relations that do not exist in the newer snapshot are identified only by their database OID and filenode.
+type RelationDiff struct {
+	Database    string `json:"database,omitempty"`
+	DatabaseOID uint32 `json:"databaseOID"`
+	Filenode    uint32 `json:"filenode"`
+	Schema      string `json:"schema,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Kind        string `json:"kind,omitempty"`
+	Change      string `json:"change"`
+	FromSize    int64  `json:"fromSize"`
+	ToSize      int64  `json:"toSize"`
+	SizeDelta   int64  `json:"sizeDelta"`
+}
+
+// Changes of relations between snapshots.
+const (
+	RelationCreated  = "created"
+	RelationModified = "modified"
+	RelationRemoved  = "removed"
+)
@@ -15,18 +15,32 @@ type StatusCode string
 
 // Constants declares available status codes and messages.
 const (
-	StatusOK        StatusCode = "OK"
-	StatusCreating  StatusCode = "CREATING"
-	StatusResetting StatusCode = "RESETTING"
-	StatusDeleting  StatusCode = "DELETING"
-	StatusExporting StatusCode = "EXPORTING"
-	StatusFatal     StatusCode = "FATAL"
+	StatusOK          StatusCode = "OK"
+	StatusCreating    StatusCode = "CREATING"
+	StatusResetting   StatusCode = "RESETTING"
+	StatusDeleting    StatusCode = "DELETING"
+	StatusExporting   StatusCode = "EXPORTING"
+	StatusBusy        StatusCode = "BUSY"
+	StatusHibernating StatusCode = "HIBERNATING"
+	StatusHibernated  StatusCode = "HIBERNATED"
+	StatusResuming    StatusCode = "RESUMING"
+	StatusFatal       StatusCode = "FATAL"
 
-	CloneMessageOK        = "Clone is ready to accept Postgres connections."
-	CloneMessageCreating  = "Clone is being created."
-	CloneMessageResetting = "Clone is being reset."
-	CloneMessageDeleting  = "Clone is being deleted."
-	CloneMessageFatal     = "Cloning failure."
+	CloneMessageOK          = "Clone is ready to accept Postgres connections."
+	CloneMessageCreating    = "Clone is being created."
+	CloneMessageResetting   = "Clone is being reset."
+	CloneMessageReverting   = "Clone is being reverted to a savepoint."
+	CloneMessageDeleting    = "Clone is being deleted."
+	CloneMessageExporting   = "Clone data is being exported."
+	CloneMessageSavepoint   = "Savepoint of the clone is being created."
+	CloneMessageForking     = "Clone is being forked."
+	CloneMessageSchemaDiff  = "Schema of the clone is being compared with the snapshot."
+	CloneMessageIndexBuild  = "Index is being built in the clone."
+	CloneMessageHibernating = "Clone is being hibernated."
+	CloneMessageHibernated  = "Clone is hibernated: its container is stopped, data and port are kept."
+	CloneMessageResuming    = "Clone is being resumed."
+	CloneMessageEvicted     = "Clone is stopped to keep the limit of running clones, it is resumed on the next request or connection."
+	CloneMessageFatal       = "Cloning failure."
 
 	InstanceMessageOK = "Instance is ready"
 )
@@ -8,6 +8,7 @@ package components
 import (
 	"context"
 
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/config"
 )
 
@@ -28,3 +29,15 @@ type JobRunner interface {
 	// Run starts a job.
 	Run(ctx context.Context) error
 }
+
+// JobCleaner releases resources of a job removed from the configuration.
+type JobCleaner interface {
+	// Cleanup releases resources held by the job, e.g., replication slots on the source.
+	Cleanup(ctx context.Context) error
+}
+
+// SyncReporter reports the state of continuous synchronization with the source.
+type SyncReporter interface {
+	// SyncStatus returns the current state of synchronization.
+	SyncStatus(ctx context.Context) (*models.Sync, error)
+}
@@ -45,6 +45,9 @@ func (s *JobBuilder) BuildJob(jobCfg config.JobConfig) (components.JobRunner, er
 	case logical.RestoreJobType:
 		return logical.NewJob(jobCfg, s.globalCfg)
 
+	case logical.SyncJobType:
+		return logical.NewSyncJob(jobCfg, s.globalCfg)
+
 	case physical.RestoreJobType:
 		return physical.NewJob(jobCfg, s.globalCfg)
 
@@ -53,6 +56,9 @@ func (s *JobBuilder) BuildJob(jobCfg config.JobConfig) (components.JobRunner, er
 
 	case snapshot.PhysicalInitialType:
 		return snapshot.NewPhysicalInitialJob(jobCfg, s.globalCfg, s.cloneManager)
+
+	case snapshot.ImportJobType:
+		return snapshot.NewImportJob(jobCfg, s.cloneManager)
 	}
 
 	return nil, errors.Errorf("unknown job type: %q", jobCfg.Spec.Name)
@@ -5,7 +5,12 @@
 package logical
 
 import (
-	"path/filepath"
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
 )
 
 type compressionType string
@@ -14,8 +19,51 @@ const (
 	noCompression    compressionType = "no"
 	gzipCompression  compressionType = "gzip"
 	bzip2Compression compressionType = "bzip2"
+	zstdCompression  compressionType = "zstd"
+	lz4Compression   compressionType = "lz4"
+
+	// compressionMagicSize defines the number of leading bytes enough to detect a compression type.
+	compressionMagicSize = 4
+)
+
+var (
+	gzipMagic      = []byte{0x1f, 0x8b}
+	bzip2Magic     = []byte("BZh")
+	zstdMagic      = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	lz4Magic       = []byte{0x04, 0x22, 0x4d, 0x18}
+	lz4LegacyMagic = []byte{0x02, 0x21, 0x4c, 0x18}
 )
 
+// compressionLevels defines the supported compression levels of compression tools.
+var compressionLevels = map[compressionType]struct{ min, max int }{
+	gzipCompression:  {min: 1, max: 9},
+	bzip2Compression: {min: 1, max: 9},
+	zstdCompression:  {min: 1, max: 19},
+	lz4Compression:   {min: 1, max: 12},
+}
+
+// validateCompression checks if the compression type and level are supported.
+func validateCompression(compression compressionType, level int) error {
+	if compression == "" || compression == noCompression {
+		if level != 0 {
+			return errors.New("compression level requires a compression type")
+		}
+
+		return nil
+	}
+
+	levels, ok := compressionLevels[compression]
+	if !ok {
+		return errors.Errorf("unknown compression type: %q", compression)
+	}
+
+	if level != 0 && (level < levels.min || level > levels.max) {
+		return errors.Errorf("%s compression level must be between %d and %d", compression, levels.min, levels.max)
+	}
+
+	return nil
+}
+
 // getReadingArchiveCommand chooses command to read dump file.
 func getReadingArchiveCommand(compressionType compressionType) string {
 	switch compressionType {
@@ -25,20 +73,99 @@ func getReadingArchiveCommand(compressionType compressionType) string {
 	case bzip2Compression:
 		return "bunzip2 -c"
 
+	case zstdCompression:
+		return "zstd -dcq"
+
+	case lz4Compression:
+		return "lz4 -dcq"
+
+	default:
+		return "cat"
+	}
+}
+
+// getWritingArchiveCommand chooses command to compress a dump. The tool default is used if the level is zero.
+func getWritingArchiveCommand(compressionType compressionType, level int) string {
+	var command string
+
+	switch compressionType {
+	case gzipCompression:
+		command = "gzip -c"
+
+	case bzip2Compression:
+		command = "bzip2 -c"
+
+	case zstdCompression:
+		command = "zstd -cq"
+
+	case lz4Compression:
+		command = "lz4 -cq"
+
 	default:
 		return "cat"
 	}
+
+	if level != 0 {
+		command += " -" + strconv.Itoa(level)
+	}
+
+	return command
 }
 
-// getCompressionType returns archive type based on filename extension.
-func getCompressionType(filename string) compressionType {
-	switch filepath.Ext(filename) {
-	case ".gz":
+// getArchiveExtension returns the extension of a plain-text dump file.
+func getArchiveExtension(compressionType compressionType) string {
+	switch compressionType {
+	case gzipCompression:
+		return ".gz"
+
+	case bzip2Compression:
+		return ".bz2"
+
+	case zstdCompression:
+		return ".zst"
+
+	case lz4Compression:
+		return ".lz4"
+
+	default:
+		return ".sql"
+	}
+}
+
+// readCompressionType returns archive type of the dump file based on its leading bytes.
+func readCompressionType(filename string) (compressionType, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open dump file")
+	}
+
+	defer func() { _ = f.Close() }()
+
+	header := make([]byte, compressionMagicSize)
+
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", errors.Wrap(err, "failed to read dump file")
+	}
+
+	return detectCompressionType(header[:n]), nil
+}
+
+// detectCompressionType returns archive type based on the leading bytes of a dump.
+func detectCompressionType(header []byte) compressionType {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
 		return gzipCompression
 
-	case ".bz2":
+	case bytes.HasPrefix(header, bzip2Magic):
 		return bzip2Compression
 
+	case bytes.HasPrefix(header, zstdMagic):
+		return zstdCompression
+
+	case bytes.HasPrefix(header, lz4Magic), bytes.HasPrefix(header, lz4LegacyMagic):
+		return lz4Compression
+
 	default:
 		return noCompression
 	}
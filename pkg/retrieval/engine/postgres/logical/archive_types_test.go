@@ -5,9 +5,12 @@
 package logical
 
 import (
+	"os"
+	"path"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestReadingArchiveCommand(t *testing.T) {
@@ -23,6 +26,14 @@ func TestReadingArchiveCommand(t *testing.T) {
 			compressionType: bzip2Compression,
 			expectedCommand: "bunzip2 -c",
 		},
+		{
+			compressionType: zstdCompression,
+			expectedCommand: "zstd -dcq",
+		},
+		{
+			compressionType: lz4Compression,
+			expectedCommand: "lz4 -dcq",
+		},
 		{
 			compressionType: noCompression,
 			expectedCommand: "cat",
@@ -39,31 +50,134 @@ func TestReadingArchiveCommand(t *testing.T) {
 	}
 }
 
-func TestArchiveType(t *testing.T) {
+func TestWritingArchiveCommand(t *testing.T) {
+	testCases := []struct {
+		compressionType   compressionType
+		level             int
+		expectedCommand   string
+		expectedExtension string
+	}{
+		{compressionType: gzipCompression, level: 6, expectedCommand: "gzip -c -6", expectedExtension: ".gz"},
+		{compressionType: bzip2Compression, expectedCommand: "bzip2 -c", expectedExtension: ".bz2"},
+		{compressionType: zstdCompression, level: 19, expectedCommand: "zstd -cq -19", expectedExtension: ".zst"},
+		{compressionType: lz4Compression, level: 1, expectedCommand: "lz4 -cq -1", expectedExtension: ".lz4"},
+		{compressionType: noCompression, expectedCommand: "cat", expectedExtension: ".sql"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expectedCommand, getWritingArchiveCommand(tc.compressionType, tc.level))
+		assert.Equal(t, tc.expectedExtension, getArchiveExtension(tc.compressionType))
+	}
+}
+
+func TestValidateCompression(t *testing.T) {
+	testCases := []struct {
+		compressionType compressionType
+		level           int
+		valid           bool
+	}{
+		{compressionType: "", valid: true},
+		{compressionType: noCompression, valid: true},
+		{compressionType: noCompression, level: 3, valid: false},
+		{compressionType: zstdCompression, level: 19, valid: true},
+		{compressionType: zstdCompression, level: 22, valid: false},
+		{compressionType: lz4Compression, level: 12, valid: true},
+		{compressionType: gzipCompression, level: 10, valid: false},
+		{compressionType: compressionType("xz"), valid: false},
+	}
+
+	for _, tc := range testCases {
+		err := validateCompression(tc.compressionType, tc.level)
+		assert.Equal(t, tc.valid, err == nil, "%s:%d", tc.compressionType, tc.level)
+	}
+}
+
+func TestReadCompressionType(t *testing.T) {
+	dir := t.TempDir()
+
 	testCases := []struct {
 		filename                string
+		content                 []byte
+		expectedCompressionType compressionType
+	}{
+		{
+			// The extension does not matter.
+			filename:                "dump.sql",
+			content:                 []byte{0x28, 0xb5, 0x2f, 0xfd, 0x24},
+			expectedCompressionType: zstdCompression,
+		},
+		{
+			filename:                "dump.gz",
+			content:                 []byte("--\n-- PostgreSQL database dump\n"),
+			expectedCompressionType: noCompression,
+		},
+		{
+			filename:                "dump",
+			content:                 []byte{0x04, 0x22, 0x4d, 0x18, 0x64},
+			expectedCompressionType: lz4Compression,
+		},
+		{
+			filename:                "short",
+			content:                 []byte{0x1f, 0x8b},
+			expectedCompressionType: gzipCompression,
+		},
+		{
+			filename:                "empty",
+			content:                 []byte{},
+			expectedCompressionType: noCompression,
+		},
+	}
+
+	for _, tc := range testCases {
+		filename := path.Join(dir, tc.filename)
+		require.NoError(t, os.WriteFile(filename, tc.content, 0600))
+
+		compressionType, err := readCompressionType(filename)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expectedCompressionType, compressionType, tc.filename)
+	}
+
+	_, err := readCompressionType(path.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestDetectCompressionType(t *testing.T) {
+	testCases := []struct {
+		header                  []byte
 		expectedCompressionType compressionType
 	}{
 		{
-			filename:                "dump.sql.gz",
+			header:                  []byte{0x1f, 0x8b, 0x08, 0x00},
 			expectedCompressionType: gzipCompression,
 		},
 		{
-			filename:                "dump.bz2",
+			header:                  []byte("BZh91AY&SY"),
 			expectedCompressionType: bzip2Compression,
 		},
 		{
-			filename:                "test.dmp",
+			header:                  []byte{0x28, 0xb5, 0x2f, 0xfd, 0x04, 0x58},
+			expectedCompressionType: zstdCompression,
+		},
+		{
+			header:                  []byte{0x04, 0x22, 0x4d, 0x18, 0x64, 0x40},
+			expectedCompressionType: lz4Compression,
+		},
+		{
+			header:                  []byte{0x02, 0x21, 0x4c, 0x18},
+			expectedCompressionType: lz4Compression,
+		},
+		{
+			header:                  []byte("--\n-- PostgreSQL database dump\n"),
 			expectedCompressionType: noCompression,
 		},
 		{
-			filename:                "dump",
+			header:                  []byte{},
 			expectedCompressionType: noCompression,
 		},
 	}
 
 	for _, tc := range testCases {
-		compressionType := getCompressionType(tc.filename)
+		compressionType := detectCompressionType(tc.header)
 		assert.Equal(t, tc.expectedCompressionType, compressionType)
 	}
 }
@@ -45,9 +45,11 @@ const (
 	tmpDBLabPGDataDir = "/tmp/dblab_dump"
 
 	// Defines dump source types.
-	sourceTypeLocal  = "local"
-	sourceTypeRemote = "remote"
-	sourceTypeRDS    = "rdsIam"
+	sourceTypeLocal    = "local"
+	sourceTypeRemote   = "remote"
+	sourceTypeRDS      = "rdsIam"
+	sourceTypeCloudSQL = "cloudSQLIam"
+	sourceTypeAzureAD  = "azureAD"
 
 	// reservePort defines reserve port in case of a local dump.
 	reservePort = 9999
@@ -72,6 +74,8 @@ type DumpJob struct {
 	dumper       dumper
 	dbMarker     *dbmarker.Marker
 	dbMark       *dbmarker.Config
+	// encryptionKey is loaded from the key file if dumps are encrypted.
+	encryptionKey []byte
 	DumpOptions
 }
 
@@ -85,21 +89,27 @@ type DumpOptions struct {
 	Databases       map[string]DumpDefinition `yaml:"databases"`
 	ParallelJobs    int                       `yaml:"parallelJobs"`
 	Restore         ImmediateRestore          `yaml:"immediateRestore"`
+	ReplicationSlot ReplicationSlot           `yaml:"replicationSlot"`
+	Encryption      Encryption                `yaml:"encryption"`
 }
 
 // Source describes source of data to dump.
 type Source struct {
-	Type       string     `yaml:"type"`
-	Connection Connection `yaml:"connection"`
-	RDS        *RDSConfig `yaml:"rdsIam"`
+	Type       string          `yaml:"type"`
+	Connection Connection      `yaml:"connection"`
+	RDS        *RDSConfig      `yaml:"rdsIam"`
+	CloudSQL   *CloudSQLConfig `yaml:"cloudSQLIam"`
+	AzureAD    *AzureADConfig  `yaml:"azureAD"`
 }
 
 // DumpDefinition describes a database for dumping.
 type DumpDefinition struct {
-	Tables      []string        `yaml:"tables"`
-	Format      string          `yaml:"format"`
-	Compression compressionType `yaml:"compression"`
-	dbName      string
+	Tables           []string        `yaml:"tables"`
+	Format           string          `yaml:"format"`
+	Compression      compressionType `yaml:"compression"`
+	CompressionLevel int             `yaml:"compressionLevel"`
+	Subset           *Subset         `yaml:"subset"`
+	dbName           string
 }
 
 type dumpJobConfig struct {
@@ -134,6 +144,13 @@ type ImmediateRestore struct {
 	Configs   map[string]string `yaml:"configs"`
 }
 
+// ReplicationSlot describes a logical replication slot created before dumping a database.
+// The dump uses the snapshot exported by the slot, so the logical sync continues exactly from the dumped state.
+type ReplicationSlot struct {
+	Enabled bool   `yaml:"enabled"`
+	Prefix  string `yaml:"prefix"`
+}
+
 // NewDumpJob creates a new DumpJob.
 func NewDumpJob(jobCfg config.JobConfig, global *global.Config) (*DumpJob, error) {
 	dumpJob := &DumpJob{
@@ -164,6 +181,40 @@ func (d *DumpJob) validate() error {
 Either set 'numberOfJobs' equals to 1 or disable the restore section`)
 	}
 
+	if d.Encryption.enabled() && d.Restore.Enabled {
+		return errors.New("dump encryption cannot be used with the immediate restore because dump files are not stored")
+	}
+
+	for dbName, definition := range d.Databases {
+		if d.Encryption.enabled() && definition.Format == directoryFormat {
+			return errors.Errorf("the directory dump of the database %q cannot be encrypted. Use the custom or plain format", dbName)
+		}
+
+		if err := validateCompression(definition.Compression, definition.CompressionLevel); err != nil {
+			return errors.Wrapf(err, "invalid compression of the database %q", dbName)
+		}
+
+		if definition.Subset == nil {
+			continue
+		}
+
+		if !d.Restore.Enabled {
+			return errors.Errorf("subset of the database %q requires the immediate restore", dbName)
+		}
+
+		if len(definition.Tables) > 0 {
+			return errors.Errorf("subset and tables cannot be used together for the database %q", dbName)
+		}
+
+		if d.ReplicationSlot.Enabled {
+			return errors.Errorf("subset of the database %q cannot be synchronized using a replication slot", dbName)
+		}
+
+		if err := definition.Subset.validate(); err != nil {
+			return errors.Wrapf(err, "invalid subset of the database %q", dbName)
+		}
+	}
+
 	return nil
 }
 
@@ -180,6 +231,10 @@ func (d *DumpJob) setDefaults() {
 	if d.DumpOptions.ParallelJobs == 0 {
 		d.DumpOptions.ParallelJobs = defaultParallelJobs
 	}
+
+	if d.DumpOptions.ReplicationSlot.Prefix == "" {
+		d.DumpOptions.ReplicationSlot.Prefix = defaultSlotPrefix
+	}
 }
 
 // setupDumper sets up a tool to perform physical restoring.
@@ -201,6 +256,29 @@ func (d *DumpJob) setupDumper() error {
 
 		d.dumper = dumper
 
+		return nil
+
+	case sourceTypeCloudSQL:
+		if d.Source.CloudSQL == nil {
+			d.Source.CloudSQL = &CloudSQLConfig{}
+		}
+
+		d.dumper = newCloudSQLDumper(d.Source.CloudSQL)
+
+		return nil
+
+	case sourceTypeAzureAD:
+		if d.Source.AzureAD == nil {
+			d.Source.AzureAD = &AzureADConfig{}
+		}
+
+		dumper, err := newAzureDumper(d.Source.AzureAD)
+		if err != nil {
+			return errors.Wrap(err, "failed to create an Azure AD dumper")
+		}
+
+		d.dumper = dumper
+
 		return nil
 	}
 
@@ -228,6 +306,14 @@ func (d *DumpJob) Reload(cfg map[string]interface{}) (err error) {
 
 	d.setDefaults()
 
+	d.encryptionKey = nil
+
+	if d.Encryption.enabled() {
+		if d.encryptionKey, err = loadEncryptionKey(d.Encryption.KeyFile); err != nil {
+			return errors.Wrap(err, "failed to load the encryption key")
+		}
+	}
+
 	return nil
 }
 
@@ -337,6 +423,11 @@ func (d *DumpJob) Run(ctx context.Context) (err error) {
 	}
 
 	for dbName, dbDetails := range dbList {
+		// Token-based passwords may expire during long dumps, so connection options are refreshed for each database.
+		if err := d.setupConnectionOptions(ctx); err != nil {
+			return errors.Wrap(err, "failed to refresh connection options")
+		}
+
 		if err := d.dumpDatabase(ctx, dumpCont.ID, dbName, dbDetails); err != nil {
 			return errors.Wrapf(err, "failed to dump the database %s", dbName)
 		}
@@ -393,6 +484,67 @@ func (d *DumpJob) getDBList(ctx context.Context) (map[string]DumpDefinition, err
 	return dbList, nil
 }
 
+// createReplicationSlot creates a logical replication slot for the database and returns the name of the exported snapshot.
+// The returned connection must be kept open until the dump is finished.
+func (d *DumpJob) createReplicationSlot(ctx context.Context, dbName string) (*pgx.Conn, string, error) {
+	slotName := replicationSlotName(d.ReplicationSlot.Prefix, dbName)
+	connStr := db.ConnectionString(d.config.db.Host, strconv.Itoa(d.config.db.Port), d.config.db.Username, dbName, d.getPassword())
+
+	if err := dropReplicationSlot(ctx, connStr, slotName); err != nil {
+		return nil, "", err
+	}
+
+	replConfig, err := pgx.ParseConfig(connStr)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to parse connection string")
+	}
+
+	replConfig.RuntimeParams["replication"] = "database"
+
+	replConn, err := pgx.ConnectConfig(ctx, replConfig)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to open a replication connection")
+	}
+
+	results, err := replConn.PgConn().Exec(ctx,
+		fmt.Sprintf("CREATE_REPLICATION_SLOT %s LOGICAL pgoutput EXPORT_SNAPSHOT", slotName)).ReadAll()
+	if err != nil {
+		_ = replConn.Close(ctx)
+		return nil, "", errors.Wrapf(err, "failed to create replication slot %q", slotName)
+	}
+
+	// The result contains slot_name, consistent_point, snapshot_name and output_plugin.
+	const snapshotNameColumn = 2
+
+	if len(results) == 0 || len(results[0].Rows) == 0 || len(results[0].Rows[0]) <= snapshotNameColumn {
+		_ = replConn.Close(ctx)
+		return nil, "", errors.New("unexpected response to the replication slot creation")
+	}
+
+	snapshotName := string(results[0].Rows[0][snapshotNameColumn])
+
+	log.Msg(fmt.Sprintf("Replication slot %q has been created. Exported snapshot: %s", slotName, snapshotName))
+
+	return replConn, snapshotName, nil
+}
+
+// dropReplicationSlot drops the replication slot left by a previous dump.
+func dropReplicationSlot(ctx context.Context, connStr, slotName string) error {
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to the source")
+	}
+
+	defer func() { _ = conn.Close(ctx) }()
+
+	if _, err := conn.Exec(ctx, `select pg_catalog.pg_drop_replication_slot(slot_name)
+from pg_catalog.pg_replication_slots where slot_name = $1`, slotName); err != nil {
+		return errors.Wrapf(err, "failed to drop the existing replication slot %q", slotName)
+	}
+
+	return nil
+}
+
 func (d *DumpJob) getPassword() string {
 	pwd := os.Getenv("PGPASSWORD")
 
@@ -421,18 +573,55 @@ func (d *DumpJob) cleanupDumpLocation(ctx context.Context, dumpContID string) er
 }
 
 func (d *DumpJob) dumpDatabase(ctx context.Context, dumpContID, dbName string, dumpDefinition DumpDefinition) error {
-	dumpCommand := d.buildLogicalDumpCommand(dbName, dumpDefinition.Tables)
+	if dumpDefinition.Subset != nil {
+		if err := d.dumpSubset(ctx, dumpContID, dbName, dumpDefinition.Subset); err != nil {
+			return errors.Wrap(err, "failed to restore a subset")
+		}
+
+		log.Msg(fmt.Sprintf("Subset of the database %q has been restored", dbName))
+
+		return nil
+	}
+
+	var snapshotName string
+
+	if d.ReplicationSlot.Enabled {
+		replConn, snapshot, err := d.createReplicationSlot(ctx, dbName)
+		if err != nil {
+			return errors.Wrap(err, "failed to create a replication slot")
+		}
+
+		// The exported snapshot is valid only while the replication connection is open.
+		defer func() { _ = replConn.Close(context.Background()) }()
+
+		snapshotName = snapshot
+	}
+
+	dumpCommand := d.buildLogicalDumpCommand(dbName, dumpDefinition, snapshotName)
 	log.Msg("Running dump command: ", dumpCommand)
 
 	if len(dumpDefinition.Tables) > 0 {
 		log.Msg("Partial dump will be run. Tables for dumping: ", strings.Join(dumpDefinition.Tables, ", "))
 	}
 
-	if output, err := d.performDumpCommand(ctx, dumpContID, types.ExecConfig{
+	commandCfg := types.ExecConfig{
 		Tty: true,
 		Cmd: dumpCommand,
 		Env: d.getExecEnvironmentVariables(),
-	}); err != nil {
+	}
+
+	if d.isEncrypted() {
+		if output, err := d.performEncryptedDumpCommand(ctx, dumpContID, dbName, commandCfg); err != nil {
+			log.Dbg(output)
+			return errors.Wrap(err, "failed to dump a database")
+		}
+
+		log.Msg(fmt.Sprintf("Encrypted dump of the database %q has been finished", dbName))
+
+		return nil
+	}
+
+	if output, err := d.performDumpCommand(ctx, dumpContID, commandCfg); err != nil {
 		log.Dbg(output)
 		return errors.Wrap(err, "failed to dump a database")
 	}
@@ -520,6 +709,52 @@ func (d *DumpJob) performDumpCommand(ctx context.Context, contID string, command
 	return tools.ExecCommandWithOutput(ctx, d.dockerClient, contID, commandCfg)
 }
 
+// isEncrypted checks if dumps are stored encrypted.
+func (d *DumpJob) isEncrypted() bool {
+	return !d.DumpOptions.Restore.Enabled && d.Encryption.enabled()
+}
+
+// performEncryptedDumpCommand streams the output of the dump command into an encrypted file in the dump location.
+func (d *DumpJob) performEncryptedDumpCommand(ctx context.Context, contID, dbName string, commandCfg types.ExecConfig) (
+	output string, err error) {
+	dumpFile := path.Join(d.DumpOptions.DumpLocation, dbName+encryptedExtension)
+
+	f, err := os.OpenFile(dumpFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create an encrypted dump file")
+	}
+
+	defer func() {
+		_ = f.Close()
+
+		if err != nil {
+			// An incomplete dump must not be restored later.
+			if errRemove := os.Remove(dumpFile); errRemove != nil {
+				log.Err(fmt.Sprintf("Failed to remove incomplete dump file %q: %v", dumpFile, errRemove))
+			}
+		}
+	}()
+
+	encWriter, err := newEncryptWriter(f, d.encryptionKey)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to set up encryption")
+	}
+
+	if output, err = tools.ExecCommandWithOutputStream(ctx, d.dockerClient, contID, commandCfg, encWriter); err != nil {
+		return output, err
+	}
+
+	if err = encWriter.Close(); err != nil {
+		return output, errors.Wrap(err, "failed to finish encryption")
+	}
+
+	if err = f.Sync(); err != nil {
+		return output, errors.Wrap(err, "failed to sync the encrypted dump file")
+	}
+
+	return output, nil
+}
+
 func (d *DumpJob) getEnvironmentVariables(password string) []string {
 	envs := []string{
 		"POSTGRES_PASSWORD=" + password,
@@ -589,18 +824,36 @@ func (d *DumpJob) getExecEnvironmentVariables() []string {
 	return execEnvs
 }
 
-func (d *DumpJob) buildLogicalDumpCommand(dbName string, tables []string) []string {
+func (d *DumpJob) buildLogicalDumpCommand(dbName string, definition DumpDefinition, snapshot string) []string {
 	optionalArgs := map[string]string{
 		"--host":     d.config.db.Host,
 		"--port":     strconv.Itoa(d.config.db.Port),
 		"--username": d.config.db.Username,
 		"--dbname":   dbName,
 		"--jobs":     strconv.Itoa(d.DumpOptions.ParallelJobs),
+		"--snapshot": snapshot,
 	}
 
-	dumpCmd := append([]string{"pg_dump", "--create"}, prepareCmdOptions(optionalArgs)...)
+	isPlainFile := !d.DumpOptions.Restore.Enabled && definition.Format == plainFormat
 
-	for _, table := range tables {
+	// Encrypted dumps are streamed from stdout of the dump command.
+	isEncrypted := d.isEncrypted()
+
+	if isPlainFile || isEncrypted {
+		// Parallel dumps are supported only for the directory format.
+		delete(optionalArgs, "--jobs")
+	}
+
+	dumpCmd := []string{"pg_dump"}
+
+	// Plain-text dumps are restored into a database named after the file, so they do not create a database.
+	if !isPlainFile {
+		dumpCmd = append(dumpCmd, "--create")
+	}
+
+	dumpCmd = append(dumpCmd, prepareCmdOptions(optionalArgs)...)
+
+	for _, table := range definition.Tables {
 		dumpCmd = append(dumpCmd, "--table", table)
 	}
 
@@ -615,6 +868,26 @@ func (d *DumpJob) buildLogicalDumpCommand(dbName string, tables []string) []stri
 		return []string{"sh", "-c", cmd}
 	}
 
+	if isPlainFile {
+		dumpCmd = append(dumpCmd, "--format", plainFormat)
+
+		if definition.Compression != noCompression && definition.Compression != "" {
+			dumpCmd = append(dumpCmd, "|", getWritingArchiveCommand(definition.Compression, definition.CompressionLevel))
+		}
+
+		if !isEncrypted {
+			dumpFile := path.Join(d.DumpOptions.DumpLocation, dbName+getArchiveExtension(definition.Compression))
+			dumpCmd = append(dumpCmd, ">", dumpFile)
+		}
+
+		// Fail if any command of the pipeline fails.
+		return []string{"bash", "-c", "set -o pipefail; " + strings.Join(dumpCmd, " ")}
+	}
+
+	if isEncrypted {
+		return append(dumpCmd, "--format", customFormat)
+	}
+
 	dumpCmd = append(dumpCmd, "--format", directoryFormat, "--file", path.Join(d.DumpOptions.DumpLocation, dbName))
 
 	return dumpCmd
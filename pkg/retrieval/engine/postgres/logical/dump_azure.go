@@ -0,0 +1,155 @@
+/*
+2021 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// azureDBResource defines the resource of Azure Database for PostgreSQL access tokens.
+	azureDBResource = "https://ossrdbms-aad.database.windows.net"
+
+	// defaultAzureIMDSURL defines the Instance Metadata Service endpoint issuing tokens for managed identities.
+	defaultAzureIMDSURL = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+	// defaultAzureAuthorityHost defines the Azure AD authority host.
+	defaultAzureAuthorityHost = "https://login.microsoftonline.com"
+
+	// azureClientSecretEnv defines the environment variable containing the client secret.
+	azureClientSecretEnv = "AZURE_CLIENT_SECRET"
+
+	azureIMDSAPIVersion = "2018-02-01"
+)
+
+type azureDumper struct {
+	cfg   *AzureADConfig
+	token *cachedToken
+}
+
+// AzureADConfig describes configuration of Azure AD authentication.
+// A service principal is used if the client secret is set, otherwise a managed identity is used.
+type AzureADConfig struct {
+	TenantID      string `yaml:"tenantID"`
+	ClientID      string `yaml:"clientID"`
+	ClientSecret  string `yaml:"clientSecret"`
+	IMDSURL       string `yaml:"imdsURL"`
+	AuthorityHost string `yaml:"authorityHost"`
+	SSLRootCert   string `yaml:"sslRootCert"`
+}
+
+func newAzureDumper(cfg *AzureADConfig) (*azureDumper, error) {
+	if secret := os.Getenv(azureClientSecretEnv); secret != "" {
+		cfg.ClientSecret = secret
+	}
+
+	if cfg.ClientSecret != "" && (cfg.TenantID == "" || cfg.ClientID == "") {
+		return nil, errors.New("tenantID and clientID must be defined to authenticate as a service principal")
+	}
+
+	return &azureDumper{
+		cfg: cfg,
+		token: newCachedToken(func(ctx context.Context) (accessToken, error) {
+			if cfg.ClientSecret != "" {
+				return fetchAzureClientToken(ctx, cfg)
+			}
+
+			return fetchAzureManagedIdentityToken(ctx, cfg)
+		}),
+	}, nil
+}
+
+// fetchAzureManagedIdentityToken requests a token of the managed identity from the Instance Metadata Service.
+func fetchAzureManagedIdentityToken(ctx context.Context, cfg *AzureADConfig) (accessToken, error) {
+	imdsURL := defaultAzureIMDSURL
+
+	if cfg.IMDSURL != "" {
+		imdsURL = cfg.IMDSURL
+	}
+
+	params := url.Values{}
+	params.Set("api-version", azureIMDSAPIVersion)
+	params.Set("resource", azureDBResource)
+
+	if cfg.ClientID != "" {
+		// User-assigned managed identity.
+		params.Set("client_id", cfg.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imdsURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return accessToken{}, errors.Wrap(err, "failed to create token request")
+	}
+
+	req.Header.Set("Metadata", "true")
+
+	return requestToken(ctx, req, time.Now())
+}
+
+// fetchAzureClientToken requests a token of the service principal using the client credentials flow.
+func fetchAzureClientToken(ctx context.Context, cfg *AzureADConfig) (accessToken, error) {
+	authorityHost := defaultAzureAuthorityHost
+
+	if cfg.AuthorityHost != "" {
+		authorityHost = strings.TrimSuffix(cfg.AuthorityHost, "/")
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", cfg.ClientID)
+	form.Set("client_secret", cfg.ClientSecret)
+	form.Set("scope", azureDBResource+"/.default")
+
+	tokenURL := authorityHost + "/" + url.PathEscape(cfg.TenantID) + "/oauth2/v2.0/token"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return accessToken{}, errors.Wrap(err, "failed to create token request")
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return requestToken(ctx, req, time.Now())
+}
+
+// GetCmdEnvVariables returns dumper environment variables.
+func (a *azureDumper) GetCmdEnvVariables() []string {
+	if a.cfg.SSLRootCert != "" {
+		return []string{
+			"PGSSLROOTCERT=" + a.cfg.SSLRootCert,
+			"PGSSLMODE=verify-full",
+		}
+	}
+
+	return []string{"PGSSLMODE=require"}
+}
+
+// SetConnectionOptions sets connection options for dumping.
+func (a *azureDumper) SetConnectionOptions(ctx context.Context, c *Connection) error {
+	if c.Username == "" {
+		return errors.New("username of the Azure AD database user must be defined")
+	}
+
+	token, err := a.token.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.Password = token
+
+	return nil
+}
+
+// GetDatabaseListQuery provides the query to get the list of databases for dumping.
+func (a *azureDumper) GetDatabaseListQuery() string {
+	return "select datname from pg_catalog.pg_database where not datistemplate and datname not in ('azure_maintenance', 'azure_sys')"
+}
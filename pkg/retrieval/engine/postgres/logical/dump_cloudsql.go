@@ -0,0 +1,89 @@
+/*
+2021 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultGCPTokenURL defines the metadata server endpoint issuing tokens for the attached service account.
+	defaultGCPTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+type cloudSQLDumper struct {
+	cfg   *CloudSQLConfig
+	token *cachedToken
+}
+
+// CloudSQLConfig describes configuration of Cloud SQL IAM database authentication.
+type CloudSQLConfig struct {
+	TokenURL    string `yaml:"tokenURL"`
+	SSLRootCert string `yaml:"sslRootCert"`
+}
+
+func newCloudSQLDumper(cfg *CloudSQLConfig) *cloudSQLDumper {
+	tokenURL := defaultGCPTokenURL
+
+	if cfg.TokenURL != "" {
+		tokenURL = cfg.TokenURL
+	}
+
+	return &cloudSQLDumper{
+		cfg: cfg,
+		token: newCachedToken(func(ctx context.Context) (accessToken, error) {
+			return fetchGCPToken(ctx, tokenURL)
+		}),
+	}
+}
+
+// fetchGCPToken requests an OAuth 2.0 access token from the metadata server.
+func fetchGCPToken(ctx context.Context, tokenURL string) (accessToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL, nil)
+	if err != nil {
+		return accessToken{}, errors.Wrap(err, "failed to create token request")
+	}
+
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	return requestToken(ctx, req, time.Now())
+}
+
+// GetCmdEnvVariables returns dumper environment variables.
+func (c *cloudSQLDumper) GetCmdEnvVariables() []string {
+	if c.cfg.SSLRootCert != "" {
+		return []string{
+			"PGSSLROOTCERT=" + c.cfg.SSLRootCert,
+			"PGSSLMODE=verify-ca",
+		}
+	}
+
+	return []string{"PGSSLMODE=require"}
+}
+
+// SetConnectionOptions sets connection options for dumping.
+func (c *cloudSQLDumper) SetConnectionOptions(ctx context.Context, conn *Connection) error {
+	if conn.Username == "" {
+		return errors.New("username of the IAM database user must be defined")
+	}
+
+	token, err := c.token.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	conn.Password = token
+
+	return nil
+}
+
+// GetDatabaseListQuery provides the query to get the list of databases for dumping.
+func (c *cloudSQLDumper) GetDatabaseListQuery() string {
+	return "select datname from pg_catalog.pg_database where not datistemplate and datname <> 'cloudsqladmin'"
+}
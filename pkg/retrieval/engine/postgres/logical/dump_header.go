@@ -0,0 +1,171 @@
+/*
+2021 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// customDumpMagic defines the leading bytes of a custom-format archive.
+	customDumpMagic = "PGDMP"
+
+	// archiveFormatCustom defines the archive format code of custom-format dumps.
+	archiveFormatCustom = 1
+
+	// maxHeaderStringLength limits strings read from an archive header.
+	maxHeaderStringLength = 1024
+)
+
+// customDumpHeader describes the header of a custom-format archive.
+type customDumpHeader struct {
+	dbName string
+
+	// createdAt is the local time of the host where the dump was made: the archive does not store the time zone,
+	// so the time is interpreted as UTC.
+	createdAt time.Time
+}
+
+// archiveVersion represents a version of the archive format.
+type archiveVersion struct {
+	major, minor, rev byte
+}
+
+func (v archiveVersion) atLeast(major, minor byte) bool {
+	return v.major > major || (v.major == major && v.minor >= minor)
+}
+
+// isCustomDump checks if the leading bytes belong to a custom-format archive.
+func isCustomDump(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(customDumpMagic))
+}
+
+// parseCustomDumpHeader reads the header of a custom-format archive the same way pg_restore does (see ReadHead in pg_backup_archiver.c).
+// The creation time is interpreted as UTC, so it is shifted by the UTC offset of the dumping host if that host does not use UTC.
+func parseCustomDumpHeader(header []byte) (*customDumpHeader, error) {
+	hr := &headerReader{r: bufio.NewReader(bytes.NewReader(header))}
+
+	magic := make([]byte, len(customDumpMagic))
+	if _, err := io.ReadFull(hr.r, magic); err != nil || string(magic) != customDumpMagic {
+		return nil, errInvalidDump
+	}
+
+	version := archiveVersion{major: hr.readByte(), minor: hr.readByte()}
+
+	if version.atLeast(1, 3) {
+		version.rev = hr.readByte()
+	}
+
+	if !version.atLeast(1, 10) {
+		return nil, errors.Errorf("unsupported archive version: %d.%d.%d", version.major, version.minor, version.rev)
+	}
+
+	hr.intSize = int(hr.readByte())
+	// Offset size is not needed for the header.
+	_ = hr.readByte()
+
+	if format := hr.readByte(); format != archiveFormatCustom {
+		return nil, errors.Errorf("unexpected archive format: %d", format)
+	}
+
+	if version.atLeast(1, 15) {
+		// Compression algorithm.
+		_ = hr.readByte()
+	} else {
+		// Compression level.
+		_ = hr.readInt()
+	}
+
+	sec, minute, hour := hr.readInt(), hr.readInt(), hr.readInt()
+	day, month, year := hr.readInt(), hr.readInt(), hr.readInt()
+	// Daylight saving time flag.
+	_ = hr.readInt()
+
+	dbName := hr.readString()
+
+	if hr.err != nil {
+		return nil, errors.Wrap(hr.err, "failed to read archive header")
+	}
+
+	return &customDumpHeader{
+		dbName:    dbName,
+		createdAt: time.Date(year+1900, time.Month(month+1), day, hour, minute, sec, 0, time.UTC),
+	}, nil
+}
+
+// headerReader reads values encoded by pg_dump and keeps the first occurred error.
+type headerReader struct {
+	r       *bufio.Reader
+	intSize int
+	err     error
+}
+
+func (h *headerReader) readByte() byte {
+	if h.err != nil {
+		return 0
+	}
+
+	b, err := h.r.ReadByte()
+	if err != nil {
+		h.err = err
+	}
+
+	return b
+}
+
+// readInt reads an integer stored as a sign byte followed by intSize bytes in little-endian order.
+func (h *headerReader) readInt() int {
+	sign := h.readByte()
+
+	if h.err != nil {
+		return 0
+	}
+
+	if h.intSize <= 0 || h.intSize > 8 {
+		h.err = errors.Errorf("unsupported integer size: %d", h.intSize)
+		return 0
+	}
+
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(h.r, buf[:h.intSize]); err != nil {
+		h.err = err
+		return 0
+	}
+
+	value := int(binary.LittleEndian.Uint64(buf))
+
+	if sign != 0 {
+		return -value
+	}
+
+	return value
+}
+
+func (h *headerReader) readString() string {
+	length := h.readInt()
+
+	if h.err != nil || length <= 0 {
+		return ""
+	}
+
+	if length > maxHeaderStringLength {
+		h.err = errors.Errorf("string is too long: %d", length)
+		return ""
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(h.r, buf); err != nil {
+		h.err = err
+		return ""
+	}
+
+	return string(buf)
+}
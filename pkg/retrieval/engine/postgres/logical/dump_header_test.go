@@ -0,0 +1,119 @@
+/*
+2021 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildCustomDumpHeader encodes a custom-format archive header the same way pg_dump does.
+func buildCustomDumpHeader(minor byte, dbName string, createdAt time.Time) []byte {
+	const intSize = 4
+
+	buf := &bytes.Buffer{}
+
+	writeInt := func(value int) {
+		sign := byte(0)
+		if value < 0 {
+			sign, value = 1, -value
+		}
+
+		buf.WriteByte(sign)
+
+		for i := 0; i < intSize; i++ {
+			buf.WriteByte(byte(value >> (8 * i)))
+		}
+	}
+
+	buf.WriteString(customDumpMagic)
+	buf.Write([]byte{1, minor, 0, intSize, 8, archiveFormatCustom})
+
+	if minor >= 15 {
+		buf.WriteByte(1)
+	} else {
+		writeInt(-1)
+	}
+
+	writeInt(createdAt.Second())
+	writeInt(createdAt.Minute())
+	writeInt(createdAt.Hour())
+	writeInt(createdAt.Day())
+	writeInt(int(createdAt.Month()) - 1)
+	writeInt(createdAt.Year() - 1900)
+	writeInt(0)
+
+	writeInt(len(dbName))
+	buf.WriteString(dbName)
+
+	return buf.Bytes()
+}
+
+func TestParseCustomDumpHeader(t *testing.T) {
+	createdAt := time.Date(2021, time.June, 15, 10, 20, 30, 0, time.UTC)
+
+	testCases := []struct {
+		name   string
+		header []byte
+		dbName string
+	}{
+		{
+			name:   "v1.14",
+			header: buildCustomDumpHeader(14, "testdb", createdAt),
+			dbName: "testdb",
+		},
+		{
+			name:   "v1.15",
+			header: buildCustomDumpHeader(15, "postgres", createdAt),
+			dbName: "postgres",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Log(tc.name)
+
+		dumpHeader, err := parseCustomDumpHeader(tc.header)
+		require.NoError(t, err)
+		assert.Equal(t, tc.dbName, dumpHeader.dbName)
+		assert.Equal(t, createdAt, dumpHeader.createdAt)
+	}
+}
+
+func TestParseInvalidCustomDumpHeader(t *testing.T) {
+	validHeader := buildCustomDumpHeader(14, "testdb", time.Now())
+
+	testCases := []struct {
+		name   string
+		header []byte
+	}{
+		{
+			name:   "empty",
+			header: []byte{},
+		},
+		{
+			name:   "plain",
+			header: []byte("--\n-- PostgreSQL database dump\n"),
+		},
+		{
+			name:   "truncated",
+			header: validHeader[:20],
+		},
+		{
+			name:   "old version",
+			header: buildCustomDumpHeader(9, "testdb", time.Now()),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Log(tc.name)
+
+		_, err := parseCustomDumpHeader(tc.header)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+2021 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildPlainDumpCommand(t *testing.T) {
+	dumpJob := &DumpJob{
+		config: dumpJobConfig{db: Connection{Host: "source", Port: 5432, Username: "john"}},
+		DumpOptions: DumpOptions{
+			DumpLocation: "/var/lib/dblab/dump",
+			ParallelJobs: 2,
+		},
+	}
+
+	testCases := []struct {
+		definition     DumpDefinition
+		expectedSuffix string
+	}{
+		{
+			definition:     DumpDefinition{Format: plainFormat, Compression: zstdCompression, CompressionLevel: 9},
+			expectedSuffix: "--format plain | zstd -cq -9 > /var/lib/dblab/dump/test.zst",
+		},
+		{
+			definition:     DumpDefinition{Format: plainFormat, Compression: lz4Compression},
+			expectedSuffix: "--format plain | lz4 -cq > /var/lib/dblab/dump/test.lz4",
+		},
+		{
+			definition:     DumpDefinition{Format: plainFormat},
+			expectedSuffix: "--format plain > /var/lib/dblab/dump/test.sql",
+		},
+	}
+
+	for _, tc := range testCases {
+		dumpCmd := dumpJob.buildLogicalDumpCommand("test", tc.definition, "")
+		require.Len(t, dumpCmd, 3)
+		assert.Equal(t, "bash", dumpCmd[0])
+
+		cmd := dumpCmd[2]
+		assert.True(t, strings.HasPrefix(cmd, "set -o pipefail; pg_dump "), cmd)
+		assert.True(t, strings.HasSuffix(cmd, tc.expectedSuffix), cmd)
+		assert.NotContains(t, cmd, "--jobs")
+		assert.NotContains(t, cmd, "--create")
+	}
+
+	dumpCmd := dumpJob.buildLogicalDumpCommand("test", DumpDefinition{}, "00000003-00000002-1")
+	assert.Equal(t, "pg_dump", dumpCmd[0])
+	assert.Contains(t, dumpCmd, "--create")
+	assert.Contains(t, strings.Join(dumpCmd, " "), "--snapshot 00000003-00000002-1")
+	assert.Equal(t, []string{"--format", directoryFormat, "--file", "/var/lib/dblab/dump/test"}, dumpCmd[len(dumpCmd)-4:])
+}
+
+func TestBuildEncryptedDumpCommand(t *testing.T) {
+	dumpJob := &DumpJob{
+		config: dumpJobConfig{db: Connection{Host: "source", Port: 5432, Username: "john"}},
+		DumpOptions: DumpOptions{
+			DumpLocation: "/var/lib/dblab/dump",
+			ParallelJobs: 2,
+			Encryption:   Encryption{KeyFile: "/run/secrets/dump.key"},
+		},
+	}
+
+	dumpCmd := dumpJob.buildLogicalDumpCommand("test", DumpDefinition{}, "")
+	assert.Equal(t, "pg_dump", dumpCmd[0])
+	assert.Contains(t, dumpCmd, "--create")
+	assert.NotContains(t, dumpCmd, "--jobs")
+	assert.NotContains(t, dumpCmd, "--file")
+	assert.Equal(t, []string{"--format", customFormat}, dumpCmd[len(dumpCmd)-2:])
+
+	dumpCmd = dumpJob.buildLogicalDumpCommand("test", DumpDefinition{Format: plainFormat, Compression: gzipCompression}, "")
+	require.Len(t, dumpCmd, 3)
+	assert.True(t, strings.HasSuffix(dumpCmd[2], "--format plain | gzip -c"), dumpCmd[2])
+}
+
+func TestValidateEncryption(t *testing.T) {
+	encryption := Encryption{KeyFile: "/run/secrets/dump.key"}
+
+	testCases := []struct {
+		options DumpOptions
+		isValid bool
+	}{
+		{
+			options: DumpOptions{Encryption: encryption, Databases: map[string]DumpDefinition{"test": {Format: plainFormat}}},
+			isValid: true,
+		},
+		{
+			options: DumpOptions{Encryption: encryption, Databases: map[string]DumpDefinition{"test": {Format: directoryFormat}}},
+			isValid: false,
+		},
+		{
+			options: DumpOptions{Encryption: encryption, Restore: ImmediateRestore{Enabled: true}},
+			isValid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		dumpJob := &DumpJob{DumpOptions: tc.options}
+		assert.Equal(t, tc.isValid, dumpJob.validate() == nil)
+	}
+}
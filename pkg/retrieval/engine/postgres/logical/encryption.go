@@ -0,0 +1,317 @@
+/*
+2021 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Encrypted dumps are stored in the following format:
+//
+//	magic (8 bytes) | version (1 byte) | salt (16 bytes) | chunk | ... | final chunk
+//
+// Each chunk is up to 64 KiB of the dump sealed with AES-256-GCM. The chunk key is derived from the key file
+// and the salt, the nonce consists of the chunk counter and the flag of the final chunk,
+// so reordered, truncated or appended chunks are detected.
+const (
+	// encryptedExtension defines the extension of encrypted dump files.
+	encryptedExtension = ".enc"
+
+	encryptionVersion   = 1
+	encryptionKeySize   = 32
+	encryptionSaltSize  = 16
+	encryptionChunkSize = 64 * 1024
+
+	// finalChunkFlag marks the last byte of the nonce of the final chunk.
+	finalChunkFlag = 1
+)
+
+var (
+	encryptionMagic = []byte("DBLABENC")
+
+	encryptionHeaderSize = len(encryptionMagic) + 1 + encryptionSaltSize
+
+	// errDecryption occurs when a chunk of an encrypted dump cannot be authenticated.
+	errDecryption = errors.New("failed to decrypt the dump: invalid encryption key or corrupted data")
+)
+
+// Encryption defines options to encrypt dumps at rest.
+type Encryption struct {
+	KeyFile string `yaml:"keyFile"`
+}
+
+// enabled checks if a key file to encrypt dumps is provided.
+func (e Encryption) enabled() bool {
+	return e.KeyFile != ""
+}
+
+// loadEncryptionKey reads a 256-bit key from the file. The key may be stored as raw bytes, hex or base64.
+func loadEncryptionKey(keyFile string) ([]byte, error) {
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the encryption key file")
+	}
+
+	if len(content) == encryptionKeySize {
+		return content, nil
+	}
+
+	encodedKey := string(bytes.TrimSpace(content))
+
+	if key, err := hex.DecodeString(encodedKey); err == nil && len(key) == encryptionKeySize {
+		return key, nil
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(encodedKey); err == nil && len(key) == encryptionKeySize {
+		return key, nil
+	}
+
+	return nil, errors.Errorf("the encryption key file must contain a %d-byte key as raw bytes, hex or base64", encryptionKeySize)
+}
+
+// isEncryptedDump checks if the leading bytes of a dump belong to an encrypted dump.
+func isEncryptedDump(header []byte) bool {
+	return bytes.HasPrefix(header, encryptionMagic)
+}
+
+// isEncryptedFile checks if the file is an encrypted dump.
+func isEncryptedFile(filename string) (bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to open dump file")
+	}
+
+	defer func() { _ = f.Close() }()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get dump file info")
+	}
+
+	if stat.IsDir() {
+		return false, nil
+	}
+
+	header := make([]byte, len(encryptionMagic))
+
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return false, errors.Wrap(err, "failed to read dump file")
+	}
+
+	return isEncryptedDump(header[:n]), nil
+}
+
+func newChunkCipher(key, salt []byte) (cipher.AEAD, error) {
+	chunkKey := make([]byte, encryptionKeySize)
+
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, salt, encryptionMagic), chunkKey); err != nil {
+		return nil, errors.Wrap(err, "failed to derive the encryption key")
+	}
+
+	block, err := aes.NewCipher(chunkKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create a block cipher")
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(nonce []byte, counter uint64, final bool) []byte {
+	for i := range nonce {
+		nonce[i] = 0
+	}
+
+	binary.BigEndian.PutUint64(nonce[len(nonce)-9:], counter)
+
+	if final {
+		nonce[len(nonce)-1] = finalChunkFlag
+	}
+
+	return nonce
+}
+
+// encryptWriter encrypts a dump written to it. Close must be called to write the final chunk.
+type encryptWriter struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	nonce   []byte
+	buf     []byte
+	out     []byte
+	counter uint64
+}
+
+func newEncryptWriter(dst io.Writer, key []byte) (io.WriteCloser, error) {
+	salt := make([]byte, encryptionSaltSize)
+
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "failed to generate salt")
+	}
+
+	aead, err := newChunkCipher(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, encryptionHeaderSize)
+	header = append(header, encryptionMagic...)
+	header = append(header, encryptionVersion)
+	header = append(header, salt...)
+
+	if _, err := dst.Write(header); err != nil {
+		return nil, errors.Wrap(err, "failed to write the encryption header")
+	}
+
+	return &encryptWriter{
+		dst:   dst,
+		aead:  aead,
+		nonce: make([]byte, aead.NonceSize()),
+		buf:   make([]byte, 0, encryptionChunkSize),
+		out:   make([]byte, 0, encryptionChunkSize+aead.Overhead()),
+	}, nil
+}
+
+// Write implements io.Writer.
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	written := len(p)
+
+	for len(p) > 0 {
+		// A full chunk is sealed only when more data arrives, so the final chunk is never empty unless the dump is.
+		if len(w.buf) == encryptionChunkSize {
+			if err := w.sealChunk(false); err != nil {
+				return 0, err
+			}
+		}
+
+		n := encryptionChunkSize - len(w.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// Close seals the final chunk. It does not close the underlying writer.
+func (w *encryptWriter) Close() error {
+	return w.sealChunk(true)
+}
+
+func (w *encryptWriter) sealChunk(final bool) error {
+	w.out = w.aead.Seal(w.out[:0], chunkNonce(w.nonce, w.counter, final), w.buf, nil)
+
+	if _, err := w.dst.Write(w.out); err != nil {
+		return errors.Wrap(err, "failed to write an encrypted chunk")
+	}
+
+	w.counter++
+	w.buf = w.buf[:0]
+
+	return nil
+}
+
+// decryptReader decrypts a dump read from the underlying reader.
+type decryptReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	nonce   []byte
+	chunk   []byte
+	plain   []byte
+	counter uint64
+	final   bool
+}
+
+func newDecryptReader(src io.Reader, key []byte) (io.Reader, error) {
+	header := make([]byte, encryptionHeaderSize)
+
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, errors.Wrap(err, "failed to read the encryption header")
+	}
+
+	if !isEncryptedDump(header) {
+		return nil, errors.New("the dump is not encrypted")
+	}
+
+	if version := header[len(encryptionMagic)]; version != encryptionVersion {
+		return nil, errors.Errorf("unsupported version of the encrypted dump: %d", version)
+	}
+
+	aead, err := newChunkCipher(key, header[len(encryptionMagic)+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		src:   bufio.NewReader(src),
+		aead:  aead,
+		nonce: make([]byte, aead.NonceSize()),
+		chunk: make([]byte, encryptionChunkSize+aead.Overhead()),
+	}, nil
+}
+
+// Read implements io.Reader.
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.final {
+			return 0, io.EOF
+		}
+
+		if err := r.openChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+
+	return n, nil
+}
+
+func (r *decryptReader) openChunk() error {
+	n, err := io.ReadFull(r.src, r.chunk)
+
+	switch {
+	case err == nil:
+		if _, err := r.src.Peek(1); err != nil {
+			if !errors.Is(err, io.EOF) {
+				return errors.Wrap(err, "failed to read the encrypted dump")
+			}
+
+			r.final = true
+		}
+
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		r.final = true
+
+	default:
+		return errors.Wrap(err, "failed to read the encrypted dump")
+	}
+
+	plain, err := r.aead.Open(r.chunk[:0], chunkNonce(r.nonce, r.counter, r.final), r.chunk[:n], nil)
+	if err != nil {
+		return errDecryption
+	}
+
+	r.plain = plain
+	r.counter++
+
+	return nil
+}
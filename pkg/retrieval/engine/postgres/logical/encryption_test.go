@@ -0,0 +1,160 @@
+/*
+2021 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encryptDump(t *testing.T, key, dump []byte) []byte {
+	t.Helper()
+
+	encrypted := &bytes.Buffer{}
+
+	encWriter, err := newEncryptWriter(encrypted, key)
+	require.NoError(t, err)
+
+	// Write in portions not aligned with chunks.
+	for offset := 0; offset < len(dump); offset += 1000 {
+		end := offset + 1000
+		if end > len(dump) {
+			end = len(dump)
+		}
+
+		_, err := encWriter.Write(dump[offset:end])
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, encWriter.Close())
+
+	return encrypted.Bytes()
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	key := make([]byte, encryptionKeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+
+	for _, size := range []int{0, 1, encryptionChunkSize, encryptionChunkSize + 1, 3*encryptionChunkSize + 100} {
+		dump := make([]byte, size)
+		_, err := rand.Read(dump)
+		require.NoError(t, err)
+
+		encrypted := encryptDump(t, key, dump)
+		assert.True(t, isEncryptedDump(encrypted))
+
+		decReader, err := newDecryptReader(bytes.NewReader(encrypted), key)
+		require.NoError(t, err)
+
+		decrypted, err := io.ReadAll(decReader)
+		require.NoError(t, err)
+		assert.Equal(t, dump, decrypted, "size %d", size)
+	}
+}
+
+func TestDecryptionFailures(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, encryptionKeySize)
+	dump := bytes.Repeat([]byte("insert into test values (1);\n"), 10000)
+	encrypted := encryptDump(t, key, dump)
+
+	testCases := []struct {
+		name      string
+		key       []byte
+		encrypted []byte
+	}{
+		{
+			name:      "wrong key",
+			key:       bytes.Repeat([]byte{2}, encryptionKeySize),
+			encrypted: encrypted,
+		},
+		{
+			name:      "truncated at the chunk boundary",
+			key:       key,
+			encrypted: encrypted[:encryptionHeaderSize+encryptionChunkSize+16],
+		},
+		{
+			name:      "truncated in the middle of a chunk",
+			key:       key,
+			encrypted: encrypted[:len(encrypted)-10],
+		},
+		{
+			name:      "appended data",
+			key:       key,
+			encrypted: append(append([]byte{}, encrypted...), 0),
+		},
+	}
+
+	for _, tc := range testCases {
+		decReader, err := newDecryptReader(bytes.NewReader(tc.encrypted), tc.key)
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(decReader)
+		assert.ErrorIs(t, err, errDecryption, tc.name)
+	}
+
+	_, err := newDecryptReader(bytes.NewReader([]byte("--\n-- PostgreSQL database dump\n--")), key)
+	assert.Error(t, err)
+}
+
+func TestLoadEncryptionKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0xab}, encryptionKeySize)
+	dir := t.TempDir()
+
+	testCases := []struct {
+		content []byte
+		isValid bool
+	}{
+		{content: key, isValid: true},
+		{content: []byte(hex.EncodeToString(key) + "\n"), isValid: true},
+		{content: []byte(base64.StdEncoding.EncodeToString(key)), isValid: true},
+		{content: []byte("secret"), isValid: false},
+	}
+
+	for i, tc := range testCases {
+		keyFile := path.Join(dir, "key"+string(rune('a'+i)))
+		require.NoError(t, os.WriteFile(keyFile, tc.content, 0600))
+
+		loadedKey, err := loadEncryptionKey(keyFile)
+		if !tc.isValid {
+			assert.Error(t, err)
+			continue
+		}
+
+		require.NoError(t, err)
+		assert.Equal(t, key, loadedKey)
+	}
+}
+
+func TestExploreEncryptedDumpFile(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, encryptionKeySize)
+	dump := []byte("--\n-- PostgreSQL database dump\n--\n\n\\connect test\n\nCREATE TABLE public.test (id integer);\n")
+
+	dumpFile := path.Join(t.TempDir(), "test"+encryptedExtension)
+	require.NoError(t, os.WriteFile(dumpFile, encryptDump(t, key, dump), 0600))
+
+	encrypted, err := isEncryptedFile(dumpFile)
+	require.NoError(t, err)
+	assert.True(t, encrypted)
+
+	restoreJob := &RestoreJob{encryptionKey: key}
+
+	definition, err := restoreJob.exploreEncryptedDumpFile(dumpFile)
+	require.NoError(t, err)
+	assert.Equal(t, plainFormat, definition.Format)
+	assert.Equal(t, "test", definition.dbName)
+
+	_, err = (&RestoreJob{}).exploreEncryptedDumpFile(dumpFile)
+	assert.Error(t, err)
+}
@@ -9,7 +9,10 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
+	"hash"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -74,6 +77,9 @@ var (
 	// errInvalidDump occurs when invalid dump found in the provided location.
 	errInvalidDump = errors.New("invalid dump provided")
 
+	// errUnknownPlainDump occurs when a plain-text dump contains neither a connection nor a table creation query.
+	errUnknownPlainDump = errors.New("unknown format of the dump file")
+
 	// filenameFormatter replaces all non-word characters to an underscore.
 	filenameFormatter = regexp.MustCompile(`\W`)
 )
@@ -87,18 +93,22 @@ type RestoreJob struct {
 	dbMarker          *dbmarker.Marker
 	dbMark            *dbmarker.Config
 	isDumpLocationDir bool
+	dumpSource        dumpSource
+	encryptionKey     []byte
 	RestoreOptions
 }
 
 // RestoreOptions defines a logical restore options.
 type RestoreOptions struct {
 	DumpLocation    string                    `yaml:"dumpLocation"`
+	Source          RestoreSource             `yaml:"source"`
 	DockerImage     string                    `yaml:"dockerImage"`
 	ContainerConfig map[string]interface{}    `yaml:"containerConfig"`
 	Databases       map[string]DumpDefinition `yaml:"databases"`
 	ForceInit       bool                      `yaml:"forceInit"`
 	ParallelJobs    int                       `yaml:"parallelJobs"`
 	Configs         map[string]string         `yaml:"configs"`
+	Encryption      Encryption                `yaml:"encryption"`
 }
 
 // Partial defines tables and rules for a partial logical restore.
@@ -150,6 +160,30 @@ func (r *RestoreJob) Reload(cfg map[string]interface{}) (err error) {
 
 	r.setDefaults()
 
+	for dbName, definition := range r.Databases {
+		if err := validateCompression(definition.Compression, 0); err != nil {
+			return errors.Wrapf(err, "invalid compression of the database %q", dbName)
+		}
+	}
+
+	r.encryptionKey = nil
+
+	if r.Encryption.enabled() {
+		if r.encryptionKey, err = loadEncryptionKey(r.Encryption.KeyFile); err != nil {
+			return errors.Wrap(err, "failed to load the encryption key")
+		}
+	}
+
+	if isRemoteSource(r.RestoreOptions.Source.Type) {
+		if r.dumpSource, err = newDumpSource(r.RestoreOptions.Source); err != nil {
+			return errors.Wrap(err, "failed to set up dump source")
+		}
+
+		return nil
+	}
+
+	r.dumpSource = nil
+
 	stat, err := os.Stat(r.RestoreOptions.DumpLocation)
 	if err != nil {
 		return errors.Wrap(err, "dumpLocation not found")
@@ -237,19 +271,10 @@ func (r *RestoreJob) Run(ctx context.Context) (err error) {
 		}
 	}
 
-	dbList, err := r.getDBList(ctx, restoreCont.ID)
-	if err != nil {
+	if err := r.restoreDatabases(ctx, restoreCont.ID); err != nil {
 		return err
 	}
 
-	log.Dbg("Database List to restore: ", dbList)
-
-	for dbName, dbDefinition := range dbList {
-		if err := r.restoreDB(ctx, restoreCont.ID, dbName, dbDefinition); err != nil {
-			return errors.Wrap(err, "failed to restore a database")
-		}
-	}
-
 	analyzeCmd := buildAnalyzeCommand(
 		Connection{Username: r.globalCfg.Database.User(), DBName: r.globalCfg.Database.Name()},
 		r.RestoreOptions.ParallelJobs,
@@ -270,6 +295,354 @@ func (r *RestoreJob) Run(ctx context.Context) (err error) {
 	return nil
 }
 
+func (r *RestoreJob) restoreDatabases(ctx context.Context, contID string) error {
+	if r.dumpSource != nil {
+		return r.restoreFromSource(ctx, contID)
+	}
+
+	dbList, err := r.getDBList(ctx, contID)
+	if err != nil {
+		return err
+	}
+
+	log.Dbg("Database List to restore: ", dbList)
+
+	for dbName, dbDefinition := range dbList {
+		if err := r.restoreDB(ctx, contID, dbName, dbDefinition); err != nil {
+			return errors.Wrap(err, "failed to restore a database")
+		}
+	}
+
+	return nil
+}
+
+// restoreFromSource streams dumps from a remote source directly into the restore container.
+func (r *RestoreJob) restoreFromSource(ctx context.Context, contID string) error {
+	dumps, err := r.dumpSource.list(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list dumps")
+	}
+
+	if len(dumps) == 0 {
+		return errors.New("no dumps found in the source")
+	}
+
+	for _, dump := range dumps {
+		definition, ok := r.Databases[dump.name]
+		if len(r.Databases) > 0 && !ok {
+			log.Dbg(fmt.Sprintf("Skip dump %q because it is not listed in databases", dump.name))
+			continue
+		}
+
+		if err := r.restoreRemoteDump(ctx, contID, dump, definition.Tables); err != nil {
+			return errors.Wrapf(err, "failed to restore dump %q", dump.name)
+		}
+	}
+
+	return nil
+}
+
+// restoreRemoteDump streams the dump into the restore container.
+// The checksum of the dump is calculated while it is streamed. If the checksum does not match, the restored databases
+// are dropped and the restore fails. With the verifyBeforeRestore option, the dump is downloaded and verified first.
+func (r *RestoreJob) restoreRemoteDump(ctx context.Context, contID string, dump remoteDump, tables []string) error {
+	if dump.checksum != "" && r.Source.VerifyBeforeRestore {
+		return r.restoreVerifiedDump(ctx, contID, dump, tables)
+	}
+
+	log.Msg("Streaming dump: ", dump.key)
+
+	body, err := r.dumpSource.open(ctx, dump.key)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = body.Close() }()
+
+	var (
+		input     io.Reader = body
+		dumpHash            = sha256.New()
+		databases []string
+	)
+
+	if dump.checksum != "" {
+		if databases, err = r.listDatabases(ctx, contID); err != nil {
+			return err
+		}
+
+		input = io.TeeReader(body, dumpHash)
+	}
+
+	stream, err := r.decryptStream(bufio.NewReaderSize(input, headerPeekSize))
+	if err != nil {
+		return err
+	}
+
+	dbDefinition, header, err := r.restoreStream(ctx, contID, dump.name, stream, tables)
+	if err != nil {
+		return err
+	}
+
+	if dump.checksum != "" {
+		if err := verifyStreamedChecksum(dump.checksum, input, dumpHash); err != nil {
+			r.dropRestoredDatabases(ctx, contID, databases)
+			return err
+		}
+
+		log.Msg("Checksum has been verified: ", dump.name)
+	}
+
+	return r.markStreamedDump(dbDefinition, header)
+}
+
+// verifyStreamedChecksum reads the rest of the hashed stream and verifies the checksum.
+// The restore command may stop reading before the end of the dump, so the remaining bytes have to be hashed too.
+func verifyStreamedChecksum(checksum string, input io.Reader, dumpHash hash.Hash) error {
+	if _, err := io.Copy(io.Discard, input); err != nil {
+		return errors.Wrap(err, "failed to read the dump")
+	}
+
+	return verifyChecksum(checksum, dumpHash.Sum(nil))
+}
+
+// listDatabases returns names of databases existing in the restore container.
+func (r *RestoreJob) listDatabases(ctx context.Context, contID string) ([]string, error) {
+	cmd := []string{"psql", "--username", r.globalCfg.Database.User(), "--dbname", defaults.DBName, "--tuples-only", "--no-align",
+		"--command", "select datname from pg_database where not datistemplate"}
+
+	output, err := tools.ExecCommandWithOutput(ctx, r.dockerClient, contID, types.ExecConfig{Cmd: cmd})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list databases")
+	}
+
+	databases := []string{}
+
+	for _, line := range strings.Split(output, "\n") {
+		if dbName := strings.TrimSpace(line); dbName != "" {
+			databases = append(databases, dbName)
+		}
+	}
+
+	return databases, nil
+}
+
+// dropRestoredDatabases drops databases which do not exist in the given list.
+// A dump restored into an existing database cannot be rolled back, so the job has to fail.
+func (r *RestoreJob) dropRestoredDatabases(ctx context.Context, contID string, existing []string) {
+	current, err := r.listDatabases(ctx, contID)
+	if err != nil {
+		log.Err("Failed to find restored databases: ", err)
+		return
+	}
+
+	for _, dbName := range restoredDatabases(existing, current) {
+		cmd := []string{"dropdb", "--username", r.globalCfg.Database.User(), "--if-exists", dbName}
+		log.Msg("Dropping restored database: ", dbName)
+
+		if out, err := tools.ExecCommandWithOutput(ctx, r.dockerClient, contID, types.ExecConfig{Cmd: cmd}); err != nil {
+			log.Err(fmt.Sprintf("Failed to drop database %q: %v. Output: %s", dbName, err, out))
+		}
+	}
+}
+
+// restoredDatabases returns databases which appeared after the restore.
+func restoredDatabases(existing, current []string) []string {
+	known := make(map[string]struct{}, len(existing))
+
+	for _, dbName := range existing {
+		known[dbName] = struct{}{}
+	}
+
+	restored := []string{}
+
+	for _, dbName := range current {
+		if _, ok := known[dbName]; !ok {
+			restored = append(restored, dbName)
+		}
+	}
+
+	return restored
+}
+
+// restoreVerifiedDump downloads the dump to a temporary file, verifies its checksum, and restores it from the file.
+// A corrupted dump is never restored, but the dump location needs space for the dump.
+func (r *RestoreJob) restoreVerifiedDump(ctx context.Context, contID string, dump remoteDump, tables []string) error {
+	log.Msg("Downloading dump: ", dump.key)
+
+	dumpFile, err := r.downloadDump(ctx, dump)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = dumpFile.Close()
+
+		if err := os.Remove(dumpFile.Name()); err != nil {
+			log.Err("Failed to remove the downloaded dump: ", err)
+		}
+	}()
+
+	log.Msg("Checksum has been verified: ", dump.name)
+
+	stream, err := r.decryptStream(bufio.NewReaderSize(dumpFile, headerPeekSize))
+	if err != nil {
+		return err
+	}
+
+	dbDefinition, header, err := r.restoreStream(ctx, contID, dump.name, stream, tables)
+	if err != nil {
+		return err
+	}
+
+	return r.markStreamedDump(dbDefinition, header)
+}
+
+// downloadDump writes the dump to a temporary file in the dump location and verifies its checksum.
+// The returned file is positioned at the beginning.
+func (r *RestoreJob) downloadDump(ctx context.Context, dump remoteDump) (*os.File, error) {
+	body, err := r.dumpSource.open(ctx, dump.key)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = body.Close() }()
+
+	if r.RestoreOptions.DumpLocation != "" {
+		if err := os.MkdirAll(r.RestoreOptions.DumpLocation, 0700); err != nil {
+			return nil, errors.Wrap(err, "failed to create the dump location")
+		}
+	}
+
+	dumpFile, err := os.CreateTemp(r.RestoreOptions.DumpLocation, downloadFilePattern)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create a file to download the dump")
+	}
+
+	hash := sha256.New()
+
+	if _, err = io.Copy(io.MultiWriter(dumpFile, hash), body); err != nil {
+		err = errors.Wrap(err, "failed to download the dump")
+	}
+
+	if err == nil {
+		err = verifyChecksum(dump.checksum, hash.Sum(nil))
+	}
+
+	if err == nil {
+		_, err = dumpFile.Seek(0, io.SeekStart)
+	}
+
+	if err != nil {
+		_ = dumpFile.Close()
+		_ = os.Remove(dumpFile.Name())
+
+		return nil, err
+	}
+
+	return dumpFile, nil
+}
+
+// restoreEncryptedDump decrypts the dump file and streams it into the restore container.
+func (r *RestoreJob) restoreEncryptedDump(ctx context.Context, contID, dumpName, dumpPath string, tables []string) error {
+	log.Msg("Decrypting dump: ", dumpPath)
+
+	f, err := os.Open(dumpPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open dump file")
+	}
+
+	defer func() { _ = f.Close() }()
+
+	stream, err := r.decryptStream(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+
+	dbDefinition, header, err := r.restoreStream(ctx, contID, dumpName, stream, tables)
+	if err != nil {
+		return err
+	}
+
+	return r.markStreamedDump(dbDefinition, header)
+}
+
+// decryptStream wraps the stream to decrypt it if the dump is encrypted.
+func (r *RestoreJob) decryptStream(dumpReader *bufio.Reader) (io.Reader, error) {
+	magic, err := dumpReader.Peek(len(encryptionMagic))
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "failed to read dump header")
+	}
+
+	if !isEncryptedDump(magic) {
+		return dumpReader, nil
+	}
+
+	if r.encryptionKey == nil {
+		return nil, errors.New("the dump is encrypted, but the encryption key file is not configured")
+	}
+
+	return newDecryptReader(dumpReader, r.encryptionKey)
+}
+
+// restoreStream restores a dump read from the stream. It returns the dump definition and leading bytes of the dump.
+func (r *RestoreJob) restoreStream(ctx context.Context, contID, dumpName string, stream io.Reader, tables []string) (
+	*DumpDefinition, []byte, error) {
+	dumpReader := bufio.NewReaderSize(stream, headerPeekSize)
+
+	peekedHeader, err := dumpReader.Peek(headerPeekSize)
+	if err != nil && err != io.EOF {
+		return nil, nil, errors.Wrap(err, "failed to read dump header")
+	}
+
+	// The peeked bytes are overwritten while the stream is read, so keep a copy.
+	header := append([]byte(nil), peekedHeader...)
+
+	dbDefinition, err := detectDumpDefinition(header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dbDefinition.Tables = tables
+
+	log.Msg(fmt.Sprintf("Found the %s dump file: %s", dbDefinition.Format, dumpName))
+
+	if dbDefinition.Format == plainFormat && dbDefinition.dbName == "" {
+		if err := r.prepareDB(ctx, contID, dumpName); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to prepare database for dump: %s", dumpName)
+		}
+	}
+
+	restoreCommand := r.buildStreamRestoreCommand(dumpName, *dbDefinition)
+	log.Msg("Running restore command for "+dumpName, restoreCommand)
+
+	output, err := tools.ExecCommandWithInput(ctx, r.dockerClient, contID, types.ExecConfig{Cmd: restoreCommand}, dumpReader)
+
+	if output != "" {
+		log.Dbg("Output of the restore command: ", output)
+	}
+
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to exec restore command")
+	}
+
+	return dbDefinition, header, nil
+}
+
+// markStreamedDump marks the data using the creation time from the header of a streamed custom dump.
+func (r *RestoreJob) markStreamedDump(dbDefinition *DumpDefinition, header []byte) error {
+	if dbDefinition.Format == plainFormat {
+		// dataStateAt cannot be found.
+		return nil
+	}
+
+	dumpHeader, err := parseCustomDumpHeader(header)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse the custom dump header")
+	}
+
+	return r.saveDatabaseMark(dumpHeader.createdAt.Format(util.DataStateAtFormat))
+}
+
 func (r *RestoreJob) getDBList(ctx context.Context, contID string) (map[string]DumpDefinition, error) {
 	if len(r.Databases) > 0 {
 		return r.Databases, nil
@@ -295,6 +668,15 @@ func (r *RestoreJob) getDBList(ctx context.Context, contID string) (map[string]D
 
 // discoverDumpLocation explores dump file to identify its type.
 func (r *RestoreJob) exploreDumpFile(ctx context.Context, contID, dumpPath string) (*DumpDefinition, error) {
+	encrypted, err := isEncryptedFile(dumpPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if encrypted {
+		return r.exploreEncryptedDumpFile(dumpPath)
+	}
+
 	// Detect if dump is custom.
 	dbName, err := r.extractDBNameFromDump(ctx, contID, dumpPath)
 	if err != nil {
@@ -313,9 +695,14 @@ func (r *RestoreJob) exploreDumpFile(ctx context.Context, contID, dumpPath strin
 	}
 
 	// Identify type of compression if plain-text dump is archived.
+	compression, err := readCompressionType(dumpPath)
+	if err != nil {
+		return nil, err
+	}
+
 	dbDefinition := &DumpDefinition{
 		Format:      plainFormat,
-		Compression: getCompressionType(dumpPath),
+		Compression: compression,
 	}
 
 	if dbDefinition.Compression != noCompression {
@@ -337,6 +724,28 @@ func (r *RestoreJob) exploreDumpFile(ctx context.Context, contID, dumpPath strin
 	return dbDefinition, nil
 }
 
+// exploreEncryptedDumpFile decrypts leading bytes of the dump file to identify its type.
+func (r *RestoreJob) exploreEncryptedDumpFile(dumpPath string) (*DumpDefinition, error) {
+	f, err := os.Open(dumpPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open dump file")
+	}
+
+	defer func() { _ = f.Close() }()
+
+	stream, err := r.decryptStream(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := bufio.NewReaderSize(stream, headerPeekSize).Peek(headerPeekSize)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "failed to read dump header")
+	}
+
+	return detectDumpDefinition(header)
+}
+
 // extractDBNameFromDump discovers dump to extract the database name.
 func (r *RestoreJob) extractDBNameFromDump(ctx context.Context, contID, dumpPath string) (string, error) {
 	extractDBNameCmd := fmt.Sprintf("pg_restore --list %s | grep %s | tr -d '[;]'", dumpPath, prefixDBName)
@@ -366,10 +775,20 @@ func (r *RestoreJob) parsePlainFile(dumpPath string) (string, error) {
 
 	defer func() { _ = f.Close() }()
 
+	dbName, err := parsePlainDump(f)
+	if err != nil && !errors.Is(err, errDBNameNotFound) {
+		return "", errors.Wrapf(err, "failed to parse %v", dumpPath)
+	}
+
+	return dbName, err
+}
+
+// parsePlainDump extracts the database name from a plain-text dump.
+func parsePlainDump(r io.Reader) (string, error) {
 	connectPrefix := []byte(prefixConnectDB)
 	tablePrefix := []byte(prefixCreateTable)
 
-	sc := bufio.NewScanner(f)
+	sc := bufio.NewScanner(r)
 
 	for sc.Scan() {
 		if bytes.HasPrefix(sc.Bytes(), connectPrefix) {
@@ -390,7 +809,7 @@ func (r *RestoreJob) parsePlainFile(dumpPath string) (string, error) {
 		}
 	}
 
-	return "", errors.Errorf("unknown format of the dump file: %v", dumpPath)
+	return "", errUnknownPlainDump
 }
 
 // discoverDumpLocation discovers dump location to find databases ready to restore.
@@ -472,6 +891,15 @@ func (r *RestoreJob) getDirectoryDumpDefinition(ctx context.Context, contID, dum
 }
 
 func (r *RestoreJob) restoreDB(ctx context.Context, contID, dbName string, dbDefinition DumpDefinition) error {
+	if dbDefinition.Format != directoryFormat {
+		dumpPath := r.getDumpLocation(dbDefinition.Format, dbName)
+
+		// A missing dump is reported by the restore command.
+		if encrypted, err := isEncryptedFile(dumpPath); err == nil && encrypted {
+			return r.restoreEncryptedDump(ctx, contID, dbName, dumpPath, dbDefinition.Tables)
+		}
+	}
+
 	// The dump contains no database creation requests, so create a new database by ourselves.
 	if dbDefinition.Format == plainFormat && dbDefinition.dbName == "" {
 		if err := r.prepareDB(ctx, contID, dbName); err != nil {
@@ -605,6 +1033,10 @@ func (r *RestoreJob) markDatabase(ctx context.Context, contID, dumpLocation stri
 		log.Err("Failed to extract dataStateAt: ", err)
 	}
 
+	return r.saveDatabaseMark(dataStateAt)
+}
+
+func (r *RestoreJob) saveDatabaseMark(dataStateAt string) error {
 	if dataStateAt != "" {
 		r.dbMark.DataStateAt = dataStateAt
 		log.Msg("Data state at: ", dataStateAt)
@@ -672,13 +1104,6 @@ func (r *RestoreJob) buildLogicalRestoreCommand(dumpName string, definition Dump
 }
 
 func (r *RestoreJob) buildPlainTextCommand(dumpName string, definition DumpDefinition) []string {
-	dbName := defaults.DBName
-
-	// It means a required database has been created in the previous step.
-	if definition.dbName == "" {
-		dbName = formatDBName(dumpName)
-	}
-
 	if len(definition.Tables) > 0 {
 		log.Msg("Partial restore is not available for plain-text dump")
 	}
@@ -689,11 +1114,42 @@ func (r *RestoreJob) buildPlainTextCommand(dumpName string, definition DumpDefin
 
 	return []string{
 		"sh", "-c", fmt.Sprintf("%s %s | psql --username %s --dbname %s", getReadingArchiveCommand(definition.Compression),
-			r.getDumpLocation(definition.Format, dumpName), r.globalCfg.Database.User(), dbName),
+			r.getDumpLocation(definition.Format, dumpName), r.globalCfg.Database.User(), r.plainTextDBName(dumpName, definition)),
+	}
+}
+
+// plainTextDBName returns a database to connect to for a plain-text dump restore.
+func (r *RestoreJob) plainTextDBName(dumpName string, definition DumpDefinition) string {
+	// It means a required database has been created in the previous step.
+	if definition.dbName == "" {
+		return formatDBName(dumpName)
 	}
+
+	return defaults.DBName
+}
+
+// buildStreamRestoreCommand builds a command reading the dump from stdin.
+func (r *RestoreJob) buildStreamRestoreCommand(dumpName string, definition DumpDefinition) []string {
+	if definition.Format == plainFormat {
+		return []string{
+			"sh", "-c", fmt.Sprintf("%s | psql --username %s --dbname %s", getReadingArchiveCommand(definition.Compression),
+				r.globalCfg.Database.User(), r.plainTextDBName(dumpName, definition)),
+		}
+	}
+
+	if r.ParallelJobs > 1 {
+		log.Msg("Parallel restore is not available for streamed dumps. It is always single-threaded")
+	}
+
+	return r.buildPGRestoreOptions(definition, 0)
 }
 
 func (r *RestoreJob) buildPGRestoreCommand(dumpName string, definition DumpDefinition) []string {
+	return append(r.buildPGRestoreOptions(definition, r.ParallelJobs), r.getDumpLocation(definition.Format, dumpName))
+}
+
+// buildPGRestoreOptions builds pg_restore options. The jobs option is omitted if parallelJobs is zero.
+func (r *RestoreJob) buildPGRestoreOptions(definition DumpDefinition, parallelJobs int) []string {
 	restoreCmd := []string{"pg_restore", "--username", r.globalCfg.Database.User(), "--dbname", defaults.DBName,
 		"--no-privileges", "--no-owner"}
 
@@ -706,7 +1162,9 @@ func (r *RestoreJob) buildPGRestoreCommand(dumpName string, definition DumpDefin
 		restoreCmd = append(restoreCmd, "--clean", "--if-exists")
 	}
 
-	restoreCmd = append(restoreCmd, "--jobs", strconv.Itoa(r.ParallelJobs))
+	if parallelJobs > 0 {
+		restoreCmd = append(restoreCmd, "--jobs", strconv.Itoa(parallelJobs))
+	}
 
 	if len(definition.Tables) > 0 {
 		log.Msg("Partial restore will be run. Tables for restoring: ", strings.Join(definition.Tables, ", "))
@@ -716,8 +1174,6 @@ func (r *RestoreJob) buildPGRestoreCommand(dumpName string, definition DumpDefin
 		}
 	}
 
-	restoreCmd = append(restoreCmd, r.getDumpLocation(definition.Format, dumpName))
-
 	return restoreCmd
 }
 
@@ -0,0 +1,346 @@
+/*
+2021 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+)
+
+const (
+	localSourceType = "local"
+	s3SourceType    = "s3"
+	urlSourceType   = "url"
+
+	// checksumExtension defines the extension of checksum files placed next to dumps in object storage.
+	checksumExtension = ".sha256"
+
+	// sha256ChecksumPrefix defines an optional prefix of the checksum value.
+	sha256ChecksumPrefix = "sha256:"
+
+	// headerPeekSize defines the number of leading bytes used to detect the dump format.
+	headerPeekSize = 64 * 1024
+
+	// downloadFilePattern defines names of temporary files which dumps with checksums are downloaded to.
+	downloadFilePattern = ".dblab_download_*"
+)
+
+// RestoreSource defines where dumps to restore are stored.
+type RestoreSource struct {
+	Type                string    `yaml:"type"`
+	S3                  *S3Source `yaml:"s3"`
+	URL                 string    `yaml:"url"`
+	Checksum            string    `yaml:"checksum"`
+	VerifyBeforeRestore bool      `yaml:"verifyBeforeRestore"`
+}
+
+// S3Source describes an S3-compatible storage containing dumps.
+type S3Source struct {
+	Endpoint       string `yaml:"endpoint"`
+	Region         string `yaml:"region"`
+	Bucket         string `yaml:"bucket"`
+	Prefix         string `yaml:"prefix"`
+	ForcePathStyle bool   `yaml:"forcePathStyle"`
+}
+
+// remoteDump describes a dump file available in a remote source.
+type remoteDump struct {
+	name     string
+	key      string
+	checksum string
+}
+
+// dumpSource provides access to dumps stored outside of the restore container.
+type dumpSource interface {
+	list(ctx context.Context) ([]remoteDump, error)
+	open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+func isRemoteSource(sourceType string) bool {
+	return sourceType == s3SourceType || sourceType == urlSourceType
+}
+
+func newDumpSource(src RestoreSource) (dumpSource, error) {
+	if src.Checksum != "" {
+		if _, err := parseChecksum(src.Checksum); err != nil {
+			return nil, err
+		}
+	}
+
+	switch src.Type {
+	case s3SourceType:
+		if src.S3 == nil || src.S3.Bucket == "" {
+			return nil, errors.New("bucket must be defined for the S3 source")
+		}
+
+		return newS3Source(src.S3, src.Checksum)
+
+	case urlSourceType:
+		return newURLSource(src.URL, src.Checksum)
+	}
+
+	return nil, errors.Errorf("unknown source type given: %q", src.Type)
+}
+
+type s3Source struct {
+	cfg      *S3Source
+	checksum string
+	svc      *s3.S3
+}
+
+func newS3Source(cfg *S3Source, checksum string) (*s3Source, error) {
+	awsCfg := &aws.Config{
+		Region:           aws.String(cfg.Region),
+		S3ForcePathStyle: aws.Bool(cfg.ForcePathStyle),
+	}
+
+	if cfg.Endpoint != "" {
+		awsCfg.Endpoint = aws.String(cfg.Endpoint)
+	}
+
+	awsSession, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to start AWS session")
+	}
+
+	return &s3Source{cfg: cfg, checksum: checksum, svc: s3.New(awsSession)}, nil
+}
+
+// list returns dumps stored under the configured prefix along with checksums found in sidecar files.
+func (s *s3Source) list(ctx context.Context) ([]remoteDump, error) {
+	keys := []string{}
+	checksumKeys := make(map[string]string)
+
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(s.cfg.Bucket), Prefix: aws.String(s.cfg.Prefix)}
+
+	if err := s.svc.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, object := range page.Contents {
+			key := aws.StringValue(object.Key)
+
+			switch {
+			case strings.HasSuffix(key, "/"):
+				continue
+
+			case strings.HasSuffix(key, checksumExtension):
+				checksumKeys[strings.TrimSuffix(key, checksumExtension)] = key
+
+			default:
+				keys = append(keys, key)
+			}
+		}
+
+		return true
+	}); err != nil {
+		return nil, errors.Wrapf(err, "failed to list objects in bucket %q", s.cfg.Bucket)
+	}
+
+	dumps := make([]remoteDump, 0, len(keys))
+
+	for _, key := range keys {
+		dump := remoteDump{name: dumpNameFromKey(s.cfg.Prefix, key), key: key}
+
+		if checksumKey, ok := checksumKeys[key]; ok {
+			checksum, err := s.readChecksum(ctx, checksumKey)
+			if err != nil {
+				return nil, err
+			}
+
+			dump.checksum = checksum
+		}
+
+		dumps = append(dumps, dump)
+	}
+
+	if len(dumps) == 1 && s.checksum != "" {
+		dumps[0].checksum = s.checksum
+	}
+
+	return dumps, nil
+}
+
+func (s *s3Source) readChecksum(ctx context.Context, key string) (string, error) {
+	body, err := s.open(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() { _ = body.Close() }()
+
+	return readChecksumFile(body)
+}
+
+func (s *s3Source) open(ctx context.Context, key string) (io.ReadCloser, error) {
+	output, err := s.svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.cfg.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get object %q", key)
+	}
+
+	return output.Body, nil
+}
+
+type urlSource struct {
+	location *url.URL
+	checksum string
+	client   *http.Client
+}
+
+func newURLSource(location, checksum string) (*urlSource, error) {
+	parsedURL, err := url.Parse(location)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid dump URL")
+	}
+
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return nil, errors.Errorf("unsupported scheme of the dump URL: %q", parsedURL.Scheme)
+	}
+
+	return &urlSource{location: parsedURL, checksum: checksum, client: http.DefaultClient}, nil
+}
+
+func (u *urlSource) list(_ context.Context) ([]remoteDump, error) {
+	return []remoteDump{{
+		name:     path.Base(u.location.Path),
+		key:      u.location.String(),
+		checksum: u.checksum,
+	}}, nil
+}
+
+func (u *urlSource) open(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to download dump")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, errors.Errorf("failed to download dump: unexpected status %s", resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// dumpNameFromKey builds a dump name relative to the prefix.
+func dumpNameFromKey(prefix, key string) string {
+	name := strings.TrimLeft(strings.TrimPrefix(key, prefix), "/")
+
+	if name == "" || strings.Contains(name, "/") {
+		return path.Base(key)
+	}
+
+	return name
+}
+
+// readChecksumFile reads a checksum in the format produced by sha256sum.
+func readChecksumFile(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", errors.Wrap(err, "failed to read checksum file")
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", errors.New("checksum file is empty")
+	}
+
+	return fields[0], nil
+}
+
+// parseChecksum validates the expected SHA-256 checksum and returns its raw value.
+func parseChecksum(checksum string) ([]byte, error) {
+	value := strings.ToLower(strings.TrimSpace(checksum))
+
+	if strings.Contains(value, ":") {
+		if !strings.HasPrefix(value, sha256ChecksumPrefix) {
+			return nil, errors.Errorf("unsupported checksum algorithm: %q. Only sha256 is supported", checksum)
+		}
+
+		value = strings.TrimPrefix(value, sha256ChecksumPrefix)
+	}
+
+	sum, err := hex.DecodeString(value)
+	if err != nil || len(sum) != sha256.Size {
+		return nil, errors.Errorf("invalid sha256 checksum: %q", checksum)
+	}
+
+	return sum, nil
+}
+
+// verifyChecksum compares the expected checksum with the calculated one.
+func verifyChecksum(expected string, actual []byte) error {
+	sum, err := parseChecksum(expected)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(sum, actual) {
+		return errors.Errorf("checksum mismatch: expected %x, got %x", sum, actual)
+	}
+
+	log.Dbg(fmt.Sprintf("Checksum verified: %x", actual))
+
+	return nil
+}
+
+// detectDumpDefinition identifies a dump type by its leading bytes.
+// It follows exploreDumpFile, but works with streams, so a custom-format header is parsed directly.
+func detectDumpDefinition(header []byte) (*DumpDefinition, error) {
+	if isCustomDump(header) {
+		dumpHeader, err := parseCustomDumpHeader(header)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse the custom dump header")
+		}
+
+		return &DumpDefinition{
+			Format: customFormat,
+			dbName: dumpHeader.dbName,
+		}, nil
+	}
+
+	dbDefinition := &DumpDefinition{
+		Format:      plainFormat,
+		Compression: detectCompressionType(header),
+	}
+
+	if dbDefinition.Compression != noCompression {
+		return dbDefinition, nil
+	}
+
+	dbName, err := parsePlainDump(bytes.NewReader(header))
+	if err != nil {
+		if errors.Is(err, errDBNameNotFound) {
+			return dbDefinition, nil
+		}
+
+		return nil, err
+	}
+
+	dbDefinition.dbName = dbName
+
+	return dbDefinition, nil
+}
@@ -0,0 +1,291 @@
+/*
+2021 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/config/global"
+)
+
+const plainDumpContent = `--
+-- PostgreSQL database dump
+--
+
+CREATE TABLE public.test (id integer);
+`
+
+func TestVerifyChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte(plainDumpContent))
+	hexSum := hex.EncodeToString(sum[:])
+
+	testCases := []struct {
+		expected string
+		valid    bool
+	}{
+		{expected: hexSum, valid: true},
+		{expected: "sha256:" + hexSum, valid: true},
+		{expected: "SHA256:" + strings.ToUpper(hexSum), valid: true},
+		{expected: "md5:" + hexSum, valid: false},
+		{expected: "sha256:abcdef", valid: false},
+		{expected: strings.Repeat("0", 64), valid: false},
+	}
+
+	for _, tc := range testCases {
+		err := verifyChecksum(tc.expected, sum[:])
+
+		if tc.valid {
+			assert.NoError(t, err, tc.expected)
+		} else {
+			assert.Error(t, err, tc.expected)
+		}
+	}
+}
+
+func TestReadChecksumFile(t *testing.T) {
+	checksum, err := readChecksumFile(strings.NewReader("0a1b2c  dump.sql.gz\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "0a1b2c", checksum)
+
+	_, err = readChecksumFile(strings.NewReader(""))
+	assert.Error(t, err)
+}
+
+func TestDumpNameFromKey(t *testing.T) {
+	testCases := []struct {
+		prefix string
+		key    string
+		name   string
+	}{
+		{prefix: "dumps/", key: "dumps/db1.dump", name: "db1.dump"},
+		{prefix: "dumps", key: "dumps/db1.dump", name: "db1.dump"},
+		{prefix: "dumps/db1.dump", key: "dumps/db1.dump", name: "db1.dump"},
+		{prefix: "", key: "dumps/nested/db1.dump", name: "db1.dump"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.name, dumpNameFromKey(tc.prefix, tc.key))
+	}
+}
+
+func TestDetectDumpDefinition(t *testing.T) {
+	testCases := []struct {
+		name       string
+		header     []byte
+		definition DumpDefinition
+	}{
+		{
+			name:       "custom",
+			header:     buildCustomDumpHeader(14, "testdb", time.Now()),
+			definition: DumpDefinition{Format: customFormat, dbName: "testdb"},
+		},
+		{
+			name:       "gzip",
+			header:     []byte{0x1f, 0x8b, 0x08},
+			definition: DumpDefinition{Format: plainFormat, Compression: gzipCompression},
+		},
+		{
+			name:       "plain without database",
+			header:     []byte(plainDumpContent),
+			definition: DumpDefinition{Format: plainFormat, Compression: noCompression},
+		},
+		{
+			name:       "plain with database",
+			header:     []byte("CREATE DATABASE test;\n\\connect test\n"),
+			definition: DumpDefinition{Format: plainFormat, Compression: noCompression, dbName: "test"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Log(tc.name)
+
+		definition, err := detectDumpDefinition(tc.header)
+		require.NoError(t, err)
+		assert.Equal(t, tc.definition, *definition)
+	}
+}
+
+func TestStreamRestoreCommand(t *testing.T) {
+	r := &RestoreJob{
+		globalCfg: &global.Config{Database: global.Database{Username: "john", DBName: "testdb"}},
+		RestoreOptions: RestoreOptions{
+			ParallelJobs: 4,
+			ForceInit:    true,
+		},
+	}
+
+	assert.Equal(t,
+		[]string{"pg_restore", "--username", "john", "--dbname", "postgres", "--no-privileges", "--no-owner", "--create",
+			"--clean", "--if-exists", "--table", "users"},
+		r.buildStreamRestoreCommand("db.dump", DumpDefinition{Format: customFormat, dbName: "db", Tables: []string{"users"}}),
+	)
+
+	assert.Equal(t,
+		[]string{"sh", "-c", "gunzip -c | psql --username john --dbname db_sql"},
+		r.buildStreamRestoreCommand("db.sql.gz", DumpDefinition{Format: plainFormat, Compression: gzipCompression}),
+	)
+}
+
+func TestURLSource(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dumps/db.sql" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(plainDumpContent))
+	}))
+	defer ts.Close()
+
+	_, err := newDumpSource(RestoreSource{Type: urlSourceType, URL: "ftp://example.com/db.sql"})
+	assert.Error(t, err)
+
+	source, err := newDumpSource(RestoreSource{Type: urlSourceType, URL: ts.URL + "/dumps/db.sql", Checksum: "sha256:" + strings.Repeat("a", 64)})
+	require.NoError(t, err)
+
+	dumps, err := source.list(context.Background())
+	require.NoError(t, err)
+	require.Len(t, dumps, 1)
+	assert.Equal(t, "db.sql", dumps[0].name)
+	assert.Equal(t, "sha256:"+strings.Repeat("a", 64), dumps[0].checksum)
+
+	body, err := source.open(context.Background(), dumps[0].key)
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, plainDumpContent, string(content))
+	require.NoError(t, body.Close())
+
+	_, err = source.open(context.Background(), ts.URL+"/unknown")
+	assert.Error(t, err)
+}
+
+func TestDownloadDump(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(plainDumpContent))
+	}))
+	defer ts.Close()
+
+	sum := sha256.Sum256([]byte(plainDumpContent))
+	dumpLocation := t.TempDir()
+
+	source, err := newDumpSource(RestoreSource{Type: urlSourceType, URL: ts.URL + "/db.sql"})
+	require.NoError(t, err)
+
+	r := &RestoreJob{dumpSource: source, RestoreOptions: RestoreOptions{DumpLocation: dumpLocation}}
+
+	dumpFile, err := r.downloadDump(context.Background(), remoteDump{key: ts.URL + "/db.sql", checksum: hex.EncodeToString(sum[:])})
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(dumpFile)
+	require.NoError(t, err)
+	assert.Equal(t, plainDumpContent, string(content))
+	require.NoError(t, dumpFile.Close())
+
+	_, err = r.downloadDump(context.Background(), remoteDump{key: ts.URL + "/db.sql", checksum: strings.Repeat("a", 64)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch")
+
+	// Only the verified download is left.
+	files, err := os.ReadDir(dumpLocation)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestVerifyStreamedChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte(plainDumpContent))
+
+	for _, tc := range []struct {
+		checksum string
+		valid    bool
+	}{
+		{checksum: hex.EncodeToString(sum[:]), valid: true},
+		{checksum: strings.Repeat("a", 64), valid: false},
+	} {
+		dumpHash := sha256.New()
+		input := io.TeeReader(strings.NewReader(plainDumpContent), dumpHash)
+
+		// The restore command reads only a part of the dump.
+		_, err := io.ReadFull(input, make([]byte, 10))
+		require.NoError(t, err)
+
+		err = verifyStreamedChecksum(tc.checksum, input, dumpHash)
+
+		if tc.valid {
+			assert.NoError(t, err)
+			continue
+		}
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "checksum mismatch")
+	}
+}
+
+func TestRestoredDatabases(t *testing.T) {
+	assert.Equal(t, []string{"db", "my db"},
+		restoredDatabases([]string{"postgres", "test"}, []string{"postgres", "db", "test", "my db"}))
+	assert.Empty(t, restoredDatabases([]string{"postgres"}, []string{"postgres"}))
+}
+
+func TestS3Source(t *testing.T) {
+	const listResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>backups</Name><Prefix>dumps/</Prefix><KeyCount>4</KeyCount><MaxKeys>1000</MaxKeys><IsTruncated>false</IsTruncated>
+  <Contents><Key>dumps/</Key><Size>0</Size></Contents>
+  <Contents><Key>dumps/db1.dump</Key><Size>10</Size></Contents>
+  <Contents><Key>dumps/db1.dump.sha256</Key><Size>10</Size></Contents>
+  <Contents><Key>dumps/db2.sql.gz</Key><Size>10</Size></Contents>
+</ListBucketResult>`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/backups" && r.URL.Query().Get("list-type") == "2":
+			_, _ = w.Write([]byte(listResponse))
+
+		case r.URL.Path == "/backups/dumps/db1.dump.sha256":
+			_, _ = fmt.Fprintln(w, "0a1b2c  db1.dump")
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	awsSession, err := session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String(ts.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("minio", "minio123", ""),
+	})
+	require.NoError(t, err)
+
+	source := &s3Source{cfg: &S3Source{Bucket: "backups", Prefix: "dumps/"}, svc: s3.New(awsSession)}
+
+	dumps, err := source.list(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []remoteDump{
+		{name: "db1.dump", key: "dumps/db1.dump", checksum: "0a1b2c"},
+		{name: "db2.sql.gz", key: "dumps/db2.sql.gz"},
+	}, dumps)
+}
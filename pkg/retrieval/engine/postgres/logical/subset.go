@@ -0,0 +1,775 @@
+/*
+2021 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/db"
+)
+
+const (
+	// defaultSchema defines a schema of tables given without a schema name.
+	defaultSchema = "public"
+
+	// fullTablePredicate selects all rows of a table.
+	fullTablePredicate = "true"
+
+	// pg_dump sections.
+	preDataSection  = "pre-data"
+	dataSection     = "data"
+	postDataSection = "post-data"
+
+	subsetTablesQuery = `select n.nspname, c.relname
+from pg_class c
+join pg_namespace n on n.oid = c.relnamespace
+where c.relkind in ('r', 'p')
+  and not c.relispartition
+  and n.nspname not in ('pg_catalog', 'information_schema')
+  and n.nspname not like 'pg_toast%'`
+
+	subsetPartitionedTablesQuery = `select n.nspname, c.relname
+from pg_class c
+join pg_namespace n on n.oid = c.relnamespace
+where c.relkind = 'p'
+  and not c.relispartition`
+
+	subsetSequencesQuery = `select n.nspname, c.relname
+from pg_class c
+join pg_namespace n on n.oid = c.relnamespace
+where c.relkind = 'S'
+  and n.nspname not in ('pg_catalog', 'information_schema')`
+
+	subsetRelationsQuery = `select c.conname,
+  cn.nspname, cl.relname,
+  array(select a.attname from unnest(c.conkey) with ordinality k(attnum, n)
+    join pg_attribute a on a.attrelid = c.conrelid and a.attnum = k.attnum order by k.n)::text[],
+  pn.nspname, pl.relname,
+  array(select a.attname from unnest(c.confkey) with ordinality k(attnum, n)
+    join pg_attribute a on a.attrelid = c.confrelid and a.attnum = k.attnum order by k.n)::text[]
+from pg_constraint c
+join pg_class cl on cl.oid = c.conrelid
+join pg_namespace cn on cn.oid = cl.relnamespace
+join pg_class pl on pl.oid = c.confrelid
+join pg_namespace pn on pn.oid = pl.relnamespace
+where c.contype = 'f'
+order by c.conname`
+)
+
+// Subset defines rules to take a referentially consistent subset of a database.
+type Subset struct {
+	Roots       []SubsetRoot `yaml:"roots"`
+	Passthrough []string     `yaml:"passthrough"`
+}
+
+// SubsetRoot defines a table the subset starts from.
+type SubsetRoot struct {
+	Table   string  `yaml:"table"`
+	Where   string  `yaml:"where"`
+	Percent float64 `yaml:"percent"`
+}
+
+// tableName describes a qualified table name.
+type tableName struct {
+	schema string
+	name   string
+}
+
+func parseTableName(table string) tableName {
+	if i := strings.Index(table, "."); i > 0 {
+		return tableName{schema: table[:i], name: table[i+1:]}
+	}
+
+	return tableName{schema: defaultSchema, name: table}
+}
+
+// String returns a quoted table name.
+func (t tableName) String() string {
+	return pgx.Identifier{t.schema, t.name}.Sanitize()
+}
+
+// fkRelation describes a foreign key between tables.
+type fkRelation struct {
+	name          string
+	child         tableName
+	childColumns  []string
+	parent        tableName
+	parentColumns []string
+}
+
+// subsetTable describes rows of the table to be copied.
+type subsetTable struct {
+	table     tableName
+	predicate string
+
+	// sets contains row sets the predicate refers to, ordered so that every set goes after the sets it refers to.
+	sets []subsetSet
+}
+
+// subsetSet describes rows of a table selected once and referred to by name from predicates of other tables,
+// so the selection is not repeated for every foreign key path leading to the table.
+type subsetSet struct {
+	name      string
+	table     tableName
+	predicate string
+	refs      []string
+}
+
+// selection describes a predicate and names of row sets it refers to.
+type selection struct {
+	predicate string
+	refs      []string
+}
+
+// subsetSets registers row sets in the order of their creation. A set may refer only to sets created before it.
+type subsetSets struct {
+	sets  []subsetSet
+	index map[string]int
+}
+
+// add registers the set of rows of the table. The same selection of the table is registered once.
+func (s *subsetSets) add(table tableName, sel selection) string {
+	for _, set := range s.sets {
+		if set.table == table && set.predicate == sel.predicate {
+			return set.name
+		}
+	}
+
+	name := "subset_" + strconv.Itoa(len(s.sets)+1)
+
+	if s.index == nil {
+		s.index = make(map[string]int)
+	}
+
+	s.index[name] = len(s.sets)
+	s.sets = append(s.sets, subsetSet{name: name, table: table, predicate: sel.predicate, refs: sel.refs})
+
+	return name
+}
+
+// resolve returns the sets referred to directly or through other sets, in the order of their creation.
+func (s *subsetSets) resolve(refs []string) []subsetSet {
+	used := make(map[int]bool)
+	stack := append([]string(nil), refs...)
+
+	for len(stack) > 0 {
+		name := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		i := s.index[name]
+		if used[i] {
+			continue
+		}
+
+		used[i] = true
+
+		stack = append(stack, s.sets[i].refs...)
+	}
+
+	if len(used) == 0 {
+		return nil
+	}
+
+	resolved := make([]subsetSet, 0, len(used))
+
+	for i := range s.sets {
+		if used[i] {
+			resolved = append(resolved, s.sets[i])
+		}
+	}
+
+	return resolved
+}
+
+func (s *Subset) validate() error {
+	if len(s.Roots) == 0 && len(s.Passthrough) == 0 {
+		return errors.New("subset must define at least one root or passthrough table")
+	}
+
+	for _, root := range s.Roots {
+		if root.Table == "" {
+			return errors.New("subset root table must not be empty")
+		}
+
+		if root.Percent < 0 || root.Percent > 100 {
+			return errors.Errorf("invalid sampling percent of the table %q: %v", root.Table, root.Percent)
+		}
+	}
+
+	return nil
+}
+
+// rootPredicate builds a predicate selecting root rows.
+func (r SubsetRoot) rootPredicate(table tableName, partitioned bool) string {
+	if r.Percent == 0 || r.Percent == 100 {
+		if r.Where == "" {
+			return fullTablePredicate
+		}
+
+		return "(" + r.Where + ")"
+	}
+
+	sample := fmt.Sprintf("select %s from %s tablesample bernoulli (%s) repeatable (0)", strings.Join(rowIdentity(partitioned), ", "),
+		table, strconv.FormatFloat(r.Percent, 'f', -1, 64))
+
+	if r.Where != "" {
+		sample += " where " + r.Where
+	}
+
+	return rowsPredicate(partitioned, sample)
+}
+
+// rowIdentity returns system columns identifying rows of the table.
+// A ctid is unique only within a single relation, so rows of partitioned tables are also identified by the partition.
+func rowIdentity(partitioned bool) []string {
+	if partitioned {
+		return []string{"tableoid", "ctid"}
+	}
+
+	return []string{"ctid"}
+}
+
+// rowsPredicate selects rows identified by the subquery returning columns of rowIdentity.
+func rowsPredicate(partitioned bool, subquery string) string {
+	if partitioned {
+		return "(tableoid, ctid) in (" + subquery + ")"
+	}
+
+	return "ctid = any(array(" + subquery + "))"
+}
+
+// buildSubsetPlan selects rows of every table so that the result does not break foreign keys.
+//
+// Starting from the roots, it follows foreign keys down to collect dependent rows once per table.
+// Then it walks tables from children to parents and adds all referenced rows.
+// Self-referencing tables are closed with a recursive query, tables involved in multi-table cycles are copied entirely.
+// Rows selected for a table are referred to as a named set by predicates of related tables.
+func buildSubsetPlan(subset *Subset, tables []tableName, partitioned map[tableName]bool, relations []fkRelation) (
+	[]subsetTable, error) {
+	known := make(map[tableName]struct{}, len(tables))
+	for _, table := range tables {
+		known[table] = struct{}{}
+	}
+
+	clauses := make(map[tableName][]selection)
+	full := make(map[tableName]bool)
+	queue := []tableName{}
+	sets := &subsetSets{}
+
+	for _, root := range subset.Roots {
+		table := parseTableName(root.Table)
+		if _, ok := known[table]; !ok {
+			return nil, errors.Errorf("subset root table %s not found", table)
+		}
+
+		if _, ok := clauses[table]; !ok {
+			queue = append(queue, table)
+		}
+
+		clauses[table] = append(clauses[table], selection{predicate: root.rootPredicate(table, partitioned[table])})
+	}
+
+	for _, passthrough := range subset.Passthrough {
+		table := parseTableName(passthrough)
+		if _, ok := known[table]; !ok {
+			return nil, errors.Errorf("subset passthrough table %s not found", table)
+		}
+
+		full[table] = true
+	}
+
+	// Downward pass: collect rows referencing the selected ones.
+	expanded := make(map[tableName]string)
+
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+
+		parentSet := sets.add(parent, orSelections(clauses[parent]))
+		expanded[parent] = parentSet
+
+		for _, rel := range relations {
+			if rel.parent != parent || rel.child == parent {
+				continue
+			}
+
+			if _, ok := expanded[rel.child]; ok || full[rel.child] {
+				continue
+			}
+
+			if _, ok := clauses[rel.child]; !ok {
+				queue = append(queue, rel.child)
+			}
+
+			clauses[rel.child] = append(clauses[rel.child],
+				selection{predicate: referencingPredicate(rel, parentSet), refs: []string{parentSet}})
+		}
+	}
+
+	// Upward pass: add rows referenced by the selected ones. Referencing tables go first.
+	components := findComponents(tables, relations)
+	final := make(map[tableName]selection)
+
+	for i := len(components) - 1; i >= 0; i-- {
+		component := components[i]
+
+		// Tables of a foreign key cycle cannot be closed by a simple query, so take them entirely.
+		if len(component) > 1 && isComponentSelected(component, clauses, full) {
+			log.Msg("Foreign key cycle found, the tables are copied entirely: ", component)
+
+			for _, table := range component {
+				full[table] = true
+			}
+		}
+
+		for _, table := range component {
+			switch {
+			case full[table]:
+				final[table] = selection{predicate: fullTablePredicate}
+
+			case len(clauses[table]) > 0:
+				final[table] = closeSelfReferences(table, partitioned[table], orSelections(clauses[table]), relations)
+
+			default:
+				continue
+			}
+
+			tableSet := ""
+
+			for _, rel := range relations {
+				if rel.child != table || rel.parent == table || full[rel.parent] {
+					continue
+				}
+
+				// Rows selected only because they reference the parent rows do not need them to be added again.
+				if parentSet, ok := expanded[rel.parent]; ok && final[table].predicate == referencingPredicate(rel, parentSet) {
+					continue
+				}
+
+				if tableSet == "" {
+					tableSet = sets.add(table, final[table])
+				}
+
+				clauses[rel.parent] = append(clauses[rel.parent],
+					selection{predicate: referencedPredicate(rel, tableSet), refs: []string{tableSet}})
+			}
+		}
+	}
+
+	plan := make([]subsetTable, 0, len(final))
+
+	for table, sel := range final {
+		plan = append(plan, subsetTable{table: table, predicate: sel.predicate, sets: sets.resolve(sel.refs)})
+	}
+
+	sort.Slice(plan, func(i, j int) bool {
+		return plan[i].table.String() < plan[j].table.String()
+	})
+
+	return plan, nil
+}
+
+// orSelections joins predicates. A full table predicate absorbs others.
+func orSelections(selections []selection) selection {
+	predicates := make([]string, 0, len(selections))
+	refs := []string{}
+
+	for _, sel := range selections {
+		if sel.predicate == fullTablePredicate {
+			return selection{predicate: fullTablePredicate}
+		}
+
+		predicates = append(predicates, sel.predicate)
+		refs = append(refs, sel.refs...)
+	}
+
+	return selection{predicate: strings.Join(predicates, " or "), refs: refs}
+}
+
+// referencingPredicate selects child rows that reference the rows of the parent set.
+func referencingPredicate(rel fkRelation, parentSet string) string {
+	return fmt.Sprintf("(%s) in (select %s from %s)", quoteColumns(rel.childColumns), quoteColumns(rel.parentColumns), parentSet)
+}
+
+// referencedPredicate selects parent rows referenced by the rows of the child set.
+func referencedPredicate(rel fkRelation, childSet string) string {
+	return fmt.Sprintf("(%s) in (select %s from %s)", quoteColumns(rel.parentColumns), quoteColumns(rel.childColumns), childSet)
+}
+
+// closeSelfReferences extends the selection with rows referenced through a self-referencing foreign key.
+func closeSelfReferences(table tableName, partitioned bool, sel selection, relations []fkRelation) selection {
+	if sel.predicate == fullTablePredicate {
+		return sel
+	}
+
+	var selfRelation *fkRelation
+
+	for i := range relations {
+		if relations[i].child != table || relations[i].parent != table {
+			continue
+		}
+
+		if selfRelation != nil {
+			// Several recursive terms are not allowed, so fall back to the full table.
+			return selection{predicate: fullTablePredicate}
+		}
+
+		selfRelation = &relations[i]
+	}
+
+	if selfRelation == nil {
+		return sel
+	}
+
+	identity := rowIdentity(partitioned)
+	rowAliases := make([]string, 0, len(identity))
+	recursiveIdentity := make([]string, 0, len(identity))
+
+	for _, column := range identity {
+		rowAliases = append(rowAliases, "row_"+column)
+		recursiveIdentity = append(recursiveIdentity, "t."+column)
+	}
+
+	fkAliases := make([]string, 0, len(selfRelation.childColumns))
+	recursiveColumns := make([]string, 0, len(selfRelation.childColumns))
+	joinConditions := make([]string, 0, len(selfRelation.childColumns))
+
+	for i, column := range selfRelation.childColumns {
+		alias := "fk_" + strconv.Itoa(i+1)
+		fkAliases = append(fkAliases, alias)
+		recursiveColumns = append(recursiveColumns, "t."+pgx.Identifier{column}.Sanitize())
+		joinConditions = append(joinConditions,
+			fmt.Sprintf("t.%s = s.%s", pgx.Identifier{selfRelation.parentColumns[i]}.Sanitize(), alias))
+	}
+
+	recursiveQuery := fmt.Sprintf("with recursive s(%s, %s) as (select %s, %s from %s where %s "+
+		"union select %s, %s from %s t join s on %s) select %s from s",
+		strings.Join(rowAliases, ", "), strings.Join(fkAliases, ", "),
+		strings.Join(identity, ", "), quoteColumns(selfRelation.childColumns), table, sel.predicate,
+		strings.Join(recursiveIdentity, ", "), strings.Join(recursiveColumns, ", "), table, strings.Join(joinConditions, " and "),
+		strings.Join(rowAliases, ", "))
+
+	return selection{predicate: rowsPredicate(partitioned, recursiveQuery), refs: sel.refs}
+}
+
+func quoteColumns(columns []string) string {
+	quoted := make([]string, 0, len(columns))
+
+	for _, column := range columns {
+		quoted = append(quoted, pgx.Identifier{column}.Sanitize())
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+func isComponentSelected(component []tableName, clauses map[tableName][]selection, full map[tableName]bool) bool {
+	for _, table := range component {
+		if len(clauses[table]) > 0 || full[table] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findComponents groups tables referencing each other through foreign keys (Tarjan's algorithm).
+// Groups are ordered so that referenced tables go before referencing ones.
+func findComponents(tables []tableName, relations []fkRelation) [][]tableName {
+	index := 0
+	indexes := make(map[tableName]int)
+	lowLinks := make(map[tableName]int)
+	onStack := make(map[tableName]bool)
+	stack := []tableName{}
+	components := [][]tableName{}
+
+	var connect func(table tableName)
+
+	connect = func(table tableName) {
+		indexes[table] = index
+		lowLinks[table] = index
+		index++
+
+		stack = append(stack, table)
+		onStack[table] = true
+
+		for _, rel := range relations {
+			if rel.child != table || rel.parent == table {
+				continue
+			}
+
+			if _, ok := indexes[rel.parent]; !ok {
+				connect(rel.parent)
+
+				if lowLinks[rel.parent] < lowLinks[table] {
+					lowLinks[table] = lowLinks[rel.parent]
+				}
+			} else if onStack[rel.parent] && indexes[rel.parent] < lowLinks[table] {
+				lowLinks[table] = indexes[rel.parent]
+			}
+		}
+
+		if lowLinks[table] != indexes[table] {
+			return
+		}
+
+		component := []tableName{}
+
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[last] = false
+			component = append(component, last)
+
+			if last == table {
+				break
+			}
+		}
+
+		components = append(components, component)
+	}
+
+	for _, table := range tables {
+		if _, ok := indexes[table]; !ok {
+			connect(table)
+		}
+	}
+
+	return components
+}
+
+// dumpSubset loads a referentially consistent subset of the database into the restored instance.
+func (d *DumpJob) dumpSubset(ctx context.Context, dumpContID, dbName string, subset *Subset) error {
+	log.Msg(fmt.Sprintf("Subset of the database %q will be restored", dbName))
+
+	if output, err := d.performDumpCommand(ctx, dumpContID, types.ExecConfig{
+		Tty: true,
+		Cmd: d.buildSectionDumpCommand(dbName, preDataSection, nil),
+		Env: d.getExecEnvironmentVariables(),
+	}); err != nil {
+		log.Dbg(output)
+		return errors.Wrap(err, "failed to restore schema")
+	}
+
+	connStr := db.ConnectionString(d.config.db.Host, strconv.Itoa(d.config.db.Port), d.config.db.Username, dbName, d.getPassword())
+
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to connect to DB: %s", dbName)
+	}
+
+	defer func() { _ = conn.Close(ctx) }()
+
+	// All tables are read from the same snapshot to keep foreign keys consistent.
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tables, err := queryTableNames(ctx, tx, subsetTablesQuery)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tables")
+	}
+
+	partitionedTables, err := queryTableNames(ctx, tx, subsetPartitionedTablesQuery)
+	if err != nil {
+		return errors.Wrap(err, "failed to get partitioned tables")
+	}
+
+	partitioned := make(map[tableName]bool, len(partitionedTables))
+	for _, table := range partitionedTables {
+		partitioned[table] = true
+	}
+
+	relations, err := queryRelations(ctx, tx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get foreign keys")
+	}
+
+	plan, err := buildSubsetPlan(subset, tables, partitioned, relations)
+	if err != nil {
+		return errors.Wrap(err, "failed to build a subset plan")
+	}
+
+	for _, table := range plan {
+		if err := d.copySubsetTable(ctx, tx, dumpContID, dbName, table); err != nil {
+			return errors.Wrapf(err, "failed to copy table %s", table.table)
+		}
+	}
+
+	sequences, err := queryTableNames(ctx, tx, subsetSequencesQuery)
+	if err != nil {
+		return errors.Wrap(err, "failed to get sequences")
+	}
+
+	if err := d.setupConnectionOptions(ctx); err != nil {
+		return errors.Wrap(err, "failed to refresh connection options")
+	}
+
+	if len(sequences) > 0 {
+		if output, err := d.performDumpCommand(ctx, dumpContID, types.ExecConfig{
+			Tty: true,
+			Cmd: d.buildSectionDumpCommand(dbName, dataSection, sequences),
+			Env: d.getExecEnvironmentVariables(),
+		}); err != nil {
+			log.Dbg(output)
+			return errors.Wrap(err, "failed to restore sequences")
+		}
+	}
+
+	if output, err := d.performDumpCommand(ctx, dumpContID, types.ExecConfig{
+		Tty: true,
+		Cmd: d.buildSectionDumpCommand(dbName, postDataSection, nil),
+		Env: d.getExecEnvironmentVariables(),
+	}); err != nil {
+		log.Dbg(output)
+		return errors.Wrap(err, "failed to restore indexes and constraints")
+	}
+
+	return nil
+}
+
+// copySubsetTable streams selected rows from the source database into the restored one.
+func (d *DumpJob) copySubsetTable(ctx context.Context, tx pgx.Tx, dumpContID, dbName string, table subsetTable) error {
+	copyToQuery := fmt.Sprintf("copy (%s) to stdout", table.query())
+	log.Dbg("Subset query: ", copyToQuery)
+
+	pipeReader, pipeWriter := io.Pipe()
+	copyDone := make(chan error, 1)
+
+	go func() {
+		_, err := tx.Conn().PgConn().CopyTo(ctx, pipeWriter, copyToQuery)
+		_ = pipeWriter.CloseWithError(err)
+		copyDone <- err
+	}()
+
+	copyFromCmd := []string{"psql", "--username", d.globalCfg.Database.User(), "--dbname", dbName, "--set", "ON_ERROR_STOP=1",
+		"--command", fmt.Sprintf("copy %s from stdin", table.table)}
+
+	output, err := tools.ExecCommandWithInput(ctx, d.dockerClient, dumpContID, types.ExecConfig{Cmd: copyFromCmd}, pipeReader)
+
+	// Unblock the source query if the target command has stopped reading.
+	_ = pipeReader.CloseWithError(io.ErrClosedPipe)
+
+	if copyErr := <-copyDone; copyErr != nil {
+		return errors.Wrap(copyErr, "failed to read rows from the source")
+	}
+
+	if err != nil {
+		log.Dbg(output)
+		return errors.Wrap(err, "failed to load rows")
+	}
+
+	log.Msg(fmt.Sprintf("Table %s: %s", table.table, output))
+
+	return nil
+}
+
+// query builds a query selecting rows of the table. Row sets the predicate refers to are defined in the WITH clause.
+func (t subsetTable) query() string {
+	query := fmt.Sprintf("select * from %s where %s", t.table, t.predicate)
+
+	if len(t.sets) == 0 {
+		return query
+	}
+
+	definitions := make([]string, 0, len(t.sets))
+
+	for _, set := range t.sets {
+		definitions = append(definitions, fmt.Sprintf("%s as (select * from %s where %s)", set.name, set.table, set.predicate))
+	}
+
+	return "with " + strings.Join(definitions, ", ") + " " + query
+}
+
+func queryTableNames(ctx context.Context, tx pgx.Tx, query string) ([]tableName, error) {
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tables := []tableName{}
+
+	for rows.Next() {
+		var table tableName
+
+		if err := rows.Scan(&table.schema, &table.name); err != nil {
+			return nil, err
+		}
+
+		tables = append(tables, table)
+	}
+
+	return tables, rows.Err()
+}
+
+func queryRelations(ctx context.Context, tx pgx.Tx) ([]fkRelation, error) {
+	rows, err := tx.Query(ctx, subsetRelationsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	relations := []fkRelation{}
+
+	for rows.Next() {
+		var rel fkRelation
+
+		if err := rows.Scan(&rel.name, &rel.child.schema, &rel.child.name, &rel.childColumns,
+			&rel.parent.schema, &rel.parent.name, &rel.parentColumns); err != nil {
+			return nil, err
+		}
+
+		relations = append(relations, rel)
+	}
+
+	return relations, rows.Err()
+}
+
+// buildSectionDumpCommand builds a command to dump and restore a single section of the database.
+func (d *DumpJob) buildSectionDumpCommand(dbName, section string, tables []tableName) []string {
+	optionalArgs := map[string]string{
+		"--host":     d.config.db.Host,
+		"--port":     strconv.Itoa(d.config.db.Port),
+		"--username": d.config.db.Username,
+		"--dbname":   dbName,
+	}
+
+	dumpCmd := append([]string{"pg_dump", "--section", section, "--format", customFormat}, prepareCmdOptions(optionalArgs)...)
+
+	for _, table := range tables {
+		dumpCmd = append(dumpCmd, "--table", "'"+table.String()+"'")
+	}
+
+	if section == preDataSection {
+		dumpCmd = append(dumpCmd, "--create")
+		dumpCmd = append(dumpCmd, d.buildLogicalRestoreCommand(dbName)...)
+	} else {
+		// The database has already been created with the schema.
+		dumpCmd = append(dumpCmd, "|", "pg_restore", "--username", d.globalCfg.Database.User(), "--dbname", dbName,
+			"--no-privileges", "--no-owner")
+	}
+
+	cmd := strings.Join(dumpCmd, " ")
+
+	log.Dbg(cmd)
+
+	return []string{"sh", "-c", cmd}
+}
@@ -0,0 +1,206 @@
+/*
+2021 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTableName(t *testing.T) {
+	assert.Equal(t, tableName{schema: "public", name: "users"}, parseTableName("users"))
+	assert.Equal(t, tableName{schema: "sales", name: "orders"}, parseTableName("sales.orders"))
+	assert.Equal(t, `"sales"."Orders"`, parseTableName("sales.Orders").String())
+}
+
+func TestRootPredicate(t *testing.T) {
+	table := parseTableName("customers")
+
+	testCases := []struct {
+		root        SubsetRoot
+		partitioned bool
+		predicate   string
+	}{
+		{
+			root:      SubsetRoot{},
+			predicate: "true",
+		},
+		{
+			root:      SubsetRoot{Where: "region = 'EU'"},
+			predicate: "(region = 'EU')",
+		},
+		{
+			root:      SubsetRoot{Percent: 2.5},
+			predicate: `ctid = any(array(select ctid from "public"."customers" tablesample bernoulli (2.5) repeatable (0)))`,
+		},
+		{
+			root:        SubsetRoot{Percent: 2.5},
+			partitioned: true,
+			predicate:   `(tableoid, ctid) in (select tableoid, ctid from "public"."customers" tablesample bernoulli (2.5) repeatable (0))`,
+		},
+		{
+			root: SubsetRoot{Where: "region = 'EU'", Percent: 10},
+			predicate: `ctid = any(array(select ctid from "public"."customers" tablesample bernoulli (10) repeatable (0) ` +
+				`where region = 'EU'))`,
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.predicate, tc.root.rootPredicate(table, tc.partitioned))
+	}
+}
+
+func TestSubsetValidation(t *testing.T) {
+	assert.Error(t, (&Subset{}).validate())
+	assert.Error(t, (&Subset{Roots: []SubsetRoot{{Where: "id > 1"}}}).validate())
+	assert.Error(t, (&Subset{Roots: []SubsetRoot{{Table: "users", Percent: 120}}}).validate())
+	assert.NoError(t, (&Subset{Roots: []SubsetRoot{{Table: "users", Percent: 20}}}).validate())
+	assert.NoError(t, (&Subset{Passthrough: []string{"countries"}}).validate())
+}
+
+func TestBuildSubsetPlan(t *testing.T) {
+	customers, orders, items := parseTableName("customers"), parseTableName("orders"), parseTableName("order_items")
+	products, countries, employees := parseTableName("products"), parseTableName("countries"), parseTableName("employees")
+	logs := parseTableName("logs")
+
+	tables := []tableName{customers, orders, items, products, countries, employees, logs}
+	relations := []fkRelation{
+		{child: orders, childColumns: []string{"customer_id"}, parent: customers, parentColumns: []string{"id"}},
+		{child: items, childColumns: []string{"order_id"}, parent: orders, parentColumns: []string{"id"}},
+		{child: items, childColumns: []string{"product_id"}, parent: products, parentColumns: []string{"id"}},
+		{child: customers, childColumns: []string{"country_id"}, parent: countries, parentColumns: []string{"id"}},
+		{child: orders, childColumns: []string{"employee_id"}, parent: employees, parentColumns: []string{"id"}},
+		{child: employees, childColumns: []string{"manager_id"}, parent: employees, parentColumns: []string{"id"}},
+	}
+
+	subset := &Subset{
+		Roots:       []SubsetRoot{{Table: "customers", Where: "region = 'EU'"}},
+		Passthrough: []string{"countries"},
+	}
+
+	plan, err := buildSubsetPlan(subset, tables, nil, relations)
+	require.NoError(t, err)
+
+	queries := make(map[tableName]string)
+	for _, table := range plan {
+		queries[table.table] = table.query()
+	}
+
+	const (
+		customersSet = `subset_1 as (select * from "public"."customers" where (region = 'EU'))`
+		ordersSet    = `subset_2 as (select * from "public"."orders" where ("customer_id") in (select "id" from subset_1))`
+	)
+
+	assert.Len(t, queries, 6)
+	assert.NotContains(t, queries, logs)
+	assert.Equal(t, `select * from "public"."countries" where true`, queries[countries])
+	assert.Equal(t, `with `+customersSet+` select * from "public"."orders" where ("customer_id") in (select "id" from subset_1)`,
+		queries[orders])
+	assert.Equal(t, `with `+customersSet+`, `+ordersSet+` select * from "public"."order_items" where ("order_id") in `+
+		`(select "id" from subset_2)`, queries[items])
+	assert.Equal(t, `with `+customersSet+`, `+ordersSet+`, subset_3 as (select * from "public"."order_items" where ("order_id") in `+
+		`(select "id" from subset_2)) select * from "public"."products" where ("id") in (select "product_id" from subset_3)`,
+		queries[products])
+	// Orders and items reference only selected rows, so their parents are not extended.
+	assert.Equal(t, `select * from "public"."customers" where (region = 'EU')`, queries[customers])
+	assert.Equal(t,
+		`with `+customersSet+`, `+ordersSet+` select * from "public"."employees" where `+
+			`ctid = any(array(with recursive s(row_ctid, fk_1) as (select ctid, "manager_id" from "public"."employees" `+
+			`where ("id") in (select "employee_id" from subset_2) `+
+			`union select t.ctid, t."manager_id" from "public"."employees" t join s on t."id" = s.fk_1) select row_ctid from s))`,
+		queries[employees])
+
+	_, err = buildSubsetPlan(&Subset{Roots: []SubsetRoot{{Table: "unknown"}}}, tables, nil, relations)
+	assert.Error(t, err)
+}
+
+func TestBuildSubsetPlanWithDiamonds(t *testing.T) {
+	const diamonds = 20
+
+	root := parseTableName("t_0")
+	tables := []tableName{root}
+	relations := []fkRelation{}
+
+	// Every table is referenced by two tables which are both referenced by the next table.
+	for i := 0; i < diamonds; i++ {
+		parent := parseTableName(fmt.Sprintf("t_%d", i))
+		left, right := parseTableName(fmt.Sprintf("l_%d", i)), parseTableName(fmt.Sprintf("r_%d", i))
+		child := parseTableName(fmt.Sprintf("t_%d", i+1))
+
+		tables = append(tables, left, right, child)
+		relations = append(relations,
+			fkRelation{child: left, childColumns: []string{"parent_id"}, parent: parent, parentColumns: []string{"id"}},
+			fkRelation{child: right, childColumns: []string{"parent_id"}, parent: parent, parentColumns: []string{"id"}},
+			fkRelation{child: child, childColumns: []string{"left_id"}, parent: left, parentColumns: []string{"id"}},
+			fkRelation{child: child, childColumns: []string{"right_id"}, parent: right, parentColumns: []string{"id"}},
+		)
+	}
+
+	plan, err := buildSubsetPlan(&Subset{Roots: []SubsetRoot{{Table: "t_0", Where: "id < 10"}}}, tables, nil, relations)
+	require.NoError(t, err)
+	require.Len(t, plan, len(tables))
+
+	for _, table := range plan {
+		// Every set is defined once, so the query grows linearly with the number of tables.
+		assert.Less(t, len(table.query()), 300*len(tables), table.table.String())
+	}
+}
+
+func TestBuildSubsetPlanPartitioned(t *testing.T) {
+	events, sessions := parseTableName("events"), parseTableName("sessions")
+
+	tables := []tableName{events, sessions}
+	relations := []fkRelation{
+		{child: events, childColumns: []string{"session_id"}, parent: sessions, parentColumns: []string{"id"}},
+		{child: events, childColumns: []string{"parent_id"}, parent: events, parentColumns: []string{"id"}},
+	}
+
+	plan, err := buildSubsetPlan(&Subset{Roots: []SubsetRoot{{Table: "events", Percent: 5}}}, tables,
+		map[tableName]bool{events: true}, relations)
+	require.NoError(t, err)
+	require.Len(t, plan, 2)
+
+	assert.Equal(t, `(tableoid, ctid) in (with recursive s(row_tableoid, row_ctid, fk_1) as (select tableoid, ctid, "parent_id" `+
+		`from "public"."events" where (tableoid, ctid) in (select tableoid, ctid from "public"."events" tablesample bernoulli (5) `+
+		`repeatable (0)) union select t.tableoid, t.ctid, t."parent_id" from "public"."events" t join s on t."id" = s.fk_1) `+
+		`select row_tableoid, row_ctid from s)`, plan[0].predicate)
+	assert.Equal(t, `("id") in (select "session_id" from subset_2)`, plan[1].predicate)
+}
+
+func TestBuildSubsetPlanWithCycle(t *testing.T) {
+	users, teams, events := parseTableName("users"), parseTableName("teams"), parseTableName("events")
+
+	tables := []tableName{events, users, teams}
+	relations := []fkRelation{
+		{child: users, childColumns: []string{"team_id"}, parent: teams, parentColumns: []string{"id"}},
+		{child: teams, childColumns: []string{"owner_id"}, parent: users, parentColumns: []string{"id"}},
+		{child: events, childColumns: []string{"user_id"}, parent: users, parentColumns: []string{"id"}},
+	}
+
+	plan, err := buildSubsetPlan(&Subset{Roots: []SubsetRoot{{Table: "events", Percent: 1}}}, tables, nil, relations)
+	require.NoError(t, err)
+
+	require.Len(t, plan, 3)
+	assert.Equal(t, subsetTable{table: teams, predicate: "true"}, plan[1])
+	assert.Equal(t, subsetTable{table: users, predicate: "true"}, plan[2])
+}
+
+func TestFindComponents(t *testing.T) {
+	a, b, c, d := parseTableName("a"), parseTableName("b"), parseTableName("c"), parseTableName("d")
+
+	relations := []fkRelation{
+		{child: a, parent: b},
+		{child: b, parent: c},
+		{child: c, parent: b},
+		{child: d, parent: d},
+	}
+
+	components := findComponents([]tableName{a, b, c, d}, relations)
+
+	assert.Equal(t, [][]tableName{{c, b}, {a}, {d}}, components)
+}
@@ -0,0 +1,492 @@
+/*
+2021 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/config/global"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/config"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/cont"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/db"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/defaults"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/health"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/options"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/databases/postgres/pgconfig"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+)
+
+const (
+	// SyncJobType declares a job type for continuous logical synchronization.
+	SyncJobType = "logicalSync"
+
+	// defaultSlotPrefix defines the default prefix of replication slots and subscriptions.
+	defaultSlotPrefix = "dblab_sync"
+
+	// maxIdentifierLength defines the maximum length of Postgres identifiers (NAMEDATALEN - 1).
+	maxIdentifierLength = 63
+
+	// unknownLag defines the lag value reported when a subscription has not received any data yet.
+	unknownLag = -1
+
+	subscriptionStatusFields = 6
+)
+
+var invalidSlotNameChars = regexp.MustCompile(`[^a-z0-9_]`)
+
+// SyncJob declares a job keeping the restored data in sync with the source using logical replication.
+type SyncJob struct {
+	name         string
+	dockerClient *client.Client
+	fsPool       *resources.Pool
+	globalCfg    *global.Config
+	SyncOptions
+}
+
+// SyncOptions defines options of logical synchronization.
+type SyncOptions struct {
+	DockerImage     string                 `yaml:"dockerImage"`
+	ContainerConfig map[string]interface{} `yaml:"containerConfig"`
+	Configs         map[string]string      `yaml:"configs"`
+	Connection      Connection             `yaml:"connection"`
+	Publication     string                 `yaml:"publication"`
+	SlotPrefix      string                 `yaml:"slotPrefix"`
+	Databases       []string               `yaml:"databases"`
+}
+
+// NewSyncJob creates a new logical sync job.
+func NewSyncJob(cfg config.JobConfig, global *global.Config) (*SyncJob, error) {
+	syncJob := &SyncJob{
+		name:         cfg.Spec.Name,
+		dockerClient: cfg.Docker,
+		fsPool:       cfg.FSPool,
+		globalCfg:    global,
+	}
+
+	if err := syncJob.Reload(cfg.Spec.Options); err != nil {
+		return nil, errors.Wrap(err, "failed to load job config")
+	}
+
+	return syncJob, nil
+}
+
+// Name returns a name of the job.
+func (s *SyncJob) Name() string {
+	return s.name
+}
+
+// Reload reloads job configuration.
+func (s *SyncJob) Reload(cfg map[string]interface{}) error {
+	if err := options.Unmarshal(cfg, &s.SyncOptions); err != nil {
+		return errors.Wrap(err, "failed to unmarshal configuration options")
+	}
+
+	if s.Publication == "" {
+		return errors.New("publication must be defined for the logical sync")
+	}
+
+	if s.Connection.Port == 0 {
+		s.Connection.Port = defaults.Port
+	}
+
+	if s.Connection.Username == "" {
+		s.Connection.Username = defaults.Username
+	}
+
+	if s.SlotPrefix == "" {
+		s.SlotPrefix = defaultSlotPrefix
+	}
+
+	return nil
+}
+
+func (s *SyncJob) syncInstanceName() string {
+	return cont.SyncInstanceContainerPrefix + s.globalCfg.InstanceID
+}
+
+// Run starts the job.
+func (s *SyncJob) Run(ctx context.Context) (err error) {
+	log.Msg("Run job: ", s.Name())
+
+	dataDir := s.fsPool.DataDir()
+
+	isEmpty, err := tools.IsEmptyDirectory(dataDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to explore the data directory")
+	}
+
+	if isEmpty {
+		return errors.New("the data directory is empty. Restore data before running the logical sync")
+	}
+
+	cfgManager, err := pgconfig.NewCorrector(dataDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to create a config manager")
+	}
+
+	if err := cfgManager.ApplySync(s.Configs); err != nil {
+		return errors.Wrap(err, "cannot update sync instance configs")
+	}
+
+	contID, err := s.startSyncInstance(ctx, cfgManager.GetPgVersion())
+	if err != nil {
+		return errors.Wrap(err, "failed to start sync instance")
+	}
+
+	defer func() {
+		if err != nil {
+			tools.PrintContainerLogs(ctx, s.dockerClient, s.syncInstanceName())
+			tools.PrintLastPostgresLogs(ctx, s.dockerClient, s.syncInstanceName(), dataDir)
+		}
+	}()
+
+	log.Msg("Starting PostgreSQL and waiting for readiness")
+	log.Msg(fmt.Sprintf("View logs using the command: %s %s", tools.ViewLogsCmd, s.syncInstanceName()))
+
+	if err := tools.CheckContainerReadiness(ctx, s.dockerClient, contID); err != nil {
+		return errors.Wrap(err, "failed to readiness check")
+	}
+
+	dbList := s.Databases
+
+	if len(dbList) == 0 {
+		dbList, err = s.getDBList(ctx, contID)
+		if err != nil {
+			return errors.Wrap(err, "failed to get the list of databases to synchronize")
+		}
+	}
+
+	for _, dbName := range dbList {
+		if err := s.subscribe(ctx, contID, dbName); err != nil {
+			return errors.Wrapf(err, "failed to subscribe the database %q", dbName)
+		}
+	}
+
+	log.Msg("Sync instance has been running")
+
+	return nil
+}
+
+func (s *SyncJob) startSyncInstance(ctx context.Context, pgVersion float64) (string, error) {
+	syncContainer, err := s.dockerClient.ContainerInspect(ctx, s.syncInstanceName())
+	if err != nil && !client.IsErrNotFound(err) {
+		return "", errors.Wrap(err, "failed to inspect sync container")
+	}
+
+	if syncContainer.ContainerJSONBase != nil {
+		if syncContainer.State.Running {
+			log.Msg("Sync instance is already running")
+			return syncContainer.ID, nil
+		}
+
+		log.Msg("Removing non-running sync instance")
+
+		tools.RemoveContainer(ctx, s.dockerClient, syncContainer.ID, cont.StopPhysicalTimeout)
+	}
+
+	syncImage := s.DockerImage
+	if syncImage == "" {
+		syncImage = fmt.Sprintf("postgresai/extended-postgres:%g", pgVersion)
+	}
+
+	if err := tools.PullImage(ctx, s.dockerClient, syncImage); err != nil {
+		return "", errors.Wrap(err, "failed to scan image pulling response")
+	}
+
+	hostConfig, err := cont.BuildHostConfig(ctx, s.dockerClient, s.fsPool.DataDir(), s.ContainerConfig)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to build container host config")
+	}
+
+	pwd, err := tools.GeneratePassword()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate PostgreSQL password")
+	}
+
+	syncCont, err := s.dockerClient.ContainerCreate(ctx, s.buildContainerConfig(syncImage, pwd), hostConfig,
+		&network.NetworkingConfig{}, s.syncInstanceName())
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create container %s", s.syncInstanceName())
+	}
+
+	log.Msg(fmt.Sprintf("Running container: %s. ID: %v", s.syncInstanceName(), syncCont.ID))
+
+	if err := s.dockerClient.ContainerStart(ctx, syncCont.ID, types.ContainerStartOptions{}); err != nil {
+		return "", errors.Wrapf(err, "failed to start container %s", s.syncInstanceName())
+	}
+
+	return syncCont.ID, nil
+}
+
+func (s *SyncJob) buildContainerConfig(syncImage, password string) *container.Config {
+	return &container.Config{
+		Labels: map[string]string{
+			cont.DBLabControlLabel:    cont.DBLabSyncLabel,
+			cont.DBLabInstanceIDLabel: s.globalCfg.InstanceID,
+		},
+		Env: []string{
+			"PGDATA=" + s.fsPool.DataDir(),
+			"POSTGRES_PASSWORD=" + password,
+		},
+		Image:       syncImage,
+		Healthcheck: health.GetConfig(s.globalCfg.Database.User(), s.globalCfg.Database.Name()),
+	}
+}
+
+func (s *SyncJob) getDBList(ctx context.Context, contID string) ([]string, error) {
+	output, err := s.query(ctx, contID, s.globalCfg.Database.Name(),
+		"select datname from pg_catalog.pg_database where not datistemplate order by datname")
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(output), nil
+}
+
+// subscribe creates a subscription of the database unless it already exists.
+// The replication slot created by the logical dump is used if it exists, so no changes made after the dump are lost.
+func (s *SyncJob) subscribe(ctx context.Context, contID, dbName string) error {
+	subName := replicationSlotName(s.SlotPrefix, dbName)
+
+	exists, err := s.query(ctx, contID, dbName,
+		fmt.Sprintf("select count(*) from pg_catalog.pg_subscription where subname = %s", quoteLiteral(subName)))
+	if err != nil {
+		return errors.Wrap(err, "failed to check subscription")
+	}
+
+	if exists != "0" {
+		log.Msg(fmt.Sprintf("Subscription %q of the database %q already exists", subName, dbName))
+		return nil
+	}
+
+	slotExists, err := s.hasSourceSlot(ctx, dbName, subName)
+	if err != nil {
+		return errors.Wrap(err, "failed to check replication slot on the source")
+	}
+
+	if !slotExists {
+		log.Msg(fmt.Sprintf("Replication slot %q not found on the source. A new slot will be created: "+
+			"changes made between the dump and the subscription may be missing", subName))
+	}
+
+	conn := s.Connection
+	conn.DBName = dbName
+	conn.Password = s.getPassword()
+
+	subscriptionQuery := buildCreateSubscriptionQuery(subName, buildConnInfo(conn), s.Publication, !slotExists)
+
+	log.Msg(fmt.Sprintf("Creating subscription %q of the database %q", subName, dbName))
+
+	if out, err := tools.ExecCommandWithInput(ctx, s.dockerClient, contID, types.ExecConfig{
+		Cmd: []string{"psql", "-U", s.globalCfg.Database.User(), "-d", dbName, "-X", "-v", "ON_ERROR_STOP=1"},
+	}, strings.NewReader(subscriptionQuery)); err != nil {
+		log.Dbg(out)
+		return errors.Wrap(err, "failed to create subscription")
+	}
+
+	return nil
+}
+
+func (s *SyncJob) hasSourceSlot(ctx context.Context, dbName, slotName string) (bool, error) {
+	connStr := db.ConnectionString(s.Connection.Host, strconv.Itoa(s.Connection.Port), s.Connection.Username, dbName,
+		s.getPassword())
+
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to connect to the source")
+	}
+
+	defer func() { _ = conn.Close(ctx) }()
+
+	var exists bool
+
+	if err := conn.QueryRow(ctx, "select exists (select 1 from pg_catalog.pg_replication_slots where slot_name = $1)",
+		slotName).Scan(&exists); err != nil {
+		return false, errors.Wrap(err, "failed to check replication slot")
+	}
+
+	return exists, nil
+}
+
+func (s *SyncJob) getPassword() string {
+	if s.Connection.Password != "" {
+		return s.Connection.Password
+	}
+
+	return os.Getenv("PGPASSWORD")
+}
+
+func (s *SyncJob) query(ctx context.Context, contID, dbName, query string) (string, error) {
+	return tools.ExecCommandWithOutput(ctx, s.dockerClient, contID, types.ExecConfig{
+		Cmd: []string{"psql", "-U", s.globalCfg.Database.User(), "-d", dbName, "-XAtc", query},
+	})
+}
+
+// SyncStatus returns the current state of the logical replication.
+func (s *SyncJob) SyncStatus(ctx context.Context) (*models.Sync, error) {
+	syncContainer, err := s.dockerClient.ContainerInspect(ctx, s.syncInstanceName())
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return &models.Sync{Status: models.SyncStatusDown, LagSeconds: unknownLag}, nil
+		}
+
+		return nil, errors.Wrap(err, "failed to inspect sync container")
+	}
+
+	if syncContainer.State == nil || !syncContainer.State.Running {
+		return &models.Sync{Status: models.SyncStatusDown, LagSeconds: unknownLag}, nil
+	}
+
+	output, err := s.query(ctx, syncContainer.ID, s.globalCfg.Database.Name(), subscriptionStatusQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get subscription status")
+	}
+
+	subscriptions, err := parseSubscriptionStatus(output)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildSyncState(subscriptions), nil
+}
+
+const subscriptionStatusQuery = `select s.subname, d.datname, st.pid is not null,
+  coalesce(st.received_lsn::text, ''),
+  coalesce(to_char(st.latest_end_time at time zone 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"'), ''),
+  coalesce(extract(epoch from now() - st.latest_end_time)::bigint, -1)
+from pg_catalog.pg_subscription s
+join pg_catalog.pg_database d on d.oid = s.subdbid
+left join pg_catalog.pg_stat_subscription st on st.subid = s.oid and st.relid is null
+order by s.subname`
+
+// parseSubscriptionStatus parses the unaligned output of the subscription status query.
+func parseSubscriptionStatus(output string) ([]*models.SyncSubscription, error) {
+	subscriptions := []*models.SyncSubscription{}
+
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "|")
+		if len(fields) != subscriptionStatusFields {
+			return nil, errors.Errorf("unexpected subscription status line: %q", line)
+		}
+
+		lag, err := strconv.ParseInt(fields[5], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid lag value: %q", fields[5])
+		}
+
+		subscriptions = append(subscriptions, &models.SyncSubscription{
+			Name:          fields[0],
+			Database:      fields[1],
+			Active:        fields[2] == "t",
+			ReceivedLSN:   fields[3],
+			LatestEndTime: fields[4],
+			LagSeconds:    lag,
+		})
+	}
+
+	return subscriptions, nil
+}
+
+// buildSyncState aggregates states of subscriptions. The lag of the most lagging subscription is reported.
+func buildSyncState(subscriptions []*models.SyncSubscription) *models.Sync {
+	state := &models.Sync{
+		Status:        models.SyncStatusActive,
+		LagSeconds:    0,
+		Subscriptions: subscriptions,
+	}
+
+	active := 0
+
+	for _, subscription := range subscriptions {
+		if subscription.Active {
+			active++
+		}
+
+		if subscription.LagSeconds == unknownLag || state.LagSeconds == unknownLag {
+			state.LagSeconds = unknownLag
+			continue
+		}
+
+		if subscription.LagSeconds > state.LagSeconds {
+			state.LagSeconds = subscription.LagSeconds
+		}
+	}
+
+	switch {
+	case active == 0:
+		state.Status = models.SyncStatusDown
+		state.LagSeconds = unknownLag
+
+	case active < len(subscriptions):
+		state.Status = models.SyncStatusDegraded
+	}
+
+	return state
+}
+
+// replicationSlotName builds a valid name of a replication slot and a subscription for the database.
+func replicationSlotName(prefix, dbName string) string {
+	slotName := invalidSlotNameChars.ReplaceAllString(strings.ToLower(prefix+"_"+dbName), "_")
+
+	if len(slotName) > maxIdentifierLength {
+		slotName = slotName[:maxIdentifierLength]
+	}
+
+	return slotName
+}
+
+func buildCreateSubscriptionQuery(subName, connInfo, publication string, createSlot bool) string {
+	return fmt.Sprintf("create subscription %s connection %s publication %s "+
+		"with (copy_data = false, create_slot = %t, slot_name = %s);",
+		pgx.Identifier{subName}.Sanitize(),
+		quoteLiteral(connInfo),
+		pgx.Identifier{publication}.Sanitize(),
+		createSlot,
+		quoteLiteral(subName),
+	)
+}
+
+// buildConnInfo builds a libpq connection string.
+func buildConnInfo(conn Connection) string {
+	params := []string{
+		"host=" + quoteConnInfoValue(conn.Host),
+		"port=" + strconv.Itoa(conn.Port),
+		"user=" + quoteConnInfoValue(conn.Username),
+		"dbname=" + quoteConnInfoValue(conn.DBName),
+	}
+
+	if conn.Password != "" {
+		params = append(params, "password="+quoteConnInfoValue(conn.Password))
+	}
+
+	return strings.Join(params, " ")
+}
+
+func quoteConnInfoValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,121 @@
+/*
+2021 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+)
+
+func TestReplicationSlotName(t *testing.T) {
+	testCases := []struct {
+		prefix   string
+		dbName   string
+		expected string
+	}{
+		{prefix: "dblab_sync", dbName: "test", expected: "dblab_sync_test"},
+		{prefix: "dblab_sync", dbName: "My-DB.prod", expected: "dblab_sync_my_db_prod"},
+		{
+			prefix:   "dblab_sync",
+			dbName:   "a_very_long_database_name_exceeding_the_postgres_identifier_limit",
+			expected: "dblab_sync_a_very_long_database_name_exceeding_the_postgres_ide",
+		},
+	}
+
+	for _, tc := range testCases {
+		slotName := replicationSlotName(tc.prefix, tc.dbName)
+		assert.Equal(t, tc.expected, slotName)
+		assert.LessOrEqual(t, len(slotName), maxIdentifierLength)
+	}
+}
+
+func TestBuildCreateSubscriptionQuery(t *testing.T) {
+	connInfo := buildConnInfo(Connection{
+		Host:     "source.example.com",
+		Port:     5432,
+		Username: "replicator",
+		Password: `it's\secret`,
+		DBName:   "test",
+	})
+
+	assert.Equal(t, `host='source.example.com' port=5432 user='replicator' dbname='test' password='it\'s\\secret'`, connInfo)
+
+	query := buildCreateSubscriptionQuery("dblab_sync_test", connInfo, "dblab_pub", false)
+
+	assert.Equal(t, `create subscription "dblab_sync_test" connection 'host=''source.example.com'' port=5432 `+
+		`user=''replicator'' dbname=''test'' password=''it\''s\\secret''' publication "dblab_pub" `+
+		`with (copy_data = false, create_slot = false, slot_name = 'dblab_sync_test');`, query)
+}
+
+func TestParseSubscriptionStatus(t *testing.T) {
+	output := `dblab_sync_app|app|t|0/16B3748|2021-06-01T12:00:00Z|3
+dblab_sync_test|test|f|||-1
+`
+
+	subscriptions, err := parseSubscriptionStatus(output)
+	require.NoError(t, err)
+	require.Len(t, subscriptions, 2)
+
+	assert.Equal(t, &models.SyncSubscription{
+		Name:          "dblab_sync_app",
+		Database:      "app",
+		Active:        true,
+		ReceivedLSN:   "0/16B3748",
+		LatestEndTime: "2021-06-01T12:00:00Z",
+		LagSeconds:    3,
+	}, subscriptions[0])
+	assert.False(t, subscriptions[1].Active)
+	assert.Equal(t, int64(unknownLag), subscriptions[1].LagSeconds)
+
+	_, err = parseSubscriptionStatus("dblab_sync_app|app|t")
+	assert.Error(t, err)
+}
+
+func TestBuildSyncState(t *testing.T) {
+	testCases := []struct {
+		subscriptions  []*models.SyncSubscription
+		expectedStatus models.SyncStatusCode
+		expectedLag    int64
+	}{
+		{
+			subscriptions:  []*models.SyncSubscription{},
+			expectedStatus: models.SyncStatusDown,
+			expectedLag:    unknownLag,
+		},
+		{
+			subscriptions: []*models.SyncSubscription{
+				{Name: "a", Active: true, LagSeconds: 3},
+				{Name: "b", Active: true, LagSeconds: 10},
+			},
+			expectedStatus: models.SyncStatusActive,
+			expectedLag:    10,
+		},
+		{
+			subscriptions: []*models.SyncSubscription{
+				{Name: "a", Active: true, LagSeconds: 3},
+				{Name: "b", Active: false, LagSeconds: unknownLag},
+			},
+			expectedStatus: models.SyncStatusDegraded,
+			expectedLag:    unknownLag,
+		},
+		{
+			subscriptions: []*models.SyncSubscription{
+				{Name: "a", Active: false, LagSeconds: 120},
+			},
+			expectedStatus: models.SyncStatusDown,
+			expectedLag:    unknownLag,
+		},
+	}
+
+	for _, tc := range testCases {
+		state := buildSyncState(tc.subscriptions)
+		assert.Equal(t, tc.expectedStatus, state.Status)
+		assert.Equal(t, tc.expectedLag, state.LagSeconds)
+	}
+}
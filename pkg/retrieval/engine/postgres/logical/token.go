@@ -0,0 +1,127 @@
+/*
+2021 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+)
+
+const (
+	// tokenRefreshMargin defines how long before the expiration a token is refreshed.
+	tokenRefreshMargin = 5 * time.Minute
+
+	// tokenRequestTimeout defines the timeout of a token request.
+	tokenRequestTimeout = 30 * time.Second
+)
+
+// accessToken describes an OAuth 2.0 access token used as a database password.
+type accessToken struct {
+	value     string
+	expiresAt time.Time
+}
+
+// tokenFetcher requests a new access token.
+type tokenFetcher func(ctx context.Context) (accessToken, error)
+
+// cachedToken keeps an access token and refreshes it when it is about to expire.
+type cachedToken struct {
+	mu    sync.Mutex
+	fetch tokenFetcher
+	token accessToken
+	now   func() time.Time
+}
+
+func newCachedToken(fetch tokenFetcher) *cachedToken {
+	return &cachedToken{fetch: fetch, now: time.Now}
+}
+
+// get returns a valid access token.
+func (c *cachedToken) get(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token.value != "" && c.now().Add(tokenRefreshMargin).Before(c.token.expiresAt) {
+		return c.token.value, nil
+	}
+
+	log.Dbg("Requesting a new access token")
+
+	token, err := c.fetch(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get an access token")
+	}
+
+	if token.value == "" {
+		return "", errors.New("empty access token received")
+	}
+
+	c.token = token
+
+	return token.value, nil
+}
+
+// tokenResponse describes a token endpoint response.
+type tokenResponse struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresIn   expiresIn `json:"expires_in"`
+}
+
+// expiresIn represents a token lifetime in seconds. Some endpoints return it as a string.
+type expiresIn int64
+
+// UnmarshalJSON decodes the token lifetime given either as a number or as a string.
+func (e *expiresIn) UnmarshalJSON(data []byte) error {
+	value, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return errors.Wrapf(err, "invalid expires_in value: %s", data)
+	}
+
+	*e = expiresIn(value)
+
+	return nil
+}
+
+// requestToken sends the request to a token endpoint and parses the response.
+func requestToken(ctx context.Context, req *http.Request, now time.Time) (accessToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, tokenRequestTimeout)
+	defer cancel()
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return accessToken{}, errors.Wrap(err, "failed to request token")
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return accessToken{}, errors.Wrap(err, "failed to read token response")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return accessToken{}, errors.Errorf("unexpected token response status %s: %s", resp.Status, body)
+	}
+
+	tokenResp := tokenResponse{}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return accessToken{}, errors.Wrap(err, "failed to decode token response")
+	}
+
+	return accessToken{
+		value:     tokenResp.AccessToken,
+		expiresAt: now.Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+	}, nil
+}
@@ -0,0 +1,170 @@
+/*
+2021 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedTokenRefresh(t *testing.T) {
+	now := time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC)
+	calls := 0
+
+	token := newCachedToken(func(_ context.Context) (accessToken, error) {
+		calls++
+		return accessToken{value: fmt.Sprintf("token-%d", calls), expiresAt: now.Add(time.Hour)}, nil
+	})
+	token.now = func() time.Time { return now }
+
+	value, err := token.get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", value)
+
+	// The token is still valid.
+	now = now.Add(30 * time.Minute)
+
+	value, err = token.get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", value)
+
+	// The token is about to expire.
+	now = now.Add(27 * time.Minute)
+
+	value, err = token.get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", value)
+	assert.Equal(t, 2, calls)
+}
+
+func TestCachedTokenEmpty(t *testing.T) {
+	token := newCachedToken(func(_ context.Context) (accessToken, error) {
+		return accessToken{}, nil
+	})
+
+	_, err := token.get(context.Background())
+	assert.Error(t, err)
+}
+
+func TestExpiresInDecoding(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected expiresIn
+		isValid  bool
+	}{
+		{input: `{"expires_in": 3599}`, expected: 3599, isValid: true},
+		{input: `{"expires_in": "86399"}`, expected: 86399, isValid: true},
+		{input: `{"expires_in": "soon"}`, isValid: false},
+	}
+
+	for _, tc := range testCases {
+		resp := tokenResponse{}
+		err := json.Unmarshal([]byte(tc.input), &resp)
+
+		if !tc.isValid {
+			assert.Error(t, err)
+			continue
+		}
+
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, resp.ExpiresIn)
+	}
+}
+
+func TestCloudSQLDumper(t *testing.T) {
+	var requests int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte(`{"access_token":"gcp-token","expires_in":3599,"token_type":"Bearer"}`))
+	}))
+	defer ts.Close()
+
+	dumper := newCloudSQLDumper(&CloudSQLConfig{TokenURL: ts.URL})
+
+	conn := &Connection{Host: "10.0.0.3", Port: 5432, Username: "dblab@project.iam"}
+	require.NoError(t, dumper.SetConnectionOptions(context.Background(), conn))
+	assert.Equal(t, "gcp-token", conn.Password)
+
+	// The cached token is reused.
+	require.NoError(t, dumper.SetConnectionOptions(context.Background(), conn))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	assert.Equal(t, []string{"PGSSLMODE=require"}, dumper.GetCmdEnvVariables())
+	assert.Error(t, dumper.SetConnectionOptions(context.Background(), &Connection{}))
+}
+
+func TestAzureManagedIdentityDumper(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("resource") != azureDBResource {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		assert.Equal(t, "identity-id", r.URL.Query().Get("client_id"))
+
+		_, _ = w.Write([]byte(`{"access_token":"msi-token","expires_in":"86399","token_type":"Bearer"}`))
+	}))
+	defer ts.Close()
+
+	dumper, err := newAzureDumper(&AzureADConfig{IMDSURL: ts.URL, ClientID: "identity-id"})
+	require.NoError(t, err)
+
+	conn := &Connection{Username: "dblab_identity"}
+	require.NoError(t, dumper.SetConnectionOptions(context.Background(), conn))
+	assert.Equal(t, "msi-token", conn.Password)
+}
+
+func TestAzureServicePrincipalDumper(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tenant-id/oauth2/v2.0/token" || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		require.NoError(t, r.ParseForm())
+
+		if r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+
+			return
+		}
+
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, azureDBResource+"/.default", r.PostForm.Get("scope"))
+
+		_, _ = w.Write([]byte(`{"access_token":"sp-token","expires_in":3599,"token_type":"Bearer"}`))
+	}))
+	defer ts.Close()
+
+	_, err := newAzureDumper(&AzureADConfig{ClientSecret: "secret"})
+	assert.Error(t, err)
+
+	dumper, err := newAzureDumper(&AzureADConfig{AuthorityHost: ts.URL, TenantID: "tenant-id", ClientID: "client", ClientSecret: "secret"})
+	require.NoError(t, err)
+
+	conn := &Connection{Username: "dblab_app"}
+	require.NoError(t, dumper.SetConnectionOptions(context.Background(), conn))
+	assert.Equal(t, "sp-token", conn.Password)
+
+	invalidDumper, err := newAzureDumper(&AzureADConfig{AuthorityHost: ts.URL, TenantID: "tenant-id", ClientID: "client", ClientSecret: "wrong"})
+	require.NoError(t, err)
+	assert.Error(t, invalidDumper.SetConnectionOptions(context.Background(), &Connection{Username: "dblab_app"}))
+}
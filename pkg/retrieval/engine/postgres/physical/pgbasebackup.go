@@ -0,0 +1,219 @@
+/*
+2021 © Postgres.ai
+*/
+
+package physical
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/db"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/defaults"
+)
+
+const (
+	pgbasebackupTool = "pgbasebackup"
+
+	// defaultSlotName defines the default name of the physical replication slot on the source.
+	defaultSlotName = "dblab_sync"
+
+	// defaultSourceDBName defines the database used to manage the replication slot.
+	defaultSourceDBName = "postgres"
+)
+
+var slotNameRegexp = regexp.MustCompile(`^[a-z0-9_]{1,63}$`)
+
+// pgbasebackup defines pg_basebackup as a tool copying data from the source to run a streaming standby.
+type pgbasebackup struct {
+	pgDataDir string
+	password  string
+	options   pgbasebackupOptions
+}
+
+type pgbasebackupOptions struct {
+	Connection Connection `yaml:"connection"`
+	SlotName   string     `yaml:"slotName"`
+}
+
+// Connection describes the connection to the source. The password is taken from the PGPASSWORD variable,
+// so it is not stored in the configuration of the sync instance.
+type Connection struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	DBName   string `yaml:"dbname"`
+}
+
+// slotManager describes tools using a replication slot on the source.
+type slotManager interface {
+	// CreateSlot creates the replication slot if it does not exist.
+	CreateSlot(ctx context.Context) error
+
+	// DropSlot drops the replication slot if it exists.
+	DropSlot(ctx context.Context) error
+}
+
+func newPgBaseBackup(pgDataDir string, options pgbasebackupOptions, envs map[string]string) (*pgbasebackup, error) {
+	if options.Connection.Host == "" {
+		return nil, errors.New("source host must be defined for pg_basebackup")
+	}
+
+	if options.Connection.Port == 0 {
+		options.Connection.Port = defaults.Port
+	}
+
+	if options.Connection.Username == "" {
+		options.Connection.Username = defaults.Username
+	}
+
+	if options.Connection.DBName == "" {
+		options.Connection.DBName = defaultSourceDBName
+	}
+
+	if options.SlotName == "" {
+		options.SlotName = defaultSlotName
+	}
+
+	if !slotNameRegexp.MatchString(options.SlotName) {
+		return nil, errors.Errorf("invalid replication slot name %q: use lower case letters, numbers and underscores",
+			options.SlotName)
+	}
+
+	password := envs["PGPASSWORD"]
+	if password == "" {
+		password = os.Getenv("PGPASSWORD")
+	}
+
+	return &pgbasebackup{
+		pgDataDir: pgDataDir,
+		password:  password,
+		options:   options,
+	}, nil
+}
+
+// GetRestoreCommand returns a command to copy data from the source.
+func (p *pgbasebackup) GetRestoreCommand() string {
+	return fmt.Sprintf("pg_basebackup --pgdata=%s --dbname=%s --wal-method=stream --checkpoint=fast --slot=%s "+
+		"--progress --verbose", p.pgDataDir, shellQuote(p.connInfo()), p.options.SlotName)
+}
+
+// GetRecoveryConfig returns a recovery config to run a standby.
+// Streaming options are stored in the recovery config only for Postgres versions without the sync config support.
+func (p *pgbasebackup) GetRecoveryConfig(pgVersion float64) map[string]string {
+	recoveryCfg := map[string]string{
+		"recovery_target_timeline": "latest",
+	}
+
+	if pgVersion < defaults.PGVersion12 {
+		recoveryCfg["standby_mode"] = "on"
+
+		for key, value := range p.streamingConfig() {
+			recoveryCfg[key] = value
+		}
+	}
+
+	return recoveryCfg
+}
+
+// GetSyncConfig returns configuration parameters of the sync instance.
+func (p *pgbasebackup) GetSyncConfig(pgVersion float64) map[string]string {
+	if pgVersion < defaults.PGVersion12 {
+		return nil
+	}
+
+	return p.streamingConfig()
+}
+
+func (p *pgbasebackup) streamingConfig() map[string]string {
+	return map[string]string{
+		"primary_conninfo":  escapeConfigValue(p.connInfo() + " application_name=" + p.options.SlotName),
+		"primary_slot_name": p.options.SlotName,
+	}
+}
+
+// connInfo builds a libpq connection string without the password.
+func (p *pgbasebackup) connInfo() string {
+	return strings.Join([]string{
+		"host=" + quoteConnInfoValue(p.options.Connection.Host),
+		"port=" + strconv.Itoa(p.options.Connection.Port),
+		"user=" + quoteConnInfoValue(p.options.Connection.Username),
+	}, " ")
+}
+
+// CreateSlot creates the physical replication slot on the source if it does not exist.
+func (p *pgbasebackup) CreateSlot(ctx context.Context) error {
+	conn, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = conn.Close(ctx) }()
+
+	tag, err := conn.Exec(ctx, `select pg_catalog.pg_create_physical_replication_slot($1)
+where not exists (select 1 from pg_catalog.pg_replication_slots where slot_name = $1)`, p.options.SlotName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create replication slot %q", p.options.SlotName)
+	}
+
+	if tag.RowsAffected() > 0 {
+		log.Msg(fmt.Sprintf("Replication slot %q has been created on the source", p.options.SlotName))
+	}
+
+	return nil
+}
+
+// DropSlot drops the physical replication slot on the source if it exists.
+func (p *pgbasebackup) DropSlot(ctx context.Context) error {
+	conn, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = conn.Close(ctx) }()
+
+	tag, err := conn.Exec(ctx, `select pg_catalog.pg_drop_replication_slot(slot_name)
+from pg_catalog.pg_replication_slots where slot_name = $1 and slot_type = 'physical'`, p.options.SlotName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to drop replication slot %q", p.options.SlotName)
+	}
+
+	if tag.RowsAffected() > 0 {
+		log.Msg(fmt.Sprintf("Replication slot %q has been dropped on the source", p.options.SlotName))
+	}
+
+	return nil
+}
+
+func (p *pgbasebackup) connect(ctx context.Context) (*pgx.Conn, error) {
+	connStr := db.ConnectionString(p.options.Connection.Host, strconv.Itoa(p.options.Connection.Port),
+		p.options.Connection.Username, p.options.Connection.DBName, p.password)
+
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to the source")
+	}
+
+	return conn, nil
+}
+
+func quoteConnInfoValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// escapeConfigValue escapes quotes of a value written to a Postgres configuration file in quotes.
+func escapeConfigValue(value string) string {
+	return strings.ReplaceAll(value, "'", "''")
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
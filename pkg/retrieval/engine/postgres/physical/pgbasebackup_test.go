@@ -0,0 +1,70 @@
+package physical
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPgBaseBackupOptions(t *testing.T) {
+	_, err := newPgBaseBackup("dataDir", pgbasebackupOptions{}, nil)
+	assert.EqualError(t, err, "source host must be defined for pg_basebackup")
+
+	_, err = newPgBaseBackup("dataDir", pgbasebackupOptions{Connection: Connection{Host: "source"}, SlotName: "DBLab-sync"}, nil)
+	assert.EqualError(t, err, `invalid replication slot name "DBLab-sync": use lower case letters, numbers and underscores`)
+
+	tool, err := newPgBaseBackup("dataDir", pgbasebackupOptions{Connection: Connection{Host: "source"}},
+		map[string]string{"PGPASSWORD": "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, Connection{Host: "source", Port: 5432, Username: "postgres", DBName: "postgres"}, tool.options.Connection)
+	assert.Equal(t, "dblab_sync", tool.options.SlotName)
+	assert.Equal(t, "secret", tool.password)
+}
+
+func TestPgBaseBackupConfig(t *testing.T) {
+	tool, err := newPgBaseBackup("/var/lib/dblab/data", pgbasebackupOptions{
+		Connection: Connection{Host: "source.hostname", Port: 6432, Username: "o'replicator"},
+		SlotName:   "dblab_standby",
+	}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, `pg_basebackup --pgdata=/var/lib/dblab/data `+
+		`--dbname='host='"'"'source.hostname'"'"' port=6432 user='"'"'o\'"'"'replicator'"'"'' `+
+		`--wal-method=stream --checkpoint=fast --slot=dblab_standby --progress --verbose`, tool.GetRestoreCommand())
+
+	streamingConfig := map[string]string{
+		"primary_conninfo":  `host=''source.hostname'' port=6432 user=''o\''replicator'' application_name=dblab_standby`,
+		"primary_slot_name": "dblab_standby",
+	}
+
+	assert.Equal(t, streamingConfig, tool.GetSyncConfig(13))
+	assert.Equal(t, map[string]string{"recovery_target_timeline": "latest"}, tool.GetRecoveryConfig(13))
+
+	assert.Nil(t, tool.GetSyncConfig(11))
+	assert.Equal(t, map[string]string{
+		"recovery_target_timeline": "latest",
+		"standby_mode":             "on",
+		"primary_conninfo":         streamingConfig["primary_conninfo"],
+		"primary_slot_name":        "dblab_standby",
+	}, tool.GetRecoveryConfig(11))
+}
+
+func TestBuildSyncConfig(t *testing.T) {
+	tool, err := newPgBaseBackup("dataDir", pgbasebackupOptions{Connection: Connection{Host: "source"}}, nil)
+	require.NoError(t, err)
+
+	r := &RestoreJob{restorer: tool, CopyOptions: CopyOptions{Sync: Sync{Configs: map[string]string{
+		"shared_buffers":    "2GB",
+		"primary_slot_name": "custom_slot",
+	}}}}
+
+	assert.Equal(t, map[string]string{
+		"shared_buffers":    "2GB",
+		"primary_conninfo":  "host=''source'' port=5432 user=''postgres'' application_name=dblab_sync",
+		"primary_slot_name": "custom_slot",
+	}, r.buildSyncConfig(13))
+
+	r.restorer = newCustomTool(customOptions{})
+	assert.Equal(t, map[string]string{"shared_buffers": "2GB", "primary_slot_name": "custom_slot"}, r.buildSyncConfig(13))
+}
@@ -22,6 +22,7 @@ import (
 
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/config/global"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/config"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/dbmarker"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools"
@@ -71,6 +72,7 @@ type CopyOptions struct {
 	Envs            map[string]string      `yaml:"envs"`
 	WALG            walgOptions            `yaml:"walg"`
 	CustomTool      customOptions          `yaml:"customTool"`
+	PgBaseBackup    pgbasebackupOptions    `yaml:"pgbasebackup"`
 	Sync            Sync                   `yaml:"sync"`
 }
 
@@ -97,6 +99,12 @@ type restorer interface {
 	GetRecoveryConfig(version float64) map[string]string
 }
 
+// syncConfigurator describes tools defining configuration parameters of the sync instance.
+type syncConfigurator interface {
+	// GetSyncConfig returns configuration parameters of the sync instance.
+	GetSyncConfig(version float64) map[string]string
+}
+
 // NewJob creates a new physical restore job.
 func NewJob(cfg config.JobConfig, global *global.Config) (*RestoreJob, error) {
 	physicalJob := &RestoreJob{
@@ -108,16 +116,9 @@ func NewJob(cfg config.JobConfig, global *global.Config) (*RestoreJob, error) {
 	}
 
 	if err := physicalJob.Reload(cfg.Spec.Options); err != nil {
-		return nil, errors.Wrap(err, "failed to unmarshal configuration options")
+		return nil, errors.Wrap(err, "failed to load job config")
 	}
 
-	restorer, err := physicalJob.getRestorer(physicalJob.Tool)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to init restorer")
-	}
-
-	physicalJob.restorer = restorer
-
 	return physicalJob, nil
 }
 
@@ -129,6 +130,9 @@ func (r *RestoreJob) getRestorer(tool string) (restorer, error) {
 
 	case customTool:
 		return newCustomTool(r.CustomTool), nil
+
+	case pgbasebackupTool:
+		return newPgBaseBackup(r.fsPool.DataDir(), r.PgBaseBackup, r.Envs)
 	}
 
 	return nil, errors.Errorf("unknown restore tool given: %v", tool)
@@ -143,9 +147,20 @@ func (r *RestoreJob) Name() string {
 	return r.name
 }
 
-// Reload reloads job configuration.
+// Reload reloads job configuration and rebuilds the restore tool from it.
 func (r *RestoreJob) Reload(cfg map[string]interface{}) (err error) {
-	return options.Unmarshal(cfg, &r.CopyOptions)
+	if err := options.Unmarshal(cfg, &r.CopyOptions); err != nil {
+		return errors.Wrap(err, "failed to unmarshal configuration options")
+	}
+
+	restorer, err := r.getRestorer(r.Tool)
+	if err != nil {
+		return errors.Wrap(err, "failed to init restorer")
+	}
+
+	r.restorer = restorer
+
+	return nil
 }
 
 // Run starts the job.
@@ -208,6 +223,12 @@ func (r *RestoreJob) Run(ctx context.Context) (err error) {
 		return errors.Wrapf(err, "failed to start container: %v", contID)
 	}
 
+	if slots, ok := r.restorer.(slotManager); ok {
+		if err := slots.CreateSlot(ctx); err != nil {
+			return errors.Wrap(err, "failed to prepare a replication slot")
+		}
+	}
+
 	log.Msg("Running restore command: ", r.restorer.GetRestoreCommand())
 	log.Msg(fmt.Sprintf("View logs using the command: %s %s", tools.ViewLogsCmd, r.restoreContainerName()))
 
@@ -239,7 +260,7 @@ func (r *RestoreJob) Run(ctx context.Context) (err error) {
 	}
 
 	// Apply sync instance configs.
-	if syncConfig := r.CopyOptions.Sync.Configs; len(syncConfig) > 0 {
+	if syncConfig := r.buildSyncConfig(cfgManager.GetPgVersion()); len(syncConfig) > 0 {
 		if err := cfgManager.ApplySync(syncConfig); err != nil {
 			return errors.Wrap(err, "cannot update sync instance configs")
 		}
@@ -341,6 +362,66 @@ func (r *RestoreJob) runSyncInstance(ctx context.Context) (err error) {
 	return nil
 }
 
+// Cleanup stops the sync instance and drops the replication slot on the source.
+func (r *RestoreJob) Cleanup(ctx context.Context) error {
+	slots, ok := r.restorer.(slotManager)
+	if !ok {
+		return nil
+	}
+
+	// An active slot cannot be dropped, so the standby using it has to be stopped first.
+	if r.CopyOptions.Sync.Enabled {
+		tools.RemoveContainer(ctx, r.dockerClient, r.syncInstanceName(), cont.StopPhysicalTimeout)
+	}
+
+	return slots.DropSlot(ctx)
+}
+
+// SyncStatus returns the current state of the sync instance replaying WAL from the source.
+func (r *RestoreJob) SyncStatus(ctx context.Context) (*models.Sync, error) {
+	if !r.CopyOptions.Sync.Enabled {
+		return nil, nil
+	}
+
+	syncContainer, err := r.dockerClient.ContainerInspect(ctx, r.syncInstanceName())
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return &models.Sync{Status: models.SyncStatusDown, LagSeconds: tools.UnknownLag}, nil
+		}
+
+		return nil, errors.Wrap(err, "failed to inspect sync container")
+	}
+
+	if syncContainer.State == nil || !syncContainer.State.Running {
+		return &models.Sync{Status: models.SyncStatusDown, LagSeconds: tools.UnknownLag}, nil
+	}
+
+	pgVersion, err := tools.DetectPGVersion(r.fsPool.DataDir())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to detect the Postgres version")
+	}
+
+	lag, err := tools.GetReplicationLag(ctx, r.dockerClient, syncContainer.ID, r.globalCfg.Database.User(),
+		r.globalCfg.Database.Name(), pgVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildSyncState(lag), nil
+}
+
+// buildSyncState describes the sync state by the replication lag.
+// The lag threshold is defined by the snapshot scheduler, so the running sync instance is reported as active.
+func buildSyncState(lag *tools.ReplicationLag) *models.Sync {
+	return &models.Sync{
+		Status:      models.SyncStatusActive,
+		LagSeconds:  lag.Seconds,
+		LagBytes:    lag.Bytes,
+		ReceivedLSN: lag.ReceivedLSN,
+		ReplayLSN:   lag.ReplayLSN,
+	}
+}
+
 func (r *RestoreJob) buildSyncInstanceConfig() (*container.Config, error) {
 	pwd, err := tools.GeneratePassword()
 	if err != nil {
@@ -395,6 +476,23 @@ func (r *RestoreJob) getEnvironmentVariables(password string) []string {
 	return envVariables
 }
 
+// buildSyncConfig merges configuration parameters of the tool with user-defined ones. User-defined parameters take precedence.
+func (r *RestoreJob) buildSyncConfig(pgVersion float64) map[string]string {
+	syncConf := make(map[string]string)
+
+	if configurator, ok := r.restorer.(syncConfigurator); ok {
+		for key, value := range configurator.GetSyncConfig(pgVersion) {
+			syncConf[key] = value
+		}
+	}
+
+	for key, value := range r.Sync.Configs {
+		syncConf[key] = value
+	}
+
+	return syncConf
+}
+
 func (r *RestoreJob) buildRecoveryConf(pgVersion float64) map[string]string {
 	recoveryConf := r.restorer.GetRecoveryConfig(pgVersion)
 
@@ -11,6 +11,9 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools"
 )
 
 func TestInitParamsExtraction(t *testing.T) {
@@ -39,3 +42,33 @@ max_wal_senders setting:              15
 	require.Nil(t, err)
 	assert.EqualValues(t, settings, expectedSettings)
 }
+
+func TestBuildSyncState(t *testing.T) {
+	lag := &tools.ReplicationLag{ReceivedLSN: "0/3000148", ReplayLSN: "0/3000060", Bytes: 232, Seconds: 120}
+
+	assert.Equal(t, &models.Sync{
+		Status:      models.SyncStatusActive,
+		LagSeconds:  120,
+		LagBytes:    232,
+		ReceivedLSN: "0/3000148",
+		ReplayLSN:   "0/3000060",
+	}, buildSyncState(lag))
+}
+
+func TestReloadRebuildsRestorer(t *testing.T) {
+	job := &RestoreJob{}
+
+	require.NoError(t, job.Reload(map[string]interface{}{
+		"tool":       customTool,
+		"customTool": map[string]interface{}{"command": "restore-v1"},
+	}))
+	assert.Equal(t, "restore-v1", job.restorer.GetRestoreCommand())
+
+	require.NoError(t, job.Reload(map[string]interface{}{
+		"tool":       customTool,
+		"customTool": map[string]interface{}{"command": "restore-v2"},
+	}))
+	assert.Equal(t, "restore-v2", job.restorer.GetRestoreCommand())
+
+	assert.EqualError(t, job.Reload(map[string]interface{}{"tool": "unknown"}), "failed to init restorer: unknown restore tool given: unknown")
+}
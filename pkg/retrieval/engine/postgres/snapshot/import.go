@@ -0,0 +1,398 @@
+/*
+2021 © Postgres.ai
+*/
+
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/client/dblabapi"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/config"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/options"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/pool"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/thinclones/zfs"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/util"
+)
+
+const (
+	// ImportJobType declares a job type for importing snapshots from another Database Lab instance.
+	ImportJobType = "snapshotImport"
+
+	// importedDataset defines the prefix of local datasets receiving imported snapshots.
+	importedDataset = "imported"
+)
+
+// ImportJob describes a job receiving snapshots exported by another Database Lab instance.
+type ImportJob struct {
+	name         string
+	cloneManager pool.FSManager
+	fsPool       *resources.Pool
+	options      ImportOptions
+	client       *dblabapi.Client
+	scheduler    *cron.Cron
+	schedulerCtx context.Context
+	importMutex  sync.Mutex
+}
+
+// ImportOptions describes options of the snapshot import.
+type ImportOptions struct {
+	Source     ImportSource `yaml:"source"`
+	SnapshotID string       `yaml:"snapshotID"`
+	Timetable  string       `yaml:"timetable"`
+}
+
+// ImportSource describes the Database Lab instance exporting snapshots.
+type ImportSource struct {
+	URL               string `yaml:"url"`
+	VerificationToken string `yaml:"verificationToken"`
+	Insecure          bool   `yaml:"insecure"`
+}
+
+// NewImportJob creates a new snapshot import job.
+func NewImportJob(cfg config.JobConfig, cloneManager pool.FSManager) (*ImportJob, error) {
+	ij := &ImportJob{
+		name:         cfg.Spec.Name,
+		cloneManager: cloneManager,
+		fsPool:       cfg.FSPool,
+	}
+
+	if err := ij.Reload(cfg.Spec.Options); err != nil {
+		return nil, errors.Wrap(err, "failed to load configuration options")
+	}
+
+	if ij.options.Timetable != "" {
+		ij.scheduler = cron.New()
+	}
+
+	return ij, nil
+}
+
+// Name returns a name of the job.
+func (j *ImportJob) Name() string {
+	return j.name
+}
+
+// Reload reloads job configuration.
+func (j *ImportJob) Reload(cfg map[string]interface{}) error {
+	importOptions := ImportOptions{}
+
+	if err := options.Unmarshal(cfg, &importOptions); err != nil {
+		return errors.Wrap(err, "failed to unmarshal configuration options")
+	}
+
+	if err := importOptions.validate(); err != nil {
+		return errors.Wrap(err, "invalid snapshotImport configuration")
+	}
+
+	apiClient, err := dblabapi.NewClient(dblabapi.Options{
+		Host:              importOptions.Source.URL,
+		VerificationToken: importOptions.Source.VerificationToken,
+		Insecure:          importOptions.Source.Insecure,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create a client of the source instance")
+	}
+
+	j.importMutex.Lock()
+	j.options = importOptions
+	j.client = apiClient
+	j.importMutex.Unlock()
+
+	if j.scheduler != nil {
+		j.reloadScheduler()
+	}
+
+	return nil
+}
+
+func (o ImportOptions) validate() error {
+	if o.Source.URL == "" {
+		return errors.New("source URL must not be empty")
+	}
+
+	if o.Timetable != "" {
+		specParser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+
+		if _, err := specParser.Parse(o.Timetable); err != nil {
+			return errors.Wrapf(err, "failed to parse import timetable %q", o.Timetable)
+		}
+	}
+
+	return nil
+}
+
+// Run starts the job.
+func (j *ImportJob) Run(ctx context.Context) error {
+	j.schedulerCtx = ctx
+
+	defer j.startScheduler(ctx)
+
+	return j.importSnapshot(ctx)
+}
+
+func (j *ImportJob) startScheduler(ctx context.Context) {
+	if j.scheduler == nil || j.options.Timetable == "" {
+		return
+	}
+
+	if _, err := j.scheduler.AddFunc(j.options.Timetable, j.runAutoImport(ctx)); err != nil {
+		log.Err(errors.Wrap(err, "failed to schedule a new import job"))
+		return
+	}
+
+	j.scheduler.Start()
+
+	log.Msg("Snapshot import scheduler has been started")
+
+	go func() {
+		<-ctx.Done()
+
+		log.Msg("Stop snapshot import scheduler")
+		j.scheduler.Stop()
+	}()
+}
+
+func (j *ImportJob) reloadScheduler() {
+	if j.schedulerCtx == nil {
+		return
+	}
+
+	j.scheduler.Stop()
+
+	for _, ent := range j.scheduler.Entries() {
+		j.scheduler.Remove(ent.ID)
+	}
+
+	j.startScheduler(j.schedulerCtx)
+}
+
+func (j *ImportJob) runAutoImport(ctx context.Context) func() {
+	return func() {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := j.importSnapshot(ctx); err != nil {
+			log.Err(errors.Wrap(err, "failed to import a snapshot automatically"))
+		}
+	}
+}
+
+// importSnapshot receives the snapshot from the source instance unless it has been imported already.
+// The stream is incremental if an earlier snapshot of the same dataset has been imported before.
+func (j *ImportJob) importSnapshot(ctx context.Context) error {
+	j.importMutex.Lock()
+	defer j.importMutex.Unlock()
+
+	sourceSnapshots, err := j.client.ListSnapshots(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get snapshots of the source instance")
+	}
+
+	target, err := chooseImportSnapshot(sourceSnapshots, j.options.SnapshotID)
+	if err != nil {
+		return err
+	}
+
+	localSnapshots, err := j.localSnapshots()
+	if err != nil {
+		return err
+	}
+
+	localName, err := importedSnapshotName(j.fsPool.Name, target)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := localSnapshots[localName]; ok {
+		log.Msg(fmt.Sprintf("Snapshot %s has already been imported as %s", target.ID, localName))
+		return nil
+	}
+
+	base := chooseBaseSnapshot(j.fsPool.Name, target, sourceSnapshots, localSnapshots)
+
+	if base == nil && hasDatasetSnapshots(localSnapshots, localName) {
+		return errors.Errorf("local dataset of %s has no snapshots in common with the source: destroy it to import a full stream",
+			localName)
+	}
+
+	dataStateAt, err := util.ParseTime(target.DataStateAt)
+	if err != nil {
+		return errors.Wrapf(err, "invalid dataStateAt of snapshot %s", target.ID)
+	}
+
+	baseID := ""
+
+	if base != nil {
+		baseID = base.ID
+		log.Msg(fmt.Sprintf("Import snapshot %s incrementally from %s", target.ID, baseID))
+	} else {
+		log.Msg(fmt.Sprintf("Import snapshot %s as a full stream", target.ID))
+	}
+
+	stream, err := j.client.ExportSnapshot(ctx, target.ID, baseID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to export snapshot %s", target.ID)
+	}
+
+	defer func() { _ = stream.Close() }()
+
+	err = j.cloneManager.ImportSnapshot(stream, localName, dataStateAt.Format(util.DataStateAtFormat), importedStatus(target.Status))
+	if err != nil {
+		return errors.Wrapf(err, "failed to import snapshot %s", target.ID)
+	}
+
+	j.fsPool.SetDSA(dataStateAt)
+
+	log.Msg(fmt.Sprintf("Snapshot %s has been imported as %s", target.ID, localName))
+
+	return nil
+}
+
+// importedStatus returns the status of the imported snapshot. Unknown statuses of the source are reported as not validated.
+func importedStatus(status string) string {
+	switch status {
+	case models.SnapshotReady, models.SnapshotQuarantined, models.SnapshotLagging:
+		return status
+	}
+
+	return models.SnapshotNotValidated
+}
+
+// localSnapshots returns names of snapshots of the local pool.
+func (j *ImportJob) localSnapshots() (map[string]struct{}, error) {
+	snapshots, err := j.cloneManager.GetSnapshots()
+	if err != nil {
+		if _, ok := errors.Cause(err).(*zfs.EmptyPoolError); ok {
+			return map[string]struct{}{}, nil
+		}
+
+		return nil, errors.Wrap(err, "failed to get local snapshots")
+	}
+
+	names := make(map[string]struct{}, len(snapshots))
+
+	for _, snapshot := range snapshots {
+		names[snapshot.ID] = struct{}{}
+	}
+
+	return names, nil
+}
+
+// chooseImportSnapshot returns the requested snapshot or the latest snapshot that is not quarantined.
+// Timestamps of the API have a fixed-width format, so they are compared as strings.
+func chooseImportSnapshot(snapshots []*models.Snapshot, snapshotID string) (*models.Snapshot, error) {
+	var latest *models.Snapshot
+
+	for _, snapshot := range snapshots {
+		if snapshotID != "" {
+			if snapshot.ID == snapshotID {
+				return snapshot, nil
+			}
+
+			continue
+		}
+
+		if snapshot.IsQuarantined() {
+			continue
+		}
+
+		if latest == nil || snapshot.DataStateAt > latest.DataStateAt {
+			latest = snapshot
+		}
+	}
+
+	if latest == nil {
+		if snapshotID != "" {
+			return nil, errors.Errorf("snapshot %q not found on the source instance", snapshotID)
+		}
+
+		return nil, errors.New("no snapshots available for import on the source instance")
+	}
+
+	return latest, nil
+}
+
+// importedSnapshotName maps a snapshot of the source pool to a snapshot of the local pool.
+// Each source dataset is received into a separate local dataset, so incremental streams keep their lineage.
+func importedSnapshotName(localPool string, snapshot *models.Snapshot) (string, error) {
+	relativeName := strings.TrimPrefix(snapshot.ID, snapshot.Pool)
+
+	if snapshot.Pool == "" || relativeName == snapshot.ID {
+		return "", errors.Errorf("failed to define the source pool of snapshot %q", snapshot.ID)
+	}
+
+	idx := strings.Index(relativeName, "@")
+	if idx == -1 {
+		return "", errors.Errorf("invalid snapshot name %q", snapshot.ID)
+	}
+
+	dataset := importedDataset
+
+	if childDataset := strings.Trim(relativeName[:idx], "/"); childDataset != "" {
+		dataset += "_" + strings.ReplaceAll(childDataset, "/", "_")
+	}
+
+	return localPool + "/" + dataset + relativeName[idx:], nil
+}
+
+// chooseBaseSnapshot returns the latest source snapshot of the same dataset taken before the target one
+// that has been imported already.
+func chooseBaseSnapshot(localPool string, target *models.Snapshot, sourceSnapshots []*models.Snapshot,
+	localSnapshots map[string]struct{}) *models.Snapshot {
+	var base *models.Snapshot
+
+	targetDataset := datasetName(target.ID)
+
+	for _, snapshot := range sourceSnapshots {
+		if snapshot.ID == target.ID || datasetName(snapshot.ID) != targetDataset || snapshot.CreatedAt >= target.CreatedAt {
+			continue
+		}
+
+		localName, err := importedSnapshotName(localPool, snapshot)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := localSnapshots[localName]; !ok {
+			continue
+		}
+
+		if base == nil || snapshot.CreatedAt > base.CreatedAt {
+			base = snapshot
+		}
+	}
+
+	return base
+}
+
+// hasDatasetSnapshots checks if the dataset of the snapshot has other snapshots.
+func hasDatasetSnapshots(snapshots map[string]struct{}, snapshotName string) bool {
+	dataset := datasetName(snapshotName)
+
+	for name := range snapshots {
+		if datasetName(name) == dataset {
+			return true
+		}
+	}
+
+	return false
+}
+
+func datasetName(snapshotName string) string {
+	if idx := strings.Index(snapshotName, "@"); idx != -1 {
+		return snapshotName[:idx]
+	}
+
+	return snapshotName
+}
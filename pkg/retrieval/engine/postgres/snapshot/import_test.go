@@ -0,0 +1,116 @@
+/*
+2021 © Postgres.ai
+*/
+
+package snapshot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+)
+
+func TestImportedSnapshotName(t *testing.T) {
+	testCases := []struct {
+		snapshot *models.Snapshot
+		name     string
+		err      string
+	}{
+		{
+			snapshot: &models.Snapshot{ID: "dblab_pool@snapshot_20210127133008", Pool: "dblab_pool"},
+			name:     "local_pool/imported@snapshot_20210127133008",
+		},
+		{
+			snapshot: &models.Snapshot{ID: "dblab_pool/clone_pre_20210127123000@snapshot_20210127133008", Pool: "dblab_pool"},
+			name:     "local_pool/imported_clone_pre_20210127123000@snapshot_20210127133008",
+		},
+		{
+			snapshot: &models.Snapshot{ID: "pools/main/clone_pre_1/nested@snapshot_1", Pool: "pools/main"},
+			name:     "local_pool/imported_clone_pre_1_nested@snapshot_1",
+		},
+		{
+			snapshot: &models.Snapshot{ID: "dblab_pool@snapshot_20210127133008"},
+			err:      `failed to define the source pool of snapshot "dblab_pool@snapshot_20210127133008"`,
+		},
+		{
+			snapshot: &models.Snapshot{ID: "dblab_pool/clone", Pool: "dblab_pool"},
+			err:      `invalid snapshot name "dblab_pool/clone"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		name, err := importedSnapshotName("local_pool", tc.snapshot)
+
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err)
+			continue
+		}
+
+		require.NoError(t, err)
+		assert.Equal(t, tc.name, name)
+	}
+}
+
+func TestChooseImportSnapshot(t *testing.T) {
+	snapshots := []*models.Snapshot{
+		{ID: "pool@snapshot_1", DataStateAt: "2021-01-27 10:00:00 UTC"},
+		{ID: "pool@snapshot_3", DataStateAt: "2021-01-27 12:00:00 UTC", Status: models.SnapshotQuarantined},
+		{ID: "pool@snapshot_2", DataStateAt: "2021-01-27 11:00:00 UTC", Status: models.SnapshotReady},
+	}
+
+	latest, err := chooseImportSnapshot(snapshots, "")
+	require.NoError(t, err)
+	assert.Equal(t, "pool@snapshot_2", latest.ID)
+
+	requested, err := chooseImportSnapshot(snapshots, "pool@snapshot_3")
+	require.NoError(t, err)
+	assert.Equal(t, "pool@snapshot_3", requested.ID)
+
+	_, err = chooseImportSnapshot(snapshots, "pool@snapshot_4")
+	assert.EqualError(t, err, `snapshot "pool@snapshot_4" not found on the source instance`)
+
+	_, err = chooseImportSnapshot(snapshots[1:2], "")
+	assert.EqualError(t, err, "no snapshots available for import on the source instance")
+}
+
+func TestImportedStatus(t *testing.T) {
+	assert.Equal(t, models.SnapshotReady, importedStatus(models.SnapshotReady))
+	assert.Equal(t, models.SnapshotQuarantined, importedStatus(models.SnapshotQuarantined))
+	assert.Equal(t, models.SnapshotLagging, importedStatus(models.SnapshotLagging))
+	assert.Equal(t, models.SnapshotNotValidated, importedStatus(""))
+	assert.Equal(t, models.SnapshotNotValidated, importedStatus("-"))
+}
+
+func TestChooseBaseSnapshot(t *testing.T) {
+	sourceSnapshots := []*models.Snapshot{
+		{ID: "pool@snapshot_4", CreatedAt: "2021-01-27 13:00:00 UTC", Pool: "pool"},
+		{ID: "pool/clone_pre_1@snapshot_3", CreatedAt: "2021-01-27 12:00:00 UTC", Pool: "pool"},
+		{ID: "pool@snapshot_2", CreatedAt: "2021-01-27 11:00:00 UTC", Pool: "pool"},
+		{ID: "pool@snapshot_1", CreatedAt: "2021-01-27 10:00:00 UTC", Pool: "pool"},
+	}
+
+	localSnapshots := map[string]struct{}{
+		"local/imported@snapshot_1":             {},
+		"local/imported@snapshot_2":             {},
+		"local/imported_clone_pre_1@snapshot_3": {},
+	}
+
+	base := chooseBaseSnapshot("local", sourceSnapshots[0], sourceSnapshots, localSnapshots)
+	require.NotNil(t, base)
+	assert.Equal(t, "pool@snapshot_2", base.ID)
+
+	assert.Nil(t, chooseBaseSnapshot("local", sourceSnapshots[3], sourceSnapshots, localSnapshots))
+	assert.Nil(t, chooseBaseSnapshot("local", sourceSnapshots[0], sourceSnapshots, map[string]struct{}{}))
+
+	assert.True(t, hasDatasetSnapshots(localSnapshots, "local/imported@snapshot_4"))
+	assert.False(t, hasDatasetSnapshots(localSnapshots, "local/imported_clone_pre_2@snapshot_5"))
+}
+
+func TestImportOptionsValidation(t *testing.T) {
+	assert.EqualError(t, ImportOptions{}.validate(), "source URL must not be empty")
+	assert.NoError(t, ImportOptions{Source: ImportSource{URL: "https://central.example.com"}, Timetable: "0 * * * *"}.validate())
+	assert.Error(t, ImportOptions{Source: ImportSource{URL: "https://central.example.com"}, Timetable: "invalid"}.validate())
+}
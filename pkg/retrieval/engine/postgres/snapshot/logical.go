@@ -9,12 +9,14 @@ import (
 	"context"
 	"fmt"
 	"path"
+	"sync"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
 
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/config/global"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
@@ -46,6 +48,9 @@ type LogicalInitial struct {
 	globalCfg      *global.Config
 	dbMarker       *dbmarker.Marker
 	queryProcessor *queryProcessor
+	scheduler      *cron.Cron
+	schedulerCtx   context.Context
+	snapshotMutex  sync.Mutex
 }
 
 // LogicalOptions describes options for a logical initialization job.
@@ -54,6 +59,7 @@ type LogicalOptions struct {
 	PreprocessingScript string            `yaml:"preprocessingScript"`
 	Configs             map[string]string `yaml:"configs"`
 	Schedule            Scheduler         `yaml:"schedule"`
+	Validation          Validation        `yaml:"validation"`
 }
 
 // DataPatching allows executing queries to transform data before snapshot taking.
@@ -74,16 +80,28 @@ func NewLogicalInitialJob(cfg config.JobConfig, global *global.Config, cloneMana
 		dbMarker:     cfg.Marker,
 	}
 
-	if err := li.Reload(cfg.Spec.Options); err != nil {
+	if err := li.loadConfig(cfg.Spec.Options); err != nil {
 		return nil, errors.Wrap(err, "failed to unmarshal configuration options")
 	}
 
+	if err := validateScheduler(&li.options.Schedule); err != nil {
+		return nil, errors.Wrap(err, "invalid logicalSnapshot configuration")
+	}
+
+	if err := li.options.Validation.validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid snapshot validation")
+	}
+
 	if li.options.DataPatching.QueryPreprocessing.QueryPath != "" {
 		li.queryProcessor = newQueryProcessor(cfg.Docker, global.Database.Name(), global.Database.User(),
 			li.options.DataPatching.QueryPreprocessing.QueryPath,
 			li.options.DataPatching.QueryPreprocessing.MaxParallelWorkers)
 	}
 
+	if hasSchedulingOptions(&li.options.Schedule) {
+		li.scheduler = cron.New()
+	}
+
 	return li, nil
 }
 
@@ -99,11 +117,132 @@ func (s *LogicalInitial) patchContainerName() string {
 
 // Reload reloads job configuration.
 func (s *LogicalInitial) Reload(cfg map[string]interface{}) (err error) {
+	if err := s.loadConfig(cfg); err != nil {
+		return errors.Wrap(err, "failed to load job config")
+	}
+
+	s.reloadScheduler()
+
+	return nil
+}
+
+func (s *LogicalInitial) loadConfig(cfg map[string]interface{}) error {
 	return options.Unmarshal(cfg, &s.options)
 }
 
+func (s *LogicalInitial) reloadScheduler() {
+	if s.scheduler == nil {
+		log.Msg("Skip schedule reloading because it has not been initialized")
+		return
+	}
+
+	s.scheduler.Stop()
+
+	for _, ent := range s.scheduler.Entries() {
+		s.scheduler.Remove(ent.ID)
+	}
+
+	s.startScheduler(s.schedulerCtx)
+}
+
+func (s *LogicalInitial) startScheduler(ctx context.Context) {
+	if s.scheduler == nil || !hasSchedulingOptions(&s.options.Schedule) {
+		return
+	}
+
+	if s.options.Schedule.Snapshot.Timetable != "" {
+		if _, err := s.scheduler.AddFunc(s.options.Schedule.Snapshot.Timetable, s.runAutoSnapshot(ctx)); err != nil {
+			log.Err(errors.Wrap(err, "failed to schedule a new snapshot job"))
+			return
+		}
+	}
+
+	if s.options.Schedule.Retention.Timetable != "" {
+		if _, err := s.scheduler.AddFunc(s.options.Schedule.Retention.Timetable,
+			s.runAutoCleanup(ctx, s.options.Schedule.Retention)); err != nil {
+			log.Err(errors.Wrap(err, "failed to schedule a new cleanup job"))
+			return
+		}
+	}
+
+	s.scheduler.Start()
+
+	log.Msg("Snapshot scheduler has been started")
+
+	go s.waitToStopScheduler(ctx)
+}
+
+func (s *LogicalInitial) waitToStopScheduler(ctx context.Context) {
+	<-ctx.Done()
+
+	if s.scheduler != nil {
+		log.Msg("Stop snapshot scheduler")
+		s.scheduler.Stop()
+	}
+}
+
+func (s *LogicalInitial) runAutoSnapshot(ctx context.Context) func() {
+	return func() {
+		if ctx.Err() != nil {
+			return
+		}
+
+		syncContainerID, err := s.runningSyncInstance(ctx)
+		if err != nil {
+			log.Err(errors.Wrap(err, "failed to check the sync instance"))
+			return
+		}
+
+		// Without continuous synchronization the data does not change, so there is nothing to take a snapshot of.
+		if syncContainerID == "" {
+			log.Msg("Skip taking a scheduled snapshot because the sync instance is not running")
+			return
+		}
+
+		if err := s.snapshotSyncInstance(ctx, syncContainerID, s.options.Schedule.Snapshot.SyncLag); err != nil {
+			if _, ok := errors.Cause(err).(*skipSnapshotErr); ok {
+				log.Msg(err.Error())
+				return
+			}
+
+			log.Err(errors.Wrap(err, "failed to take a snapshot automatically"))
+		}
+	}
+}
+
+func (s *LogicalInitial) runAutoCleanup(ctx context.Context, retention RetentionSpec) func() {
+	return func() {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if _, err := cleanupSnapshots(s.cloneManager, retention); err != nil {
+			log.Err(errors.Wrap(err, "failed to clean up snapshots automatically"))
+		}
+	}
+}
+
 // Run starts the job.
 func (s *LogicalInitial) Run(ctx context.Context) error {
+	s.schedulerCtx = ctx
+
+	// Start scheduling after initial snapshot.
+	defer s.startScheduler(ctx)
+
+	syncContainerID, err := s.runningSyncInstance(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to check the sync instance")
+	}
+
+	if syncContainerID != "" {
+		return s.snapshotSyncInstance(ctx, syncContainerID, SyncLagGate{})
+	}
+
+	return s.snapshotDataDir(ctx)
+}
+
+// snapshotDataDir prepares the restored data directory and takes a snapshot of it.
+func (s *LogicalInitial) snapshotDataDir(ctx context.Context) error {
 	if s.options.PreprocessingScript != "" {
 		if err := runPreprocessingScript(s.options.PreprocessingScript); err != nil {
 			return err
@@ -127,15 +266,17 @@ func (s *LogicalInitial) Run(ctx context.Context) error {
 		return errors.Wrap(err, "failed to store PostgreSQL configs for the snapshot")
 	}
 
-	if s.queryProcessor != nil {
-		if err := s.runPreprocessingQueries(ctx, dataDir); err != nil {
-			return errors.Wrap(err, "failed to run preprocessing queries")
+	var snapshotStatus string
+
+	if s.queryProcessor != nil || !s.options.Validation.isEmpty() {
+		if snapshotStatus, err = s.patchData(ctx, dataDir); err != nil {
+			return errors.Wrap(err, "failed to patch data")
 		}
 	}
 
 	dataStateAt := extractDataStateAt(s.dbMarker)
 
-	if _, err := s.cloneManager.CreateSnapshot("", dataStateAt); err != nil {
+	if _, err := s.cloneManager.CreateSnapshot("", dataStateAt, snapshotStatus); err != nil {
 		return errors.Wrap(err, "failed to create a snapshot")
 	}
 
@@ -152,10 +293,46 @@ func (s *LogicalInitial) touchConfigFiles() error {
 	return tools.TouchFile(path.Join(dataDir, "pg_hba.conf"))
 }
 
-func (s *LogicalInitial) runPreprocessingQueries(ctx context.Context, dataDir string) error {
+// patchData runs preprocessing queries and validation checks in a patch container. It returns the snapshot status.
+func (s *LogicalInitial) patchData(ctx context.Context, dataDir string) (status string, err error) {
+	patchContID, err := s.startPatchContainer(ctx, dataDir)
+	if err != nil {
+		return "", err
+	}
+
+	defer tools.RemoveContainer(ctx, s.dockerClient, patchContID, cont.StopPhysicalTimeout)
+
+	defer func() {
+		if err != nil {
+			tools.PrintContainerLogs(ctx, s.dockerClient, s.patchContainerName())
+			tools.PrintLastPostgresLogs(ctx, s.dockerClient, s.patchContainerName(), dataDir)
+		}
+	}()
+
+	if s.queryProcessor != nil {
+		if err := s.queryProcessor.applyPreprocessingQueries(ctx, patchContID); err != nil {
+			return "", errors.Wrap(err, "failed to run preprocessing queries")
+		}
+	}
+
+	return s.validateData(ctx, patchContID), nil
+}
+
+// validateData runs validation checks if they are configured and returns the snapshot status.
+func (s *LogicalInitial) validateData(ctx context.Context, containerID string) string {
+	if s.options.Validation.isEmpty() {
+		return ""
+	}
+
+	return newSnapshotValidator(s.dockerClient, s.globalCfg.Database.Name(), s.globalCfg.Database.User(),
+		s.options.Validation).run(ctx, containerID)
+}
+
+// startPatchContainer starts a Postgres container on the data directory and waits for its readiness.
+func (s *LogicalInitial) startPatchContainer(ctx context.Context, dataDir string) (string, error) {
 	pgVersion, err := tools.DetectPGVersion(dataDir)
 	if err != nil {
-		return errors.Wrap(err, "failed to detect the Postgres version")
+		return "", errors.Wrap(err, "failed to detect the Postgres version")
 	}
 
 	patchImage := s.options.DataPatching.DockerImage
@@ -164,17 +341,17 @@ func (s *LogicalInitial) runPreprocessingQueries(ctx context.Context, dataDir st
 	}
 
 	if err := tools.PullImage(ctx, s.dockerClient, patchImage); err != nil {
-		return errors.Wrap(err, "failed to scan image pulling response")
+		return "", errors.Wrap(err, "failed to scan image pulling response")
 	}
 
 	pwd, err := tools.GeneratePassword()
 	if err != nil {
-		return errors.Wrap(err, "failed to generate PostgreSQL password")
+		return "", errors.Wrap(err, "failed to generate PostgreSQL password")
 	}
 
-	hostConfig, err := cont.BuildHostConfig(ctx, s.dockerClient, s.fsPool.DataDir(), s.options.DataPatching.ContainerConfig)
+	hostConfig, err := cont.BuildHostConfig(ctx, s.dockerClient, dataDir, s.options.DataPatching.ContainerConfig)
 	if err != nil {
-		return errors.Wrap(err, "failed to build container host config")
+		return "", errors.Wrap(err, "failed to build container host config")
 	}
 
 	// Run patch container.
@@ -185,36 +362,27 @@ func (s *LogicalInitial) runPreprocessingQueries(ctx context.Context, dataDir st
 		s.patchContainerName(),
 	)
 	if err != nil {
-		return errors.Wrap(err, "failed to create container")
+		return "", errors.Wrap(err, "failed to create container")
 	}
 
-	defer tools.RemoveContainer(ctx, s.dockerClient, patchCont.ID, cont.StopPhysicalTimeout)
-
-	defer func() {
-		if err != nil {
-			tools.PrintContainerLogs(ctx, s.dockerClient, s.patchContainerName())
-			tools.PrintLastPostgresLogs(ctx, s.dockerClient, s.patchContainerName(), dataDir)
-		}
-	}()
-
 	log.Msg(fmt.Sprintf("Running container: %s. ID: %v", s.patchContainerName(), patchCont.ID))
 
 	if err := s.dockerClient.ContainerStart(ctx, patchCont.ID, types.ContainerStartOptions{}); err != nil {
-		return errors.Wrap(err, "failed to start container")
+		tools.RemoveContainer(ctx, s.dockerClient, patchCont.ID, cont.StopPhysicalTimeout)
+		return "", errors.Wrap(err, "failed to start container")
 	}
 
 	log.Msg("Starting PostgreSQL and waiting for readiness")
 	log.Msg(fmt.Sprintf("View logs using the command: %s %s", tools.ViewLogsCmd, s.patchContainerName()))
 
 	if err := tools.CheckContainerReadiness(ctx, s.dockerClient, patchCont.ID); err != nil {
-		return errors.Wrap(err, "failed to readiness check")
-	}
+		tools.PrintContainerLogs(ctx, s.dockerClient, s.patchContainerName())
+		tools.RemoveContainer(ctx, s.dockerClient, patchCont.ID, cont.StopPhysicalTimeout)
 
-	if err := s.queryProcessor.applyPreprocessingQueries(ctx, patchCont.ID); err != nil {
-		return errors.Wrap(err, "failed to run preprocessing queries")
+		return "", errors.Wrap(err, "failed to readiness check")
 	}
 
-	return nil
+	return patchCont.ID, nil
 }
 
 func (s *LogicalInitial) buildContainerConfig(clonePath, patchImage, password string) *container.Config {
@@ -0,0 +1,312 @@
+/*
+2021 © Postgres.ai
+*/
+
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/dbmarker"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/cont"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/databases/postgres/pgconfig"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/util"
+)
+
+// detachedCloneConfig keeps logical replication workers of a snapshot clone from connecting to the source.
+var detachedCloneConfig = map[string]string{
+	"max_logical_replication_workers": "0",
+}
+
+func (s *LogicalInitial) syncInstanceName() string {
+	return cont.SyncInstanceContainerPrefix + s.globalCfg.InstanceID
+}
+
+// runningSyncInstance returns the ID of the running sync instance or an empty string if it is not running.
+func (s *LogicalInitial) runningSyncInstance(ctx context.Context) (string, error) {
+	syncContainer, err := s.dockerClient.ContainerInspect(ctx, s.syncInstanceName())
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return "", nil
+		}
+
+		return "", errors.Wrap(err, "failed to inspect sync container")
+	}
+
+	if syncContainer.State == nil || !syncContainer.State.Running {
+		return "", nil
+	}
+
+	return syncContainer.ID, nil
+}
+
+// snapshotSyncInstance takes a snapshot of the data continuously synchronized by the sync instance.
+// The snapshot is taken from a clone whose subscriptions are dropped, so the sync instance keeps applying changes.
+// The lag gate is checked against the age of the latest change confirmed by all subscriptions.
+func (s *LogicalInitial) snapshotSyncInstance(ctx context.Context, syncContainerID string, lagGate SyncLagGate) (err error) {
+	s.snapshotMutex.Lock()
+	defer s.snapshotMutex.Unlock()
+
+	log.Msg("Take a snapshot of the sync instance: ", s.syncInstanceName())
+
+	if err := s.checkpoint(ctx, syncContainerID); err != nil {
+		return errors.Wrap(err, "failed to make a checkpoint for sync instance")
+	}
+
+	dataStateAt, err := s.getSyncDataStateAt(ctx, syncContainerID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get dataStateAt from the sync instance")
+	}
+
+	log.Msg("Sync instance data state at: ", dataStateAt)
+
+	lagStatus, err := lagGate.check(syncLagSeconds(dataStateAt, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	preDataStateAt := time.Now().Format(tools.DataStateAtFormat)
+	cloneName := fmt.Sprintf("clone%s_%s", pre, preDataStateAt)
+
+	snapshotName, err := s.cloneManager.CreateSnapshot("", preDataStateAt+pre, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to create snapshot")
+	}
+
+	defer func() {
+		if err != nil {
+			if errDestroy := s.cloneManager.DestroySnapshot(snapshotName); errDestroy != nil {
+				log.Err(fmt.Sprintf("Failed to destroy the %q snapshot: %v", snapshotName, errDestroy))
+			}
+		}
+	}()
+
+	if err := s.cloneManager.CreateClone(cloneName, snapshotName); err != nil {
+		return errors.Wrapf(err, "failed to create \"pre\" clone %s", cloneName)
+	}
+
+	defer func() {
+		if err != nil {
+			if errDestroy := s.cloneManager.DestroyClone(cloneName); errDestroy != nil {
+				log.Err(fmt.Sprintf("Failed to destroy clone %q: %v", cloneName, errDestroy))
+			}
+		}
+	}()
+
+	clonePath := path.Join(s.fsPool.ClonesDir(), cloneName, s.fsPool.DataSubDir)
+
+	snapshotStatus, err := s.detachClone(ctx, clonePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to detach the clone from the source")
+	}
+
+	if s.options.PreprocessingScript != "" {
+		if err := runPreprocessingScript(s.options.PreprocessingScript); err != nil {
+			return err
+		}
+	}
+
+	cloneMarker := dbmarker.NewMarker(clonePath)
+
+	if err := cloneMarker.CreateConfig(); err != nil {
+		return errors.Wrap(err, "failed to create a DBMarker config of the database")
+	}
+
+	if err := cloneMarker.SaveConfig(&dbmarker.Config{DataType: dbmarker.LogicalDataType, DataStateAt: dataStateAt}); err != nil {
+		return errors.Wrap(err, "failed to mark the prepared data")
+	}
+
+	if _, err := s.cloneManager.CreateSnapshot(cloneName, dataStateAt, mergeSnapshotStatus(snapshotStatus, lagStatus)); err != nil {
+		return errors.Wrap(err, "failed to create a snapshot")
+	}
+
+	if dsaTime, err := time.Parse(util.DataStateAtFormat, dataStateAt); err == nil {
+		s.fsPool.SetDSA(dsaTime)
+	}
+
+	return nil
+}
+
+// detachClone drops subscriptions of the clone, applies snapshot configs and data patching, and validates the data.
+// It returns the snapshot status.
+func (s *LogicalInitial) detachClone(ctx context.Context, clonePath string) (status string, err error) {
+	cfgManager, err := pgconfig.NewCorrector(clonePath)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create a config manager")
+	}
+
+	if err := cfgManager.TruncateSyncConfig(); err != nil {
+		return "", errors.Wrap(err, "failed to truncate sync config file")
+	}
+
+	if err := cfgManager.ApplyPromotion(detachedCloneConfig); err != nil {
+		return "", errors.Wrap(err, "failed to store detaching configuration")
+	}
+
+	if err := cfgManager.ApplySnapshot(s.options.Configs); err != nil {
+		return "", errors.Wrap(err, "failed to store PostgreSQL configs for the snapshot")
+	}
+
+	patchContID, err := s.startPatchContainer(ctx, clonePath)
+	if err != nil {
+		return "", err
+	}
+
+	defer tools.RemoveContainer(ctx, s.dockerClient, patchContID, cont.StopPhysicalTimeout)
+
+	defer func() {
+		if err != nil {
+			tools.PrintContainerLogs(ctx, s.dockerClient, s.patchContainerName())
+			tools.PrintLastPostgresLogs(ctx, s.dockerClient, s.patchContainerName(), clonePath)
+		}
+	}()
+
+	if err := s.dropSubscriptions(ctx, patchContID); err != nil {
+		return "", errors.Wrap(err, "failed to drop subscriptions")
+	}
+
+	if s.queryProcessor != nil {
+		if err := s.queryProcessor.applyPreprocessingQueries(ctx, patchContID); err != nil {
+			return "", errors.Wrap(err, "failed to run preprocessing queries")
+		}
+	}
+
+	status = s.validateData(ctx, patchContID)
+
+	if err := tools.StopPostgres(ctx, s.dockerClient, patchContID, clonePath, tools.DefaultStopTimeout); err != nil {
+		return "", errors.Wrap(err, "failed to stop Postgres")
+	}
+
+	if err := cfgManager.TruncatePromotionConfig(); err != nil {
+		return "", errors.Wrap(err, "failed to truncate detaching config file")
+	}
+
+	return status, nil
+}
+
+// dropSubscriptions drops subscriptions without touching their replication slots on the source.
+func (s *LogicalInitial) dropSubscriptions(ctx context.Context, containerID string) error {
+	output, err := tools.ExecCommandWithOutput(ctx, s.dockerClient, containerID, types.ExecConfig{
+		Cmd: []string{"psql", "-U", s.globalCfg.Database.User(), "-d", s.globalCfg.Database.Name(), "-XAtc",
+			"select d.datname, s.subname from pg_catalog.pg_subscription s join pg_catalog.pg_database d on d.oid = s.subdbid"},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to get the list of subscriptions")
+	}
+
+	subscriptions, err := parseSubscriptionList(output)
+	if err != nil {
+		return err
+	}
+
+	for dbName, subNames := range subscriptions {
+		dropQuery := buildDropSubscriptionsQuery(subNames)
+
+		log.Msg(fmt.Sprintf("Dropping subscriptions of the database %q: %s", dbName, strings.Join(subNames, ", ")))
+
+		if out, err := tools.ExecCommandWithInput(ctx, s.dockerClient, containerID, types.ExecConfig{
+			Cmd: []string{"psql", "-U", s.globalCfg.Database.User(), "-d", dbName, "-X", "-v", "ON_ERROR_STOP=1"},
+		}, strings.NewReader(dropQuery)); err != nil {
+			log.Dbg(out)
+			return errors.Wrapf(err, "failed to drop subscriptions of the database %q", dbName)
+		}
+	}
+
+	return nil
+}
+
+func (s *LogicalInitial) checkpoint(ctx context.Context, containerID string) error {
+	commandCheckpoint := []string{"psql", "-U", s.globalCfg.Database.User(), "-d", s.globalCfg.Database.Name(), "-XAtc", "checkpoint"}
+	log.Msg("Run checkpoint command", commandCheckpoint)
+
+	if err := tools.ExecCommand(ctx, s.dockerClient, containerID, types.ExecConfig{Cmd: commandCheckpoint}); err != nil {
+		return errors.Wrap(err, "failed to make checkpoint")
+	}
+
+	return nil
+}
+
+// getSyncDataStateAt returns the time of the latest change confirmed by all subscriptions.
+func (s *LogicalInitial) getSyncDataStateAt(ctx context.Context, containerID string) (string, error) {
+	output, err := tools.ExecCommandWithOutput(ctx, s.dockerClient, containerID, types.ExecConfig{
+		Cmd: []string{"psql", "-U", s.globalCfg.Database.User(), "-d", s.globalCfg.Database.Name(), "-XAtc",
+			"select coalesce(to_char(min(latest_end_time) at time zone 'UTC', 'YYYYMMDDHH24MISS'), '') " +
+				"from pg_catalog.pg_stat_subscription where relid is null"},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if output == "" {
+		log.Msg("Subscriptions have not received any data yet. Use the current time as dataStateAt")
+
+		return time.Now().UTC().Format(tools.DataStateAtFormat), nil
+	}
+
+	if _, err := time.Parse(util.DataStateAtFormat, output); err != nil {
+		return "", errors.Errorf("unexpected dataStateAt value: %q", output)
+	}
+
+	return output, nil
+}
+
+// syncLagSeconds returns the lag of the data state or an unknown lag if the data state cannot be parsed.
+func syncLagSeconds(dataStateAt string, now time.Time) int64 {
+	dsa, err := time.Parse(util.DataStateAtFormat, dataStateAt)
+	if err != nil {
+		return tools.UnknownLag
+	}
+
+	if lag := int64(now.Sub(dsa).Seconds()); lag > 0 {
+		return lag
+	}
+
+	return 0
+}
+
+// parseSubscriptionList groups subscription names by databases.
+func parseSubscriptionList(output string) (map[string][]string, error) {
+	subscriptions := make(map[string][]string)
+
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, "|", 2)
+		if len(fields) != 2 {
+			return nil, errors.Errorf("unexpected subscription line: %q", line)
+		}
+
+		subscriptions[fields[0]] = append(subscriptions[fields[0]], fields[1])
+	}
+
+	return subscriptions, nil
+}
+
+// buildDropSubscriptionsQuery builds queries dropping subscriptions.
+// Slots are detached first, so the replication slots on the source keep serving the sync instance.
+func buildDropSubscriptionsQuery(subNames []string) string {
+	queries := strings.Builder{}
+
+	for _, subName := range subNames {
+		name := pgx.Identifier{subName}.Sanitize()
+
+		queries.WriteString(fmt.Sprintf("alter subscription %s disable;\n", name))
+		queries.WriteString(fmt.Sprintf("alter subscription %s set (slot_name = none);\n", name))
+		queries.WriteString(fmt.Sprintf("drop subscription %s;\n", name))
+	}
+
+	return queries.String()
+}
@@ -0,0 +1,41 @@
+/*
+2021 © Postgres.ai
+*/
+
+package snapshot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSubscriptionList(t *testing.T) {
+	subscriptions, err := parseSubscriptionList("app|dblab_sync_app\napp|extra\ntest|dblab_sync_test\n")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string][]string{
+		"app":  {"dblab_sync_app", "extra"},
+		"test": {"dblab_sync_test"},
+	}, subscriptions)
+
+	subscriptions, err = parseSubscriptionList("")
+	require.NoError(t, err)
+	assert.Empty(t, subscriptions)
+
+	_, err = parseSubscriptionList("app")
+	assert.Error(t, err)
+}
+
+func TestBuildDropSubscriptionsQuery(t *testing.T) {
+	query := buildDropSubscriptionsQuery([]string{"dblab_sync_app", `Weird"Name`})
+
+	assert.Equal(t, `alter subscription "dblab_sync_app" disable;
+alter subscription "dblab_sync_app" set (slot_name = none);
+drop subscription "dblab_sync_app";
+alter subscription "Weird""Name" disable;
+alter subscription "Weird""Name" set (slot_name = none);
+drop subscription "Weird""Name";
+`, query)
+}
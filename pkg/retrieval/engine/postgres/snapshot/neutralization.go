@@ -0,0 +1,54 @@
+/*
+2021 © Postgres.ai
+*/
+
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/neutralization"
+)
+
+// containerQuerier runs queries using psql inside the container.
+func containerQuerier(dockerClient *client.Client, containerID, username string) neutralization.Querier {
+	return func(ctx context.Context, dbName, query string) (string, error) {
+		output, err := tools.ExecCommandWithInput(ctx, dockerClient, containerID, types.ExecConfig{
+			Cmd: []string{"psql", "-U", username, "-d", dbName, "-XAtq", "-v", "ON_ERROR_STOP=1"},
+			Env: []string{"PGOPTIONS=-c client_min_messages=warning"},
+		}, strings.NewReader(query))
+		if err != nil {
+			log.Dbg(output)
+			return "", err
+		}
+
+		return output, nil
+	}
+}
+
+// neutralize disables objects of the instance that can reach other systems and stores the report in the data directory.
+func neutralize(ctx context.Context, querier neutralization.Querier, dbName, dataDir string, options neutralization.Options) error {
+	log.Msg("Neutralize objects reaching other systems")
+
+	report, err := neutralization.NewNeutralizer(querier, dbName, options).Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Msg(fmt.Sprintf("Neutralized: %d subscriptions, %d replication slots, %d cron jobs, %d foreign servers",
+		len(report.Subscriptions), len(report.ReplicationSlots), len(report.CronJobs), len(report.ForeignServers)))
+
+	if err := neutralization.SaveReport(dataDir, report); err != nil {
+		return errors.Wrap(err, "failed to save the neutralization report")
+	}
+
+	return nil
+}
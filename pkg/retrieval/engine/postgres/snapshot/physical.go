@@ -33,11 +33,13 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/cont"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/defaults"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/health"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/neutralization"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/pgtool"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/options"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/databases/postgres/pgconfig"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/pool"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/thinclones"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/util"
 )
 
@@ -96,13 +98,15 @@ type PhysicalInitial struct {
 
 // PhysicalOptions describes options for a physical initialization job.
 type PhysicalOptions struct {
-	SkipStartSnapshot   bool              `yaml:"skipStartSnapshot"`
-	Promotion           Promotion         `yaml:"promotion"`
-	PreprocessingScript string            `yaml:"preprocessingScript"`
-	Configs             map[string]string `yaml:"configs"`
-	Sysctls             map[string]string `yaml:"sysctls"`
-	Envs                map[string]string `yaml:"envs"`
-	Scheduler           *Scheduler        `yaml:"scheduler"`
+	SkipStartSnapshot   bool                   `yaml:"skipStartSnapshot"`
+	Promotion           Promotion              `yaml:"promotion"`
+	PreprocessingScript string                 `yaml:"preprocessingScript"`
+	Configs             map[string]string      `yaml:"configs"`
+	Sysctls             map[string]string      `yaml:"sysctls"`
+	Envs                map[string]string      `yaml:"envs"`
+	Scheduler           *Scheduler             `yaml:"scheduler"`
+	Validation          Validation             `yaml:"validation"`
+	Neutralization      neutralization.Options `yaml:"neutralization"`
 }
 
 // Promotion describes promotion options.
@@ -124,14 +128,69 @@ type HealthCheck struct {
 
 // Scheduler provides scheduler options.
 type Scheduler struct {
-	Snapshot  ScheduleSpec `yaml:"snapshot"`
-	Retention ScheduleSpec `yaml:"retention"`
+	Snapshot  ScheduleSpec  `yaml:"snapshot"`
+	Retention RetentionSpec `yaml:"retention"`
 }
 
 // ScheduleSpec defines options to set up scheduler components.
 type ScheduleSpec struct {
-	Timetable string `yaml:"timetable"`
-	Limit     int    `yaml:"limit"`
+	Timetable string      `yaml:"timetable"`
+	Limit     int         `yaml:"limit"`
+	SyncLag   SyncLagGate `yaml:"syncLag"`
+}
+
+// RetentionSpec defines options of the scheduled snapshot cleanup.
+// Limit keeps the newest snapshots, Keep defines time-based rules. A snapshot kept by any rule is not destroyed.
+type RetentionSpec struct {
+	Timetable string     `yaml:"timetable"`
+	Limit     int        `yaml:"limit"`
+	Keep      KeepPolicy `yaml:"keep"`
+	DryRun    bool       `yaml:"dryRun"`
+}
+
+// KeepPolicy defines the number of hourly, daily, weekly and monthly snapshots to keep.
+type KeepPolicy struct {
+	Hourly  int `yaml:"hourly"`
+	Daily   int `yaml:"daily"`
+	Weekly  int `yaml:"weekly"`
+	Monthly int `yaml:"monthly"`
+}
+
+// policy returns the retention policy of the snapshot cleanup.
+func (r RetentionSpec) policy() thinclones.RetentionPolicy {
+	return thinclones.RetentionPolicy{
+		Limit:   r.Limit,
+		Hourly:  r.Keep.Hourly,
+		Daily:   r.Keep.Daily,
+		Weekly:  r.Keep.Weekly,
+		Monthly: r.Keep.Monthly,
+	}
+}
+
+func (r RetentionSpec) validate() error {
+	if r.Limit < 0 || r.Keep.Hourly < 0 || r.Keep.Daily < 0 || r.Keep.Weekly < 0 || r.Keep.Monthly < 0 {
+		return errors.New("retention values must not be negative")
+	}
+
+	if r.Timetable != "" && !r.policy().IsDefined() {
+		return errors.New("retention requires a limit or at least one keep rule")
+	}
+
+	return nil
+}
+
+// cleanupSnapshots destroys snapshots expired by the retention policy or only reports them in the dry-run mode.
+func cleanupSnapshots(snapshotter pool.Snapshotter, retention RetentionSpec) ([]string, error) {
+	snapshots, err := snapshotter.CleanupSnapshots(retention.policy(), retention.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	if retention.DryRun {
+		log.Msg(fmt.Sprintf("Dry run: %d snapshots would be destroyed by the retention policy", len(snapshots)))
+	}
+
+	return snapshots, nil
 }
 
 // QueryPreprocessing defines query preprocessing options.
@@ -203,27 +262,54 @@ func (p *PhysicalInitial) validateConfig() error {
 		return err
 	}
 
+	if !p.options.Validation.isEmpty() && !p.options.Promotion.Enabled {
+		return errors.New("snapshot validation requires the promotion to be enabled")
+	}
+
+	if err := p.options.Validation.validate(); err != nil {
+		return errors.Wrap(err, "invalid snapshot validation")
+	}
+
+	if p.options.Neutralization.IsEnabled() && !p.options.Promotion.Enabled {
+		return errors.New("neutralization requires the promotion to be enabled")
+	}
+
 	return nil
 }
 
 func (p *PhysicalInitial) hasSchedulingOptions() bool {
-	return p.options.Scheduler != nil &&
-		(p.options.Scheduler.Snapshot.Timetable != "" || p.options.Scheduler.Retention.Timetable != "")
+	return hasSchedulingOptions(p.options.Scheduler)
 }
 
 func (p *PhysicalInitial) validateScheduler() error {
-	if !p.hasSchedulingOptions() {
+	return validateScheduler(p.options.Scheduler)
+}
+
+func hasSchedulingOptions(scheduler *Scheduler) bool {
+	return scheduler != nil && (scheduler.Snapshot.Timetable != "" || scheduler.Retention.Timetable != "")
+}
+
+func validateScheduler(scheduler *Scheduler) error {
+	if !hasSchedulingOptions(scheduler) {
 		return nil
 	}
 
 	specParser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
 
-	if _, err := specParser.Parse(p.options.Scheduler.Snapshot.Timetable); p.options.Scheduler.Snapshot.Timetable != "" && err != nil {
-		return errors.Wrapf(err, "failed to parse schedule timetable %q", p.options.Scheduler.Snapshot.Timetable)
+	if _, err := specParser.Parse(scheduler.Snapshot.Timetable); scheduler.Snapshot.Timetable != "" && err != nil {
+		return errors.Wrapf(err, "failed to parse schedule timetable %q", scheduler.Snapshot.Timetable)
+	}
+
+	if _, err := specParser.Parse(scheduler.Retention.Timetable); scheduler.Retention.Timetable != "" && err != nil {
+		return errors.Wrapf(err, "failed to parse retention timetable %q", scheduler.Retention.Timetable)
+	}
+
+	if err := scheduler.Retention.validate(); err != nil {
+		return errors.Wrap(err, "invalid retention options")
 	}
 
-	if _, err := specParser.Parse(p.options.Scheduler.Retention.Timetable); p.options.Scheduler.Retention.Timetable != "" && err != nil {
-		return errors.Wrapf(err, "failed to parse retention timetable %q", p.options.Scheduler.Retention.Timetable)
+	if err := scheduler.Snapshot.SyncLag.validate(); err != nil {
+		return errors.Wrap(err, "invalid sync lag options")
 	}
 
 	return nil
@@ -281,10 +367,11 @@ func (p *PhysicalInitial) Run(ctx context.Context) (err error) {
 		return nil
 	}
 
-	return p.run(p.schedulerCtx)
+	return p.run(p.schedulerCtx, "")
 }
 
-func (p *PhysicalInitial) run(ctx context.Context) (err error) {
+// run takes a snapshot. The lag status is set to the snapshot unless it fails validation.
+func (p *PhysicalInitial) run(ctx context.Context, lagStatus string) (err error) {
 	select {
 	case <-ctx.Done():
 		if p.scheduler != nil {
@@ -322,7 +409,7 @@ func (p *PhysicalInitial) run(ctx context.Context) (err error) {
 	}
 
 	// Prepare pre-snapshot.
-	snapshotName, err := p.cloneManager.CreateSnapshot("", preDataStateAt+pre)
+	snapshotName, err := p.cloneManager.CreateSnapshot("", preDataStateAt+pre, "")
 	if err != nil {
 		return errors.Wrap(err, "failed to create snapshot")
 	}
@@ -347,9 +434,12 @@ func (p *PhysicalInitial) run(ctx context.Context) (err error) {
 		}
 	}()
 
+	var snapshotStatus string
+
 	// Promotion.
 	if p.options.Promotion.Enabled {
-		if err := p.promoteInstance(ctx, path.Join(p.fsPool.ClonesDir(), cloneName, p.fsPool.DataSubDir), syState); err != nil {
+		snapshotStatus, err = p.promoteInstance(ctx, path.Join(p.fsPool.ClonesDir(), cloneName, p.fsPool.DataSubDir), syState)
+		if err != nil {
 			return errors.Wrap(err, "failed to promote instance")
 		}
 	}
@@ -367,7 +457,7 @@ func (p *PhysicalInitial) run(ctx context.Context) (err error) {
 	}
 
 	// Create a snapshot.
-	if _, err := p.cloneManager.CreateSnapshot(cloneName, p.dbMark.DataStateAt); err != nil {
+	if _, err := p.cloneManager.CreateSnapshot(cloneName, p.dbMark.DataStateAt, mergeSnapshotStatus(snapshotStatus, lagStatus)); err != nil {
 		return errors.Wrap(err, "failed to create a snapshot")
 	}
 
@@ -404,6 +494,21 @@ func (p *PhysicalInitial) checkSyncInstance(ctx context.Context) (string, error)
 	return extractedDataStateAt, nil
 }
 
+// checkSyncLag returns the lag status of the snapshot or an error skipping it.
+func (p *PhysicalInitial) checkSyncLag(ctx context.Context, lagGate SyncLagGate) (string, error) {
+	if lagGate.MaxSeconds == 0 {
+		return "", nil
+	}
+
+	lagSeconds, err := getSyncLag(ctx, p.dockerClient, p.syncInstanceName(), p.globalCfg.Database.User(),
+		p.globalCfg.Database.Name(), p.fsPool.DataDir())
+	if err != nil {
+		return "", errors.Wrap(err, "failed to check the sync instance lag")
+	}
+
+	return lagGate.check(lagSeconds)
+}
+
 func (p *PhysicalInitial) syncInstanceName() string {
 	return cont.SyncInstanceContainerPrefix + p.globalCfg.InstanceID
 }
@@ -422,7 +527,7 @@ func (p *PhysicalInitial) startScheduler(ctx context.Context) {
 
 	if p.options.Scheduler.Retention.Timetable != "" {
 		if _, err := p.scheduler.AddFunc(p.options.Scheduler.Retention.Timetable,
-			p.runAutoCleanup(p.options.Scheduler.Retention.Limit)); err != nil {
+			p.runAutoCleanup(p.options.Scheduler.Retention)); err != nil {
 			log.Err(errors.Wrap(err, "failed to schedule a new cleanup job"))
 			return
 		}
@@ -446,15 +551,27 @@ func (p *PhysicalInitial) waitToStopScheduler() {
 
 func (p *PhysicalInitial) runAutoSnapshot(ctx context.Context) func() {
 	return func() {
-		if err := p.run(ctx); err != nil {
+		lagStatus, err := p.checkSyncLag(ctx, p.options.Scheduler.Snapshot.SyncLag)
+		if err != nil {
+			if _, ok := errors.Cause(err).(*skipSnapshotErr); ok {
+				log.Msg(err.Error())
+				return
+			}
+
+			log.Err(errors.Wrap(err, "failed to take a snapshot automatically"))
+
+			return
+		}
+
+		if err := p.run(ctx, lagStatus); err != nil {
 			log.Err(errors.Wrap(err, "failed to take a snapshot automatically"))
 		}
 	}
 }
 
-func (p *PhysicalInitial) runAutoCleanup(retentionLimit int) func() {
+func (p *PhysicalInitial) runAutoCleanup(retention RetentionSpec) func() {
 	return func() {
-		if err := p.cleanupSnapshots(retentionLimit); err != nil {
+		if err := p.cleanupSnapshots(retention); err != nil {
 			log.Err(errors.Wrap(err, "failed to clean up snapshots automatically"))
 		}
 	}
@@ -464,7 +581,7 @@ func (p *PhysicalInitial) promoteContainerName() string {
 	return promoteContainerPrefix + p.globalCfg.InstanceID
 }
 
-func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string, syState syncState) (err error) {
+func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string, syState syncState) (status string, err error) {
 	p.promotionMutex.Lock()
 	defer p.promotionMutex.Unlock()
 
@@ -472,22 +589,22 @@ func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string,
 
 	cfgManager, err := pgconfig.NewCorrector(clonePath)
 	if err != nil {
-		return errors.Wrap(err, "failed to init configs manager")
+		return "", errors.Wrap(err, "failed to init configs manager")
 	}
 
 	// Adjust recovery configuration.
 	if err := cfgManager.AdjustRecoveryFiles(); err != nil {
-		return errors.Wrap(err, "failed to adjust recovery configuration")
+		return "", errors.Wrap(err, "failed to adjust recovery configuration")
 	}
 
 	recoveryFileConfig, err := cfgManager.ReadRecoveryConfig()
 	if err != nil {
-		return errors.Wrap(err, "failed to read recovery configuration file")
+		return "", errors.Wrap(err, "failed to read recovery configuration file")
 	}
 
 	if len(recoveryFileConfig) == 0 {
 		if err := cfgManager.RemoveRecoveryConfig(); err != nil {
-			return errors.Wrap(err, "failed to remove recovery config file")
+			return "", errors.Wrap(err, "failed to remove recovery config file")
 		}
 	}
 
@@ -498,7 +615,7 @@ func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string,
 		recoveryConfig = buildRecoveryConfig(recoveryFileConfig, p.options.Promotion.Recovery)
 
 		if err := cfgManager.ApplyRecovery(recoveryFileConfig); err != nil {
-			return errors.Wrap(err, "failed to apply recovery configuration")
+			return "", errors.Wrap(err, "failed to apply recovery configuration")
 		}
 	} else if err := cfgManager.RemoveRecoveryConfig(); err != nil {
 		log.Err(errors.Wrap(err, "failed to remove recovery config file"))
@@ -507,13 +624,20 @@ func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string,
 	// Apply promotion configs.
 	if promotionConfig := p.options.Promotion.Configs; len(promotionConfig) > 0 {
 		if err := cfgManager.ApplyPromotion(p.options.Promotion.Configs); err != nil {
-			return errors.Wrap(err, "failed to store prepared configuration")
+			return "", errors.Wrap(err, "failed to store prepared configuration")
+		}
+	}
+
+	// The startup configuration of the neutralization is kept in the snapshot, so clones start neutralized as well.
+	if p.options.Neutralization.IsEnabled() {
+		if err := neutralization.ApplyStartupConfig(cfgManager, p.options.Neutralization); err != nil {
+			return "", errors.Wrap(err, "failed to apply the neutralization configuration")
 		}
 	}
 
 	hostConfig, err := p.buildHostConfig(ctx, clonePath)
 	if err != nil {
-		return errors.Wrap(err, "failed to build container host config")
+		return "", errors.Wrap(err, "failed to build container host config")
 	}
 
 	promoteImage := p.options.Promotion.DockerImage
@@ -522,12 +646,12 @@ func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string,
 	}
 
 	if err := tools.PullImage(ctx, p.dockerClient, promoteImage); err != nil {
-		return errors.Wrap(err, "failed to scan image pulling response")
+		return "", errors.Wrap(err, "failed to scan image pulling response")
 	}
 
 	pwd, err := tools.GeneratePassword()
 	if err != nil {
-		return errors.Wrap(err, "failed to generate PostgreSQL password")
+		return "", errors.Wrap(err, "failed to generate PostgreSQL password")
 	}
 
 	// Run promotion container.
@@ -539,7 +663,7 @@ func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string,
 	)
 
 	if err != nil {
-		return errors.Wrap(err, "failed to create container")
+		return "", errors.Wrap(err, "failed to create container")
 	}
 
 	defer tools.RemoveContainer(ctx, p.dockerClient, promoteCont.ID, cont.StopPhysicalTimeout)
@@ -554,7 +678,7 @@ func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string,
 	log.Msg(fmt.Sprintf("Running container: %s. ID: %v", p.promoteContainerName(), promoteCont.ID))
 
 	if err := p.dockerClient.ContainerStart(ctx, promoteCont.ID, types.ContainerStartOptions{}); err != nil {
-		return errors.Wrap(err, "failed to start container")
+		return "", errors.Wrap(err, "failed to start container")
 	}
 
 	if syState.DSA == "" {
@@ -574,12 +698,12 @@ func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string,
 	log.Msg(fmt.Sprintf("View logs using the command: %s %s", tools.ViewLogsCmd, p.promoteContainerName()))
 
 	if err := tools.CheckContainerReadiness(ctx, p.dockerClient, promoteCont.ID); err != nil {
-		return errors.Wrap(err, "failed to readiness check")
+		return "", errors.Wrap(err, "failed to readiness check")
 	}
 
 	shouldBePromoted, err := p.checkRecovery(ctx, promoteCont.ID)
 	if err != nil {
-		return errors.Wrap(err, "failed to check recovery mode")
+		return "", errors.Wrap(err, "failed to check recovery mode")
 	}
 
 	log.Msg("Should be promoted: ", shouldBePromoted)
@@ -588,49 +712,62 @@ func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string,
 	if shouldBePromoted == "t" {
 		// Promote PGDATA.
 		if err := p.runPromoteCommand(ctx, promoteCont.ID, clonePath); err != nil {
-			return errors.Wrapf(err, "failed to promote PGDATA: %s", clonePath)
+			return "", errors.Wrapf(err, "failed to promote PGDATA: %s", clonePath)
 		}
 
 		isInRecovery, err := p.checkRecovery(ctx, promoteCont.ID)
 		if err != nil {
-			return errors.Wrap(err, "failed to check recovery mode after promotion")
+			return "", errors.Wrap(err, "failed to check recovery mode after promotion")
 		}
 
 		if isInRecovery != "f" {
-			return errors.Errorf("PostgreSQL is in recovery, promotion has been failed: %s", clonePath)
+			return "", errors.Errorf("PostgreSQL is in recovery, promotion has been failed: %s", clonePath)
 		}
 	}
 
 	if err := p.markDSA(ctx, syState.DSA, promoteCont.ID, clonePath, cfgManager.GetPgVersion()); err != nil {
-		return errors.Wrap(err, "failed to mark dataStateAt")
+		return "", errors.Wrap(err, "failed to mark dataStateAt")
+	}
+
+	if p.options.Neutralization.IsEnabled() {
+		querier := containerQuerier(p.dockerClient, promoteCont.ID, p.globalCfg.Database.User())
+
+		if err := neutralize(ctx, querier, p.globalCfg.Database.Name(), clonePath, p.options.Neutralization); err != nil {
+			return "", errors.Wrap(err, "failed to neutralize the instance")
+		}
 	}
 
 	if p.queryProcessor != nil {
 		if err := p.queryProcessor.applyPreprocessingQueries(ctx, promoteCont.ID); err != nil {
-			return errors.Wrap(err, "failed to run preprocessing queries")
+			return "", errors.Wrap(err, "failed to run preprocessing queries")
 		}
 	}
 
+	if !p.options.Validation.isEmpty() {
+		status = newSnapshotValidator(p.dockerClient, p.globalCfg.Database.Name(), p.globalCfg.Database.User(),
+			p.options.Validation).run(ctx, promoteCont.ID)
+	}
+
 	// Checkpoint.
 	if err := p.checkpoint(ctx, promoteCont.ID); err != nil {
-		return err
+		return "", err
 	}
 
 	if err := cfgManager.RemoveRecoveryConfig(); err != nil {
-		return errors.Wrap(err, "failed to remove recovery config file")
+		return "", errors.Wrap(err, "failed to remove recovery config file")
 	}
 
 	if err := cfgManager.TruncateSyncConfig(); err != nil {
-		return errors.Wrap(err, "failed to truncate sync config file")
+		return "", errors.Wrap(err, "failed to truncate sync config file")
 	}
 
 	if err := cfgManager.TruncatePromotionConfig(); err != nil {
-		return errors.Wrap(err, "failed to truncate promotion config file")
+		return "", errors.Wrap(err, "failed to truncate promotion config file")
 	}
 
 	// Apply configs to the snapshot.
 	if err := cfgManager.ApplySnapshot(p.options.Configs); err != nil {
-		return errors.Wrap(err, "failed to store prepared configuration")
+		return "", errors.Wrap(err, "failed to store prepared configuration")
 	}
 
 	if err := tools.StopPostgres(ctx, p.dockerClient, promoteCont.ID, clonePath, tools.DefaultStopTimeout); err != nil {
@@ -638,7 +775,7 @@ func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string,
 		tools.PrintContainerLogs(ctx, p.dockerClient, promoteCont.ID)
 	}
 
-	return nil
+	return status, nil
 }
 
 func (p *PhysicalInitial) getDSAFromWAL(ctx context.Context, pgVersion float64, containerID, cloneDir string) (string, error) {
@@ -1006,7 +1143,7 @@ func (p *PhysicalInitial) updateDataStateAt() {
 	p.fsPool.SetDSA(dsaTime)
 }
 
-func (p *PhysicalInitial) cleanupSnapshots(retentionLimit int) error {
+func (p *PhysicalInitial) cleanupSnapshots(retention RetentionSpec) error {
 	select {
 	case <-p.schedulerCtx.Done():
 		log.Msg("Stop automatic snapshot cleanup")
@@ -1014,8 +1151,7 @@ func (p *PhysicalInitial) cleanupSnapshots(retentionLimit int) error {
 	default:
 	}
 
-	_, err := p.cloneManager.CleanupSnapshots(retentionLimit)
-	if err != nil {
+	if _, err := cleanupSnapshots(p.cloneManager, retention); err != nil {
 		return errors.Wrap(err, "failed to clean up snapshots")
 	}
 
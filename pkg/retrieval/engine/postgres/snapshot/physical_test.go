@@ -164,3 +164,33 @@ func TestParsingWalLine(t *testing.T) {
 		assert.EqualValues(t, tc.expectedDataStateAt, dsa)
 	}
 }
+
+func TestRetentionValidation(t *testing.T) {
+	testCases := []struct {
+		retention RetentionSpec
+		err       string
+	}{
+		{retention: RetentionSpec{}},
+		{retention: RetentionSpec{Timetable: "0 * * * *", Limit: 4}},
+		{retention: RetentionSpec{Timetable: "0 * * * *", Keep: KeepPolicy{Daily: 14}}},
+		{
+			retention: RetentionSpec{Timetable: "0 * * * *"},
+			err:       "retention requires a limit or at least one keep rule",
+		},
+		{
+			retention: RetentionSpec{Timetable: "0 * * * *", Keep: KeepPolicy{Weekly: -1}},
+			err:       "retention values must not be negative",
+		},
+	}
+
+	for _, tc := range testCases {
+		err := tc.retention.validate()
+
+		if tc.err == "" {
+			assert.NoError(t, err)
+			continue
+		}
+
+		assert.EqualError(t, err, tc.err)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+2021 © Postgres.ai
+*/
+
+package snapshot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools"
+)
+
+const (
+	// syncLagSkip skips scheduled snapshots while the sync instance lags.
+	syncLagSkip = "skip"
+
+	// syncLagFlag takes scheduled snapshots of a lagging sync instance but marks them as lagging.
+	syncLagFlag = "flag"
+)
+
+// SyncLagGate defines how scheduled snapshots treat a sync instance lagging behind the source.
+type SyncLagGate struct {
+	MaxSeconds int64  `yaml:"maxSeconds"`
+	Action     string `yaml:"action"`
+}
+
+func (g SyncLagGate) validate() error {
+	if g.MaxSeconds < 0 {
+		return errors.New("maximum sync lag must not be negative")
+	}
+
+	switch g.Action {
+	case "", syncLagSkip, syncLagFlag:
+		return nil
+	}
+
+	return errors.Errorf("unknown sync lag action %q: use %q or %q", g.Action, syncLagSkip, syncLagFlag)
+}
+
+// check returns the status of a snapshot taken with the given lag or an error skipping the snapshot.
+// An unknown lag is treated as exceeding the threshold.
+func (g SyncLagGate) check(lagSeconds int64) (string, error) {
+	if g.MaxSeconds == 0 || (lagSeconds != tools.UnknownLag && lagSeconds <= g.MaxSeconds) {
+		return "", nil
+	}
+
+	lagMessage := fmt.Sprintf("sync instance lag %ds exceeds the threshold of %ds", lagSeconds, g.MaxSeconds)
+	if lagSeconds == tools.UnknownLag {
+		lagMessage = "sync instance lag is unknown"
+	}
+
+	if g.Action == syncLagFlag {
+		log.Msg(fmt.Sprintf("The snapshot will be marked as %s: %s", models.SnapshotLagging, lagMessage))
+
+		return models.SnapshotLagging, nil
+	}
+
+	return "", newSkipSnapshotErr(fmt.Sprintf("Skip taking a scheduled snapshot: %s", lagMessage))
+}
+
+// mergeSnapshotStatus combines the validation status with the lag status. Quarantine takes precedence.
+func mergeSnapshotStatus(validationStatus, lagStatus string) string {
+	if validationStatus == models.SnapshotQuarantined || lagStatus == "" {
+		return validationStatus
+	}
+
+	return lagStatus
+}
+
+// getSyncLag returns the replay lag of the sync instance in seconds.
+func getSyncLag(ctx context.Context, dockerClient *client.Client, containerName, username, dbName, dataDir string) (int64, error) {
+	syncContainer, err := dockerClient.ContainerInspect(ctx, containerName)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return tools.UnknownLag, nil
+		}
+
+		return 0, errors.Wrap(err, "failed to inspect sync container")
+	}
+
+	if syncContainer.State == nil || !syncContainer.State.Running {
+		return tools.UnknownLag, nil
+	}
+
+	pgVersion, err := tools.DetectPGVersion(dataDir)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to detect the Postgres version")
+	}
+
+	lag, err := tools.GetReplicationLag(ctx, dockerClient, syncContainer.ID, username, dbName, pgVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	return lag.Seconds, nil
+}
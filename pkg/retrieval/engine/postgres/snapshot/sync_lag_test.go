@@ -0,0 +1,53 @@
+package snapshot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools"
+)
+
+func TestSyncLagGate(t *testing.T) {
+	assert.NoError(t, SyncLagGate{}.validate())
+	assert.NoError(t, SyncLagGate{MaxSeconds: 60, Action: syncLagFlag}.validate())
+	assert.EqualError(t, SyncLagGate{MaxSeconds: -1}.validate(), "maximum sync lag must not be negative")
+	assert.EqualError(t, SyncLagGate{MaxSeconds: 60, Action: "stop"}.validate(), `unknown sync lag action "stop": use "skip" or "flag"`)
+
+	status, err := SyncLagGate{}.check(tools.UnknownLag)
+	assert.NoError(t, err)
+	assert.Equal(t, "", status)
+
+	status, err = SyncLagGate{MaxSeconds: 60}.check(60)
+	assert.NoError(t, err)
+	assert.Equal(t, "", status)
+
+	_, err = SyncLagGate{MaxSeconds: 60}.check(61)
+	assert.IsType(t, &skipSnapshotErr{}, err)
+	assert.EqualError(t, err, "Skip taking a scheduled snapshot: sync instance lag 61s exceeds the threshold of 60s")
+
+	_, err = SyncLagGate{MaxSeconds: 60, Action: syncLagSkip}.check(tools.UnknownLag)
+	assert.EqualError(t, err, "Skip taking a scheduled snapshot: sync instance lag is unknown")
+
+	status, err = SyncLagGate{MaxSeconds: 60, Action: syncLagFlag}.check(61)
+	assert.NoError(t, err)
+	assert.Equal(t, models.SnapshotLagging, status)
+}
+
+func TestMergeSnapshotStatus(t *testing.T) {
+	assert.Equal(t, "", mergeSnapshotStatus("", ""))
+	assert.Equal(t, models.SnapshotReady, mergeSnapshotStatus(models.SnapshotReady, ""))
+	assert.Equal(t, models.SnapshotLagging, mergeSnapshotStatus(models.SnapshotReady, models.SnapshotLagging))
+	assert.Equal(t, models.SnapshotLagging, mergeSnapshotStatus("", models.SnapshotLagging))
+	assert.Equal(t, models.SnapshotQuarantined, mergeSnapshotStatus(models.SnapshotQuarantined, models.SnapshotLagging))
+}
+
+func TestSyncLagSeconds(t *testing.T) {
+	now := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, int64(90), syncLagSeconds("20210310115830", now))
+	assert.Equal(t, int64(0), syncLagSeconds("20210310120100", now))
+	assert.Equal(t, int64(tools.UnknownLag), syncLagSeconds("invalid", now))
+}
@@ -0,0 +1,218 @@
+/*
+2021 © Postgres.ai
+*/
+
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools"
+)
+
+// Validation defines checks run against the prepared instance before a snapshot is published.
+// A snapshot failing any check is marked as quarantined, so it is not chosen as the latest snapshot for cloning.
+type Validation struct {
+	Amcheck    *AmcheckValidation `yaml:"amcheck"`
+	RowCounts  []RowCountCheck    `yaml:"rowCounts"`
+	Assertions []AssertionCheck   `yaml:"assertions"`
+}
+
+// AmcheckValidation defines options to verify B-tree indexes using the amcheck extension.
+type AmcheckValidation struct {
+	Databases      []string `yaml:"databases"`
+	HeapAllIndexed bool     `yaml:"heapAllIndexed"`
+}
+
+// RowCountCheck defines the expected number of rows in a table. Zero Max means no upper limit.
+type RowCountCheck struct {
+	DBName string `yaml:"dbname"`
+	Table  string `yaml:"table"`
+	Min    int64  `yaml:"min"`
+	Max    int64  `yaml:"max"`
+}
+
+// AssertionCheck defines a custom SQL query that must return a single true value.
+type AssertionCheck struct {
+	Name   string `yaml:"name"`
+	DBName string `yaml:"dbname"`
+	Query  string `yaml:"query"`
+}
+
+// isEmpty checks if no validation checks are configured.
+func (v Validation) isEmpty() bool {
+	return v.Amcheck == nil && len(v.RowCounts) == 0 && len(v.Assertions) == 0
+}
+
+// validate checks the validation configuration.
+func (v Validation) validate() error {
+	for _, rowCount := range v.RowCounts {
+		if rowCount.Table == "" {
+			return errors.New("table of the row count check must not be empty")
+		}
+
+		if rowCount.Min < 0 || rowCount.Max < 0 || (rowCount.Max != 0 && rowCount.Max < rowCount.Min) {
+			return errors.Errorf("invalid row count limits of the table %q", rowCount.Table)
+		}
+	}
+
+	for _, assertion := range v.Assertions {
+		if assertion.Query == "" {
+			return errors.Errorf("query of the assertion %q must not be empty", assertion.Name)
+		}
+	}
+
+	return nil
+}
+
+type snapshotValidator struct {
+	docker   *client.Client
+	dbName   string
+	username string
+	options  Validation
+}
+
+func newSnapshotValidator(docker *client.Client, dbName, username string, options Validation) *snapshotValidator {
+	return &snapshotValidator{docker: docker, dbName: dbName, username: username, options: options}
+}
+
+// run runs validation checks against the instance and returns the snapshot status.
+func (v *snapshotValidator) run(ctx context.Context, containerID string) string {
+	log.Msg("Validating the snapshot data")
+
+	failures := v.check(ctx, containerID)
+
+	if len(failures) > 0 {
+		for _, failure := range failures {
+			log.Err("Snapshot validation failed: ", failure)
+		}
+
+		log.Msg("The snapshot will be quarantined")
+
+		return models.SnapshotQuarantined
+	}
+
+	log.Msg("Snapshot validation has been passed")
+
+	return models.SnapshotReady
+}
+
+// check runs all validation checks and returns descriptions of failed ones.
+func (v *snapshotValidator) check(ctx context.Context, containerID string) []string {
+	failures := []string{}
+
+	if v.options.Amcheck != nil {
+		databases := v.options.Amcheck.Databases
+		if len(databases) == 0 {
+			databases = []string{v.dbName}
+		}
+
+		for _, dbName := range databases {
+			if _, err := v.runQuery(ctx, containerID, dbName, buildAmcheckQuery(v.options.Amcheck.HeapAllIndexed)); err != nil {
+				failures = append(failures, fmt.Sprintf("amcheck of the database %q: %v", dbName, err))
+			}
+		}
+	}
+
+	for _, rowCount := range v.options.RowCounts {
+		output, err := v.runQuery(ctx, containerID, v.database(rowCount.DBName), buildRowCountQuery(rowCount.Table))
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("row count of the table %q: %v", rowCount.Table, err))
+			continue
+		}
+
+		if err := checkRowCount(rowCount, output); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	for _, assertion := range v.options.Assertions {
+		output, err := v.runQuery(ctx, containerID, v.database(assertion.DBName), assertion.Query)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("assertion %q: %v", assertion.Name, err))
+			continue
+		}
+
+		if output != "t" {
+			failures = append(failures, fmt.Sprintf("assertion %q returned %q instead of true", assertion.Name, output))
+		}
+	}
+
+	return failures
+}
+
+func (v *snapshotValidator) database(dbName string) string {
+	if dbName == "" {
+		return v.dbName
+	}
+
+	return dbName
+}
+
+func (v *snapshotValidator) runQuery(ctx context.Context, containerID, dbName, query string) (string, error) {
+	output, err := tools.ExecCommandWithInput(ctx, v.docker, containerID, types.ExecConfig{
+		Cmd: []string{"psql", "-U", v.username, "-d", dbName, "-XAtq", "-v", "ON_ERROR_STOP=1"},
+	}, strings.NewReader(query))
+	if err != nil {
+		if output != "" {
+			return "", errors.Errorf("%v: %s", err, output)
+		}
+
+		return "", err
+	}
+
+	return output, nil
+}
+
+// buildAmcheckQuery builds a query verifying all valid B-tree indexes.
+// The amcheck extension is created in a transaction rolled back after the check, so the snapshot data is not changed.
+// Corrupted indexes raise errors, so the output of the check is discarded.
+func buildAmcheckQuery(heapAllIndexed bool) string {
+	checkArgs := "c.oid"
+	if heapAllIndexed {
+		checkArgs += ", true"
+	}
+
+	return `begin;
+create extension if not exists amcheck;
+\o /dev/null
+select bt_index_check(` + checkArgs + `)
+from pg_catalog.pg_index i
+join pg_catalog.pg_class c on c.oid = i.indexrelid
+join pg_catalog.pg_am am on am.oid = c.relam
+where am.amname = 'btree' and c.relpersistence <> 't' and i.indisready and i.indisvalid;
+\o
+rollback;
+`
+}
+
+func buildRowCountQuery(table string) string {
+	return fmt.Sprintf("select count(*) from %s", pgx.Identifier(strings.Split(table, ".")).Sanitize())
+}
+
+func checkRowCount(rowCount RowCountCheck, output string) error {
+	count, err := strconv.ParseInt(output, 10, 64)
+	if err != nil {
+		return errors.Errorf("row count of the table %q: unexpected output %q", rowCount.Table, output)
+	}
+
+	if count < rowCount.Min {
+		return errors.Errorf("table %q has %d rows, expected at least %d", rowCount.Table, count, rowCount.Min)
+	}
+
+	if rowCount.Max != 0 && count > rowCount.Max {
+		return errors.Errorf("table %q has %d rows, expected at most %d", rowCount.Table, count, rowCount.Max)
+	}
+
+	return nil
+}
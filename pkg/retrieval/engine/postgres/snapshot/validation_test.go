@@ -0,0 +1,62 @@
+/*
+2021 © Postgres.ai
+*/
+
+package snapshot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationConfig(t *testing.T) {
+	testCases := []struct {
+		validation Validation
+		isEmpty    bool
+		isValid    bool
+	}{
+		{validation: Validation{}, isEmpty: true, isValid: true},
+		{validation: Validation{Amcheck: &AmcheckValidation{}}, isValid: true},
+		{validation: Validation{RowCounts: []RowCountCheck{{Table: "public.users", Min: 10, Max: 20}}}, isValid: true},
+		{validation: Validation{RowCounts: []RowCountCheck{{Table: "users", Min: 10}}}, isValid: true},
+		{validation: Validation{RowCounts: []RowCountCheck{{Min: 10}}}, isValid: false},
+		{validation: Validation{RowCounts: []RowCountCheck{{Table: "users", Min: 20, Max: 10}}}, isValid: false},
+		{validation: Validation{RowCounts: []RowCountCheck{{Table: "users", Min: -1}}}, isValid: false},
+		{validation: Validation{Assertions: []AssertionCheck{{Name: "orders", Query: "select true"}}}, isValid: true},
+		{validation: Validation{Assertions: []AssertionCheck{{Name: "orders"}}}, isValid: false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.isEmpty, tc.validation.isEmpty())
+		assert.Equal(t, tc.isValid, tc.validation.validate() == nil, tc.validation)
+	}
+}
+
+func TestCheckRowCount(t *testing.T) {
+	testCases := []struct {
+		rowCount RowCountCheck
+		output   string
+		isValid  bool
+	}{
+		{rowCount: RowCountCheck{Table: "users", Min: 10}, output: "10", isValid: true},
+		{rowCount: RowCountCheck{Table: "users", Min: 10}, output: "100500", isValid: true},
+		{rowCount: RowCountCheck{Table: "users", Min: 10}, output: "9", isValid: false},
+		{rowCount: RowCountCheck{Table: "users", Min: 10, Max: 20}, output: "21", isValid: false},
+		{rowCount: RowCountCheck{Table: "users", Max: 20}, output: "0", isValid: true},
+		{rowCount: RowCountCheck{Table: "users"}, output: "ERROR: relation does not exist", isValid: false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.isValid, checkRowCount(tc.rowCount, tc.output) == nil, tc.output)
+	}
+}
+
+func TestBuildValidationQueries(t *testing.T) {
+	assert.Equal(t, `select count(*) from "public"."users"`, buildRowCountQuery("public.users"))
+	assert.Equal(t, `select count(*) from "Orders"`, buildRowCountQuery("Orders"))
+
+	assert.Contains(t, buildAmcheckQuery(false), "bt_index_check(c.oid)")
+	assert.Contains(t, buildAmcheckQuery(true), "bt_index_check(c.oid, true)")
+	assert.Contains(t, buildAmcheckQuery(true), "rollback;")
+}
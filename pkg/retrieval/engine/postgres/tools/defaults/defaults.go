@@ -15,6 +15,9 @@ const (
 	// DBName defines a default database name.
 	DBName = "postgres"
 
+	// PGVersion10 defines the PostgreSQL 10 version.
+	PGVersion10 = 10
+
 	// PGVersion12 defines the PostgreSQL 12 version.
 	PGVersion12 = 12
 )
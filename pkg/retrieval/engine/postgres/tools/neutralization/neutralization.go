@@ -0,0 +1,450 @@
+/*
+2021 © Postgres.ai
+*/
+
+// Package neutralization provides tools to disable objects of copied databases that can reach other systems.
+package neutralization
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/defaults"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/databases/postgres/pgconfig"
+)
+
+const (
+	reportDir      = ".dblab"
+	reportFilename = "neutralization.json"
+
+	// pgVersion10 defines the version number of Postgres supporting logical replication.
+	pgVersion10 = 100000
+
+	fieldSeparator = "|"
+
+	sharedPreloadLibraries = "shared_preload_libraries"
+	pgCronLibrary          = "pg_cron"
+)
+
+// Options defines objects to neutralize.
+type Options struct {
+	DisableSubscriptions bool   `yaml:"disableSubscriptions"`
+	DropReplicationSlots bool   `yaml:"dropReplicationSlots"`
+	UnscheduleCronJobs   bool   `yaml:"unscheduleCronJobs"`
+	ForeignServerHost    string `yaml:"foreignServerHost"`
+}
+
+// IsEnabled checks if any neutralization step is enabled.
+func (o Options) IsEnabled() bool {
+	return o.DisableSubscriptions || o.DropReplicationSlots || o.UnscheduleCronJobs || o.ForeignServerHost != ""
+}
+
+// StartupConfig returns configuration parameters keeping the instance from reaching other systems
+// before its objects are neutralized: logical replication workers are not started and pg_cron is not preloaded.
+func StartupConfig(options Options, pgVersion float64, preloadLibraries string) map[string]string {
+	cfg := make(map[string]string)
+
+	if options.DisableSubscriptions && pgVersion >= defaults.PGVersion10 {
+		cfg["max_logical_replication_workers"] = "0"
+	}
+
+	if options.UnscheduleCronJobs {
+		if libraries, ok := removeLibrary(preloadLibraries, pgCronLibrary); ok {
+			cfg[sharedPreloadLibraries] = libraries
+		}
+	}
+
+	return cfg
+}
+
+// ApplyStartupConfig stores the startup configuration of the neutralization in PGDATA, so it is applied on the next start.
+func ApplyStartupConfig(cfgManager *pgconfig.Manager, options Options) error {
+	libraries, isAutoConf, err := cfgManager.ReadParameter(sharedPreloadLibraries)
+	if err != nil {
+		return errors.Wrap(err, "failed to read preloaded libraries")
+	}
+
+	if _, hasCron := removeLibrary(libraries, pgCronLibrary); options.UnscheduleCronJobs && hasCron && isAutoConf {
+		return errors.New("pg_cron is preloaded by ALTER SYSTEM and cannot be disabled before the start")
+	}
+
+	return cfgManager.ApplyNeutralization(StartupConfig(options, cfgManager.GetPgVersion(), libraries))
+}
+
+// removeLibrary removes the library from the comma-separated list and reports if it has been found.
+func removeLibrary(libraries, library string) (string, bool) {
+	kept := make([]string, 0)
+	found := false
+
+	for _, name := range strings.Split(libraries, ",") {
+		name = strings.Trim(strings.TrimSpace(name), `"`)
+
+		switch name {
+		case "":
+			continue
+
+		case library:
+			found = true
+
+		default:
+			kept = append(kept, name)
+		}
+	}
+
+	return strings.Join(kept, ", "), found
+}
+
+// Report describes neutralized objects.
+type Report struct {
+	NeutralizedAt    string            `json:"neutralizedAt"`
+	Subscriptions    []Subscription    `json:"subscriptions"`
+	ReplicationSlots []ReplicationSlot `json:"replicationSlots"`
+	CronJobs         []CronJob         `json:"cronJobs"`
+	ForeignServers   []ForeignServer   `json:"foreignServers"`
+}
+
+// Subscription describes a disabled subscription.
+type Subscription struct {
+	Database string `json:"database"`
+	Name     string `json:"name"`
+}
+
+// ReplicationSlot describes a dropped replication slot.
+type ReplicationSlot struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Database string `json:"database,omitempty"`
+}
+
+// CronJob describes an unscheduled pg_cron job.
+type CronJob struct {
+	Database string `json:"database"`
+	JobID    int64  `json:"jobId"`
+	Schedule string `json:"schedule"`
+	Command  string `json:"command"`
+}
+
+// ForeignServer describes a postgres_fdw server with the rewritten host.
+type ForeignServer struct {
+	Database string `json:"database"`
+	Name     string `json:"name"`
+	Host     string `json:"host"`
+}
+
+// IsEmpty checks if no objects have been neutralized.
+func (r *Report) IsEmpty() bool {
+	return len(r.Subscriptions) == 0 && len(r.ReplicationSlots) == 0 && len(r.CronJobs) == 0 && len(r.ForeignServers) == 0
+}
+
+// Querier runs a query returning one text value in the database.
+type Querier func(ctx context.Context, dbName, query string) (string, error)
+
+// Neutralizer disables objects of a Postgres instance that can reach other systems.
+type Neutralizer struct {
+	query   Querier
+	dbName  string
+	options Options
+}
+
+// NewNeutralizer creates a new Neutralizer. The database is used to run queries on the instance level.
+func NewNeutralizer(query Querier, dbName string, options Options) *Neutralizer {
+	return &Neutralizer{
+		query:   query,
+		dbName:  dbName,
+		options: options,
+	}
+}
+
+// Run neutralizes the instance and reports neutralized objects.
+// Neutralization stops on the first failure, so the instance must not be used if an error is returned.
+func (n *Neutralizer) Run(ctx context.Context) (*Report, error) {
+	report := &Report{
+		NeutralizedAt:    time.Now().UTC().Format(time.RFC3339),
+		Subscriptions:    []Subscription{},
+		ReplicationSlots: []ReplicationSlot{},
+		CronJobs:         []CronJob{},
+		ForeignServers:   []ForeignServer{},
+	}
+
+	if !n.options.IsEnabled() {
+		return report, nil
+	}
+
+	if n.options.DisableSubscriptions {
+		if err := n.disableSubscriptions(ctx, report); err != nil {
+			return nil, errors.Wrap(err, "failed to disable subscriptions")
+		}
+	}
+
+	if n.options.DropReplicationSlots {
+		if err := n.dropReplicationSlots(ctx, report); err != nil {
+			return nil, errors.Wrap(err, "failed to drop replication slots")
+		}
+	}
+
+	if !n.options.UnscheduleCronJobs && n.options.ForeignServerHost == "" {
+		return report, nil
+	}
+
+	databases, err := n.listDatabases(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list databases")
+	}
+
+	for _, dbName := range databases {
+		if n.options.UnscheduleCronJobs {
+			if err := n.unscheduleCronJobs(ctx, dbName, report); err != nil {
+				return nil, errors.Wrapf(err, "failed to unschedule cron jobs of the database %q", dbName)
+			}
+		}
+
+		if n.options.ForeignServerHost != "" {
+			if err := n.rewriteForeignServers(ctx, dbName, report); err != nil {
+				return nil, errors.Wrapf(err, "failed to rewrite foreign servers of the database %q", dbName)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+func (n *Neutralizer) listDatabases(ctx context.Context) ([]string, error) {
+	output, err := n.query(ctx, n.dbName, `select coalesce(string_agg(datname, E'\n' order by datname), '')
+from pg_catalog.pg_database where datallowconn and not datistemplate`)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitLines(output), nil
+}
+
+// disableSubscriptions disables subscriptions and detaches them from their slots,
+// so dropping a subscription in a clone does not touch the replication slot on the publisher.
+func (n *Neutralizer) disableSubscriptions(ctx context.Context, report *Report) error {
+	versionOutput, err := n.query(ctx, n.dbName, "select current_setting('server_version_num')")
+	if err != nil {
+		return errors.Wrap(err, "failed to get the server version")
+	}
+
+	version, err := strconv.Atoi(strings.TrimSpace(versionOutput))
+	if err != nil {
+		return errors.Wrapf(err, "invalid server version: %q", versionOutput)
+	}
+
+	if version < pgVersion10 {
+		return nil
+	}
+
+	output, err := n.query(ctx, n.dbName, `select coalesce(string_agg(d.datname || '|' || s.subname,
+  E'\n' order by d.datname, s.subname), '')
+from pg_catalog.pg_subscription s
+join pg_catalog.pg_database d on d.oid = s.subdbid
+where s.subenabled or s.subslotname is not null`)
+	if err != nil {
+		return errors.Wrap(err, "failed to list subscriptions")
+	}
+
+	for _, line := range splitLines(output) {
+		fields := strings.SplitN(line, fieldSeparator, 2)
+		if len(fields) != 2 {
+			return errors.Errorf("unexpected subscription line: %q", line)
+		}
+
+		subscription := Subscription{Database: fields[0], Name: fields[1]}
+		name := pq.QuoteIdentifier(subscription.Name)
+
+		if _, err := n.query(ctx, subscription.Database,
+			fmt.Sprintf("alter subscription %s disable; alter subscription %s set (slot_name = none)", name, name)); err != nil {
+			return errors.Wrapf(err, "failed to disable subscription %q", subscription.Name)
+		}
+
+		log.Msg(fmt.Sprintf("Subscription %q of the database %q has been disabled", subscription.Name, subscription.Database))
+
+		report.Subscriptions = append(report.Subscriptions, subscription)
+	}
+
+	return nil
+}
+
+// dropReplicationSlots drops replication slots. Logical slots are dropped in their databases.
+func (n *Neutralizer) dropReplicationSlots(ctx context.Context, report *Report) error {
+	output, err := n.query(ctx, n.dbName, `select coalesce(string_agg(slot_name || '|' || slot_type || '|' || coalesce(database, ''),
+  E'\n' order by slot_name), '')
+from pg_catalog.pg_replication_slots`)
+	if err != nil {
+		return errors.Wrap(err, "failed to list replication slots")
+	}
+
+	for _, line := range splitLines(output) {
+		fields := strings.SplitN(line, fieldSeparator, 3)
+		if len(fields) != 3 {
+			return errors.Errorf("unexpected replication slot line: %q", line)
+		}
+
+		slot := ReplicationSlot{Name: fields[0], Type: fields[1], Database: fields[2]}
+
+		dbName := slot.Database
+		if dbName == "" {
+			dbName = n.dbName
+		}
+
+		if _, err := n.query(ctx, dbName,
+			fmt.Sprintf("select pg_catalog.pg_drop_replication_slot(%s)", pq.QuoteLiteral(slot.Name))); err != nil {
+			return errors.Wrapf(err, "failed to drop replication slot %q", slot.Name)
+		}
+
+		log.Msg(fmt.Sprintf("Replication slot %q has been dropped", slot.Name))
+
+		report.ReplicationSlots = append(report.ReplicationSlots, slot)
+	}
+
+	return nil
+}
+
+// unscheduleCronJobs unschedules all jobs of pg_cron if the extension is installed in the database.
+// pg_cron is not preloaded during the neutralization and its library cannot be loaded otherwise,
+// so jobs are deleted without firing the trigger invalidating the job cache of the library.
+func (n *Neutralizer) unscheduleCronJobs(ctx context.Context, dbName string, report *Report) error {
+	installed, err := n.query(ctx, dbName, "select exists (select 1 from pg_catalog.pg_extension where extname = 'pg_cron')::text")
+	if err != nil {
+		return errors.Wrap(err, "failed to check the pg_cron extension")
+	}
+
+	if installed != "true" {
+		return nil
+	}
+
+	output, err := n.query(ctx, dbName, `select coalesce(string_agg(jobid || '|' || schedule || '|' || replace(command, E'\n', ' '),
+  E'\n' order by jobid), '')
+from cron.job`)
+	if err != nil {
+		return errors.Wrap(err, "failed to list cron jobs")
+	}
+
+	jobs := make([]CronJob, 0)
+
+	for _, line := range splitLines(output) {
+		fields := strings.SplitN(line, fieldSeparator, 3)
+		if len(fields) != 3 {
+			return errors.Errorf("unexpected cron job line: %q", line)
+		}
+
+		jobID, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid cron job ID: %q", fields[0])
+		}
+
+		jobs = append(jobs, CronJob{Database: dbName, JobID: jobID, Schedule: fields[1], Command: fields[2]})
+	}
+
+	for _, job := range jobs {
+		if _, err := n.query(ctx, dbName,
+			fmt.Sprintf("set session_replication_role = replica; delete from cron.job where jobid = %d", job.JobID)); err != nil {
+			return errors.Wrapf(err, "failed to unschedule cron job %d", job.JobID)
+		}
+
+		log.Msg(fmt.Sprintf("Cron job %d of the database %q has been unscheduled", job.JobID, dbName))
+	}
+
+	report.CronJobs = append(report.CronJobs, jobs...)
+
+	return nil
+}
+
+// rewriteForeignServers points postgres_fdw servers to the configured host.
+// The hostaddr option is dropped because it takes precedence over the host.
+func (n *Neutralizer) rewriteForeignServers(ctx context.Context, dbName string, report *Report) error {
+	output, err := n.query(ctx, dbName, fmt.Sprintf(`select coalesce(string_agg(s.srvname || '|' ||
+    coalesce((select substr(o, 6) from unnest(s.srvoptions) o where o like 'host=%%' limit 1), '') || '|' ||
+    exists (select 1 from unnest(s.srvoptions) o where o like 'hostaddr=%%')::text,
+  E'\n' order by s.srvname), '')
+from pg_catalog.pg_foreign_server s
+join pg_catalog.pg_foreign_data_wrapper w on w.oid = s.srvfdw
+where w.fdwname = 'postgres_fdw'
+  and (s.srvoptions is null
+    or not (%s = any (s.srvoptions))
+    or exists (select 1 from unnest(s.srvoptions) o where o like 'hostaddr=%%'))`,
+		pq.QuoteLiteral("host="+n.options.ForeignServerHost)))
+	if err != nil {
+		return errors.Wrap(err, "failed to list foreign servers")
+	}
+
+	for _, line := range splitLines(output) {
+		fields := strings.SplitN(line, fieldSeparator, 3)
+		if len(fields) != 3 {
+			return errors.Errorf("unexpected foreign server line: %q", line)
+		}
+
+		server := ForeignServer{Database: dbName, Name: fields[0], Host: fields[1]}
+
+		if _, err := n.query(ctx, dbName, buildServerOptionsQuery(server, n.options.ForeignServerHost, fields[2] == "true")); err != nil {
+			return errors.Wrapf(err, "failed to rewrite options of the foreign server %q", server.Name)
+		}
+
+		log.Msg(fmt.Sprintf("Foreign server %q of the database %q has been pointed to %q", server.Name, dbName,
+			n.options.ForeignServerHost))
+
+		report.ForeignServers = append(report.ForeignServers, server)
+	}
+
+	return nil
+}
+
+func buildServerOptionsQuery(server ForeignServer, host string, hasHostAddr bool) string {
+	action := "add"
+	if server.Host != "" {
+		action = "set"
+	}
+
+	options := []string{fmt.Sprintf("%s host %s", action, pq.QuoteLiteral(host))}
+
+	if hasHostAddr {
+		options = append(options, "drop hostaddr")
+	}
+
+	return fmt.Sprintf("alter server %s options (%s)", pq.QuoteIdentifier(server.Name), strings.Join(options, ", "))
+}
+
+func splitLines(output string) []string {
+	lines := make([]string, 0)
+
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// SaveReport stores the report in the data directory, so it is kept in snapshots and clones.
+func SaveReport(dataDir string, report *Report) error {
+	dirname := path.Join(dataDir, reportDir)
+	if err := os.MkdirAll(dirname, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create the directory %s", dirname)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the neutralization report")
+	}
+
+	if err := ioutil.WriteFile(path.Join(dirname, reportFilename), data, 0600); err != nil {
+		return errors.Wrap(err, "failed to write the neutralization report")
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package neutralization
+
+import (
+	"context"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type executedQuery struct {
+	dbName string
+	query  string
+}
+
+// fakeInstance answers queries by their fragments and records executed statements.
+type fakeInstance struct {
+	responses map[string]string
+	failOn    string
+	executed  []executedQuery
+}
+
+func (f *fakeInstance) query(_ context.Context, dbName, query string) (string, error) {
+	f.executed = append(f.executed, executedQuery{dbName: dbName, query: query})
+
+	if f.failOn != "" && strings.Contains(query, f.failOn) {
+		return "", errors.New("query failed")
+	}
+
+	for fragment, response := range f.responses {
+		if strings.Contains(query, fragment) {
+			return response, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (f *fakeInstance) statements() []executedQuery {
+	statements := make([]executedQuery, 0)
+
+	for _, executed := range f.executed {
+		if !strings.HasPrefix(executed.query, "select") || strings.Contains(executed.query, "pg_drop_replication_slot") ||
+			strings.Contains(executed.query, "delete from cron.job") {
+			statements = append(statements, executed)
+		}
+	}
+
+	return statements
+}
+
+func newFakeInstance() *fakeInstance {
+	return &fakeInstance{
+		responses: map[string]string{
+			"server_version_num":          "130003",
+			"from pg_catalog.pg_database": "app\npostgres",
+			"pg_subscription":             "app|sub_orders",
+			"pg_replication_slots":        "physical_slot|physical|\nlogical_slot|logical|app",
+			"extname = 'pg_cron'":         "false",
+			"pg_foreign_server":           "",
+		},
+	}
+}
+
+func TestOptionsIsEnabled(t *testing.T) {
+	assert.False(t, Options{}.IsEnabled())
+	assert.True(t, Options{DropReplicationSlots: true}.IsEnabled())
+	assert.True(t, Options{ForeignServerHost: "neutralized.invalid"}.IsEnabled())
+}
+
+func TestNeutralizerRun(t *testing.T) {
+	instance := newFakeInstance()
+	instance.responses["extname = 'pg_cron'"] = "true"
+	instance.responses["from cron.job"] = "3|*/5 * * * *|vacuum orders"
+	instance.responses["pg_foreign_server"] = "prod|db.example.com|true"
+
+	report, err := NewNeutralizer(instance.query, "postgres", Options{
+		DisableSubscriptions: true,
+		DropReplicationSlots: true,
+		UnscheduleCronJobs:   true,
+		ForeignServerHost:    "neutralized.invalid",
+	}).Run(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []Subscription{{Database: "app", Name: "sub_orders"}}, report.Subscriptions)
+	assert.Equal(t, []ReplicationSlot{
+		{Name: "physical_slot", Type: "physical"},
+		{Name: "logical_slot", Type: "logical", Database: "app"},
+	}, report.ReplicationSlots)
+	assert.Equal(t, []CronJob{
+		{Database: "app", JobID: 3, Schedule: "*/5 * * * *", Command: "vacuum orders"},
+		{Database: "postgres", JobID: 3, Schedule: "*/5 * * * *", Command: "vacuum orders"},
+	}, report.CronJobs)
+	assert.Equal(t, []ForeignServer{
+		{Database: "app", Name: "prod", Host: "db.example.com"},
+		{Database: "postgres", Name: "prod", Host: "db.example.com"},
+	}, report.ForeignServers)
+	assert.NotEmpty(t, report.NeutralizedAt)
+
+	assert.Equal(t, []executedQuery{
+		{dbName: "app", query: `alter subscription "sub_orders" disable; alter subscription "sub_orders" set (slot_name = none)`},
+		{dbName: "postgres", query: `select pg_catalog.pg_drop_replication_slot('physical_slot')`},
+		{dbName: "app", query: `select pg_catalog.pg_drop_replication_slot('logical_slot')`},
+		{dbName: "app", query: `set session_replication_role = replica; delete from cron.job where jobid = 3`},
+		{dbName: "app", query: `alter server "prod" options (set host 'neutralized.invalid', drop hostaddr)`},
+		{dbName: "postgres", query: `set session_replication_role = replica; delete from cron.job where jobid = 3`},
+		{dbName: "postgres", query: `alter server "prod" options (set host 'neutralized.invalid', drop hostaddr)`},
+	}, instance.statements())
+}
+
+func TestNeutralizerRunDisabled(t *testing.T) {
+	instance := newFakeInstance()
+
+	report, err := NewNeutralizer(instance.query, "postgres", Options{}).Run(context.Background())
+	require.NoError(t, err)
+	assert.True(t, report.IsEmpty())
+	assert.Empty(t, instance.executed)
+}
+
+func TestNeutralizerSkipsSubscriptionsBeforePostgres10(t *testing.T) {
+	instance := newFakeInstance()
+	instance.responses["server_version_num"] = "90624"
+
+	report, err := NewNeutralizer(instance.query, "postgres", Options{DisableSubscriptions: true}).Run(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, report.Subscriptions)
+	assert.Len(t, instance.executed, 1)
+}
+
+func TestNeutralizerStopsOnFailure(t *testing.T) {
+	instance := newFakeInstance()
+	instance.failOn = "pg_drop_replication_slot"
+
+	_, err := NewNeutralizer(instance.query, "postgres", Options{DropReplicationSlots: true, UnscheduleCronJobs: true}).
+		Run(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `failed to drop replication slot "physical_slot"`)
+	assert.Empty(t, instance.statements()[1:])
+}
+
+func TestBuildServerOptionsQuery(t *testing.T) {
+	assert.Equal(t, `alter server "prod" options (add host 'neutralized.invalid')`,
+		buildServerOptionsQuery(ForeignServer{Name: "prod"}, "neutralized.invalid", false))
+	assert.Equal(t, `alter server "prod" options (set host 'neutralized.invalid')`,
+		buildServerOptionsQuery(ForeignServer{Name: "prod", Host: "db"}, "neutralized.invalid", false))
+}
+
+func TestStartupConfig(t *testing.T) {
+	options := Options{DisableSubscriptions: true, UnscheduleCronJobs: true}
+
+	assert.Equal(t, map[string]string{
+		"max_logical_replication_workers": "0",
+		"shared_preload_libraries":        "pg_stat_statements, auto_explain",
+	}, StartupConfig(options, 13, "pg_stat_statements, pg_cron,auto_explain"))
+
+	assert.Equal(t, map[string]string{"shared_preload_libraries": ""}, StartupConfig(options, 9.6, `"pg_cron"`))
+	assert.Equal(t, map[string]string{}, StartupConfig(Options{UnscheduleCronJobs: true}, 13, "pg_stat_statements"))
+	assert.Equal(t, map[string]string{}, StartupConfig(Options{ForeignServerHost: "neutralized.invalid"}, 13, "pg_cron"))
+}
+
+func TestSaveReport(t *testing.T) {
+	dataDir := t.TempDir()
+
+	require.NoError(t, SaveReport(dataDir, &Report{
+		NeutralizedAt:  "2021-05-01T10:00:00Z",
+		Subscriptions:  []Subscription{{Database: "app", Name: "sub_orders"}},
+		ForeignServers: []ForeignServer{},
+	}))
+
+	data, err := ioutil.ReadFile(path.Join(dataDir, reportDir, reportFilename))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"name": "sub_orders"`)
+}
@@ -0,0 +1,89 @@
+/*
+2021 © Postgres.ai
+*/
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+const (
+	// UnknownLag defines the lag value that cannot be calculated.
+	UnknownLag = -1
+
+	replicationLagFields = 4
+	pgVersion10          = 10
+)
+
+// ReplicationLag describes how far a standby is behind its source.
+type ReplicationLag struct {
+	ReceivedLSN string
+	ReplayLSN   string
+	Bytes       int64
+	Seconds     int64
+}
+
+// ReplicationLagQuery builds a query returning the replication lag of a standby.
+// The lag is zero if all received WAL has been replayed and the age of the last replayed transaction if WAL is pending.
+// Standbys restoring WAL from an archive without streaming do not know if WAL is pending, so their lag is unknown:
+// the age of the last replayed transaction would grow while the source is idle.
+func ReplicationLagQuery(pgVersion float64) string {
+	receiveLSN, replayLSN, lsnDiff := "pg_last_wal_receive_lsn()", "pg_last_wal_replay_lsn()", "pg_wal_lsn_diff"
+
+	if pgVersion < pgVersion10 {
+		receiveLSN, replayLSN, lsnDiff = "pg_last_xlog_receive_location()", "pg_last_xlog_replay_location()", "pg_xlog_location_diff"
+	}
+
+	return fmt.Sprintf(`select coalesce(%[1]s::text, ''), coalesce(%[2]s::text, ''),
+  coalesce(%[3]s(%[1]s, %[2]s)::bigint, -1),
+  case when %[1]s is null then -1
+    when %[1]s = %[2]s then 0
+    else coalesce(extract(epoch from now() - pg_last_xact_replay_timestamp())::bigint, -1) end`,
+		receiveLSN, replayLSN, lsnDiff)
+}
+
+// ParseReplicationLag parses the unaligned output of the replication lag query.
+func ParseReplicationLag(output string) (*ReplicationLag, error) {
+	fields := strings.Split(strings.TrimSpace(output), "|")
+	if len(fields) != replicationLagFields {
+		return nil, errors.Errorf("unexpected replication lag output: %q", output)
+	}
+
+	lagBytes, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid lag size: %q", fields[2])
+	}
+
+	lagSeconds, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid lag time: %q", fields[3])
+	}
+
+	return &ReplicationLag{
+		ReceivedLSN: fields[0],
+		ReplayLSN:   fields[1],
+		Bytes:       lagBytes,
+		Seconds:     lagSeconds,
+	}, nil
+}
+
+// GetReplicationLag returns the replication lag of the standby running in the container.
+func GetReplicationLag(ctx context.Context, dockerClient *client.Client, containerID, username, dbName string,
+	pgVersion float64) (*ReplicationLag, error) {
+	output, err := ExecCommandWithOutput(ctx, dockerClient, containerID, types.ExecConfig{
+		Cmd: []string{"psql", "-U", username, "-d", dbName, "-XAtc", ReplicationLagQuery(pgVersion)},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get replication lag")
+	}
+
+	return ParseReplicationLag(output)
+}
@@ -0,0 +1,30 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicationLagQuery(t *testing.T) {
+	assert.Contains(t, ReplicationLagQuery(13), "pg_wal_lsn_diff(pg_last_wal_receive_lsn(), pg_last_wal_replay_lsn())")
+	assert.Contains(t, ReplicationLagQuery(9.6), "pg_xlog_location_diff(pg_last_xlog_receive_location(), pg_last_xlog_replay_location())")
+	assert.Contains(t, ReplicationLagQuery(13), "case when pg_last_wal_receive_lsn() is null then -1")
+}
+
+func TestParseReplicationLag(t *testing.T) {
+	lag, err := ParseReplicationLag("0/3000148|0/3000060|232|15\n")
+	require.NoError(t, err)
+	assert.Equal(t, &ReplicationLag{ReceivedLSN: "0/3000148", ReplayLSN: "0/3000060", Bytes: 232, Seconds: 15}, lag)
+
+	lag, err = ParseReplicationLag("|0/3000060|-1|-1")
+	require.NoError(t, err)
+	assert.Equal(t, &ReplicationLag{ReplayLSN: "0/3000060", Bytes: UnknownLag, Seconds: UnknownLag}, lag)
+
+	_, err = ParseReplicationLag("0/3000148|0/3000060")
+	assert.EqualError(t, err, `unexpected replication lag output: "0/3000148|0/3000060"`)
+
+	_, err = ParseReplicationLag("0/3000148|0/3000060|232|abc")
+	assert.Error(t, err)
+}
@@ -482,6 +482,114 @@ func ExecCommandWithOutput(ctx context.Context, dockerClient *client.Client, con
 	return string(bytes.TrimSpace(wb.Bytes())), nil
 }
 
+// ExecCommandWithInput runs command in Docker container, streams the input to its stdin and returns the command output.
+func ExecCommandWithInput(ctx context.Context, dockerClient *client.Client, containerID string, execCfg types.ExecConfig,
+	input io.Reader) (string, error) {
+	execCfg.AttachStdin = true
+	execCfg.AttachStdout = true
+	execCfg.AttachStderr = true
+	// TTY would mangle binary input.
+	execCfg.Tty = false
+
+	execCommand, err := dockerClient.ContainerExecCreate(ctx, containerID, execCfg)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create an exec command")
+	}
+
+	attachResponse, err := dockerClient.ContainerExecAttach(ctx, execCommand.ID, types.ExecStartCheck{})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to attach to exec command")
+	}
+
+	defer attachResponse.Close()
+
+	var output bytes.Buffer
+
+	outputDone := make(chan error, 1)
+
+	go func() {
+		// Stderr is collected too: tools like pg_restore report warnings there, so the exit code decides about success.
+		_, err := stdcopy.StdCopy(&output, &output, attachResponse.Reader)
+		outputDone <- err
+	}()
+
+	_, copyErr := io.Copy(attachResponse.Conn, input)
+
+	if err := attachResponse.CloseWrite(); err != nil {
+		log.Dbg("Failed to close stdin of exec command: ", err)
+	}
+
+	select {
+	case err := <-outputDone:
+		if err != nil {
+			return "", errors.Wrap(err, "failed to copy output")
+		}
+
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	outputLine := string(bytes.TrimSpace(output.Bytes()))
+
+	if err := inspectCommandExitCode(ctx, dockerClient, execCommand.ID); err != nil {
+		return outputLine, errors.Wrap(err, "unsuccessful command response")
+	}
+
+	if copyErr != nil {
+		return outputLine, errors.Wrap(copyErr, "failed to stream input")
+	}
+
+	return outputLine, nil
+}
+
+// ExecCommandWithOutputStream runs command in Docker container, streams its stdout to the writer and returns the stderr output.
+func ExecCommandWithOutputStream(ctx context.Context, dockerClient *client.Client, containerID string, execCfg types.ExecConfig,
+	output io.Writer) (string, error) {
+	execCfg.AttachStdout = true
+	execCfg.AttachStderr = true
+	// TTY would mangle binary output and merge it with stderr.
+	execCfg.Tty = false
+
+	execCommand, err := dockerClient.ContainerExecCreate(ctx, containerID, execCfg)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create an exec command")
+	}
+
+	attachResponse, err := dockerClient.ContainerExecAttach(ctx, execCommand.ID, types.ExecStartCheck{})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to attach to exec command")
+	}
+
+	defer attachResponse.Close()
+
+	var stderr bytes.Buffer
+
+	outputDone := make(chan error, 1)
+
+	go func() {
+		_, err := stdcopy.StdCopy(output, &stderr, attachResponse.Reader)
+		outputDone <- err
+	}()
+
+	select {
+	case err := <-outputDone:
+		if err != nil {
+			return "", errors.Wrap(err, "failed to copy output")
+		}
+
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	stderrLine := string(bytes.TrimSpace(stderr.Bytes()))
+
+	if err := inspectCommandExitCode(ctx, dockerClient, execCommand.ID); err != nil {
+		return stderrLine, errors.Wrap(err, "unsuccessful command response")
+	}
+
+	return stderrLine, nil
+}
+
 // processAttachResponse reads and processes the cmd output.
 func processAttachResponse(ctx context.Context, reader io.Reader, output io.Writer) error {
 	var errBuf bytes.Buffer
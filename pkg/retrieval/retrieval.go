@@ -19,6 +19,7 @@ import (
 	dblabCfg "gitlab.com/postgres-ai/database-lab/v2/pkg/config"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/config/global"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/components"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/config"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/dbmarker"
@@ -39,6 +40,7 @@ type Retrieval struct {
 	docker        *client.Client
 	poolManager   *pool.Manager
 	runner        runners.Runner
+	jobsMutex     sync.RWMutex
 	jobs          []components.JobRunner
 	scheduler     *cron.Cron
 	retrieveMutex sync.Mutex
@@ -62,21 +64,65 @@ func New(cfg *dblabCfg.Config, docker *client.Client, pm *pool.Manager, runner r
 func (r *Retrieval) Reload(ctx context.Context, cfg *dblabCfg.Config) {
 	*r.cfg = cfg.Retrieval
 
-	for _, job := range r.jobs {
+	currentJobs := r.currentJobs()
+	jobs := make([]components.JobRunner, 0, len(currentJobs))
+
+	enabledJobs := make(map[string]struct{}, len(r.cfg.Jobs))
+	for _, jobName := range r.cfg.Jobs {
+		enabledJobs[jobName] = struct{}{}
+	}
+
+	for _, job := range currentJobs {
 		cfg, ok := r.cfg.JobsSpec[job.Name()]
-		if !ok {
-			log.Msg("Skip reloading of the retrieval job", job.Name())
+		_, enabled := enabledJobs[job.Name()]
+
+		if !ok || !enabled {
+			r.removeJob(ctx, job)
 			continue
 		}
 
 		if err := job.Reload(cfg.Options); err != nil {
 			log.Err("Failed to reload configuration of the retrieval job", job.Name(), err)
 		}
+
+		jobs = append(jobs, job)
 	}
 
+	r.setJobs(jobs)
+
 	r.setupScheduler(ctx)
 }
 
+// currentJobs returns the current list of jobs. The list is replaced as a whole and never modified in place.
+func (r *Retrieval) currentJobs() []components.JobRunner {
+	r.jobsMutex.RLock()
+	defer r.jobsMutex.RUnlock()
+
+	return r.jobs
+}
+
+// setJobs replaces the list of jobs.
+func (r *Retrieval) setJobs(jobs []components.JobRunner) {
+	r.jobsMutex.Lock()
+	r.jobs = jobs
+	r.jobsMutex.Unlock()
+}
+
+// removeJob releases resources of the job removed from the list of jobs or from their specs.
+func (r *Retrieval) removeJob(ctx context.Context, job components.JobRunner) {
+	cleaner, ok := job.(components.JobCleaner)
+	if !ok {
+		log.Msg("Retrieval job has been removed from the configuration", job.Name())
+		return
+	}
+
+	log.Msg("Retrieval job has been removed from the configuration. Clean up its resources", job.Name())
+
+	if err := cleaner.Cleanup(ctx); err != nil {
+		log.Err("Failed to clean up resources of the retrieval job", job.Name(), err)
+	}
+}
+
 // Run start retrieving process.
 func (r *Retrieval) Run(ctx context.Context) error {
 	runCtx, cancel := context.WithCancel(ctx)
@@ -108,7 +154,7 @@ func (r *Retrieval) run(ctx context.Context, fsm pool.FSManager) error {
 		return errors.Wrap(errors.Unwrap(err), "filesystem manager is not ready")
 	}
 
-	for _, j := range r.jobs {
+	for _, j := range r.currentJobs() {
 		if err := j.Run(ctx); err != nil {
 			return err
 		}
@@ -143,7 +189,7 @@ func (r *Retrieval) parseJobs(fsm pool.FSManager) error {
 
 	dbMarker := dbmarker.NewMarker(fsm.Pool().DataDir())
 
-	r.jobs = make([]components.JobRunner, 0, len(r.cfg.Jobs))
+	jobs := make([]components.JobRunner, 0, len(r.cfg.Jobs))
 
 	for _, jobName := range r.cfg.Jobs {
 		jobSpec, ok := r.cfg.JobsSpec[jobName]
@@ -166,15 +212,12 @@ func (r *Retrieval) parseJobs(fsm pool.FSManager) error {
 			return errors.Wrap(err, "failed to build job")
 		}
 
-		r.addJob(job)
+		jobs = append(jobs, job)
 	}
 
-	return nil
-}
+	r.setJobs(jobs)
 
-// addJob applies a job to the current data retrieval.
-func (r *Retrieval) addJob(job components.JobRunner) {
-	r.jobs = append(r.jobs, job)
+	return nil
 }
 
 func (r *Retrieval) validate() error {
@@ -185,6 +228,10 @@ func (r *Retrieval) validate() error {
 		return errors.New("must not contain physical and logical restore jobs simultaneously")
 	}
 
+	if _, hasLogicalSync := r.jobSpecs[logical.SyncJobType]; hasLogicalSync && hasPhysicalRestore {
+		return errors.New("must not contain physical restore and logical sync jobs simultaneously")
+	}
+
 	return nil
 }
 
@@ -284,6 +331,31 @@ func (r *Retrieval) fullRefresh(ctx context.Context) error {
 	return nil
 }
 
+// Status returns the current state of data retrieval.
+func (r *Retrieval) Status(ctx context.Context) *models.Retrieving {
+	for _, job := range r.currentJobs() {
+		reporter, ok := job.(components.SyncReporter)
+		if !ok {
+			continue
+		}
+
+		syncState, err := reporter.SyncStatus(ctx)
+		if err != nil {
+			log.Err("Failed to get the sync status: ", err)
+
+			syncState = &models.Sync{Status: models.SyncStatusDown, LagSeconds: -1}
+		}
+
+		if syncState == nil {
+			continue
+		}
+
+		return &models.Retrieving{Sync: syncState}
+	}
+
+	return nil
+}
+
 // Stop stops a retrieval service.
 func (r *Retrieval) Stop() {
 	r.stopScheduler()
@@ -1,10 +1,15 @@
 package retrieval
 
 import (
+	"context"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
+	dblabCfg "gitlab.com/postgres-ai/database-lab/v2/pkg/config"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/components"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/config"
 )
 
@@ -23,6 +28,11 @@ func TestParallelJobSpecs(t *testing.T) {
 			"logicalDump":    {},
 			"logicalRestore": {},
 		},
+		{
+			"logicalDump":     {},
+			"logicalSync":     {},
+			"logicalSnapshot": {},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -42,6 +52,10 @@ func TestInvalidParallelJobSpecs(t *testing.T) {
 			"physicalRestore": {},
 			"logicalRestore":  {},
 		},
+		{
+			"physicalRestore": {},
+			"logicalSync":     {},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -53,3 +67,83 @@ func TestInvalidParallelJobSpecs(t *testing.T) {
 		assert.Error(t, err)
 	}
 }
+
+type syncJob struct {
+	lag int64
+}
+
+func (j *syncJob) Name() string                          { return "logicalSync" }
+func (j *syncJob) Reload(_ map[string]interface{}) error { return nil }
+func (j *syncJob) Run(_ context.Context) error           { return nil }
+func (j *syncJob) SyncStatus(_ context.Context) (*models.Sync, error) {
+	return &models.Sync{Status: models.SyncStatusActive, LagSeconds: j.lag}, nil
+}
+
+func TestStatusWhileJobsReplaced(t *testing.T) {
+	r := &Retrieval{}
+	r.setJobs([]components.JobRunner{&syncJob{lag: 1}})
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 100; i++ {
+			r.setJobs([]components.JobRunner{&syncJob{lag: int64(i)}})
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		status := r.Status(context.Background())
+		assert.NotNil(t, status)
+	}
+
+	wg.Wait()
+}
+
+type cleanedJob struct {
+	name    string
+	cleaned bool
+}
+
+func (j *cleanedJob) Name() string                          { return j.name }
+func (j *cleanedJob) Reload(_ map[string]interface{}) error { return nil }
+func (j *cleanedJob) Run(_ context.Context) error           { return nil }
+func (j *cleanedJob) Cleanup(_ context.Context) error {
+	j.cleaned = true
+	return nil
+}
+
+func TestReloadRemovesJobs(t *testing.T) {
+	dumpJob := &cleanedJob{name: "logicalDump"}
+	restoreJob := &cleanedJob{name: "logicalRestore"}
+	syncJob := &cleanedJob{name: "logicalSync"}
+
+	r := &Retrieval{cfg: &config.Config{}}
+	r.setJobs([]components.JobRunner{dumpJob, restoreJob, syncJob})
+
+	// The sync job is removed from the list only, its spec is kept.
+	r.Reload(context.Background(), &dblabCfg.Config{Retrieval: config.Config{
+		Jobs: []string{"logicalDump", "logicalRestore"},
+		JobsSpec: map[string]config.JobSpec{
+			"logicalDump":    {},
+			"logicalRestore": {},
+			"logicalSync":    {},
+		},
+	}})
+
+	assert.Equal(t, []components.JobRunner{dumpJob, restoreJob}, r.currentJobs())
+	assert.True(t, syncJob.cleaned)
+
+	// The restore job is removed from specs only.
+	r.Reload(context.Background(), &dblabCfg.Config{Retrieval: config.Config{
+		Jobs:     []string{"logicalDump", "logicalRestore"},
+		JobsSpec: map[string]config.JobSpec{"logicalDump": {}},
+	}})
+
+	assert.Equal(t, []components.JobRunner{dumpJob}, r.currentJobs())
+	assert.True(t, restoreJob.cleaned)
+	assert.False(t, dumpJob.cleaned)
+}
@@ -6,9 +6,11 @@ package cloning
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"fmt"
 	"math/big"
+	"net"
 	"sort"
 	"strconv"
 	"strings"
@@ -16,7 +18,6 @@ import (
 	"time"
 
 	"github.com/dustin/go-humanize"
-	"github.com/jackc/pgtype/pgxtype"
 	"github.com/jackc/pgx/v4"
 	_ "github.com/lib/pq" // Register Postgres database driver.
 	"github.com/pkg/errors"
@@ -39,8 +40,12 @@ const (
 
 // Config contains a cloning configuration.
 type Config struct {
-	MaxIdleMinutes uint   `yaml:"maxIdleMinutes"`
-	AccessHost     string `yaml:"accessHost"`
+	MaxIdleMinutes      uint   `yaml:"maxIdleMinutes"`
+	IdlePolicy          string `yaml:"idlePolicy"`
+	MaxRunningClones    uint   `yaml:"maxRunningClones"`
+	AccessHost          string `yaml:"accessHost"`
+	ExportDir           string `yaml:"exportDir"`
+	QueryTimeoutSeconds uint   `yaml:"queryTimeoutSeconds"`
 }
 
 // Base provides cloning service.
@@ -53,6 +58,7 @@ type Base struct {
 	snapshots      []models.Snapshot
 	provision      *provision.Provisioner
 	observingCh    chan string
+	evictionMutex  sync.Mutex
 }
 
 // NewBase instances a new Base service.
@@ -80,6 +86,10 @@ func (c *Base) Reload(cfg Config) {
 
 // Run initializes and runs cloning component.
 func (c *Base) Run(ctx context.Context) error {
+	if err := IsValidConfig(*c.config); err != nil {
+		return errors.Wrap(err, "invalid cloning configuration")
+	}
+
 	if err := c.provision.Init(); err != nil {
 		return errors.Wrap(err, "failed to run cloning service")
 	}
@@ -125,10 +135,11 @@ func (c *Base) CreateClone(cloneRequest *types.CloneCreateRequest) (*models.Clon
 	}
 
 	clone := &models.Clone{
-		ID:        cloneRequest.ID,
-		Snapshot:  &snapshot,
-		Protected: cloneRequest.Protected,
-		CreatedAt: util.FormatTime(createdAt),
+		ID:          cloneRequest.ID,
+		Snapshot:    &snapshot,
+		Protected:   cloneRequest.Protected,
+		CreatedAt:   util.FormatTime(createdAt),
+		AllowEgress: cloneRequest.AllowEgress,
 		Status: models.Status{
 			Code:    models.StatusCreating,
 			Message: models.CloneMessageCreating,
@@ -160,7 +171,10 @@ func (c *Base) CreateClone(cloneRequest *types.CloneCreateRequest) (*models.Clon
 	}
 
 	go func() {
-		session, err := c.provision.StartSession(w.snapshot.ID, ephemeralUser, cloneRequest.ExtraConf)
+		c.ensureRunningLimit(cloneID)
+
+		session, err := c.provision.StartSession(w.snapshot.ID, ephemeralUser, cloneRequest.ExtraConf, cloneRequest.AllowEgress,
+			c.config.AccessHost)
 		if err != nil {
 			// TODO(anatoly): Empty room case.
 			log.Errf("Failed to start session: %v.", err)
@@ -175,45 +189,51 @@ func (c *Base) CreateClone(cloneRequest *types.CloneCreateRequest) (*models.Clon
 			return
 		}
 
-		c.cloneMutex.Lock()
-		defer c.cloneMutex.Unlock()
+		c.setCloneSession(cloneID, session)
+	}()
 
-		w, ok := c.clones[cloneID]
-		if !ok {
-			log.Errf("Clone %q not found", cloneID)
-			return
-		}
+	return clone, nil
+}
 
-		w.session = session
-		w.timeStartedAt = time.Now()
+// setCloneSession marks the created clone as ready to accept connections.
+func (c *Base) setCloneSession(cloneID string, session *resources.Session) {
+	c.cloneMutex.Lock()
+	defer c.cloneMutex.Unlock()
 
-		clone := w.clone
-		clone.Status = models.Status{
-			Code:    models.StatusOK,
-			Message: models.CloneMessageOK,
-		}
+	w, ok := c.clones[cloneID]
+	if !ok {
+		log.Errf("Clone %q not found", cloneID)
+		return
+	}
 
-		dbName := clone.DB.DBName
-		if dbName == "" {
-			dbName = defaultDatabaseName
-		}
+	w.session = session
+	w.timeStartedAt = time.Now()
 
-		clone.DB.Port = strconv.FormatUint(uint64(session.Port), 10)
-		clone.DB.Host = c.config.AccessHost
-		clone.DB.ConnStr = fmt.Sprintf("host=%s port=%s user=%s dbname=%s",
-			clone.DB.Host, clone.DB.Port, clone.DB.Username, dbName)
+	clone := w.clone
+	clone.Status = models.Status{
+		Code:    models.StatusOK,
+		Message: models.CloneMessageOK,
+	}
 
-		clone.Metadata = models.CloneMetadata{
-			CloningTime:    w.timeStartedAt.Sub(w.timeCreatedAt).Seconds(),
-			MaxIdleMinutes: c.config.MaxIdleMinutes,
-		}
-	}()
+	dbName := clone.DB.DBName
+	if dbName == "" {
+		dbName = defaultDatabaseName
+	}
 
-	return clone, nil
+	clone.DB.Port = strconv.FormatUint(uint64(session.Port), 10)
+	clone.DB.Host = c.config.AccessHost
+	clone.DB.CACert = session.CACert
+	clone.DB.ConnStr = fmt.Sprintf("host=%s port=%s user=%s dbname=%s",
+		clone.DB.Host, clone.DB.Port, clone.DB.Username, dbName)
+
+	clone.Metadata = models.CloneMetadata{
+		CloningTime:    w.timeStartedAt.Sub(w.timeCreatedAt).Seconds(),
+		MaxIdleMinutes: c.config.MaxIdleMinutes,
+	}
 }
 
 // ConnectToClone connects to clone by cloneID.
-func (c *Base) ConnectToClone(ctx context.Context, cloneID string) (pgxtype.Querier, error) {
+func (c *Base) ConnectToClone(ctx context.Context, cloneID string) (*pgx.Conn, error) {
 	w, ok := c.findWrapper(cloneID)
 	if !ok {
 		return nil, errors.New("not found")
@@ -230,6 +250,42 @@ func (c *Base) ConnectToClone(ctx context.Context, cloneID string) (pgxtype.Quer
 	return db, nil
 }
 
+// ProxyCertificate issues the server certificate of the connection proxy for the access host.
+func (c *Base) ProxyCertificate() (*tls.Certificate, error) {
+	return c.provision.ProxyCertificate(c.config.AccessHost)
+}
+
+// CloneAddress returns the address of a running clone in the internal network of the engine.
+// A hibernated clone is resumed and the address is returned once the clone is ready.
+func (c *Base) CloneAddress(cloneID string) (string, error) {
+	if w, err := c.transitCloneStatus(cloneID, models.StatusHibernated, models.Status{
+		Code:    models.StatusResuming,
+		Message: models.CloneMessageResuming,
+	}); err == nil {
+		log.Msg(fmt.Sprintf("Hibernated clone %q is being resumed by a new connection.", cloneID))
+		c.resumeClone(cloneID, w)
+	}
+
+	return c.waitCloneAddress(cloneID)
+}
+
+func (c *Base) cloneAddress(cloneID string) (string, models.StatusCode, error) {
+	c.cloneMutex.RLock()
+	defer c.cloneMutex.RUnlock()
+
+	w, ok := c.clones[cloneID]
+	if !ok {
+		return "", "", errors.Errorf("clone %q not found", cloneID)
+	}
+
+	if w.session == nil {
+		return "", w.clone.Status.Code, nil
+	}
+
+	return net.JoinHostPort(util.GetCloneName(w.session.Port), strconv.FormatUint(uint64(w.session.Port), 10)),
+		w.clone.Status.Code, nil
+}
+
 func connectionString(host, port, username, dbname string) string {
 	return fmt.Sprintf("host=%s port=%s user=%s database='%s'",
 		host, port, username, dbname)
@@ -246,11 +302,11 @@ func (c *Base) DestroyClone(cloneID string) error {
 		return models.New(models.ErrCodeBadRequest, "clone is protected")
 	}
 
-	if err := c.UpdateCloneStatus(cloneID, models.Status{
+	if err := c.updateStatusWithoutForks(cloneID, models.Status{
 		Code:    models.StatusDeleting,
 		Message: models.CloneMessageDeleting,
 	}); err != nil {
-		return errors.Wrap(err, "failed to update clone status")
+		return err
 	}
 
 	if w.session == nil {
@@ -292,6 +348,8 @@ func (c *Base) GetClone(id string) (*models.Clone, error) {
 		return w.clone, nil
 	}
 
+	c.resumeEvictedClone(id, w)
+
 	sessionState, err := c.provision.GetSessionState(w.session)
 	if err != nil {
 		// Session not ready yet.
@@ -367,14 +425,16 @@ func (c *Base) ResetClone(cloneID string, resetOptions types.ResetCloneRequest)
 		snapshotID = w.snapshot.ID
 	}
 
-	if err := c.UpdateCloneStatus(cloneID, models.Status{
+	if err := c.updateStatusWithoutForks(cloneID, models.Status{
 		Code:    models.StatusResetting,
 		Message: models.CloneMessageResetting,
 	}); err != nil {
-		return errors.Wrap(err, "failed to update clone status")
+		return err
 	}
 
 	go func() {
+		c.ensureRunningLimit(cloneID)
+
 		snapshot, err := c.provision.ResetSession(w.session, snapshotID)
 		if err != nil {
 			log.Errf("Failed to reset clone: %+v.", err)
@@ -391,6 +451,8 @@ func (c *Base) ResetClone(cloneID string, resetOptions types.ResetCloneRequest)
 
 		c.cloneMutex.Lock()
 		w.clone.Snapshot = snapshot
+		w.clone.DB.CACert = w.session.CACert
+		w.clone.Parent = ""
 		c.cloneMutex.Unlock()
 
 		if err := c.UpdateCloneStatus(cloneID, models.Status{
@@ -478,8 +540,19 @@ func (c *Base) setWrapper(id string, wrapper *CloneWrapper) {
 // deleteClone removes the clone by ID.
 func (c *Base) deleteClone(cloneID string) {
 	c.cloneMutex.Lock()
+
+	var export *cloneExport
+
+	if w, ok := c.clones[cloneID]; ok {
+		export = w.export
+	}
+
 	delete(c.clones, cloneID)
 	c.cloneMutex.Unlock()
+
+	if export != nil {
+		removeExportFile(export.path)
+	}
 }
 
 // lenClones returns the number of clones.
@@ -522,6 +595,8 @@ func (c *Base) fetchSnapshots() error {
 			ID:          entry.ID,
 			CreatedAt:   util.FormatTime(entry.CreatedAt),
 			DataStateAt: util.FormatTime(entry.DataStateAt),
+			Status:      entry.Status,
+			Pool:        entry.Pool,
 		}
 
 		log.Dbg("snapshot:", snapshots[i])
@@ -534,7 +609,7 @@ func (c *Base) fetchSnapshots() error {
 	return nil
 }
 
-// getLatestSnapshot returns the latest snapshot.
+// getLatestSnapshot returns the latest snapshot. Quarantined snapshots are skipped.
 func (c *Base) getLatestSnapshot() (models.Snapshot, error) {
 	c.snapshotMutex.RLock()
 	defer c.snapshotMutex.RUnlock()
@@ -543,9 +618,16 @@ func (c *Base) getLatestSnapshot() (models.Snapshot, error) {
 		return models.Snapshot{}, errors.New("no snapshot found")
 	}
 
-	snapshot := c.snapshots[0]
+	for _, snapshot := range c.snapshots {
+		if snapshot.IsQuarantined() {
+			log.Dbg("Skip the quarantined snapshot: ", snapshot.ID)
+			continue
+		}
+
+		return snapshot, nil
+	}
 
-	return snapshot, nil
+	return models.Snapshot{}, errors.New("no snapshot found: all snapshots are quarantined")
 }
 
 // getSnapshotByID returns the snapshot by ID.
@@ -594,6 +676,16 @@ func (c *Base) destroyIdleClones(ctx context.Context) {
 				continue
 			}
 
+			if isIdleClone && c.config.IdlePolicy == IdlePolicyHibernate && cloneWrapper.clone.Status.Code != models.StatusHibernated {
+				log.Msg(fmt.Sprintf("Idle clone %q is going to be hibernated.", cloneWrapper.clone.ID))
+
+				if err = c.HibernateClone(cloneWrapper.clone.ID); err != nil {
+					log.Errf("Failed to hibernate clone: %+v.", err)
+				}
+
+				continue
+			}
+
 			if isIdleClone {
 				log.Msg(fmt.Sprintf("Idle clone %q is going to be removed.", cloneWrapper.clone.ID))
 
@@ -606,14 +698,21 @@ func (c *Base) destroyIdleClones(ctx context.Context) {
 	}
 }
 
-// isIdleClone checks if clone is idle.
+// isIdleClone checks if clone is idle. A hibernated clone is idle if it has not been resumed for the idle duration.
 func (c *Base) isIdleClone(wrapper *CloneWrapper) (bool, error) {
 	currentTime := time.Now()
 
 	idleDuration := time.Duration(c.config.MaxIdleMinutes) * time.Minute
 	minimumTime := currentTime.Add(-idleDuration)
 
-	if wrapper.clone.Protected || wrapper.clone.Status.Code == models.StatusExporting || wrapper.timeStartedAt.After(minimumTime) {
+	statusCode := wrapper.clone.Status.Code
+
+	if statusCode == models.StatusHibernated && !wrapper.clone.Protected {
+		return wrapper.timeHibernatedAt.Before(minimumTime), nil
+	}
+
+	if wrapper.clone.Protected || statusCode == models.StatusExporting || statusCode == models.StatusBusy || isSuspended(statusCode) ||
+		wrapper.timeStartedAt.After(minimumTime) {
 		return false, nil
 	}
 
@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/suite"
 
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
 )
 
 func TestBaseCloningSuite(t *testing.T) {
@@ -178,3 +179,73 @@ func (s *BaseCloningSuite) TestSnapshotByID() {
 	require.NoError(s.T(), err)
 	assert.Equal(s.T(), latestSnapshot, snapshot2)
 }
+
+func (s *BaseCloningSuite) TestLatestSnapshotSkipsQuarantined() {
+	snapshot1 := models.Snapshot{
+		ID:          "TestSnapshotID1",
+		CreatedAt:   "2020-02-21 01:23:45",
+		DataStateAt: "2020-02-21 00:00:00",
+		Status:      models.SnapshotQuarantined,
+	}
+
+	snapshot2 := models.Snapshot{
+		ID:          "TestSnapshotID2",
+		CreatedAt:   "2020-02-20 05:43:21",
+		DataStateAt: "2020-02-20 00:00:00",
+		Status:      models.SnapshotReady,
+	}
+
+	s.cloning.snapshots = append(s.cloning.snapshots, snapshot1)
+
+	latestSnapshot, err := s.cloning.getLatestSnapshot()
+	require.Equal(s.T(), latestSnapshot, models.Snapshot{})
+	assert.EqualError(s.T(), err, "no snapshot found: all snapshots are quarantined")
+
+	s.cloning.snapshots = append(s.cloning.snapshots, snapshot2)
+
+	latestSnapshot, err = s.cloning.getLatestSnapshot()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), latestSnapshot, snapshot2)
+
+	// Quarantined snapshots are still available by ID.
+	snapshot, err := s.cloning.getSnapshotByID("TestSnapshotID1")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), snapshot, snapshot1)
+}
+
+func (s *BaseCloningSuite) TestCloneAddress() {
+	_, err := s.cloning.CloneAddress("testCloneID")
+	assert.Error(s.T(), err)
+
+	wrapper := &CloneWrapper{clone: &models.Clone{ID: "testCloneID", Status: models.Status{Code: models.StatusCreating}}}
+	s.cloning.setWrapper("testCloneID", wrapper)
+
+	_, err = s.cloning.CloneAddress("testCloneID")
+	assert.Error(s.T(), err)
+
+	wrapper.session = &resources.Session{Port: 6000}
+	wrapper.clone.Status.Code = models.StatusOK
+
+	address, err := s.cloning.CloneAddress("testCloneID")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "dblab_clone_6000:6000", address)
+
+	for _, statusCode := range []models.StatusCode{models.StatusBusy, models.StatusExporting} {
+		wrapper.clone.Status.Code = statusCode
+
+		address, err = s.cloning.CloneAddress("testCloneID")
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "dblab_clone_6000:6000", address)
+	}
+}
+
+// requireModelError checks that the error is an API error with the code.
+func requireModelError(t *testing.T, err error, code models.ErrorCode) {
+	t.Helper()
+
+	require.Error(t, err)
+
+	modelErr, ok := err.(*models.Error)
+	require.True(t, ok, err.Error())
+	assert.Equal(t, code, modelErr.Code, err.Error())
+}
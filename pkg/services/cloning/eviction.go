@@ -0,0 +1,165 @@
+/*
+2021 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"fmt"
+	"time"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/util/pglog"
+)
+
+// evictionCandidate describes a running clone that can be stopped to keep the limit of running clones.
+type evictionCandidate struct {
+	cloneID string
+	wrapper *CloneWrapper
+}
+
+// ensureRunningLimit hibernates the least recently active clones while the number of running clones exceeds the limit.
+// The clone being started is counted as running and is never evicted.
+func (c *Base) ensureRunningLimit(cloneID string) {
+	c.evictionMutex.Lock()
+	defer c.evictionMutex.Unlock()
+
+	lastActivity := cachedActivity(c.lastActivity)
+
+	for {
+		maxRunning := c.config.MaxRunningClones
+		if maxRunning == 0 {
+			return
+		}
+
+		running, candidates := c.runningClones(cloneID)
+		if running <= int(maxRunning) {
+			return
+		}
+
+		victim := leastRecentlyActive(candidates, lastActivity)
+		if victim == nil {
+			log.Msg(fmt.Sprintf("Running clones exceed the limit of %d, but there are no clones to stop.", maxRunning))
+			return
+		}
+
+		w, err := c.transitCloneStatus(victim.cloneID, models.StatusOK, models.Status{
+			Code:    models.StatusHibernating,
+			Message: models.CloneMessageHibernating,
+		})
+		if err != nil {
+			// The clone has changed its state, so it is not a candidate anymore.
+			log.Dbg("Failed to evict clone: ", err.Error())
+			continue
+		}
+
+		log.Msg(fmt.Sprintf("Clone %q is going to be stopped to keep the limit of %d running clones.", victim.cloneID, maxRunning))
+
+		c.hibernateClone(victim.cloneID, w, true)
+	}
+}
+
+// resumeEvictedClone starts resuming the clone if it has been stopped to keep the limit of running clones.
+func (c *Base) resumeEvictedClone(cloneID string, w *CloneWrapper) {
+	c.cloneMutex.RLock()
+	evicted := w.evicted
+	c.cloneMutex.RUnlock()
+
+	if !evicted {
+		return
+	}
+
+	if err := c.ResumeClone(cloneID); err != nil {
+		log.Dbg("Failed to resume evicted clone: ", err.Error())
+		return
+	}
+
+	log.Msg(fmt.Sprintf("Evicted clone %q is being resumed by a request.", cloneID))
+}
+
+// runningClones counts clones with running containers and collects ones that can be evicted.
+func (c *Base) runningClones(cloneID string) (int, []evictionCandidate) {
+	c.cloneMutex.RLock()
+	defer c.cloneMutex.RUnlock()
+
+	running := 0
+	candidates := []evictionCandidate{}
+
+	for id, w := range c.clones {
+		if !isRunning(w.clone.Status.Code) {
+			continue
+		}
+
+		running++
+
+		if id != cloneID && w.clone.Status.Code == models.StatusOK && !w.clone.Protected && w.session != nil {
+			candidates = append(candidates, evictionCandidate{cloneID: id, wrapper: w})
+		}
+	}
+
+	return running, candidates
+}
+
+// lastActivity returns the time of the last activity in the clone. The start time is used if there is no activity.
+func (c *Base) lastActivity(candidate evictionCandidate) time.Time {
+	c.cloneMutex.RLock()
+	session, startedAt := candidate.wrapper.session, candidate.wrapper.timeStartedAt
+	c.cloneMutex.RUnlock()
+
+	activity, err := c.provision.LastSessionActivity(session, startedAt)
+	if err != nil {
+		if err != pglog.ErrNotFound {
+			log.Err(fmt.Sprintf("Failed to get the last activity of clone %q: %v", candidate.cloneID, err))
+		}
+
+		return startedAt
+	}
+
+	return *activity
+}
+
+// cachedActivity remembers the last activity of each clone, so query logs are read once while clones are evicted.
+func cachedActivity(lastActivity func(evictionCandidate) time.Time) func(evictionCandidate) time.Time {
+	activities := make(map[string]time.Time)
+
+	return func(candidate evictionCandidate) time.Time {
+		if active, ok := activities[candidate.cloneID]; ok {
+			return active
+		}
+
+		active := lastActivity(candidate)
+		activities[candidate.cloneID] = active
+
+		return active
+	}
+}
+
+// leastRecentlyActive chooses the candidate having the oldest last activity.
+func leastRecentlyActive(candidates []evictionCandidate, lastActivity func(evictionCandidate) time.Time) *evictionCandidate {
+	var (
+		victim       *evictionCandidate
+		victimActive time.Time
+	)
+
+	for i := range candidates {
+		active := lastActivity(candidates[i])
+
+		if victim == nil || active.Before(victimActive) {
+			victim, victimActive = &candidates[i], active
+		}
+	}
+
+	return victim
+}
+
+// isRunning checks if the clone has a running container or the container is being started.
+func isRunning(statusCode models.StatusCode) bool {
+	switch statusCode {
+	case models.StatusOK, models.StatusCreating, models.StatusResetting, models.StatusResuming, models.StatusExporting,
+		models.StatusBusy:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,76 @@
+package cloning
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+)
+
+func TestRunningClones(t *testing.T) {
+	newWrapper := func(code models.StatusCode, protected bool) *CloneWrapper {
+		return &CloneWrapper{
+			clone:   &models.Clone{Status: models.Status{Code: code}, Protected: protected},
+			session: &resources.Session{},
+		}
+	}
+
+	c := &Base{clones: map[string]*CloneWrapper{
+		"new":        {clone: &models.Clone{Status: models.Status{Code: models.StatusCreating}}},
+		"ready":      newWrapper(models.StatusOK, false),
+		"protected":  newWrapper(models.StatusOK, true),
+		"resetting":  newWrapper(models.StatusResetting, false),
+		"hibernated": newWrapper(models.StatusHibernated, false),
+		"fatal":      newWrapper(models.StatusFatal, false),
+	}}
+
+	running, candidates := c.runningClones("new")
+	assert.Equal(t, 4, running)
+	require.Len(t, candidates, 1)
+	assert.Equal(t, "ready", candidates[0].cloneID)
+
+	_, candidates = c.runningClones("ready")
+	assert.Len(t, candidates, 0)
+}
+
+func TestLeastRecentlyActive(t *testing.T) {
+	now := time.Now()
+	activity := map[string]time.Time{
+		"recent": now,
+		"oldest": now.Add(-2 * time.Hour),
+		"old":    now.Add(-time.Hour),
+	}
+
+	lastActivity := func(candidate evictionCandidate) time.Time {
+		return activity[candidate.cloneID]
+	}
+
+	assert.Nil(t, leastRecentlyActive(nil, lastActivity))
+
+	victim := leastRecentlyActive([]evictionCandidate{{cloneID: "recent"}, {cloneID: "oldest"}, {cloneID: "old"}}, lastActivity)
+	require.NotNil(t, victim)
+	assert.Equal(t, "oldest", victim.cloneID)
+}
+
+func TestCachedActivity(t *testing.T) {
+	now := time.Now()
+	calls := map[string]int{}
+
+	lastActivity := cachedActivity(func(candidate evictionCandidate) time.Time {
+		calls[candidate.cloneID]++
+		return now
+	})
+
+	candidates := []evictionCandidate{{cloneID: "first"}, {cloneID: "second"}}
+
+	// Each eviction round compares the same candidates again.
+	for i := 0; i < 3; i++ {
+		require.NotNil(t, leastRecentlyActive(candidates, lastActivity))
+	}
+
+	assert.Equal(t, map[string]int{"first": 1, "second": 1}, calls)
+}
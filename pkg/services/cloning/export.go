@@ -0,0 +1,286 @@
+/*
+2021 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/util"
+)
+
+// exportDBNameRegexp matches database names which can be exported, they are passed to pg_dump in a shell command.
+var exportDBNameRegexp = regexp.MustCompile(`^[\w$-]{1,63}$`)
+
+// maxExportJobs defines the maximum number of parallel pg_dump jobs of an export.
+const maxExportJobs = 16
+
+// exportExtensions defines extensions of export files by formats.
+var exportExtensions = map[string]string{
+	models.ExportFormatCustom:    ".dump",
+	models.ExportFormatDirectory: ".tar",
+	models.ExportFormatZFS:       ".zfs",
+}
+
+// cloneExport tracks an export of a clone.
+type cloneExport struct {
+	// written is the number of bytes written to the export file, it is accessed atomically.
+	written uint64
+
+	// info is guarded by the clone mutex.
+	info models.CloneExport
+	path string
+}
+
+// model returns the description of the export. The clone mutex must be held by the caller.
+func (e *cloneExport) model() *models.CloneExport {
+	info := e.info
+	info.BytesWritten = atomic.LoadUint64(&e.written)
+
+	return &info
+}
+
+// exportWriter counts bytes written to the export file.
+type exportWriter struct {
+	file    *os.File
+	written *uint64
+}
+
+func (w *exportWriter) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	atomic.AddUint64(w.written, uint64(n))
+
+	return n, err
+}
+
+// ExportClone starts exporting the data of the clone to a file in the export directory.
+// The clone has the EXPORTING status until the export is finished. The file of the previous export is removed.
+func (c *Base) ExportClone(cloneID string, request types.CloneExportRequest) (*models.CloneExport, error) {
+	if c.config.ExportDir == "" {
+		return nil, models.New(models.ErrCodeBadRequest, "clone export is disabled: export directory is not configured")
+	}
+
+	if request.Format == "" {
+		request.Format = models.ExportFormatCustom
+	}
+
+	extension, ok := exportExtensions[request.Format]
+	if !ok {
+		return nil, models.New(models.ErrCodeBadRequest,
+			fmt.Sprintf("invalid export format %q: use %q, %q, or %q",
+				request.Format, models.ExportFormatCustom, models.ExportFormatDirectory, models.ExportFormatZFS))
+	}
+
+	if request.Jobs > 1 && request.Format != models.ExportFormatDirectory {
+		return nil, models.New(models.ErrCodeBadRequest,
+			fmt.Sprintf("parallel jobs are available only in the %q format", models.ExportFormatDirectory))
+	}
+
+	if request.Jobs > maxExportJobs {
+		return nil, models.New(models.ErrCodeBadRequest,
+			fmt.Sprintf("too many parallel jobs: %d, the maximum is %d", request.Jobs, maxExportJobs))
+	}
+
+	w, export, options, err := c.registerExport(cloneID, request, extension)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cloneMutex.RLock()
+	info := export.model()
+	c.cloneMutex.RUnlock()
+
+	go c.exportClone(cloneID, w, export, options)
+
+	return info, nil
+}
+
+// registerExport sets the EXPORTING status of the clone and replaces its previous export.
+func (c *Base) registerExport(cloneID string, request types.CloneExportRequest, extension string) (
+	*CloneWrapper, *cloneExport, resources.ExportOptions, error) {
+	c.cloneMutex.Lock()
+
+	w, err := c.checkCloneStatus(cloneID, models.StatusOK)
+	if err != nil {
+		c.cloneMutex.Unlock()
+		return nil, nil, resources.ExportOptions{}, err
+	}
+
+	options := resources.ExportOptions{Format: request.Format, Jobs: request.Jobs}
+
+	if request.Format != models.ExportFormatZFS {
+		options.DBName = request.DBName
+
+		if options.DBName == "" {
+			options.DBName = w.clone.DB.DBName
+		}
+
+		if options.DBName == "" {
+			options.DBName = defaultDatabaseName
+		}
+
+		if !exportDBNameRegexp.MatchString(options.DBName) {
+			c.cloneMutex.Unlock()
+			return nil, nil, resources.ExportOptions{}, models.New(models.ErrCodeBadRequest,
+				fmt.Sprintf("database %q cannot be exported: use up to 63 letters, digits, dollar signs, dashes, and underscores",
+					options.DBName))
+		}
+	}
+
+	exportID := xid.New().String()
+
+	export := &cloneExport{
+		info: models.CloneExport{
+			ID:        exportID,
+			CloneID:   cloneID,
+			Format:    options.Format,
+			DBName:    options.DBName,
+			Status:    models.ExportStatusInProgress,
+			StartedAt: util.FormatTime(time.Now()),
+		},
+		path: path.Join(c.config.ExportDir, exportID+extension),
+	}
+
+	previousExport := w.export
+	w.export = export
+	w.clone.Status = models.Status{
+		Code:    models.StatusExporting,
+		Message: models.CloneMessageExporting,
+	}
+
+	c.cloneMutex.Unlock()
+
+	if previousExport != nil {
+		removeExportFile(previousExport.path)
+	}
+
+	return w, export, options, nil
+}
+
+// exportClone writes the export file and returns the clone to the OK status.
+func (c *Base) exportClone(cloneID string, w *CloneWrapper, export *cloneExport, options resources.ExportOptions) {
+	err := c.writeExportFile(w.session, export, options)
+	if err != nil {
+		log.Errf("Failed to export clone %q: %+v.", cloneID, err)
+	}
+
+	c.finishExport(cloneID, w, export, err)
+}
+
+// finishExport records the result of the export. The export file is removed if the clone has been destroyed.
+func (c *Base) finishExport(cloneID string, w *CloneWrapper, export *cloneExport, err error) {
+	c.cloneMutex.Lock()
+
+	export.info.FinishedAt = util.FormatTime(time.Now())
+	export.info.Status = models.ExportStatusFinished
+
+	if err != nil {
+		export.info.Status = models.ExportStatusFailed
+		export.info.Error = errors.Cause(err).Error()
+	}
+
+	// The clone may be destroyed or reset during the export.
+	if w.clone.Status.Code == models.StatusExporting {
+		w.clone.Status = models.Status{
+			Code:    models.StatusOK,
+			Message: models.CloneMessageOK,
+		}
+	}
+
+	removed := c.clones[cloneID] != w
+
+	c.cloneMutex.Unlock()
+
+	if removed {
+		removeExportFile(export.path)
+	}
+}
+
+// writeExportFile writes the data of the clone to a temporary file and renames it once the export succeeds.
+func (c *Base) writeExportFile(session *resources.Session, export *cloneExport, options resources.ExportOptions) error {
+	partialPath := export.path + ".partial"
+
+	if err := os.MkdirAll(path.Dir(partialPath), 0700); err != nil {
+		return errors.Wrap(err, "failed to create the export directory")
+	}
+
+	file, err := os.Create(partialPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to create an export file")
+	}
+
+	exportErr := c.provision.ExportSession(session, options, &exportWriter{file: file, written: &export.written})
+
+	if err := file.Close(); err != nil && exportErr == nil {
+		exportErr = errors.Wrap(err, "failed to close the export file")
+	}
+
+	if exportErr == nil {
+		exportErr = errors.Wrap(os.Rename(partialPath, export.path), "failed to rename the export file")
+	}
+
+	if exportErr != nil {
+		removeExportFile(partialPath)
+	}
+
+	return exportErr
+}
+
+// GetCloneExport returns the latest export of the clone.
+func (c *Base) GetCloneExport(cloneID string) (*models.CloneExport, error) {
+	c.cloneMutex.RLock()
+	defer c.cloneMutex.RUnlock()
+
+	w, ok := c.clones[cloneID]
+	if !ok {
+		return nil, models.New(models.ErrCodeNotFound, "clone not found")
+	}
+
+	if w.export == nil {
+		return nil, models.New(models.ErrCodeNotFound, "clone has not been exported")
+	}
+
+	return w.export.model(), nil
+}
+
+// GetCloneExportFile returns the path of the file of the finished export of the clone and a name to download it with.
+func (c *Base) GetCloneExportFile(cloneID string) (string, string, error) {
+	c.cloneMutex.RLock()
+	defer c.cloneMutex.RUnlock()
+
+	w, ok := c.clones[cloneID]
+	if !ok {
+		return "", "", models.New(models.ErrCodeNotFound, "clone not found")
+	}
+
+	if w.export == nil {
+		return "", "", models.New(models.ErrCodeNotFound, "clone has not been exported")
+	}
+
+	if w.export.info.Status != models.ExportStatusFinished {
+		return "", "", models.New(models.ErrCodeBadRequest,
+			fmt.Sprintf("export status must be %s, current status is %s", models.ExportStatusFinished, w.export.info.Status))
+	}
+
+	return w.export.path, cloneID + exportExtensions[w.export.info.Format], nil
+}
+
+// removeExportFile removes the export file if it exists.
+func removeExportFile(exportPath string) {
+	if err := os.Remove(exportPath); err != nil && !os.IsNotExist(err) {
+		log.Err("Failed to remove the export file: ", err)
+	}
+}
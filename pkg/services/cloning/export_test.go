@@ -0,0 +1,173 @@
+package cloning
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+)
+
+func TestExportCloneRequest(t *testing.T) {
+	c := &Base{
+		config: &Config{ExportDir: t.TempDir()},
+		clones: map[string]*CloneWrapper{
+			"clone": {clone: &models.Clone{Status: models.Status{Code: models.StatusOK}}, session: &resources.Session{}},
+		},
+	}
+
+	for _, request := range []types.CloneExportRequest{
+		{Format: "plain"},
+		{DBName: "test'; rm -rf /"},
+		{Jobs: 4},
+		{Format: models.ExportFormatZFS, Jobs: 2},
+		{Format: models.ExportFormatDirectory, Jobs: 100},
+	} {
+		_, err := c.ExportClone("clone", request)
+		requireModelError(t, err, models.ErrCodeBadRequest)
+	}
+
+	assert.Equal(t, models.StatusOK, c.clones["clone"].clone.Status.Code)
+
+	c.config.ExportDir = ""
+
+	_, err := c.ExportClone("clone", types.CloneExportRequest{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "export directory is not configured")
+}
+
+func TestExportCloneLifecycle(t *testing.T) {
+	exportDir := t.TempDir()
+	previousPath := path.Join(exportDir, "c5ocm0qq2sfadq6ekqr0.dump")
+	require.NoError(t, os.WriteFile(previousPath, []byte("data"), 0600))
+
+	c := &Base{
+		config: &Config{ExportDir: exportDir},
+		clones: map[string]*CloneWrapper{
+			"clone": {
+				clone:   &models.Clone{Status: models.Status{Code: models.StatusOK}, DB: models.Database{DBName: "app"}},
+				session: &resources.Session{},
+				export:  &cloneExport{info: models.CloneExport{Status: models.ExportStatusFinished}, path: previousPath},
+			},
+		},
+	}
+
+	request := types.CloneExportRequest{Format: models.ExportFormatDirectory, Jobs: 4}
+
+	w, export, options, err := c.registerExport("clone", request, ".tar")
+	require.NoError(t, err)
+	assert.Equal(t, resources.ExportOptions{Format: models.ExportFormatDirectory, DBName: "app", Jobs: 4}, options)
+	assert.Equal(t, models.Status{Code: models.StatusExporting, Message: models.CloneMessageExporting}, w.clone.Status)
+	assert.Equal(t, path.Join(exportDir, export.info.ID+".tar"), export.path)
+
+	// The file of the previous export is replaced.
+	_, err = os.Stat(previousPath)
+	assert.True(t, os.IsNotExist(err))
+
+	// Another export cannot be started until the current one is finished.
+	_, _, _, err = c.registerExport("clone", request, ".tar")
+	requireModelError(t, err, models.ErrCodeBadRequest)
+
+	file, err := os.Create(export.path)
+	require.NoError(t, err)
+
+	_, err = (&exportWriter{file: file, written: &export.written}).Write([]byte("data"))
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	progress, err := c.GetCloneExport("clone")
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportStatusInProgress, progress.Status)
+	assert.Equal(t, uint64(4), progress.BytesWritten)
+
+	c.finishExport("clone", w, export, nil)
+	assert.Equal(t, models.Status{Code: models.StatusOK, Message: models.CloneMessageOK}, w.clone.Status)
+
+	finished, err := c.GetCloneExport("clone")
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportStatusFinished, finished.Status)
+	assert.NotEmpty(t, finished.FinishedAt)
+
+	w, export, _, err = c.registerExport("clone", request, ".tar")
+	require.NoError(t, err)
+
+	c.finishExport("clone", w, export, errors.Wrap(errors.New("connection refused"), "failed to dump the clone"))
+	assert.Equal(t, models.StatusOK, w.clone.Status.Code)
+
+	failed, err := c.GetCloneExport("clone")
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportStatusFailed, failed.Status)
+	assert.Equal(t, "connection refused", failed.Error)
+
+	// The file of an export finished after the clone is destroyed is removed.
+	w, export, _, err = c.registerExport("clone", request, ".tar")
+	require.NoError(t, err)
+
+	c.deleteClone("clone")
+	require.NoError(t, os.WriteFile(export.path, []byte("data"), 0600))
+
+	c.finishExport("clone", w, export, nil)
+
+	_, err = os.Stat(export.path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestExportedCloneCannotBeChanged(t *testing.T) {
+	c := &Base{clones: map[string]*CloneWrapper{
+		"exporting": {
+			clone:    &models.Clone{Status: models.Status{Code: models.StatusExporting, Message: models.CloneMessageExporting}},
+			session:  &resources.Session{},
+			snapshot: models.Snapshot{ID: "dblab_pool@snapshot_20211019120000"},
+		},
+	}}
+
+	requireModelError(t, c.DestroyClone("exporting"), models.ErrCodeBadRequest)
+	requireModelError(t, c.ResetClone("exporting", types.ResetCloneRequest{Latest: true}), models.ErrCodeBadRequest)
+	assert.Equal(t, models.StatusExporting, c.clones["exporting"].clone.Status.Code)
+}
+
+func TestGetCloneExportFile(t *testing.T) {
+	exportDir := t.TempDir()
+	exportPath := path.Join(exportDir, "c5ocm0qq2sfadq6ekqr0.tar")
+
+	c := &Base{clones: map[string]*CloneWrapper{
+		"not_exported": {clone: &models.Clone{}},
+		"exporting": {clone: &models.Clone{}, export: &cloneExport{
+			info: models.CloneExport{Format: models.ExportFormatDirectory, Status: models.ExportStatusInProgress},
+		}},
+		"exported": {clone: &models.Clone{}, export: &cloneExport{
+			written: 512,
+			info:    models.CloneExport{Format: models.ExportFormatDirectory, Status: models.ExportStatusFinished},
+			path:    exportPath,
+		}},
+	}}
+
+	_, _, err := c.GetCloneExportFile("not_exported")
+	require.Error(t, err)
+
+	_, _, err = c.GetCloneExportFile("exporting")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "current status is in_progress")
+
+	filePath, fileName, err := c.GetCloneExportFile("exported")
+	require.NoError(t, err)
+	assert.Equal(t, exportPath, filePath)
+	assert.Equal(t, "exported.tar", fileName)
+
+	export, err := c.GetCloneExport("exported")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(512), export.BytesWritten)
+
+	require.NoError(t, os.WriteFile(exportPath, []byte("data"), 0600))
+
+	c.deleteClone("exported")
+
+	_, err = os.Stat(exportPath)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,174 @@
+/*
+2021 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/util"
+)
+
+// ForkClone creates a new clone with the current data of the running clone.
+// The parent clone is busy until the fork is created and cannot be destroyed or reset while its forks exist.
+func (c *Base) ForkClone(parentID string, forkRequest *types.CloneForkRequest) (*models.Clone, error) {
+	clone, parentSession, release, err := c.registerFork(parentID, forkRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeralUser := resources.EphemeralUser{
+		Name:        forkRequest.DB.Username,
+		Password:    forkRequest.DB.Password,
+		Restricted:  forkRequest.DB.Restricted,
+		AvailableDB: forkRequest.DB.DBName,
+	}
+
+	go c.forkSession(clone.ID, parentSession, ephemeralUser, release)
+
+	return clone, nil
+}
+
+// registerFork holds the parent clone and tracks the fork. The returned function releases the parent.
+func (c *Base) registerFork(parentID string, forkRequest *types.CloneForkRequest) (*models.Clone, *resources.Session, func(), error) {
+	forkRequest.ID = strings.TrimSpace(forkRequest.ID)
+
+	if forkRequest.ID == "" {
+		forkRequest.ID = xid.New().String()
+	}
+
+	createdAt := time.Now()
+
+	// The parent is held, so it is neither evicted nor hibernated while its data is snapshotted.
+	parent, release, err := c.holdCloneStatus(parentID, models.CloneMessageForking)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	c.cloneMutex.Lock()
+
+	if _, ok := c.clones[forkRequest.ID]; ok {
+		c.cloneMutex.Unlock()
+		release()
+
+		return nil, nil, nil, models.New(models.ErrCodeBadRequest, "clone with such ID already exists")
+	}
+
+	snapshot := *parent.clone.Snapshot
+
+	clone := &models.Clone{
+		ID:          forkRequest.ID,
+		Snapshot:    &snapshot,
+		Protected:   forkRequest.Protected,
+		CreatedAt:   util.FormatTime(createdAt),
+		AllowEgress: parent.clone.AllowEgress,
+		Parent:      parentID,
+		Status: models.Status{
+			Code:    models.StatusCreating,
+			Message: models.CloneMessageCreating,
+		},
+		DB: models.Database{
+			Username: forkRequest.DB.Username,
+			DBName:   forkRequest.DB.DBName,
+		},
+	}
+
+	w := NewCloneWrapper(clone)
+
+	w.username = forkRequest.DB.Username
+	w.password = forkRequest.DB.Password
+	w.timeCreatedAt = createdAt
+	w.snapshot = snapshot
+
+	// Registered under the same lock, so the parent cannot be destroyed before the fork is tracked.
+	c.clones[clone.ID] = w
+	parentSession := parent.session
+
+	c.cloneMutex.Unlock()
+
+	return clone, parentSession, release, nil
+}
+
+// forkSession starts the session of the fork. The parent is released once the session is started or has failed.
+func (c *Base) forkSession(cloneID string, parentSession *resources.Session, user resources.EphemeralUser, release func()) {
+	c.ensureRunningLimit(cloneID)
+
+	session, err := c.provision.ForkSession(parentSession, user, c.config.AccessHost)
+
+	release()
+
+	if err != nil {
+		log.Errf("Failed to fork session: %v.", err)
+
+		if updateErr := c.UpdateCloneStatus(cloneID, models.Status{
+			Code:    models.StatusFatal,
+			Message: errors.Cause(err).Error(),
+		}); updateErr != nil {
+			log.Errf("Failed to update clone status: %v", updateErr)
+		}
+
+		return
+	}
+
+	c.setCloneSession(cloneID, session)
+}
+
+// updateStatusWithoutForks sets the clone status if no clones are forked from the clone and the clone is neither busy nor exported.
+func (c *Base) updateStatusWithoutForks(cloneID string, status models.Status) error {
+	c.cloneMutex.Lock()
+	defer c.cloneMutex.Unlock()
+
+	w, ok := c.clones[cloneID]
+	if !ok {
+		return models.New(models.ErrCodeNotFound, "clone not found")
+	}
+
+	switch w.clone.Status.Code {
+	case models.StatusBusy:
+		return models.New(models.ErrCodeBadRequest, "clone is busy: "+w.clone.Status.Message)
+
+	case models.StatusExporting:
+		return models.New(models.ErrCodeBadRequest, "clone is being exported")
+	}
+
+	if err := c.checkNoForks(cloneID); err != nil {
+		return err
+	}
+
+	w.clone.Status = status
+
+	return nil
+}
+
+// checkNoForks checks that no clones are forked from the clone.
+// Forks are created only from clones having the OK status, so no forks appear once the clone status is changed.
+// The clone mutex must be held by the caller.
+func (c *Base) checkNoForks(cloneID string) error {
+	forks := []string{}
+
+	for id, w := range c.clones {
+		if w.clone.Parent == cloneID {
+			forks = append(forks, id)
+		}
+	}
+
+	if len(forks) > 0 {
+		sort.Strings(forks)
+
+		return models.New(models.ErrCodeBadRequest,
+			fmt.Sprintf("clone has forks, destroy them first: %s", strings.Join(forks, ", ")))
+	}
+
+	return nil
+}
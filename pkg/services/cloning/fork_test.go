@@ -0,0 +1,76 @@
+package cloning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+)
+
+func TestRegisterForkHoldsParent(t *testing.T) {
+	c := &Base{clones: map[string]*CloneWrapper{
+		"parent": {
+			clone: &models.Clone{
+				Status:   models.Status{Code: models.StatusOK},
+				Snapshot: &models.Snapshot{ID: "dblab_pool@snapshot_20211019120000"},
+			},
+			session: &resources.Session{},
+		},
+		"existing": {clone: &models.Clone{Status: models.Status{Code: models.StatusHibernated}}, session: &resources.Session{}},
+	}}
+
+	forkRequest := func(id string) *types.CloneForkRequest {
+		return &types.CloneForkRequest{ID: id, DB: &types.DatabaseRequest{Username: "john", Password: "secret"}}
+	}
+
+	// The parent is released if the fork cannot be registered.
+	_, _, _, err := c.registerFork("parent", forkRequest("existing"))
+	assert.EqualError(t, err, "clone with such ID already exists")
+	assert.Equal(t, models.StatusOK, c.clones["parent"].clone.Status.Code)
+
+	fork, parentSession, release, err := c.registerFork("parent", forkRequest("fork"))
+	require.NoError(t, err)
+	assert.Equal(t, "parent", fork.Parent)
+	assert.Equal(t, c.clones["parent"].session, parentSession)
+	assert.Equal(t, models.StatusBusy, c.clones["parent"].clone.Status.Code)
+
+	// The parent and the fork are running, but the parent cannot be evicted while the fork is in progress.
+	running, candidates := c.runningClones("fork")
+	assert.Equal(t, 2, running)
+	assert.Empty(t, candidates)
+
+	_, _, _, err = c.registerFork("parent", forkRequest("fork2"))
+	assert.Error(t, err)
+
+	release()
+
+	running, candidates = c.runningClones("fork")
+	assert.Equal(t, 2, running)
+	require.Len(t, candidates, 1)
+	assert.Equal(t, "parent", candidates[0].cloneID)
+}
+
+func TestParentCannotBeDestroyedWithForks(t *testing.T) {
+	c := &Base{clones: map[string]*CloneWrapper{
+		"parent": {clone: &models.Clone{ID: "parent", Status: models.Status{Code: models.StatusOK}}, session: &resources.Session{}},
+		"fork2":  {clone: &models.Clone{ID: "fork2", Parent: "parent", Status: models.Status{Code: models.StatusOK}}},
+		"fork1":  {clone: &models.Clone{ID: "fork1", Parent: "parent", Status: models.Status{Code: models.StatusFatal}}},
+	}}
+
+	err := c.DestroyClone("parent")
+	require.Error(t, err)
+	assert.Equal(t, "clone has forks, destroy them first: fork1, fork2", err.Error())
+	assert.Equal(t, models.StatusOK, c.clones["parent"].clone.Status.Code)
+
+	err = c.ResetClone("parent", types.ResetCloneRequest{})
+	assert.Error(t, err)
+	assert.Equal(t, models.StatusOK, c.clones["parent"].clone.Status.Code)
+
+	// A fork without a session is removed immediately.
+	require.NoError(t, c.DestroyClone("fork1"))
+	assert.NotContains(t, c.clones, "fork1")
+}
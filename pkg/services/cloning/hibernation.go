@@ -0,0 +1,214 @@
+/*
+2021 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+)
+
+const (
+	// IdlePolicyDestroy defines the policy to destroy idle clones.
+	IdlePolicyDestroy = "destroy"
+
+	// IdlePolicyHibernate defines the policy to hibernate idle clones.
+	IdlePolicyHibernate = "hibernate"
+
+	// resumeTimeout limits waiting for a clone resumed by a new connection.
+	resumeTimeout         = 5 * time.Minute
+	resumePollingInterval = 500 * time.Millisecond
+)
+
+// IsValidConfig checks the cloning configuration.
+func IsValidConfig(cfg Config) error {
+	switch cfg.IdlePolicy {
+	case "", IdlePolicyDestroy, IdlePolicyHibernate:
+		return nil
+	}
+
+	return errors.Errorf(`unknown "idlePolicy" %q: use %q or %q`, cfg.IdlePolicy, IdlePolicyDestroy, IdlePolicyHibernate)
+}
+
+// HibernateClone stops the container of the clone keeping its data and port.
+func (c *Base) HibernateClone(cloneID string) error {
+	w, err := c.transitCloneStatus(cloneID, models.StatusOK, models.Status{
+		Code:    models.StatusHibernating,
+		Message: models.CloneMessageHibernating,
+	})
+	if err != nil {
+		return err
+	}
+
+	go c.hibernateClone(cloneID, w, false)
+
+	return nil
+}
+
+// hibernateClone stops the container of the clone. Evicted clones are resumed on the next request.
+func (c *Base) hibernateClone(cloneID string, w *CloneWrapper, evicted bool) {
+	if err := c.provision.HibernateSession(w.session); err != nil {
+		log.Errf("Failed to hibernate clone: %+v.", err)
+
+		if updateErr := c.UpdateCloneStatus(cloneID, models.Status{
+			Code:    models.StatusFatal,
+			Message: errors.Cause(err).Error(),
+		}); updateErr != nil {
+			log.Errf("Failed to update clone status: %v", updateErr)
+		}
+
+		return
+	}
+
+	c.cloneMutex.Lock()
+	defer c.cloneMutex.Unlock()
+
+	w.evicted = evicted
+	w.timeHibernatedAt = time.Now()
+	w.clone.Status = models.Status{
+		Code:    models.StatusHibernated,
+		Message: models.CloneMessageHibernated,
+	}
+
+	if evicted {
+		w.clone.Status.Message = models.CloneMessageEvicted
+	}
+}
+
+// ResumeClone starts the container of a hibernated clone.
+func (c *Base) ResumeClone(cloneID string) error {
+	w, err := c.transitCloneStatus(cloneID, models.StatusHibernated, models.Status{
+		Code:    models.StatusResuming,
+		Message: models.CloneMessageResuming,
+	})
+	if err != nil {
+		return err
+	}
+
+	go c.resumeClone(cloneID, w)
+
+	return nil
+}
+
+func (c *Base) resumeClone(cloneID string, w *CloneWrapper) {
+	c.ensureRunningLimit(cloneID)
+
+	if err := c.provision.ResumeSession(w.session); err != nil {
+		log.Errf("Failed to resume clone: %+v.", err)
+
+		if updateErr := c.UpdateCloneStatus(cloneID, models.Status{
+			Code:    models.StatusFatal,
+			Message: errors.Cause(err).Error(),
+		}); updateErr != nil {
+			log.Errf("Failed to update clone status: %v", updateErr)
+		}
+
+		return
+	}
+
+	c.cloneMutex.Lock()
+	defer c.cloneMutex.Unlock()
+
+	w.timeStartedAt = time.Now()
+	w.evicted = false
+	w.clone.DB.CACert = w.session.CACert
+	w.clone.Status = models.Status{
+		Code:    models.StatusOK,
+		Message: models.CloneMessageOK,
+	}
+}
+
+// transitCloneStatus sets the status of a started clone if the clone has the expected status.
+func (c *Base) transitCloneStatus(cloneID string, expected models.StatusCode, status models.Status) (*CloneWrapper, error) {
+	c.cloneMutex.Lock()
+	defer c.cloneMutex.Unlock()
+
+	w, err := c.checkCloneStatus(cloneID, expected)
+	if err != nil {
+		return nil, err
+	}
+
+	w.clone.Status = status
+
+	return w, nil
+}
+
+// holdCloneStatus moves the running clone into the busy status for the duration of an operation,
+// so the clone cannot be reset, destroyed, or hibernated meanwhile. The returned function restores the OK status.
+func (c *Base) holdCloneStatus(cloneID, message string) (*CloneWrapper, func(), error) {
+	w, err := c.transitCloneStatus(cloneID, models.StatusOK, models.Status{
+		Code:    models.StatusBusy,
+		Message: message,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	release := func() {
+		c.cloneMutex.Lock()
+		defer c.cloneMutex.Unlock()
+
+		if w.clone.Status.Code == models.StatusBusy {
+			w.clone.Status = models.Status{
+				Code:    models.StatusOK,
+				Message: models.CloneMessageOK,
+			}
+		}
+	}
+
+	return w, release, nil
+}
+
+// checkCloneStatus returns the wrapper of a started clone if the clone has the expected status.
+// The clone mutex must be held by the caller.
+func (c *Base) checkCloneStatus(cloneID string, expected models.StatusCode) (*CloneWrapper, error) {
+	w, ok := c.clones[cloneID]
+	if !ok {
+		return nil, models.New(models.ErrCodeNotFound, "clone not found")
+	}
+
+	if w.session == nil {
+		return nil, models.New(models.ErrCodeBadRequest, "clone is not started yet")
+	}
+
+	if w.clone.Status.Code != expected {
+		return nil, models.New(models.ErrCodeBadRequest,
+			fmt.Sprintf("clone status must be %s, current status is %s", expected, w.clone.Status.Code))
+	}
+
+	return w, nil
+}
+
+// waitCloneAddress returns the address of the clone once it is resumed.
+func (c *Base) waitCloneAddress(cloneID string) (string, error) {
+	deadline := time.Now().Add(resumeTimeout)
+
+	for {
+		address, statusCode, err := c.cloneAddress(cloneID)
+		if err != nil {
+			return "", err
+		}
+
+		// Busy and exported clones keep accepting connections.
+		if statusCode == models.StatusOK || statusCode == models.StatusBusy || statusCode == models.StatusExporting {
+			return address, nil
+		}
+
+		if statusCode != models.StatusResuming || time.Now().After(deadline) {
+			return "", errors.Errorf("clone %q is not ready: %s", cloneID, statusCode)
+		}
+
+		time.Sleep(resumePollingInterval)
+	}
+}
+
+// isSuspended checks if the container of the clone is stopped or is being stopped or started by hibernation.
+func isSuspended(statusCode models.StatusCode) bool {
+	return statusCode == models.StatusHibernating || statusCode == models.StatusHibernated || statusCode == models.StatusResuming
+}
@@ -0,0 +1,125 @@
+package cloning
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+)
+
+func TestIsValidConfig(t *testing.T) {
+	assert.NoError(t, IsValidConfig(Config{}))
+	assert.NoError(t, IsValidConfig(Config{IdlePolicy: IdlePolicyDestroy}))
+	assert.NoError(t, IsValidConfig(Config{IdlePolicy: IdlePolicyHibernate}))
+	assert.Error(t, IsValidConfig(Config{IdlePolicy: "stop"}))
+}
+
+func TestTransitCloneStatus(t *testing.T) {
+	c := &Base{clones: map[string]*CloneWrapper{
+		"started":    {clone: &models.Clone{Status: models.Status{Code: models.StatusOK}}, session: &resources.Session{}},
+		"notStarted": {clone: &models.Clone{Status: models.Status{Code: models.StatusCreating}}},
+	}}
+
+	hibernating := models.Status{Code: models.StatusHibernating}
+
+	w, err := c.transitCloneStatus("started", models.StatusOK, hibernating)
+	require.NoError(t, err)
+	assert.Equal(t, hibernating, w.clone.Status)
+
+	_, err = c.transitCloneStatus("started", models.StatusOK, hibernating)
+	assert.Error(t, err)
+
+	_, err = c.transitCloneStatus("notStarted", models.StatusCreating, hibernating)
+	assert.Error(t, err)
+
+	_, err = c.transitCloneStatus("unknown", models.StatusOK, hibernating)
+	assert.Error(t, err)
+}
+
+func TestHoldCloneStatus(t *testing.T) {
+	started := func(code models.StatusCode) *CloneWrapper {
+		return &CloneWrapper{clone: &models.Clone{Status: models.Status{Code: code}}, session: &resources.Session{}}
+	}
+
+	testCases := []struct {
+		name    string
+		wrapper *CloneWrapper
+		code    models.ErrorCode
+	}{
+		{name: "ready clone", wrapper: started(models.StatusOK)},
+		{name: "unknown clone", code: models.ErrCodeNotFound},
+		{
+			name:    "clone is not started",
+			wrapper: &CloneWrapper{clone: &models.Clone{Status: models.Status{Code: models.StatusCreating}}},
+			code:    models.ErrCodeBadRequest,
+		},
+		{name: "busy clone", wrapper: started(models.StatusBusy), code: models.ErrCodeBadRequest},
+		{name: "hibernated clone", wrapper: started(models.StatusHibernated), code: models.ErrCodeBadRequest},
+		{name: "exported clone", wrapper: started(models.StatusExporting), code: models.ErrCodeBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Base{clones: map[string]*CloneWrapper{}}
+
+			if tc.wrapper != nil {
+				c.clones["clone"] = tc.wrapper
+			}
+
+			w, release, err := c.holdCloneStatus("clone", models.CloneMessageSavepoint)
+
+			if tc.code != "" {
+				requireModelError(t, err, tc.code)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, models.Status{Code: models.StatusBusy, Message: models.CloneMessageSavepoint}, w.clone.Status)
+
+			// Busy clones cannot be held twice, reset or destroyed.
+			_, _, err = c.holdCloneStatus("clone", models.CloneMessageSavepoint)
+			requireModelError(t, err, models.ErrCodeBadRequest)
+			requireModelError(t, c.updateStatusWithoutForks("clone", models.Status{Code: models.StatusResetting}),
+				models.ErrCodeBadRequest)
+
+			release()
+			assert.Equal(t, models.StatusOK, w.clone.Status.Code)
+			assert.NoError(t, c.updateStatusWithoutForks("clone", models.Status{Code: models.StatusResetting}))
+
+			// A status set by another operation is not overwritten.
+			release()
+			assert.Equal(t, models.StatusResetting, w.clone.Status.Code)
+		})
+	}
+}
+
+func TestIsIdleHibernatedClone(t *testing.T) {
+	c := &Base{config: &Config{MaxIdleMinutes: 60}}
+
+	testCases := []struct {
+		name         string
+		hibernatedAt time.Time
+		protected    bool
+		idle         bool
+	}{
+		{name: "not resumed for the idle duration", hibernatedAt: time.Now().Add(-2 * time.Hour), idle: true},
+		{name: "recently hibernated", hibernatedAt: time.Now().Add(-30 * time.Minute)},
+		{name: "protected", hibernatedAt: time.Now().Add(-2 * time.Hour), protected: true},
+	}
+
+	for _, tc := range testCases {
+		w := &CloneWrapper{
+			clone:            &models.Clone{Protected: tc.protected, Status: models.Status{Code: models.StatusHibernated}},
+			session:          &resources.Session{},
+			timeHibernatedAt: tc.hibernatedAt,
+		}
+
+		idle, err := c.isIdleClone(w)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.idle, idle, tc.name)
+	}
+}
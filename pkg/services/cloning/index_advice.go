@@ -0,0 +1,252 @@
+/*
+2021 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgtype/pgxtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+)
+
+const (
+	explainCostPrefix = "explain (format json) "
+	explainTimePrefix = "explain (analyze, format json) "
+
+	hypopgAvailableQuery = "select exists (select from pg_catalog.pg_available_extensions where name = 'hypopg')"
+
+	totalPercent = 100
+)
+
+// AdviseIndexes compares plan costs of the query with each of the candidate indexes using hypothetical indexes of hypopg.
+// The query is not executed and the clone is not changed unless the build of the index reducing the cost the most
+// is requested: then the index is created in the clone and the query is executed with EXPLAIN ANALYZE before and after.
+// Executions of the query are limited by the configured statement timeout.
+func (c *Base) AdviseIndexes(ctx context.Context, cloneID string, adviceRequest types.IndexAdviceRequest) (*models.IndexAdvice, error) {
+	query := strings.TrimSpace(adviceRequest.Query)
+	if query == "" {
+		return nil, models.New(models.ErrCodeBadRequest, "query must not be empty")
+	}
+
+	if len(adviceRequest.Indexes) == 0 {
+		return nil, models.New(models.ErrCodeBadRequest, "at least one candidate index must be defined")
+	}
+
+	c.cloneMutex.RLock()
+	_, err := c.checkCloneStatus(cloneID, models.StatusOK)
+	c.cloneMutex.RUnlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	advice, err := c.compareIndexes(ctx, cloneID, query, adviceRequest.Indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	if adviceRequest.Build && advice.BestIndex != "" {
+		if advice.Build, err = c.buildIndex(ctx, cloneID, query, advice.BestIndex); err != nil {
+			return nil, err
+		}
+	}
+
+	return advice, nil
+}
+
+// compareIndexes explains the query with each hypothetical index in a transaction which is rolled back,
+// so hypopg is not left installed in the clone.
+func (c *Base) compareIndexes(ctx context.Context, cloneID, query string, indexes []string) (*models.IndexAdvice, error) {
+	conn, err := c.ConnectToClone(ctx, cloneID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to the clone")
+	}
+
+	defer func() { _ = conn.Close(ctx) }()
+
+	var hypopgAvailable bool
+
+	if err := conn.QueryRow(ctx, hypopgAvailableQuery).Scan(&hypopgAvailable); err != nil {
+		return nil, errors.Wrap(err, "failed to check available extensions")
+	}
+
+	if !hypopgAvailable {
+		return nil, models.New(models.ErrCodeBadRequest, "hypopg extension is not available in the clone image")
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to begin a transaction")
+	}
+
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, "create extension if not exists hypopg"); err != nil {
+		return nil, errors.Wrap(err, "failed to create hypopg extension")
+	}
+
+	_, output, err := explainQuery(ctx, tx, explainCostPrefix, query)
+	if err != nil {
+		return nil, err
+	}
+
+	advice := &models.IndexAdvice{
+		Cost:       output.Plan.TotalCost,
+		Candidates: make([]models.IndexCandidate, 0, len(indexes)),
+	}
+
+	for _, definition := range indexes {
+		candidate := models.IndexCandidate{Definition: definition}
+
+		if err := explainHypotheticalIndex(ctx, tx, query, &candidate); err != nil {
+			candidate.Error = errors.Cause(err).Error()
+		}
+
+		advice.Candidates = append(advice.Candidates, candidate)
+	}
+
+	advice.BestIndex = bestIndex(advice)
+
+	return advice, nil
+}
+
+// explainHypotheticalIndex explains the query with the hypothetical index of the candidate.
+// Errors are rolled back to a savepoint, so other candidates can be explained in the transaction.
+func explainHypotheticalIndex(ctx context.Context, tx pgxtype.Querier, query string, candidate *models.IndexCandidate) error {
+	if _, err := tx.Exec(ctx, "savepoint hypothetical_index"); err != nil {
+		return err
+	}
+
+	var (
+		indexOID  uint32
+		indexName string
+	)
+
+	err := tx.QueryRow(ctx, "select indexrelid, indexname from hypopg_create_index($1)", candidate.Definition).
+		Scan(&indexOID, &indexName)
+	if err != nil {
+		_, _ = tx.Exec(ctx, "rollback to savepoint hypothetical_index")
+		return queryError(err)
+	}
+
+	plan, output, err := explainQuery(ctx, tx, explainCostPrefix, query)
+	if err != nil {
+		_, _ = tx.Exec(ctx, "rollback to savepoint hypothetical_index")
+	}
+
+	// Hypothetical indexes are not transactional.
+	if _, dropErr := tx.Exec(ctx, "select hypopg_drop_index($1)", indexOID); dropErr != nil && err == nil {
+		err = errors.Wrap(dropErr, "failed to drop the hypothetical index")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	candidate.Cost = output.Plan.TotalCost
+	candidate.Used = strings.Contains(plan, indexName)
+
+	return nil
+}
+
+// bestIndex chooses the used candidate index with the lowest cost and calculates cost reductions of candidates.
+func bestIndex(advice *models.IndexAdvice) string {
+	var best *models.IndexCandidate
+
+	for i := range advice.Candidates {
+		candidate := &advice.Candidates[i]
+
+		if candidate.Error != "" {
+			continue
+		}
+
+		if advice.Cost > 0 {
+			candidate.CostReduction = (advice.Cost - candidate.Cost) / advice.Cost * totalPercent
+		}
+
+		if candidate.Used && candidate.Cost < advice.Cost && (best == nil || candidate.Cost < best.Cost) {
+			best = candidate
+		}
+	}
+
+	if best == nil {
+		return ""
+	}
+
+	return best.Definition
+}
+
+// buildIndex creates the index in the clone and measures execution times of the query before and after.
+// Executions of the query are rolled back. The first execution is discarded, so both measured executions use a warm cache.
+// The clone is busy during the build, so it cannot be reset or destroyed meanwhile.
+func (c *Base) buildIndex(ctx context.Context, cloneID, query, definition string) (*models.IndexBuild, error) {
+	_, release, err := c.holdCloneStatus(cloneID, models.CloneMessageIndexBuild)
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	conn, err := c.ConnectToClone(ctx, cloneID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to the clone")
+	}
+
+	defer func() { _ = conn.Close(ctx) }()
+
+	timeoutSeconds := c.config.QueryTimeoutSeconds
+
+	if _, err := explainInRolledBackTx(ctx, conn, query, timeoutSeconds); err != nil {
+		return nil, err
+	}
+
+	before, err := explainInRolledBackTx(ctx, conn, query, timeoutSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	startedAt := time.Now()
+
+	if _, err := conn.Exec(ctx, definition); err != nil {
+		return nil, queryError(err)
+	}
+
+	buildTime := time.Since(startedAt)
+
+	after, err := explainInRolledBackTx(ctx, conn, query, timeoutSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.IndexBuild{
+		Definition:          definition,
+		BuildTime:           float64(buildTime) / float64(time.Millisecond),
+		ExecutionTimeBefore: before.ExecutionTime,
+		ExecutionTimeAfter:  after.ExecutionTime,
+	}, nil
+}
+
+func explainInRolledBackTx(ctx context.Context, conn *pgx.Conn, query string, timeoutSeconds uint) (*explainOutput, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to begin a transaction")
+	}
+
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := setStatementTimeout(ctx, tx, timeoutSeconds); err != nil {
+		return nil, err
+	}
+
+	_, output, err := explainQuery(ctx, tx, explainTimePrefix, query)
+
+	return output, err
+}
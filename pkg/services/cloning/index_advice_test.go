@@ -0,0 +1,88 @@
+package cloning
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+)
+
+func TestExplainHypotheticalIndex(t *testing.T) {
+	const (
+		query      = "select * from users where email = 'alice@example.com'"
+		definition = "create index on users (email)"
+		plan       = `[{"Plan": {"Node Type": "Index Scan", "Index Name": "<13543>btree_users_email", "Total Cost": 8.3}}]`
+	)
+
+	newQuerier := func(failOn string) *recordingQuerier {
+		return &recordingQuerier{
+			failOn: failOn,
+			rows: map[string][]interface{}{
+				"select indexrelid": {uint32(13543), "<13543>btree_users_email"},
+				explainCostPrefix:   {plan},
+			},
+		}
+	}
+
+	querier := newQuerier("")
+	candidate := models.IndexCandidate{Definition: definition}
+
+	require.NoError(t, explainHypotheticalIndex(context.Background(), querier, query, &candidate))
+	assert.Equal(t, models.IndexCandidate{Definition: definition, Cost: 8.3, Used: true}, candidate)
+	assert.Equal(t, []string{
+		"savepoint hypothetical_index",
+		"select indexrelid, indexname from hypopg_create_index($1)",
+		explainCostPrefix + query,
+		"select hypopg_drop_index($1)",
+	}, querier.statements)
+
+	// Invalid definitions are reported as bad requests and rolled back, so other candidates can be explained.
+	querier = newQuerier("hypopg_create_index")
+	candidate = models.IndexCandidate{Definition: "create index on users (emial)"}
+
+	err := explainHypotheticalIndex(context.Background(), querier, query, &candidate)
+	requireModelError(t, err, models.ErrCodeBadRequest)
+	assert.Equal(t, "rollback to savepoint hypothetical_index", querier.statements[len(querier.statements)-1])
+	assert.Equal(t, float64(0), candidate.Cost)
+
+	// The hypothetical index is dropped if the query cannot be explained.
+	querier = newQuerier(explainCostPrefix)
+
+	err = explainHypotheticalIndex(context.Background(), querier, query, &models.IndexCandidate{Definition: definition})
+	requireModelError(t, err, models.ErrCodeBadRequest)
+	assert.Equal(t, []string{"rollback to savepoint hypothetical_index", "select hypopg_drop_index($1)"}, querier.statements[3:])
+
+	// Failures to drop the hypothetical index are reported.
+	querier = newQuerier("hypopg_drop_index")
+
+	err = explainHypotheticalIndex(context.Background(), querier, query, &models.IndexCandidate{Definition: definition})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to drop the hypothetical index")
+}
+
+func TestBestIndex(t *testing.T) {
+	advice := &models.IndexAdvice{
+		Cost: 200,
+		Candidates: []models.IndexCandidate{
+			{Definition: "create index on users (name)", Cost: 200},
+			{Definition: "create index on users (email)", Cost: 50, Used: true},
+			{Definition: "create index on users (email, name)", Cost: 20, Used: true},
+			{Definition: "create index on users (id)", Cost: 10, Used: true, Error: "failed"},
+			{Definition: "create index on users (created_at)", Cost: 10},
+		},
+	}
+
+	assert.Equal(t, "create index on users (email, name)", bestIndex(advice))
+	assert.Equal(t, float64(0), advice.Candidates[0].CostReduction)
+	assert.Equal(t, float64(75), advice.Candidates[1].CostReduction)
+	assert.Equal(t, float64(90), advice.Candidates[2].CostReduction)
+	assert.Equal(t, float64(0), advice.Candidates[3].CostReduction)
+
+	assert.Equal(t, "", bestIndex(&models.IndexAdvice{
+		Cost:       100,
+		Candidates: []models.IndexCandidate{{Definition: "create index on users (name)", Cost: 150, Used: true}},
+	}))
+}
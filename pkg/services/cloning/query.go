@@ -0,0 +1,189 @@
+/*
+2021 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype/pgxtype"
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/estimator"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+)
+
+const (
+	explainPrefix = "explain (analyze, buffers, format json) "
+
+	// profilerStopTimeout limits waiting for the profiler to notice that the query is finished.
+	profilerStopTimeout = time.Second
+)
+
+// explainOutput contains the cost and timings of a query reported by EXPLAIN in the JSON format.
+type explainOutput struct {
+	Plan struct {
+		TotalCost float64 `json:"Total Cost"`
+	} `json:"Plan"`
+	PlanningTime  float64 `json:"Planning Time"`
+	ExecutionTime float64 `json:"Execution Time"`
+}
+
+// RunQuery executes the query with EXPLAIN ANALYZE in a transaction of the clone, the transaction is rolled back
+// unless the commit is requested. Wait events of the query are sampled by the profiler from another connection.
+// The query is limited by the configured statement timeout.
+func (c *Base) RunQuery(ctx context.Context, cloneID string, queryRequest types.QueryRequest,
+	estCfg estimator.Config) (*models.QueryResult, error) {
+	query := strings.TrimSpace(queryRequest.Query)
+	if query == "" {
+		return nil, models.New(models.ErrCodeBadRequest, "query must not be empty")
+	}
+
+	c.cloneMutex.RLock()
+	_, err := c.checkCloneStatus(cloneID, models.StatusOK)
+	c.cloneMutex.RUnlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := c.ConnectToClone(ctx, cloneID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to the clone")
+	}
+
+	defer func() { _ = conn.Close(ctx) }()
+
+	profilerConn, err := c.ConnectToClone(ctx, cloneID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to the clone")
+	}
+
+	defer func() { _ = profilerConn.Close(ctx) }()
+
+	// The transaction is started before profiling, so the profiler does not stop when the BEGIN command finishes.
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to begin a transaction")
+	}
+
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := setStatementTimeout(ctx, tx, c.config.QueryTimeoutSeconds); err != nil {
+		return nil, err
+	}
+
+	profiler := estimator.NewProfiler(profilerConn, estimator.TraceOptions{
+		Pid:             int(conn.PgConn().PID()),
+		Interval:        estCfg.ProfilingInterval,
+		SampleThreshold: estCfg.SampleThreshold,
+		ReadRatio:       estCfg.ReadRatio,
+		WriteRatio:      estCfg.WriteRatio,
+		StopOnQueryEnd:  true,
+	})
+
+	profilerCtx, cancelProfiler := context.WithCancel(ctx)
+	defer cancelProfiler()
+
+	go profiler.Start(profilerCtx)
+
+	startedAt := time.Now()
+
+	plan, output, err := explainQuery(ctx, tx, explainPrefix, query)
+	if err != nil {
+		return nil, err
+	}
+
+	totalTime := time.Since(startedAt)
+
+	if queryRequest.Commit {
+		if err := tx.Commit(ctx); err != nil {
+			return nil, queryError(err)
+		}
+	}
+
+	select {
+	case <-profiler.Finish():
+	case <-time.After(profilerStopTimeout):
+		cancelProfiler()
+		<-profiler.Finish()
+	}
+
+	result := &models.QueryResult{
+		Plan:      json.RawMessage(plan),
+		TotalTime: float64(totalTime) / float64(time.Millisecond),
+		Committed: queryRequest.Commit,
+		Profile: models.QueryProfile{
+			IsEnoughStat:  profiler.IsEnoughSamples(),
+			SampleCounter: profiler.CountSamples(),
+			TotalTime:     profiler.TotalTime(),
+			WaitEvents:    profiler.WaitEventsRatio(),
+			RenderedStat:  profiler.RenderStat(),
+		},
+	}
+
+	result.PlanningTime = output.PlanningTime
+	result.ExecutionTime = output.ExecutionTime
+
+	if estCfg.CanEstimate() && profiler.IsEnoughSamples() {
+		if result.Profile.EstTime, err = profiler.EstimateTime(ctx); err != nil {
+			log.Err("Failed to estimate query timing: ", err)
+		}
+	}
+
+	return result, nil
+}
+
+// setStatementTimeout limits statements of the transaction. Zero timeout keeps the default of the clone.
+func setStatementTimeout(ctx context.Context, tx pgxtype.Querier, timeoutSeconds uint) error {
+	if timeoutSeconds == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, "select pg_catalog.set_config('statement_timeout', $1, true)",
+		strconv.FormatUint(uint64(timeoutSeconds), 10)+"s"); err != nil {
+		return errors.Wrap(err, "failed to set the statement timeout")
+	}
+
+	return nil
+}
+
+// explainQuery runs EXPLAIN with the prefix defining its options and parses the JSON output.
+func explainQuery(ctx context.Context, db pgxtype.Querier, prefix, query string) (string, *explainOutput, error) {
+	var plan string
+
+	if err := db.QueryRow(ctx, prefix+query).Scan(&plan); err != nil {
+		return "", nil, queryError(err)
+	}
+
+	var outputs []explainOutput
+
+	if err := json.Unmarshal([]byte(plan), &outputs); err != nil {
+		return "", nil, errors.Wrap(err, "failed to parse the query plan")
+	}
+
+	if len(outputs) == 0 {
+		return "", nil, errors.New("query plan is empty")
+	}
+
+	return plan, &outputs[0], nil
+}
+
+// queryError reports errors of Postgres as bad requests, they are caused by queries.
+func queryError(err error) error {
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) {
+		return models.New(models.ErrCodeBadRequest, "failed to run query: "+pgErr.Error())
+	}
+
+	return errors.Wrap(err, "failed to run query")
+}
@@ -0,0 +1,97 @@
+package cloning
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype/pgxtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+)
+
+// recordingQuerier records executed statements and fails the statement containing failOn with a Postgres error.
+// Rows contain values of single-row results by prefixes of statements.
+type recordingQuerier struct {
+	pgxtype.Querier
+	statements []string
+	args       [][]interface{}
+	failOn     string
+	rows       map[string][]interface{}
+}
+
+func (q *recordingQuerier) record(sql string, args []interface{}) error {
+	q.statements = append(q.statements, sql)
+	q.args = append(q.args, args)
+
+	if q.failOn != "" && strings.Contains(sql, q.failOn) {
+		return &pgconn.PgError{Severity: "ERROR", Code: "42601", Message: "syntax error"}
+	}
+
+	return nil
+}
+
+func (q *recordingQuerier) Exec(_ context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return nil, q.record(sql, args)
+}
+
+func (q *recordingQuerier) QueryRow(_ context.Context, sql string, args ...interface{}) pgx.Row {
+	if err := q.record(sql, args); err != nil {
+		return testRow{err: err}
+	}
+
+	for prefix, values := range q.rows {
+		if strings.HasPrefix(sql, prefix) {
+			return testRow{values: values}
+		}
+	}
+
+	return testRow{err: pgx.ErrNoRows}
+}
+
+type testRow struct {
+	values []interface{}
+	err    error
+}
+
+func (r testRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	for i := range dest {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(r.values[i]))
+	}
+
+	return nil
+}
+
+func TestSetStatementTimeout(t *testing.T) {
+	querier := &recordingQuerier{}
+
+	require.NoError(t, setStatementTimeout(context.Background(), querier, 0))
+	assert.Empty(t, querier.statements)
+
+	require.NoError(t, setStatementTimeout(context.Background(), querier, 600))
+	assert.Equal(t, []string{"select pg_catalog.set_config('statement_timeout', $1, true)"}, querier.statements)
+	assert.Equal(t, [][]interface{}{{"600s"}}, querier.args)
+}
+
+func TestQueryError(t *testing.T) {
+	err := queryError(errors.Wrap(&pgconn.PgError{Severity: "ERROR", Code: "42P01", Message: `relation "users" does not exist`}, "query"))
+
+	modelErr, ok := err.(*models.Error)
+	require.True(t, ok)
+	assert.Equal(t, models.ErrCodeBadRequest, modelErr.Code)
+	assert.Contains(t, modelErr.Message, `relation "users" does not exist`)
+
+	err = queryError(errors.New("connection reset"))
+	_, ok = err.(*models.Error)
+	assert.False(t, ok)
+}
@@ -0,0 +1,176 @@
+/*
+2021 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/util"
+)
+
+// savepointNameRegexp matches valid savepoint names, so they can be used in snapshot names.
+var savepointNameRegexp = regexp.MustCompile(`^[\w.-]{1,64}$`)
+
+// CreateSavepoint snapshots the running clone, so the clone can be reverted to its current state.
+// The name is generated from the current time if it is empty. The clone is busy until the snapshot is taken.
+func (c *Base) CreateSavepoint(cloneID, name string) (*models.Savepoint, error) {
+	if name == "" {
+		name = time.Now().Format(util.DataStateAtFormat)
+	}
+
+	if !savepointNameRegexp.MatchString(name) {
+		return nil, models.New(models.ErrCodeBadRequest,
+			fmt.Sprintf("invalid savepoint name %q: use up to 64 letters, digits, dots, dashes, and underscores", name))
+	}
+
+	w, release, err := c.holdCloneStatus(cloneID, models.CloneMessageSavepoint)
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	savepoints, err := c.provision.ListSavepoints(w.session)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list savepoints")
+	}
+
+	if findSavepoint(savepoints, name) != nil {
+		return nil, models.New(models.ErrCodeBadRequest, fmt.Sprintf("savepoint %q already exists", name))
+	}
+
+	savepoint, err := c.provision.CreateSavepoint(w.session, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create a savepoint")
+	}
+
+	return &models.Savepoint{Name: savepoint.Name, CreatedAt: util.FormatTime(savepoint.CreatedAt)}, nil
+}
+
+// GetSavepoints lists savepoints of the clone ordered from the latest one.
+func (c *Base) GetSavepoints(cloneID string) ([]models.Savepoint, error) {
+	w, ok := c.findWrapper(cloneID)
+	if !ok {
+		return nil, models.New(models.ErrCodeNotFound, "clone not found")
+	}
+
+	if w.session == nil {
+		return nil, models.New(models.ErrCodeBadRequest, "clone is not started yet")
+	}
+
+	savepoints, err := c.provision.ListSavepoints(w.session)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list savepoints")
+	}
+
+	savepointModels := make([]models.Savepoint, 0, len(savepoints))
+
+	for _, savepoint := range savepoints {
+		savepointModels = append(savepointModels, models.Savepoint{
+			Name:      savepoint.Name,
+			CreatedAt: util.FormatTime(savepoint.CreatedAt),
+		})
+	}
+
+	return savepointModels, nil
+}
+
+// RevertSavepoint starts reverting the clone to the savepoint. Savepoints created after it are destroyed.
+func (c *Base) RevertSavepoint(cloneID, name string) error {
+	c.cloneMutex.RLock()
+	w, err := c.checkCloneStatus(cloneID, models.StatusOK)
+	c.cloneMutex.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	savepoints, err := c.provision.ListSavepoints(w.session)
+	if err != nil {
+		return errors.Wrap(err, "failed to list savepoints")
+	}
+
+	if findSavepoint(savepoints, name) == nil {
+		return models.New(models.ErrCodeNotFound, fmt.Sprintf("savepoint %q not found", name))
+	}
+
+	if err := c.markReverting(cloneID); err != nil {
+		return err
+	}
+
+	go c.revertSavepoint(cloneID, w, name)
+
+	return nil
+}
+
+// markReverting sets the RESETTING status if the clone is still ready and no clones are forked from it.
+func (c *Base) markReverting(cloneID string) error {
+	c.cloneMutex.Lock()
+	defer c.cloneMutex.Unlock()
+
+	w, err := c.checkCloneStatus(cloneID, models.StatusOK)
+	if err != nil {
+		return err
+	}
+
+	// Snapshots which forks are created from cannot be rolled back.
+	if err := c.checkNoForks(cloneID); err != nil {
+		return err
+	}
+
+	w.clone.Status = models.Status{
+		Code:    models.StatusResetting,
+		Message: models.CloneMessageReverting,
+	}
+
+	return nil
+}
+
+func (c *Base) revertSavepoint(cloneID string, w *CloneWrapper, name string) {
+	c.finishRevert(cloneID, w, c.provision.RevertSavepoint(w.session, name))
+}
+
+// finishRevert sets the status of the clone once the revert is finished.
+func (c *Base) finishRevert(cloneID string, w *CloneWrapper, err error) {
+	if err != nil {
+		log.Errf("Failed to revert clone to savepoint: %+v.", err)
+
+		if updateErr := c.UpdateCloneStatus(cloneID, models.Status{
+			Code:    models.StatusFatal,
+			Message: errors.Cause(err).Error(),
+		}); updateErr != nil {
+			log.Errf("Failed to update clone status: %v", updateErr)
+		}
+
+		return
+	}
+
+	c.cloneMutex.Lock()
+	defer c.cloneMutex.Unlock()
+
+	w.clone.DB.CACert = w.session.CACert
+	w.clone.Status = models.Status{
+		Code:    models.StatusOK,
+		Message: models.CloneMessageOK,
+	}
+}
+
+// findSavepoint finds the savepoint by name.
+func findSavepoint(savepoints []resources.Savepoint, name string) *resources.Savepoint {
+	for i := range savepoints {
+		if savepoints[i].Name == name {
+			return &savepoints[i]
+		}
+	}
+
+	return nil
+}
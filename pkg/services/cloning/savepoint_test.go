@@ -0,0 +1,61 @@
+package cloning
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+)
+
+func TestRevertSavepointStatus(t *testing.T) {
+	ok := models.Status{Code: models.StatusOK, Message: models.CloneMessageOK}
+
+	c := &Base{clones: map[string]*CloneWrapper{
+		"clone": {
+			clone:   &models.Clone{Status: ok, DB: models.Database{CACert: "previous"}},
+			session: &resources.Session{CACert: "current"},
+		},
+		"parent": {clone: &models.Clone{Status: ok}, session: &resources.Session{}},
+		"fork":   {clone: &models.Clone{Status: ok, Parent: "parent"}, session: &resources.Session{}},
+	}}
+
+	// Snapshots of the parent are used by the fork.
+	requireModelError(t, c.markReverting("parent"), models.ErrCodeBadRequest)
+	assert.Equal(t, ok, c.clones["parent"].clone.Status)
+
+	w := c.clones["clone"]
+
+	require.NoError(t, c.markReverting("clone"))
+	assert.Equal(t, models.Status{Code: models.StatusResetting, Message: models.CloneMessageReverting}, w.clone.Status)
+
+	// The clone cannot be reverted twice at the same time.
+	requireModelError(t, c.markReverting("clone"), models.ErrCodeBadRequest)
+
+	c.finishRevert("clone", w, nil)
+	assert.Equal(t, ok, w.clone.Status)
+	assert.Equal(t, "current", w.clone.DB.CACert)
+
+	require.NoError(t, c.markReverting("clone"))
+
+	c.finishRevert("clone", w, errors.Wrap(errors.New("dataset is busy"), "failed to revert the clone to the savepoint"))
+	assert.Equal(t, models.Status{Code: models.StatusFatal, Message: "dataset is busy"}, w.clone.Status)
+}
+
+func TestSavepointNameRegexp(t *testing.T) {
+	assert.True(t, savepointNameRegexp.MatchString("before_migration-1.2"))
+	assert.True(t, savepointNameRegexp.MatchString("20211019120000"))
+	assert.False(t, savepointNameRegexp.MatchString(""))
+	assert.False(t, savepointNameRegexp.MatchString("a/b"))
+	assert.False(t, savepointNameRegexp.MatchString(string(make([]byte, 65))))
+}
+
+func TestFindSavepoint(t *testing.T) {
+	savepoints := []resources.Savepoint{{Name: "first"}, {Name: "second"}}
+
+	assert.Equal(t, &savepoints[1], findSavepoint(savepoints, "second"))
+	assert.Nil(t, findSavepoint(savepoints, "third"))
+}
@@ -0,0 +1,782 @@
+/*
+2021 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+)
+
+// userSchemaCondition excludes system schemas from the schema diff.
+const userSchemaCondition = `n.nspname not in ('pg_catalog', 'information_schema') and n.nspname !~ '^pg_(toast|temp_)'`
+
+// Identity columns appeared in Postgres 10, generated columns appeared in Postgres 12.
+const (
+	identityColumnsVersion  = 100000
+	generatedColumnsVersion = 120000
+)
+
+// tableColumnsQuery is a template: attributes of identity and generated columns are substituted depending on the Postgres version.
+const tableColumnsQuery = `select n.nspname, c.relname, a.attname, pg_catalog.format_type(a.atttypid, a.atttypmod),
+		coalesce(a.attnotnull, false), coalesce(pg_catalog.pg_get_expr(d.adbin, d.adrelid), ''),
+		coalesce(%s::text, ''), coalesce(%s::text, '')
+	from pg_catalog.pg_class c
+	join pg_catalog.pg_namespace n on n.oid = c.relnamespace
+	left join pg_catalog.pg_attribute a on a.attrelid = c.oid and a.attnum > 0 and not a.attisdropped
+	left join pg_catalog.pg_attrdef d on d.adrelid = c.oid and d.adnum = a.attnum
+	where c.relkind in ('r', 'p') and ` + userSchemaCondition + `
+	order by n.nspname, c.relname, a.attnum`
+
+// Indexes of primary keys, unique and exclusion constraints are created with the constraints.
+const indexesQuery = `select n.nspname, t.relname, i.relname, pg_catalog.pg_get_indexdef(x.indexrelid)
+	from pg_catalog.pg_index x
+	join pg_catalog.pg_class i on i.oid = x.indexrelid
+	join pg_catalog.pg_class t on t.oid = x.indrelid
+	join pg_catalog.pg_namespace n on n.oid = i.relnamespace
+	where t.relkind in ('r', 'p') and ` + userSchemaCondition + `
+		and not exists (select from pg_catalog.pg_constraint con
+			where con.conindid = x.indexrelid and con.contype in ('p', 'u', 'x'))`
+
+// Not-null constraints are reported as attributes of columns.
+const constraintsQuery = `select n.nspname, t.relname, con.conname, con.contype::text, pg_catalog.pg_get_constraintdef(con.oid)
+	from pg_catalog.pg_constraint con
+	join pg_catalog.pg_class t on t.oid = con.conrelid
+	join pg_catalog.pg_namespace n on n.oid = t.relnamespace
+	where t.relkind in ('r', 'p') and con.contype <> 'n' and ` + userSchemaCondition
+
+// Sequences owned by columns are created by serial types. Sequences of identity columns are created with the columns.
+const ownedSequencesQuery = `select n.nspname, t.relname, a.attname, sn.nspname, s.relname
+	from pg_catalog.pg_depend d
+	join pg_catalog.pg_class s on s.oid = d.objid
+	join pg_catalog.pg_namespace sn on sn.oid = s.relnamespace
+	join pg_catalog.pg_class t on t.oid = d.refobjid
+	join pg_catalog.pg_namespace n on n.oid = t.relnamespace
+	join pg_catalog.pg_attribute a on a.attrelid = t.oid and a.attnum = d.refobjsubid
+	where d.classid = 'pg_catalog.pg_class'::pg_catalog.regclass and d.refclassid = 'pg_catalog.pg_class'::pg_catalog.regclass
+		and d.deptype = 'a' and s.relkind = 'S' and t.relkind in ('r', 'p') and ` + userSchemaCondition
+
+// Aggregates have no definitions, functions of extensions are created with the extensions.
+const functionsQuery = `select n.nspname, p.proname, pg_catalog.pg_get_function_identity_arguments(p.oid),
+		pg_catalog.pg_get_functiondef(p.oid)
+	from pg_catalog.pg_proc p
+	join pg_catalog.pg_namespace n on n.oid = p.pronamespace
+	where ` + userSchemaCondition + `
+		and not exists (select from pg_catalog.pg_aggregate a where a.aggfnoid = p.oid)
+		and not exists (select from pg_catalog.pg_depend d
+			where d.classid = 'pg_catalog.pg_proc'::pg_catalog.regclass and d.objid = p.oid and d.deptype = 'e')`
+
+// foreignKeyConstraint defines the type of foreign key constraints in pg_constraint.
+const foreignKeyConstraint = "f"
+
+// Values of attidentity and attgenerated in pg_attribute.
+const (
+	identityByDefault = "d"
+	generatedStored   = "s"
+)
+
+// schemaObjectKey identifies a schema object. Table is empty for tables and functions.
+type schemaObjectKey struct {
+	schema string
+	table  string
+	name   string
+}
+
+// tableColumn describes a column. The default expression contains the generation expression of generated columns.
+type tableColumn struct {
+	name        string
+	dataType    string
+	notNull     bool
+	defaultExpr string
+	identity    string
+	generated   string
+}
+
+// ownedSequence describes a sequence owned by a column.
+type ownedSequence struct {
+	table  schemaObjectKey
+	column string
+}
+
+type schemaObject struct {
+	// kind contains the type of constraints and the kind of functions.
+	kind       string
+	definition string
+
+	// name and arguments identify functions to drop them.
+	name      string
+	arguments string
+}
+
+type schemaCatalog struct {
+	tables      map[schemaObjectKey][]tableColumn
+	sequences   map[schemaObjectKey]ownedSequence
+	indexes     map[schemaObjectKey]schemaObject
+	constraints map[schemaObjectKey]schemaObject
+	functions   map[schemaObjectKey]schemaObject
+}
+
+type schemaObjectChange struct {
+	key    schemaObjectKey
+	change string
+	from   schemaObject
+	to     schemaObject
+}
+
+// isForeignKey checks if the change is of a foreign key constraint.
+func (c schemaObjectChange) isForeignKey() bool {
+	return c.from.kind == foreignKeyConstraint || c.to.kind == foreignKeyConstraint
+}
+
+// tableKey returns the key of the table of a constraint or an index.
+func (c schemaObjectChange) tableKey() schemaObjectKey {
+	return schemaObjectKey{schema: c.key.schema, name: c.key.table}
+}
+
+// DiffCloneSchema compares the schema of the clone database with the schema in the snapshot the clone is created from.
+// The catalog of the snapshot is read from a temporary session. The clone is busy until the comparison is finished.
+func (c *Base) DiffCloneSchema(ctx context.Context, cloneID, dbName string) (*models.SchemaDiff, error) {
+	w, release, err := c.holdCloneStatus(cloneID, models.CloneMessageSchemaDiff)
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	c.cloneMutex.RLock()
+
+	session, snapshotID := w.session, w.clone.Snapshot.ID
+
+	if dbName == "" {
+		dbName = w.clone.DB.DBName
+	}
+
+	c.cloneMutex.RUnlock()
+
+	if dbName == "" {
+		dbName = defaultDatabaseName
+	}
+
+	cloneSchema, err := loadSchemaCatalog(ctx,
+		connectionString(session.SocketHost, strconv.FormatUint(uint64(session.Port), 10), session.User, dbName))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the schema of the clone")
+	}
+
+	var snapshotSchema *schemaCatalog
+
+	err = c.withDiffSession(ctx, snapshotID, func(ctx context.Context, snapshotSession *resources.Session) error {
+		var err error
+
+		snapshotSchema, err = loadSchemaCatalog(ctx, connectionString(snapshotSession.SocketHost,
+			strconv.FormatUint(uint64(snapshotSession.Port), 10), snapshotSession.User, dbName))
+
+		return errors.Wrap(err, "failed to load the schema of the snapshot")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	diff := diffSchemas(snapshotSchema, cloneSchema)
+	diff.CloneID = cloneID
+	diff.SnapshotID = snapshotID
+	diff.DBName = dbName
+
+	return diff, nil
+}
+
+func loadSchemaCatalog(ctx context.Context, connStr string) (*schemaCatalog, error) {
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = conn.Close(ctx) }()
+
+	catalog := &schemaCatalog{
+		tables:      make(map[schemaObjectKey][]tableColumn),
+		sequences:   make(map[schemaObjectKey]ownedSequence),
+		indexes:     make(map[schemaObjectKey]schemaObject),
+		constraints: make(map[schemaObjectKey]schemaObject),
+		functions:   make(map[schemaObjectKey]schemaObject),
+	}
+
+	var serverVersion int
+
+	if err := conn.QueryRow(ctx, "select current_setting('server_version_num')::int").Scan(&serverVersion); err != nil {
+		return nil, errors.Wrap(err, "failed to get the Postgres version")
+	}
+
+	if err := loadTableColumns(ctx, conn, columnsQuery(serverVersion), catalog.tables); err != nil {
+		return nil, errors.Wrap(err, "failed to load tables")
+	}
+
+	if err := loadOwnedSequences(ctx, conn, catalog.sequences); err != nil {
+		return nil, errors.Wrap(err, "failed to load sequences")
+	}
+
+	if err := loadIndexes(ctx, conn, catalog.indexes); err != nil {
+		return nil, errors.Wrap(err, "failed to load indexes")
+	}
+
+	if err := loadConstraints(ctx, conn, catalog.constraints); err != nil {
+		return nil, errors.Wrap(err, "failed to load constraints")
+	}
+
+	if err := loadFunctions(ctx, conn, catalog.functions); err != nil {
+		return nil, errors.Wrap(err, "failed to load functions")
+	}
+
+	return catalog, nil
+}
+
+// columnsQuery builds the query of table columns for the Postgres version.
+func columnsQuery(serverVersion int) string {
+	identity, generated := "null", "null"
+
+	if serverVersion >= identityColumnsVersion {
+		identity = "a.attidentity"
+	}
+
+	if serverVersion >= generatedColumnsVersion {
+		generated = "a.attgenerated"
+	}
+
+	return fmt.Sprintf(tableColumnsQuery, identity, generated)
+}
+
+func loadTableColumns(ctx context.Context, conn *pgx.Conn, query string, tables map[schemaObjectKey][]tableColumn) error {
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			schema, table        string
+			columnName, dataType *string
+			column               tableColumn
+		)
+
+		if err := rows.Scan(&schema, &table, &columnName, &dataType, &column.notNull, &column.defaultExpr,
+			&column.identity, &column.generated); err != nil {
+			return err
+		}
+
+		key := schemaObjectKey{schema: schema, name: table}
+		columns := tables[key]
+
+		// Tables without columns have a single row with the empty column.
+		if columnName != nil && dataType != nil {
+			column.name, column.dataType = *columnName, *dataType
+			columns = append(columns, column)
+		}
+
+		tables[key] = columns
+	}
+
+	return rows.Err()
+}
+
+func loadOwnedSequences(ctx context.Context, conn *pgx.Conn, sequences map[schemaObjectKey]ownedSequence) error {
+	rows, err := conn.Query(ctx, ownedSequencesQuery)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			key      schemaObjectKey
+			sequence ownedSequence
+		)
+
+		if err := rows.Scan(&sequence.table.schema, &sequence.table.name, &sequence.column, &key.schema, &key.name); err != nil {
+			return err
+		}
+
+		sequences[key] = sequence
+	}
+
+	return rows.Err()
+}
+
+func loadIndexes(ctx context.Context, conn *pgx.Conn, indexes map[schemaObjectKey]schemaObject) error {
+	rows, err := conn.Query(ctx, indexesQuery)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key schemaObjectKey
+
+		index := schemaObject{}
+
+		if err := rows.Scan(&key.schema, &key.table, &key.name, &index.definition); err != nil {
+			return err
+		}
+
+		indexes[key] = index
+	}
+
+	return rows.Err()
+}
+
+func loadConstraints(ctx context.Context, conn *pgx.Conn, constraints map[schemaObjectKey]schemaObject) error {
+	rows, err := conn.Query(ctx, constraintsQuery)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key schemaObjectKey
+
+		constraint := schemaObject{}
+
+		if err := rows.Scan(&key.schema, &key.table, &key.name, &constraint.kind, &constraint.definition); err != nil {
+			return err
+		}
+
+		constraints[key] = constraint
+	}
+
+	return rows.Err()
+}
+
+func loadFunctions(ctx context.Context, conn *pgx.Conn, functions map[schemaObjectKey]schemaObject) error {
+	rows, err := conn.Query(ctx, functionsQuery)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var schema string
+
+		function := schemaObject{kind: "FUNCTION"}
+
+		if err := rows.Scan(&schema, &function.name, &function.arguments, &function.definition); err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(function.definition, "CREATE OR REPLACE PROCEDURE") {
+			function.kind = "PROCEDURE"
+		}
+
+		functions[schemaObjectKey{schema: schema, name: function.name + "(" + function.arguments + ")"}] = function
+	}
+
+	return rows.Err()
+}
+
+// diffSchemas describes changes from the snapshot schema to the clone schema.
+// Statements of the generated DDL are ordered, so objects are dropped before their dependencies and created after them.
+func diffSchemas(from, to *schemaCatalog) *models.SchemaDiff {
+	diff := &models.SchemaDiff{
+		Tables:      []models.SchemaObjectDiff{},
+		Columns:     []models.SchemaObjectDiff{},
+		Indexes:     []models.SchemaObjectDiff{},
+		Constraints: []models.SchemaObjectDiff{},
+		Functions:   []models.SchemaObjectDiff{},
+		DDL:         []string{},
+	}
+
+	var drops, creates []string
+
+	tableChanges := diffSchemaObjects(tableObjects(from.tables), tableObjects(to.tables))
+
+	// Constraints and indexes of dropped tables are dropped with the tables.
+	droppedTables := make(map[schemaObjectKey]bool)
+
+	for _, change := range tableChanges {
+		if change.change == models.RelationRemoved {
+			droppedTables[change.key] = true
+		}
+	}
+
+	constraintChanges := diffSchemaObjects(from.constraints, to.constraints)
+
+	// Foreign keys are created after and dropped before other constraints they may depend on.
+	// Foreign keys of dropped tables are dropped as well, so tables referencing each other can be dropped.
+	sort.SliceStable(constraintChanges, func(i, j int) bool {
+		return !constraintChanges[i].isForeignKey() && constraintChanges[j].isForeignKey()
+	})
+
+	for i := len(constraintChanges) - 1; i >= 0; i-- {
+		change := constraintChanges[i]
+
+		if change.change != models.RelationCreated && (!droppedTables[change.tableKey()] || change.isForeignKey()) {
+			drops = append(drops, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;",
+				tableIdentifier(change.key), pgx.Identifier{change.key.name}.Sanitize()))
+		}
+	}
+
+	var addConstraints []string
+
+	for _, change := range constraintChanges {
+		diff.Constraints = append(diff.Constraints, schemaObjectDiff(change))
+
+		if change.change != models.RelationRemoved {
+			addConstraints = append(addConstraints, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s;",
+				tableIdentifier(change.key), pgx.Identifier{change.key.name}.Sanitize(), change.to.definition))
+		}
+	}
+
+	var createIndexes []string
+
+	for _, change := range diffSchemaObjects(from.indexes, to.indexes) {
+		diff.Indexes = append(diff.Indexes, schemaObjectDiff(change))
+
+		if change.change != models.RelationCreated && !droppedTables[change.tableKey()] {
+			drops = append(drops, fmt.Sprintf("DROP INDEX %s;", pgx.Identifier{change.key.schema, change.key.name}.Sanitize()))
+		}
+
+		if change.change != models.RelationRemoved {
+			createIndexes = append(createIndexes, change.to.definition+";")
+		}
+	}
+
+	for _, change := range tableChanges {
+		diff.Tables = append(diff.Tables, schemaObjectDiff(change))
+
+		table := pgx.Identifier{change.key.schema, change.key.name}.Sanitize()
+
+		if change.change == models.RelationRemoved {
+			drops = append(drops, fmt.Sprintf("DROP TABLE %s;", table))
+			continue
+		}
+
+		creates = append(creates, createTableStatement(table, to.tables[change.key]))
+	}
+
+	var alterColumns []string
+
+	for _, table := range commonTables(from.tables, to.tables) {
+		columnDiffs, columnDrops, columnAlters := diffColumns(table, from.tables[table], to.tables[table])
+		diff.Columns = append(diff.Columns, columnDiffs...)
+		drops = append(drops, columnDrops...)
+		alterColumns = append(alterColumns, columnAlters...)
+	}
+
+	// Sequences of serial columns are created before their tables and owned by the columns once the columns exist.
+	var createSequences, ownSequences []string
+
+	for _, change := range diffSchemaObjects(sequenceObjects(from.sequences), sequenceObjects(to.sequences)) {
+		if change.change == models.RelationRemoved {
+			continue
+		}
+
+		sequence := pgx.Identifier{change.key.schema, change.key.name}.Sanitize()
+		owner := to.sequences[change.key]
+
+		createSequences = append(createSequences, fmt.Sprintf("CREATE SEQUENCE IF NOT EXISTS %s;", sequence))
+		ownSequences = append(ownSequences, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s;",
+			sequence, pgx.Identifier{owner.table.schema, owner.table.name, owner.column}.Sanitize()))
+	}
+
+	var createFunctions []string
+
+	for _, change := range diffSchemaObjects(from.functions, to.functions) {
+		diff.Functions = append(diff.Functions, schemaObjectDiff(change))
+
+		switch change.change {
+		case models.RelationRemoved:
+			drops = append(drops, fmt.Sprintf("DROP %s %s(%s);",
+				change.from.kind, pgx.Identifier{change.key.schema, change.from.name}.Sanitize(), change.from.arguments))
+
+		default:
+			createFunctions = append(createFunctions, strings.TrimSpace(change.to.definition)+";")
+		}
+	}
+
+	// Functions and sequences are created first, so they can be used in defaults of columns and definitions of constraints and indexes.
+	creates = append(createSequences, creates...)
+	creates = append(createFunctions, creates...)
+	creates = append(creates, alterColumns...)
+	creates = append(creates, ownSequences...)
+	creates = append(creates, addConstraints...)
+	creates = append(creates, createIndexes...)
+
+	diff.DDL = append(diff.DDL, drops...)
+	diff.DDL = append(diff.DDL, creates...)
+
+	return diff
+}
+
+// tableObjects represents tables as schema objects without definitions, so only created and removed tables are reported.
+func tableObjects(tables map[schemaObjectKey][]tableColumn) map[schemaObjectKey]schemaObject {
+	objects := make(map[schemaObjectKey]schemaObject, len(tables))
+
+	for key := range tables {
+		objects[key] = schemaObject{}
+	}
+
+	return objects
+}
+
+// sequenceObjects represents owned sequences as schema objects defined by their columns, so changed owners are reported.
+func sequenceObjects(sequences map[schemaObjectKey]ownedSequence) map[schemaObjectKey]schemaObject {
+	objects := make(map[schemaObjectKey]schemaObject, len(sequences))
+
+	for key, sequence := range sequences {
+		objects[key] = schemaObject{definition: sequence.table.schema + "." + sequence.table.name + "." + sequence.column}
+	}
+
+	return objects
+}
+
+// commonTables lists tables existing in both schemas ordered by their keys.
+func commonTables(from, to map[schemaObjectKey][]tableColumn) []schemaObjectKey {
+	changes := []schemaObjectChange{}
+
+	for key := range to {
+		if _, ok := from[key]; ok {
+			changes = append(changes, schemaObjectChange{key: key})
+		}
+	}
+
+	sortSchemaObjectChanges(changes)
+
+	tables := make([]schemaObjectKey, 0, len(changes))
+
+	for _, change := range changes {
+		tables = append(tables, change.key)
+	}
+
+	return tables
+}
+
+// diffSchemaObjects reports created, removed, and modified objects ordered by their keys.
+func diffSchemaObjects(from, to map[schemaObjectKey]schemaObject) []schemaObjectChange {
+	changes := []schemaObjectChange{}
+
+	for key, fromObject := range from {
+		toObject, ok := to[key]
+
+		switch {
+		case !ok:
+			changes = append(changes, schemaObjectChange{key: key, change: models.RelationRemoved, from: fromObject})
+
+		case fromObject.definition != toObject.definition:
+			changes = append(changes, schemaObjectChange{key: key, change: models.RelationModified, from: fromObject, to: toObject})
+		}
+	}
+
+	for key, toObject := range to {
+		if _, ok := from[key]; !ok {
+			changes = append(changes, schemaObjectChange{key: key, change: models.RelationCreated, to: toObject})
+		}
+	}
+
+	sortSchemaObjectChanges(changes)
+
+	return changes
+}
+
+func sortSchemaObjectChanges(changes []schemaObjectChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].key.schema != changes[j].key.schema {
+			return changes[i].key.schema < changes[j].key.schema
+		}
+
+		if changes[i].key.table != changes[j].key.table {
+			return changes[i].key.table < changes[j].key.table
+		}
+
+		return changes[i].key.name < changes[j].key.name
+	})
+}
+
+// diffColumns compares columns of a table existing in both schemas.
+func diffColumns(table schemaObjectKey, from, to []tableColumn) ([]models.SchemaObjectDiff, []string, []string) {
+	var (
+		diffs  []models.SchemaObjectDiff
+		drops  []string
+		alters []string
+	)
+
+	tableName := pgx.Identifier{table.schema, table.name}.Sanitize()
+	fromColumns := make(map[string]tableColumn, len(from))
+	toColumns := make(map[string]tableColumn, len(to))
+
+	for _, column := range from {
+		fromColumns[column.name] = column
+	}
+
+	for _, column := range to {
+		toColumns[column.name] = column
+	}
+
+	for _, fromColumn := range from {
+		if _, ok := toColumns[fromColumn.name]; ok {
+			continue
+		}
+
+		diffs = append(diffs, columnDiff(table, fromColumn.name, models.RelationRemoved, columnDefinition(fromColumn), ""))
+		drops = append(drops, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", tableName, pgx.Identifier{fromColumn.name}.Sanitize()))
+	}
+
+	for _, toColumn := range to {
+		columnName := pgx.Identifier{toColumn.name}.Sanitize()
+
+		fromColumn, ok := fromColumns[toColumn.name]
+		if !ok {
+			diffs = append(diffs, columnDiff(table, toColumn.name, models.RelationCreated, "", columnDefinition(toColumn)))
+			alters = append(alters, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", tableName, columnName, columnDefinition(toColumn)))
+
+			continue
+		}
+
+		if fromColumn == toColumn {
+			continue
+		}
+
+		diffs = append(diffs, columnDiff(table, toColumn.name, models.RelationModified,
+			columnDefinition(fromColumn), columnDefinition(toColumn)))
+
+		// Generation expressions cannot be changed, so generated columns are added again.
+		if toColumn.generated != "" && (fromColumn.generated != toColumn.generated || fromColumn.defaultExpr != toColumn.defaultExpr) {
+			drops = append(drops, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", tableName, columnName))
+			alters = append(alters, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", tableName, columnName, columnDefinition(toColumn)))
+
+			continue
+		}
+
+		alterColumn := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s ", tableName, columnName)
+
+		if fromColumn.generated != "" {
+			alters = append(alters, alterColumn+"DROP EXPRESSION;")
+		}
+
+		if fromColumn.identity != "" && toColumn.identity == "" {
+			alters = append(alters, alterColumn+"DROP IDENTITY;")
+		}
+
+		if fromColumn.dataType != toColumn.dataType {
+			alters = append(alters, alterColumn+"TYPE "+toColumn.dataType+";")
+		}
+
+		if fromDefault, toDefault := columnDefault(fromColumn), columnDefault(toColumn); fromDefault != toDefault {
+			if toDefault == "" {
+				alters = append(alters, alterColumn+"DROP DEFAULT;")
+			} else {
+				alters = append(alters, alterColumn+"SET DEFAULT "+toDefault+";")
+			}
+		}
+
+		if fromColumn.notNull != toColumn.notNull {
+			if toColumn.notNull {
+				alters = append(alters, alterColumn+"SET NOT NULL;")
+			} else {
+				alters = append(alters, alterColumn+"DROP NOT NULL;")
+			}
+		}
+
+		if toColumn.identity != "" && toColumn.identity != fromColumn.identity {
+			if fromColumn.identity == "" {
+				alters = append(alters, alterColumn+"ADD "+identityClause(toColumn.identity)+";")
+			} else {
+				alters = append(alters, alterColumn+"SET "+strings.TrimSuffix(identityClause(toColumn.identity), " AS IDENTITY")+";")
+			}
+		}
+	}
+
+	return diffs, drops, alters
+}
+
+func columnDiff(table schemaObjectKey, name, change, from, to string) models.SchemaObjectDiff {
+	return models.SchemaObjectDiff{
+		Change: change,
+		Schema: table.schema,
+		Table:  table.name,
+		Name:   name,
+		From:   from,
+		To:     to,
+	}
+}
+
+func columnDefinition(column tableColumn) string {
+	definition := column.dataType
+
+	if column.notNull {
+		definition += " NOT NULL"
+	}
+
+	switch {
+	case column.generated == generatedStored:
+		definition += " GENERATED ALWAYS AS (" + column.defaultExpr + ") STORED"
+
+	case column.identity != "":
+		definition += " " + identityClause(column.identity)
+
+	case column.defaultExpr != "":
+		definition += " DEFAULT " + column.defaultExpr
+	}
+
+	return definition
+}
+
+// columnDefault returns the default expression of the column. Generated columns have no defaults.
+func columnDefault(column tableColumn) string {
+	if column.generated != "" {
+		return ""
+	}
+
+	return column.defaultExpr
+}
+
+func identityClause(identity string) string {
+	if identity == identityByDefault {
+		return "GENERATED BY DEFAULT AS IDENTITY"
+	}
+
+	return "GENERATED ALWAYS AS IDENTITY"
+}
+
+func createTableStatement(table string, columns []tableColumn) string {
+	columnDefinitions := make([]string, 0, len(columns))
+
+	for _, column := range columns {
+		columnDefinitions = append(columnDefinitions, "    "+pgx.Identifier{column.name}.Sanitize()+" "+columnDefinition(column))
+	}
+
+	if len(columnDefinitions) == 0 {
+		return fmt.Sprintf("CREATE TABLE %s ();", table)
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n);", table, strings.Join(columnDefinitions, ",\n"))
+}
+
+func schemaObjectDiff(change schemaObjectChange) models.SchemaObjectDiff {
+	return models.SchemaObjectDiff{
+		Change: change.change,
+		Schema: change.key.schema,
+		Table:  change.key.table,
+		Name:   change.key.name,
+		From:   change.from.definition,
+		To:     change.to.definition,
+	}
+}
+
+// tableIdentifier returns the qualified name of the table of a constraint or an index.
+func tableIdentifier(key schemaObjectKey) string {
+	return pgx.Identifier{key.schema, key.table}.Sanitize()
+}
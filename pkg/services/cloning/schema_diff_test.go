@@ -0,0 +1,243 @@
+package cloning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+)
+
+func TestDiffSchemas(t *testing.T) {
+	users := schemaObjectKey{schema: "public", name: "users"}
+	orders := schemaObjectKey{schema: "public", name: "orders"}
+	logs := schemaObjectKey{schema: "public", name: "logs"}
+
+	from := &schemaCatalog{
+		tables: map[schemaObjectKey][]tableColumn{
+			users: {
+				{name: "id", dataType: "bigint", notNull: true},
+				{name: "name", dataType: "character varying(100)"},
+				{name: "legacy", dataType: "text"},
+			},
+			logs: {{name: "message", dataType: "text"}},
+		},
+		indexes: map[schemaObjectKey]schemaObject{
+			{schema: "public", table: "users", name: "users_name_idx"}: {
+				definition: "CREATE INDEX users_name_idx ON public.users USING btree (name)",
+			},
+			{schema: "public", table: "logs", name: "logs_message_idx"}: {
+				definition: "CREATE INDEX logs_message_idx ON public.logs USING btree (message)",
+			},
+		},
+		constraints: map[schemaObjectKey]schemaObject{
+			{schema: "public", table: "users", name: "users_pkey"}: {kind: "p", definition: "PRIMARY KEY (id)"},
+		},
+		functions: map[schemaObjectKey]schemaObject{
+			{schema: "public", name: "cleanup(integer)"}: {
+				kind:       "PROCEDURE",
+				definition: "CREATE OR REPLACE PROCEDURE public.cleanup(days integer)\n LANGUAGE sql\nAS $procedure$ select 1 $procedure$\n",
+				name:       "cleanup",
+				arguments:  "days integer",
+			},
+		},
+	}
+
+	to := &schemaCatalog{
+		tables: map[schemaObjectKey][]tableColumn{
+			users: {
+				{name: "id", dataType: "bigint", notNull: true},
+				{name: "name", dataType: "text", notNull: true, defaultExpr: "''::text"},
+				{name: "email", dataType: "text"},
+			},
+			orders: {
+				{name: "id", dataType: "bigint", notNull: true},
+				{name: "user_id", dataType: "bigint"},
+			},
+		},
+		indexes: map[schemaObjectKey]schemaObject{
+			{schema: "public", table: "users", name: "users_name_idx"}: {
+				definition: "CREATE INDEX users_name_idx ON public.users USING btree (lower(name))",
+			},
+			{schema: "public", table: "orders", name: "orders_user_id_idx"}: {
+				definition: "CREATE INDEX orders_user_id_idx ON public.orders USING btree (user_id)",
+			},
+		},
+		constraints: map[schemaObjectKey]schemaObject{
+			{schema: "public", table: "users", name: "users_pkey"}: {kind: "p", definition: "PRIMARY KEY (id)"},
+			{schema: "public", table: "orders", name: "orders_user_id_fkey"}: {
+				kind:       "f",
+				definition: "FOREIGN KEY (user_id) REFERENCES public.users(id)",
+			},
+			{schema: "public", table: "orders", name: "orders_pkey"}: {kind: "p", definition: "PRIMARY KEY (id)"},
+		},
+		functions: map[schemaObjectKey]schemaObject{},
+	}
+
+	diff := diffSchemas(from, to)
+
+	assert.Equal(t, []models.SchemaObjectDiff{
+		{Change: models.RelationRemoved, Schema: "public", Name: "logs"},
+		{Change: models.RelationCreated, Schema: "public", Name: "orders"},
+	}, diff.Tables)
+
+	assert.Equal(t, []models.SchemaObjectDiff{
+		{Change: models.RelationRemoved, Schema: "public", Table: "users", Name: "legacy", From: "text"},
+		{Change: models.RelationModified, Schema: "public", Table: "users", Name: "name",
+			From: "character varying(100)", To: "text NOT NULL DEFAULT ''::text"},
+		{Change: models.RelationCreated, Schema: "public", Table: "users", Name: "email", To: "text"},
+	}, diff.Columns)
+
+	assert.Len(t, diff.Indexes, 3)
+	assert.Len(t, diff.Constraints, 2)
+	assert.Equal(t, []models.SchemaObjectDiff{{
+		Change: models.RelationRemoved,
+		Schema: "public",
+		Name:   "cleanup(integer)",
+		From:   from.functions[schemaObjectKey{schema: "public", name: "cleanup(integer)"}].definition,
+	}}, diff.Functions)
+
+	assert.Equal(t, []string{
+		`DROP INDEX "public"."users_name_idx";`,
+		`DROP TABLE "public"."logs";`,
+		`ALTER TABLE "public"."users" DROP COLUMN "legacy";`,
+		`DROP PROCEDURE "public"."cleanup"(days integer);`,
+		"CREATE TABLE \"public\".\"orders\" (\n    \"id\" bigint NOT NULL,\n    \"user_id\" bigint\n);",
+		`ALTER TABLE "public"."users" ALTER COLUMN "name" TYPE text;`,
+		`ALTER TABLE "public"."users" ALTER COLUMN "name" SET DEFAULT ''::text;`,
+		`ALTER TABLE "public"."users" ALTER COLUMN "name" SET NOT NULL;`,
+		`ALTER TABLE "public"."users" ADD COLUMN "email" text;`,
+		`ALTER TABLE "public"."orders" ADD CONSTRAINT "orders_pkey" PRIMARY KEY (id);`,
+		`ALTER TABLE "public"."orders" ADD CONSTRAINT "orders_user_id_fkey" FOREIGN KEY (user_id) REFERENCES public.users(id);`,
+		"CREATE INDEX orders_user_id_idx ON public.orders USING btree (user_id);",
+		"CREATE INDEX users_name_idx ON public.users USING btree (lower(name));",
+	}, diff.DDL)
+}
+
+func TestDiffSchemasNoChanges(t *testing.T) {
+	catalog := &schemaCatalog{
+		tables:    map[schemaObjectKey][]tableColumn{{schema: "public", name: "empty"}: nil},
+		functions: map[schemaObjectKey]schemaObject{},
+	}
+
+	diff := diffSchemas(catalog, catalog)
+
+	assert.Empty(t, diff.Tables)
+	assert.Empty(t, diff.Columns)
+	assert.Empty(t, diff.DDL)
+	assert.NotNil(t, diff.DDL)
+}
+
+func TestDiffSchemasGeneratedColumns(t *testing.T) {
+	items := schemaObjectKey{schema: "public", name: "items"}
+	events := schemaObjectKey{schema: "public", name: "events"}
+
+	from := &schemaCatalog{
+		tables: map[schemaObjectKey][]tableColumn{
+			items: {
+				{name: "id", dataType: "integer", notNull: true},
+				{name: "price", dataType: "numeric"},
+				{name: "total", dataType: "numeric", defaultExpr: "(price * 2)", generated: "s"},
+				{name: "code", dataType: "integer", notNull: true, identity: "a"},
+			},
+		},
+		sequences: map[schemaObjectKey]ownedSequence{},
+		functions: map[schemaObjectKey]schemaObject{},
+	}
+
+	to := &schemaCatalog{
+		tables: map[schemaObjectKey][]tableColumn{
+			items: {
+				{name: "id", dataType: "integer", notNull: true, identity: "d"},
+				{name: "price", dataType: "numeric"},
+				{name: "total", dataType: "numeric", defaultExpr: "(price * 3)", generated: "s"},
+				{name: "code", dataType: "integer", notNull: true, identity: "d"},
+			},
+			events: {
+				{name: "id", dataType: "integer", notNull: true, defaultExpr: "nextval('events_id_seq'::regclass)"},
+				{name: "ts", dataType: "bigint", notNull: true, identity: "a"},
+			},
+		},
+		sequences: map[schemaObjectKey]ownedSequence{
+			{schema: "public", name: "events_id_seq"}: {table: events, column: "id"},
+		},
+		functions: map[schemaObjectKey]schemaObject{},
+	}
+
+	diff := diffSchemas(from, to)
+
+	assert.Equal(t, []string{
+		`ALTER TABLE "public"."items" DROP COLUMN "total";`,
+		`CREATE SEQUENCE IF NOT EXISTS "public"."events_id_seq";`,
+		"CREATE TABLE \"public\".\"events\" (\n" +
+			"    \"id\" integer NOT NULL DEFAULT nextval('events_id_seq'::regclass),\n" +
+			"    \"ts\" bigint NOT NULL GENERATED ALWAYS AS IDENTITY\n);",
+		`ALTER TABLE "public"."items" ALTER COLUMN "id" ADD GENERATED BY DEFAULT AS IDENTITY;`,
+		`ALTER TABLE "public"."items" ADD COLUMN "total" numeric GENERATED ALWAYS AS ((price * 3)) STORED;`,
+		`ALTER TABLE "public"."items" ALTER COLUMN "code" SET GENERATED BY DEFAULT;`,
+		`ALTER SEQUENCE "public"."events_id_seq" OWNED BY "public"."events"."id";`,
+	}, diff.DDL)
+
+	// Generated columns become regular columns keeping their data.
+	diff = diffSchemas(to, &schemaCatalog{
+		tables: map[schemaObjectKey][]tableColumn{
+			items: {
+				{name: "id", dataType: "integer", notNull: true},
+				{name: "price", dataType: "numeric"},
+				{name: "total", dataType: "numeric"},
+				{name: "code", dataType: "integer", notNull: true, identity: "d"},
+			},
+			events: to.tables[events],
+		},
+		sequences: to.sequences,
+		functions: map[schemaObjectKey]schemaObject{},
+	})
+
+	assert.Equal(t, []string{
+		`ALTER TABLE "public"."items" ALTER COLUMN "id" DROP IDENTITY;`,
+		`ALTER TABLE "public"."items" ALTER COLUMN "total" DROP EXPRESSION;`,
+	}, diff.DDL)
+}
+
+func TestDiffSchemasDropsReferencingTables(t *testing.T) {
+	parents := schemaObjectKey{schema: "public", name: "parents"}
+	children := schemaObjectKey{schema: "public", name: "children"}
+
+	from := &schemaCatalog{
+		tables: map[schemaObjectKey][]tableColumn{
+			parents:  {{name: "id", dataType: "integer", notNull: true}, {name: "child_id", dataType: "integer"}},
+			children: {{name: "id", dataType: "integer", notNull: true}, {name: "parent_id", dataType: "integer"}},
+		},
+		constraints: map[schemaObjectKey]schemaObject{
+			{schema: "public", table: "parents", name: "parents_pkey"}:   {kind: "p", definition: "PRIMARY KEY (id)"},
+			{schema: "public", table: "children", name: "children_pkey"}: {kind: "p", definition: "PRIMARY KEY (id)"},
+			{schema: "public", table: "parents", name: "parents_child_id_fkey"}: {
+				kind:       "f",
+				definition: "FOREIGN KEY (child_id) REFERENCES public.children(id)",
+			},
+			{schema: "public", table: "children", name: "children_parent_id_fkey"}: {
+				kind:       "f",
+				definition: "FOREIGN KEY (parent_id) REFERENCES public.parents(id)",
+			},
+		},
+		functions: map[schemaObjectKey]schemaObject{},
+	}
+
+	to := &schemaCatalog{
+		tables:    map[schemaObjectKey][]tableColumn{},
+		functions: map[schemaObjectKey]schemaObject{},
+	}
+
+	assert.Equal(t, []string{
+		`ALTER TABLE "public"."parents" DROP CONSTRAINT "parents_child_id_fkey";`,
+		`ALTER TABLE "public"."children" DROP CONSTRAINT "children_parent_id_fkey";`,
+		`DROP TABLE "public"."children";`,
+		`DROP TABLE "public"."parents";`,
+	}, diffSchemas(from, to).DDL)
+}
+
+func TestColumnsQuery(t *testing.T) {
+	assert.Contains(t, columnsQuery(90600), "coalesce(null::text, ''), coalesce(null::text, '')")
+	assert.Contains(t, columnsQuery(110000), "coalesce(a.attidentity::text, ''), coalesce(null::text, '')")
+	assert.Contains(t, columnsQuery(130000), "coalesce(a.attidentity::text, ''), coalesce(a.attgenerated::text, '')")
+}
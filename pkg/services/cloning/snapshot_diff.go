@@ -0,0 +1,374 @@
+/*
+2021 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+)
+
+const (
+	diffUserPrefix = "dblab_diff_"
+
+	// diffSessionTimeout bounds a temporary diff session including its start.
+	diffSessionTimeout = 10 * time.Minute
+)
+
+// relationFileRegexp matches relation files: main fork segments, free space maps, visibility maps and init forks.
+var relationFileRegexp = regexp.MustCompile(`^(?:base/(\d+)|global)/(\d+)(_(?:fsm|vm|init))?(\.\d+)?$`)
+
+// relationKinds maps pg_class.relkind to kinds of relations.
+var relationKinds = map[string]string{
+	"r": "table",
+	"i": "index",
+	"S": "sequence",
+	"t": "toast table",
+	"m": "materialized view",
+}
+
+type relationKey struct {
+	databaseOID uint32
+	filenode    uint32
+}
+
+type relationInfo struct {
+	database string
+	schema   string
+	name     string
+	kind     string
+}
+
+type relationChange struct {
+	change   string
+	fromSize int64
+	toSize   int64
+}
+
+// DiffSnapshots reports relations changed between two snapshots.
+// Relation files are mapped to relations by the catalog of the newer snapshot, so a temporary session is started on it.
+// The reported snapshots are the compared ones, they differ from the requested ones if the origins of their datasets are compared.
+func (c *Base) DiffSnapshots(ctx context.Context, fromSnapshotID, toSnapshotID string) (*models.SnapshotDiff, error) {
+	if err := c.fetchSnapshots(); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch snapshots")
+	}
+
+	for _, snapshotID := range []string{fromSnapshotID, toSnapshotID} {
+		if _, err := c.getSnapshotByID(snapshotID); err != nil {
+			return nil, models.New(models.ErrCodeNotFound, fmt.Sprintf("snapshot %q not found", snapshotID))
+		}
+	}
+
+	filesDiff, err := c.provision.DiffSnapshots(fromSnapshotID, toSnapshotID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get changed files")
+	}
+
+	changes := aggregateRelationChanges(filesDiff.Files)
+
+	diff := &models.SnapshotDiff{
+		From:      filesDiff.From,
+		To:        filesDiff.To,
+		Relations: []models.RelationDiff{},
+	}
+
+	if len(changes) == 0 {
+		return diff, nil
+	}
+
+	catalog, err := c.loadRelationCatalog(ctx, filesDiff.To)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the relation catalog")
+	}
+
+	diff.Relations = buildRelationDiffs(changes, catalog)
+
+	for _, relation := range diff.Relations {
+		diff.SizeDelta += relation.SizeDelta
+	}
+
+	return diff, nil
+}
+
+// aggregateRelationChanges sums sizes of changed files by relations.
+// A relation is created or removed if the first segment of its main fork is.
+func aggregateRelationChanges(files []resources.FileDiff) map[relationKey]*relationChange {
+	changes := make(map[relationKey]*relationChange)
+
+	for _, file := range files {
+		key, isMainFile, ok := parseRelationPath(file.Path)
+		if !ok {
+			continue
+		}
+
+		change, ok := changes[key]
+		if !ok {
+			change = &relationChange{change: models.RelationModified}
+			changes[key] = change
+		}
+
+		change.fromSize += file.FromSize
+		change.toSize += file.ToSize
+
+		if isMainFile && file.Change != resources.FileModified {
+			change.change = file.Change
+		}
+	}
+
+	return changes
+}
+
+// parseRelationPath extracts the database OID and filenode from the path of a relation file.
+// Shared relations belong to the database with zero OID.
+func parseRelationPath(filePath string) (key relationKey, isMainFile bool, ok bool) {
+	matches := relationFileRegexp.FindStringSubmatch(filePath)
+	if matches == nil {
+		return relationKey{}, false, false
+	}
+
+	if matches[1] != "" {
+		databaseOID, err := strconv.ParseUint(matches[1], 10, 32)
+		if err != nil {
+			return relationKey{}, false, false
+		}
+
+		key.databaseOID = uint32(databaseOID)
+	}
+
+	filenode, err := strconv.ParseUint(matches[2], 10, 32)
+	if err != nil {
+		return relationKey{}, false, false
+	}
+
+	key.filenode = uint32(filenode)
+
+	return key, matches[3] == "" && matches[4] == "", true
+}
+
+// buildRelationDiffs describes relation changes ordered by the absolute size delta.
+func buildRelationDiffs(changes map[relationKey]*relationChange, catalog map[relationKey]relationInfo) []models.RelationDiff {
+	relations := make([]models.RelationDiff, 0, len(changes))
+
+	for key, change := range changes {
+		info := catalog[key]
+
+		relations = append(relations, models.RelationDiff{
+			Database:    info.database,
+			DatabaseOID: key.databaseOID,
+			Filenode:    key.filenode,
+			Schema:      info.schema,
+			Name:        info.name,
+			Kind:        info.kind,
+			Change:      change.change,
+			FromSize:    change.fromSize,
+			ToSize:      change.toSize,
+			SizeDelta:   change.toSize - change.fromSize,
+		})
+	}
+
+	sort.Slice(relations, func(i, j int) bool {
+		deltaI, deltaJ := abs(relations[i].SizeDelta), abs(relations[j].SizeDelta)
+		if deltaI != deltaJ {
+			return deltaI > deltaJ
+		}
+
+		if relations[i].DatabaseOID != relations[j].DatabaseOID {
+			return relations[i].DatabaseOID < relations[j].DatabaseOID
+		}
+
+		return relations[i].Filenode < relations[j].Filenode
+	})
+
+	return relations
+}
+
+func abs(value int64) int64 {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
+
+// loadRelationCatalog maps filenodes of the snapshot to relations of all databases allowing connections.
+func (c *Base) loadRelationCatalog(ctx context.Context, snapshotID string) (map[relationKey]relationInfo, error) {
+	catalog := make(map[relationKey]relationInfo)
+
+	err := c.withDiffSession(ctx, snapshotID, func(ctx context.Context, session *resources.Session) error {
+		port := strconv.FormatUint(uint64(session.Port), 10)
+
+		databases, err := listDatabases(ctx, connectionString(session.SocketHost, port, session.User, defaultDatabaseName))
+		if err != nil {
+			return errors.Wrap(err, "failed to list databases")
+		}
+
+		for databaseOID, databaseName := range databases {
+			connStr := connectionString(session.SocketHost, port, session.User, databaseName)
+
+			if err := loadDatabaseRelations(ctx, connStr, databaseOID, databaseName, catalog); err != nil {
+				return errors.Wrapf(err, "failed to load relations of the database %q", databaseName)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return catalog, nil
+}
+
+type diffSessionStart struct {
+	session *resources.Session
+	err     error
+}
+
+// withDiffSession runs the function with a temporary session on the snapshot bounded by diffSessionTimeout.
+// The start of a session cannot be interrupted, so a session started after the timeout is stopped in the background.
+func (c *Base) withDiffSession(ctx context.Context, snapshotID string, fn func(context.Context, *resources.Session) error) error {
+	ctx, cancel := context.WithTimeout(ctx, diffSessionTimeout)
+	defer cancel()
+
+	started := make(chan diffSessionStart, 1)
+
+	go func() {
+		session, err := c.startDiffSession(snapshotID)
+		started <- diffSessionStart{session: session, err: err}
+	}()
+
+	var start diffSessionStart
+
+	select {
+	case <-ctx.Done():
+		go func() {
+			if start := <-started; start.err == nil {
+				c.stopDiffSession(start.session)
+			}
+		}()
+
+		return errors.Wrap(ctx.Err(), "failed to start a session")
+
+	case start = <-started:
+	}
+
+	if start.err != nil {
+		return errors.Wrap(start.err, "failed to start a session")
+	}
+
+	defer c.stopDiffSession(start.session)
+
+	return fn(ctx, start.session)
+}
+
+// startDiffSession starts a temporary session on the snapshot to read its catalog. The caller must stop the session.
+func (c *Base) startDiffSession(snapshotID string) (*resources.Session, error) {
+	user := resources.EphemeralUser{
+		Name:     diffUserPrefix + xid.New().String(),
+		Password: xid.New().String(),
+	}
+
+	return c.provision.StartSession(snapshotID, user, nil, nil, c.config.AccessHost)
+}
+
+func (c *Base) stopDiffSession(session *resources.Session) {
+	if err := c.provision.StopSession(session); err != nil {
+		log.Err("Failed to stop the diff session: ", err)
+	}
+}
+
+func listDatabases(ctx context.Context, connStr string) (map[uint32]string, error) {
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = conn.Close(ctx) }()
+
+	rows, err := conn.Query(ctx, "select oid, datname from pg_catalog.pg_database where datallowconn")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	databases := make(map[uint32]string)
+
+	for rows.Next() {
+		var (
+			oid  uint32
+			name string
+		)
+
+		if err := rows.Scan(&oid, &name); err != nil {
+			return nil, err
+		}
+
+		databases[oid] = name
+	}
+
+	return databases, rows.Err()
+}
+
+func loadDatabaseRelations(ctx context.Context, connStr string, databaseOID uint32, databaseName string,
+	catalog map[relationKey]relationInfo) error {
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = conn.Close(ctx) }()
+
+	rows, err := conn.Query(ctx, `select pg_catalog.pg_relation_filenode(c.oid), c.relisshared, n.nspname, c.relname, c.relkind::text
+		from pg_catalog.pg_class c
+		join pg_catalog.pg_namespace n on n.oid = c.relnamespace
+		where pg_catalog.pg_relation_filenode(c.oid) is not null`)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			filenode     uint32
+			isShared     bool
+			schema, name string
+			kind         string
+		)
+
+		if err := rows.Scan(&filenode, &isShared, &schema, &name, &kind); err != nil {
+			return err
+		}
+
+		info := relationInfo{database: databaseName, schema: schema, name: name, kind: kind}
+
+		if relationKind, ok := relationKinds[kind]; ok {
+			info.kind = relationKind
+		}
+
+		key := relationKey{databaseOID: databaseOID, filenode: filenode}
+
+		if isShared {
+			info.database = ""
+			key.databaseOID = 0
+		}
+
+		catalog[key] = info
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,70 @@
+package cloning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+)
+
+func TestParseRelationPath(t *testing.T) {
+	testCases := []struct {
+		path       string
+		key        relationKey
+		isMainFile bool
+		ok         bool
+	}{
+		{path: "base/16384/16385", key: relationKey{databaseOID: 16384, filenode: 16385}, isMainFile: true, ok: true},
+		{path: "base/16384/16385.2", key: relationKey{databaseOID: 16384, filenode: 16385}, ok: true},
+		{path: "base/16384/16385_fsm", key: relationKey{databaseOID: 16384, filenode: 16385}, ok: true},
+		{path: "base/16384/16385_vm.1", key: relationKey{databaseOID: 16384, filenode: 16385}, ok: true},
+		{path: "global/1262", key: relationKey{filenode: 1262}, isMainFile: true, ok: true},
+		{path: "global/pg_control"},
+		{path: "base/16384/pg_internal.init"},
+		{path: "pg_wal/000000010000000000000001"},
+		{path: "base/16384/99999999999"},
+	}
+
+	for _, tc := range testCases {
+		key, isMainFile, ok := parseRelationPath(tc.path)
+
+		assert.Equal(t, tc.ok, ok, tc.path)
+		assert.Equal(t, tc.key, key, tc.path)
+		assert.Equal(t, tc.isMainFile, isMainFile, tc.path)
+	}
+}
+
+func TestRelationDiffs(t *testing.T) {
+	files := []resources.FileDiff{
+		{Path: "base/16384/16385", Change: resources.FileModified, FromSize: 8192, ToSize: 16384},
+		{Path: "base/16384/16385_fsm", Change: resources.FileCreated, ToSize: 24576},
+		{Path: "base/16384/16390", Change: resources.FileCreated, ToSize: 0},
+		{Path: "base/16384/16391", Change: resources.FileRemoved, FromSize: 1 << 20},
+		{Path: "base/16384/16391.1", Change: resources.FileRemoved, FromSize: 1 << 20},
+		{Path: "global/1262", Change: resources.FileModified, FromSize: 8192, ToSize: 8192},
+		{Path: "postmaster.opts", Change: resources.FileModified},
+	}
+
+	catalog := map[relationKey]relationInfo{
+		{databaseOID: 16384, filenode: 16385}: {database: "test", schema: "public", name: "orders", kind: "table"},
+		{databaseOID: 16384, filenode: 16390}: {database: "test", schema: "public", name: "orders_pkey", kind: "index"},
+		{filenode: 1262}:                      {schema: "pg_catalog", name: "pg_database", kind: "table"},
+	}
+
+	expected := []models.RelationDiff{
+		{DatabaseOID: 16384, Filenode: 16391, Change: models.RelationRemoved, FromSize: 2 << 20, SizeDelta: -2 << 20},
+		{
+			Database: "test", DatabaseOID: 16384, Filenode: 16385, Schema: "public", Name: "orders", Kind: "table",
+			Change: models.RelationModified, FromSize: 8192, ToSize: 40960, SizeDelta: 32768,
+		},
+		{Filenode: 1262, Schema: "pg_catalog", Name: "pg_database", Kind: "table", Change: models.RelationModified, FromSize: 8192, ToSize: 8192},
+		{
+			Database: "test", DatabaseOID: 16384, Filenode: 16390, Schema: "public", Name: "orders_pkey", Kind: "index",
+			Change: models.RelationCreated,
+		},
+	}
+
+	assert.Equal(t, expected, buildRelationDiffs(aggregateRelationChanges(files), catalog))
+}
@@ -17,13 +17,20 @@ type CloneWrapper struct {
 	clone   *models.Clone
 	session *resources.Session
 
-	timeCreatedAt time.Time
-	timeStartedAt time.Time
+	timeCreatedAt    time.Time
+	timeStartedAt    time.Time
+	timeHibernatedAt time.Time
 
 	username string
 	password string
 
 	snapshot models.Snapshot
+
+	// evicted shows that the clone is hibernated to keep the limit of running clones.
+	evicted bool
+
+	// export tracks the latest export of the clone.
+	export *cloneExport
 }
 
 // NewCloneWrapper constructs a new CloneWrapper.
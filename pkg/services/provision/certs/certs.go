@@ -0,0 +1,225 @@
+/*
+2021 © Postgres.ai
+*/
+
+// Package certs provides a local certificate authority issuing certificates of clones.
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	caCertName = "ca.crt"
+	caKeyName  = "ca.key"
+
+	caCommonName = "Database Lab Clone CA"
+
+	caValidity   = 10 * 365 * 24 * time.Hour
+	certValidity = 365 * 24 * time.Hour
+
+	// notBeforeSkew tolerates clock differences between the engine and clients.
+	notBeforeSkew = time.Hour
+
+	certificateBlockType = "CERTIFICATE"
+	privateKeyBlockType  = "EC PRIVATE KEY"
+)
+
+// Authority issues certificates signed by the local CA.
+type Authority struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+}
+
+// Certificate describes a PEM-encoded certificate and its private key.
+type Certificate struct {
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+// NewAuthority loads the CA from the directory. A new CA is created if the directory does not contain it.
+func NewAuthority(dir string) (*Authority, error) {
+	certFile, keyFile := path.Join(dir, caCertName), path.Join(dir, caKeyName)
+
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, errors.Wrap(err, "failed to read the CA certificate")
+		}
+
+		return createAuthority(dir)
+	}
+
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the CA key")
+	}
+
+	return parseAuthority(certPEM, keyPEM)
+}
+
+func createAuthority(dir string) (*Authority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate the CA key")
+	}
+
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: caCommonName},
+		NotBefore:             now.Add(-notBeforeSkew),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the CA certificate")
+	}
+
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: certificateBlockType, Bytes: certDER})
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create the CA directory")
+	}
+
+	if err := os.WriteFile(path.Join(dir, caKeyName), keyPEM, 0600); err != nil {
+		return nil, errors.Wrap(err, "failed to write the CA key")
+	}
+
+	if err := os.WriteFile(path.Join(dir, caCertName), certPEM, 0644); err != nil {
+		return nil, errors.Wrap(err, "failed to write the CA certificate")
+	}
+
+	return parseAuthority(certPEM, keyPEM)
+}
+
+func parseAuthority(certPEM, keyPEM []byte) (*Authority, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil || certBlock.Type != certificateBlockType {
+		return nil, errors.New("failed to decode the CA certificate")
+	}
+
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the CA certificate")
+	}
+
+	if !cert.IsCA {
+		return nil, errors.New("the CA certificate cannot sign certificates")
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, errors.New("failed to decode the CA key")
+	}
+
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the CA key")
+	}
+
+	if !key.PublicKey.Equal(cert.PublicKey) {
+		return nil, errors.New("the CA key does not match the CA certificate")
+	}
+
+	return &Authority{cert: cert, key: key, certPEM: certPEM}, nil
+}
+
+// Bundle returns the PEM-encoded CA certificate to verify issued certificates.
+func (a *Authority) Bundle() string {
+	return string(a.certPEM)
+}
+
+// Issue issues a server certificate valid for the host, which is either an IP address or a DNS name.
+func (a *Authority) Issue(commonName, host string) (*Certificate, error) {
+	if host == "" {
+		return nil, errors.New("host of the certificate is not defined")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate a key")
+	}
+
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    now.Add(-notBeforeSkew),
+		NotAfter:     now.Add(certValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create a certificate")
+	}
+
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Certificate{
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: certificateBlockType, Bytes: certDER}),
+		KeyPEM:  keyPEM,
+	}, nil
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode a key")
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: privateKeyBlockType, Bytes: keyDER}), nil
+}
+
+func newSerialNumber() (*big.Int, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate a serial number")
+	}
+
+	return serialNumber, nil
+}
@@ -0,0 +1,89 @@
+package certs
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func verifyCertificate(t *testing.T, bundle string, cert *Certificate, host string) error {
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM([]byte(bundle)))
+
+	block, _ := pem.Decode(cert.CertPEM)
+	require.NotNil(t, block)
+
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	_, err = parsed.Verify(x509.VerifyOptions{
+		DNSName:   host,
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+
+	return err
+}
+
+func TestAuthorityIssue(t *testing.T) {
+	authority, err := NewAuthority(t.TempDir())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		host   string
+		verify []string
+		fail   []string
+	}{
+		{host: "db.example.com", verify: []string{"db.example.com"}, fail: []string{"other.example.com", "127.0.0.1"}},
+		{host: "127.0.0.1", verify: []string{"127.0.0.1"}, fail: []string{"localhost"}},
+	}
+
+	for _, tc := range testCases {
+		cert, err := authority.Issue("dblab_clone_6000", tc.host)
+		require.NoError(t, err)
+
+		for _, host := range tc.verify {
+			assert.NoError(t, verifyCertificate(t, authority.Bundle(), cert, host))
+		}
+
+		for _, host := range tc.fail {
+			assert.Error(t, verifyCertificate(t, authority.Bundle(), cert, host))
+		}
+	}
+
+	_, err = authority.Issue("dblab_clone_6000", "")
+	assert.Error(t, err)
+}
+
+func TestAuthorityIsKept(t *testing.T) {
+	dir := path.Join(t.TempDir(), "ca")
+
+	authority, err := NewAuthority(dir)
+	require.NoError(t, err)
+
+	cert, err := authority.Issue("dblab_clone_6000", "localhost")
+	require.NoError(t, err)
+
+	loadedAuthority, err := NewAuthority(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, authority.Bundle(), loadedAuthority.Bundle())
+	assert.NoError(t, verifyCertificate(t, loadedAuthority.Bundle(), cert, "localhost"))
+
+	keyInfo, err := os.Stat(path.Join(dir, caKeyName))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), keyInfo.Mode().Perm())
+
+	// A key not matching the certificate is rejected.
+	otherAuthority, err := NewAuthority(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path.Join(dir, caCertName), []byte(otherAuthority.Bundle()), 0644))
+
+	_, err = NewAuthority(dir)
+	assert.Error(t, err)
+}
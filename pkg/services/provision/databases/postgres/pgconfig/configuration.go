@@ -40,6 +40,9 @@ const (
 	// PgConfName defines the name of general Postgres config.
 	PgConfName = "postgresql.conf"
 
+	// pgAutoConfName defines the name of the config written by ALTER SYSTEM. Postgres reads it after the general config.
+	pgAutoConfName = "postgresql.auto.conf"
+
 	// recoveryConfName defines the name of recovery Postgres (<11) config.
 	recoveryConfName = "recovery.conf"
 
@@ -65,8 +68,21 @@ const (
 	// snapshotConfigName describes a file to store snapshot configuration.
 	snapshotConfigName = "snapshot.conf"
 
+	// tlsConfigName describes a file to store TLS configuration of a clone.
+	tlsConfigName = "tls.conf"
+
 	// userConfigName declares a file to store user-defined configuration.
 	userConfigName = "user_defined.conf"
+
+	// neutralizationConfigName describes a file to store configuration keeping a clone from reaching other systems.
+	neutralizationConfigName = "neutralization.conf"
+
+	// maxIncludeDepth limits nested includes of configuration files like Postgres does.
+	maxIncludeDepth = 10
+
+	// serverCertName and serverKeyName define files of the clone certificate at PGDATA.
+	serverCertName = "dblab_server.crt"
+	serverKeyName  = "dblab_server.key"
 )
 
 var includedDBLabConfigFiles = []string{
@@ -76,6 +92,7 @@ var includedDBLabConfigFiles = []string{
 	syncConfigName,
 	promotionConfigName,
 	snapshotConfigName,
+	tlsConfigName,
 	userConfigName,
 }
 
@@ -437,6 +454,209 @@ func (m *Manager) ApplyUserConfig(cfg map[string]string) error {
 	return nil
 }
 
+// ApplyTLS writes the server certificate and its key to PGDATA and enables SSL.
+func (m *Manager) ApplyTLS(certPEM, keyPEM []byte) error {
+	owner, err := getOwner(m.dataDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the owner of PGDATA")
+	}
+
+	// Postgres refuses to use the key if it is accessible by other users.
+	if err := writeOwnedFile(path.Join(m.dataDir, serverKeyName), keyPEM, 0600, owner); err != nil {
+		return errors.Wrap(err, "failed to write the server key")
+	}
+
+	if err := writeOwnedFile(path.Join(m.dataDir, serverCertName), certPEM, 0644, owner); err != nil {
+		return errors.Wrap(err, "failed to write the server certificate")
+	}
+
+	if err := m.rewriteConfig(m.getConfigPath(tlsConfigName), map[string]string{
+		"ssl":           "on",
+		"ssl_cert_file": serverCertName,
+		"ssl_key_file":  serverKeyName,
+	}); err != nil {
+		return err
+	}
+
+	return m.includeConfig(tlsConfigName)
+}
+
+// ApplyNeutralization applies configuration parameters keeping the instance from reaching other systems.
+// The file is included at the end of the general configuration file, so its parameters take precedence over other files.
+func (m *Manager) ApplyNeutralization(cfg map[string]string) error {
+	if err := m.rewriteConfig(m.getConfigPath(neutralizationConfigName), cfg); err != nil {
+		return err
+	}
+
+	return m.includeConfigLast(neutralizationConfigName)
+}
+
+// ReadParameter returns the value of the parameter set by configuration files of PGDATA in the order Postgres reads them.
+// The neutralization config is skipped. isAutoConf reports that the value is set by ALTER SYSTEM,
+// so it cannot be overridden by included files.
+func (m *Manager) ReadParameter(name string) (value string, isAutoConf bool, err error) {
+	params := make(map[string]string)
+
+	if err := readConfigFile(path.Join(m.dataDir, PgConfName), params, 0); err != nil {
+		return "", false, err
+	}
+
+	autoParams := make(map[string]string)
+
+	if err := readConfigFile(path.Join(m.dataDir, pgAutoConfName), autoParams, 0); err != nil {
+		return "", false, err
+	}
+
+	if autoValue, ok := autoParams[name]; ok {
+		return autoValue, true, nil
+	}
+
+	return params[name], false, nil
+}
+
+// readConfigFile reads parameters of the configuration file and files included by it. Missing files are skipped.
+func readConfigFile(filename string, params map[string]string, depth int) error {
+	if depth > maxIncludeDepth {
+		return errors.Errorf("too many nested includes in %s", filename)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return errors.Wrapf(err, "cannot read %s", filename)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if directive, includeName := splitIncludeDirective(line); directive != "" {
+			if path.Base(includeName) == configPrefix+neutralizationConfigName {
+				continue
+			}
+
+			if !path.IsAbs(includeName) {
+				includeName = path.Join(path.Dir(filename), includeName)
+			}
+
+			if err := readConfigFile(includeName, params, depth+1); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		param := strings.SplitN(line, "=", 2)
+		if len(param) != 2 {
+			continue
+		}
+
+		params[strings.ToLower(strings.TrimSpace(param[0]))] = parseConfigValue(param[1])
+	}
+
+	return nil
+}
+
+// splitIncludeDirective returns the include directive of the line and the included filename.
+func splitIncludeDirective(line string) (string, string) {
+	for _, directive := range []string{"include_if_exists", "include"} {
+		if strings.HasPrefix(line, directive+" ") || strings.HasPrefix(line, directive+"'") {
+			return directive, parseConfigValue(strings.TrimPrefix(line, directive))
+		}
+	}
+
+	return "", ""
+}
+
+// parseConfigValue unquotes the value of a configuration parameter and drops a trailing comment.
+func parseConfigValue(value string) string {
+	value = strings.TrimSpace(value)
+
+	if strings.HasPrefix(value, "'") {
+		if end := strings.Index(value[1:], "'"); end >= 0 {
+			return value[1 : end+1]
+		}
+
+		return strings.Trim(value, "'")
+	}
+
+	if commentIndex := strings.Index(value, "#"); commentIndex >= 0 {
+		value = value[:commentIndex]
+	}
+
+	return strings.TrimSpace(value)
+}
+
+// includeConfigLast moves the include of the Database Lab config file to the end of the general configuration file.
+func (m *Manager) includeConfigLast(configName string) error {
+	pgConfDst := path.Join(m.dataDir, PgConfName)
+	includeLine := fmt.Sprintf("include_if_exists %s%s", configPrefix, configName)
+
+	pgConf, err := os.ReadFile(pgConfDst)
+	if err != nil {
+		return errors.Wrapf(err, "cannot read %s at PGDATA", pgConfDst)
+	}
+
+	lines := make([]string, 0)
+
+	for _, line := range strings.Split(strings.TrimRight(string(pgConf), "\n"), "\n") {
+		if strings.TrimSpace(line) != includeLine {
+			lines = append(lines, line)
+		}
+	}
+
+	lines = append(lines, includeLine, "")
+
+	if err := os.WriteFile(pgConfDst, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		return errors.Wrapf(err, "cannot include %s", configName)
+	}
+
+	return nil
+}
+
+// includeConfig includes the Database Lab config file to the general configuration file
+// if the configuration has been initialized before the file was introduced.
+func (m *Manager) includeConfig(configName string) error {
+	pgConfDst := path.Join(m.dataDir, PgConfName)
+	includeLine := fmt.Sprintf("include_if_exists %s%s", configPrefix, configName)
+
+	pgConf, err := os.ReadFile(pgConfDst)
+	if err != nil {
+		return errors.Wrapf(err, "cannot read %s at PGDATA", pgConfDst)
+	}
+
+	for _, line := range strings.Split(string(pgConf), "\n") {
+		if strings.TrimSpace(line) == includeLine {
+			return nil
+		}
+	}
+
+	if err := fs.AppendFile(pgConfDst, []byte("\n"+includeLine+"\n")); err != nil {
+		return errors.Wrapf(err, "cannot include %s", configName)
+	}
+
+	return nil
+}
+
+// writeOwnedFile replaces the file and passes it to the owner.
+func writeOwnedFile(filename string, data []byte, perm os.FileMode, owner fileOwner) error {
+	if err := os.Remove(filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.WriteFile(filename, data, perm); err != nil {
+		return err
+	}
+
+	return setOwner(filename, owner)
+}
+
 // getConfigPath builds a path of the Database Lab config file.
 func (m *Manager) getConfigPath(configName string) string {
 	return path.Join(m.dataDir, configPrefix+configName)
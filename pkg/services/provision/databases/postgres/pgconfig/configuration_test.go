@@ -2,6 +2,8 @@ package pgconfig
 
 import (
 	"os"
+	"path"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -35,3 +37,74 @@ recovery_target_timeline = latest
 	assert.Equal(t, expected["standby_mode"], fileConfig["standby_mode"])
 	assert.Equal(t, expected["recovery_target_timeline"], fileConfig["recovery_target_timeline"])
 }
+
+func TestApplyTLS(t *testing.T) {
+	dataDir := t.TempDir()
+	pgConf := path.Join(dataDir, PgConfName)
+
+	// Configuration initialized before TLS config files were introduced.
+	require.NoError(t, os.WriteFile(pgConf, []byte(initializedLabel+"\ninclude_if_exists postgresql.dblab.user_defined.conf\n"), 0644))
+
+	m := &Manager{dataDir: dataDir}
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, m.ApplyTLS([]byte("certificate"), []byte("key")))
+	}
+
+	keyInfo, err := os.Stat(path.Join(dataDir, serverKeyName))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), keyInfo.Mode().Perm())
+
+	cert, err := os.ReadFile(path.Join(dataDir, serverCertName))
+	require.NoError(t, err)
+	assert.Equal(t, "certificate", string(cert))
+
+	tlsConfig, err := readConfig(m.getConfigPath(tlsConfigName))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"ssl": "on", "ssl_cert_file": serverCertName, "ssl_key_file": serverKeyName}, tlsConfig)
+
+	pgConfContent, err := os.ReadFile(pgConf)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(pgConfContent), "include_if_exists postgresql.dblab.tls.conf"))
+}
+
+func TestApplyNeutralization(t *testing.T) {
+	dataDir := t.TempDir()
+	pgConf := path.Join(dataDir, PgConfName)
+
+	require.NoError(t, os.WriteFile(pgConf, []byte(initializedLabel+`
+include_if_exists postgresql.dblab.postgresql.conf
+include_if_exists postgresql.dblab.user_defined.conf
+shared_preload_libraries = 'pg_stat_statements, pg_cron' # preloaded
+`), 0644))
+	require.NoError(t, os.WriteFile(path.Join(dataDir, "postgresql.dblab.user_defined.conf"),
+		[]byte("shared_preload_libraries = 'pg_cron'\nmax_connections = 200"), 0644))
+
+	m := &Manager{dataDir: dataDir}
+
+	value, isAutoConf, err := m.ReadParameter("shared_preload_libraries")
+	require.NoError(t, err)
+	assert.Equal(t, "pg_stat_statements, pg_cron", value)
+	assert.False(t, isAutoConf)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, m.ApplyNeutralization(map[string]string{"shared_preload_libraries": "pg_stat_statements"}))
+	}
+
+	pgConfContent, err := os.ReadFile(pgConf)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(pgConfContent), "\ninclude_if_exists postgresql.dblab.neutralization.conf\n"))
+	assert.Equal(t, 1, strings.Count(string(pgConfContent), "postgresql.dblab.neutralization.conf"))
+
+	// The neutralization config is not taken into account.
+	value, _, err = m.ReadParameter("shared_preload_libraries")
+	require.NoError(t, err)
+	assert.Equal(t, "pg_stat_statements, pg_cron", value)
+
+	require.NoError(t, os.WriteFile(path.Join(dataDir, pgAutoConfName), []byte("shared_preload_libraries = 'pg_cron'\n"), 0600))
+
+	value, isAutoConf, err = m.ReadParameter("shared_preload_libraries")
+	require.NoError(t, err)
+	assert.Equal(t, "pg_cron", value)
+	assert.True(t, isAutoConf)
+}
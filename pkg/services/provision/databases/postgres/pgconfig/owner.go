@@ -0,0 +1,38 @@
+// +build !windows
+
+/*
+2021 © Postgres.ai
+*/
+
+package pgconfig
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// fileOwner describes the owner of a file.
+type fileOwner struct {
+	uid int
+	gid int
+}
+
+func getOwner(filename string) (fileOwner, error) {
+	fileInfo, err := os.Stat(filename)
+	if err != nil {
+		return fileOwner{}, err
+	}
+
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileOwner{}, errors.New("failed to read file ownership")
+	}
+
+	return fileOwner{uid: int(stat.Uid), gid: int(stat.Gid)}, nil
+}
+
+func setOwner(filename string, owner fileOwner) error {
+	return os.Chown(filename, owner.uid, owner.gid)
+}
@@ -0,0 +1,20 @@
+// +build windows
+
+/*
+2021 © Postgres.ai
+*/
+
+package pgconfig
+
+// fileOwner describes the owner of a file.
+type fileOwner struct{}
+
+func getOwner(filename string) (fileOwner, error) {
+	// Not supported for windows.
+	return fileOwner{}, nil
+}
+
+func setOwner(filename string, owner fileOwner) error {
+	// Not supported for windows.
+	return nil
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/pkg/errors"
 
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/neutralization"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/databases/postgres/pgconfig"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/docker"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
@@ -40,15 +41,8 @@ const (
 func Start(r runners.Runner, c *resources.AppConfig) error {
 	log.Dbg("Starting Postgres container...")
 
-	if extraConf := c.ExtraConf(); len(extraConf) > 0 {
-		configManager, err := pgconfig.NewCorrector(c.DataDir())
-		if err != nil {
-			return errors.Wrap(err, "failed to create a config manager")
-		}
-
-		if err := configManager.ApplyUserConfig(extraConf); err != nil {
-			return errors.Wrap(err, "cannot apply user configs")
-		}
+	if err := applyConfigs(c); err != nil {
+		return err
 	}
 
 	if err := docker.RunContainer(r, c); err != nil {
@@ -119,6 +113,41 @@ func Start(r runners.Runner, c *resources.AppConfig) error {
 	return nil
 }
 
+// applyConfigs applies user-defined configuration, the server certificate and the neutralization configuration of the clone.
+func applyConfigs(c *resources.AppConfig) error {
+	extraConf := c.ExtraConf()
+
+	if len(extraConf) == 0 && c.TLS == nil && !c.Neutralization.IsEnabled() {
+		return nil
+	}
+
+	configManager, err := pgconfig.NewCorrector(c.DataDir())
+	if err != nil {
+		return errors.Wrap(err, "failed to create a config manager")
+	}
+
+	if len(extraConf) > 0 {
+		if err := configManager.ApplyUserConfig(extraConf); err != nil {
+			return errors.Wrap(err, "cannot apply user configs")
+		}
+	}
+
+	if c.TLS != nil {
+		if err := configManager.ApplyTLS(c.TLS.CertPEM, c.TLS.KeyPEM); err != nil {
+			return errors.Wrap(err, "cannot apply TLS configs")
+		}
+	}
+
+	// Subscriptions and cron jobs must not run before they are disabled in the catalog.
+	if c.Neutralization.IsEnabled() {
+		if err := neutralization.ApplyStartupConfig(configManager, c.Neutralization); err != nil {
+			return errors.Wrap(err, "cannot apply neutralization configs")
+		}
+	}
+
+	return nil
+}
+
 // Stop stops Postgres instance.
 func Stop(r runners.Runner, p *resources.Pool, name string) error {
 	log.Dbg("Stopping Postgres container...")
@@ -134,6 +163,10 @@ func Stop(r runners.Runner, p *resources.Pool, name string) error {
 
 	}
 
+	if err := docker.RemoveCloneNetwork(r, name); err != nil {
+		return err
+	}
+
 	if _, err := r.Run("rm -rf " + p.SocketCloneDir(name) + "/*"); err != nil {
 		return errors.Wrap(err, "failed to clean unix socket directory")
 	}
@@ -154,6 +187,11 @@ func pgctlPromote(r runners.Runner, c *resources.AppConfig) (string, error) {
 	return docker.Exec(r, c, promoteCmd)
 }
 
+// Query runs a query returning one string value in the database of the instance.
+func Query(c *resources.AppConfig, dbName, query string) (string, error) {
+	return runSimpleSQL(query, getPgConnStr(c.Host, dbName, c.DB.Username, c.Port))
+}
+
 // Generate postgres connection string.
 func getPgConnStr(host, dbname, username string, port uint) string {
 	var sb strings.Builder
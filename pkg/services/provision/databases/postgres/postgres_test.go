@@ -65,6 +65,13 @@ func TestRemoveContainers(t *testing.T) {
 					func(cmd string) bool {
 						return strings.HasPrefix(cmd, "rm -rf ")
 					})).
+			Return("", nil).
+			On("Run",
+				mock.MatchedBy(
+					func(cmd string) bool {
+						return strings.HasPrefix(cmd, "docker network ") ||
+							strings.HasPrefix(cmd, "docker container rm --force dblab_network_holder_")
+					})).
 			Return("", nil)
 
 		err := Stop(runner, p, "test_clone")
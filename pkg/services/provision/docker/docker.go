@@ -8,6 +8,7 @@ package docker
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"strconv"
@@ -58,13 +59,29 @@ func RunContainer(r runners.Runner, c *resources.AppConfig) error {
 		containerFlags = append(containerFlags, fmt.Sprintf("--%s=%s", flagName, flagValue))
 	}
 
-	// TODO (akartasov): use Docker client instead of command execution.
+	// An isolated clone joins the network namespace prepared for it, which publishes the port of the clone.
 	instancePort := strconv.Itoa(int(c.Port))
+	networkFlags := []string{"--publish", fmt.Sprintf("%[1]s:%[1]s", instancePort)}
+
+	if c.Network.Isolated {
+		engineContainer := ""
+		if hostInfo.VirtualizationRole == "guest" {
+			engineContainer = hostInfo.Hostname
+		}
+
+		if err := setupCloneNetwork(r, c, engineContainer); err != nil {
+			return err
+		}
+
+		networkFlags = []string{"--network", "container:" + networkHolderName(c.CloneName)}
+	}
+
+	// TODO (akartasov): use Docker client instead of command execution.
 	dockerRunCmd := strings.Join([]string{
 		"docker run",
 		"--name", c.CloneName,
 		"--detach",
-		"--publish", fmt.Sprintf("%[1]s:%[1]s", instancePort),
+		strings.Join(networkFlags, " "),
 		"--env", "PGDATA=" + c.DataDir(),
 		strings.Join(volumes, " "),
 		"--label", labelClone,
@@ -79,6 +96,12 @@ func RunContainer(r runners.Runner, c *resources.AppConfig) error {
 		return errors.Wrap(err, "failed to run command")
 	}
 
+	// Isolated clones are not attached to the internal network shared by other containers,
+	// the engine reaches them through their own networks.
+	if c.Network.Isolated {
+		return nil
+	}
+
 	dockerConnectCmd := strings.Join([]string{"docker network connect", c.NetworkID, c.CloneName}, " ")
 
 	if _, err := r.Run(dockerConnectCmd, true); err != nil {
@@ -218,6 +241,11 @@ func Exec(r runners.Runner, c *resources.AppConfig, cmd string) (string, error)
 	return r.Run(dockerExecCmd, true)
 }
 
+// ExecStream runs the command in the clone container and streams its output to the writer.
+func ExecStream(r runners.StreamRunner, c *resources.AppConfig, cmd string, w io.Writer) error {
+	return r.Stream("docker exec "+c.CloneName+" "+cmd, nil, w)
+}
+
 // ImageExists checks existence of Docker image.
 func ImageExists(r runners.Runner, dockerImage string) (bool, error) {
 	dockerListImagesCmd := "docker images " + dockerImage + " --quiet"
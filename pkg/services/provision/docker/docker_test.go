@@ -5,10 +5,12 @@
 package docker
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/docker/docker/api/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
 )
@@ -71,3 +73,83 @@ func TestVolumesBuilding(t *testing.T) {
 		assert.Equal(t, tc.expectedVolumes, volumes)
 	}
 }
+
+func TestBuildEgressScript(t *testing.T) {
+	networks, err := resolveEgressNetworks([]string{"10.0.0.0/8", "192.168.1.10:5432", "[2001:db8::1]:443"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "set -e; "+
+		"iptables -A OUTPUT -o lo -j ACCEPT; "+
+		"iptables -A OUTPUT -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT; "+
+		"iptables -A OUTPUT -d 10.0.0.0/8 -j ACCEPT; "+
+		"iptables -A OUTPUT -d 192.168.1.10/32 -p tcp --dport 5432 -j ACCEPT; "+
+		"iptables -A OUTPUT -j REJECT; "+
+		"if ip6tables -L OUTPUT >/dev/null 2>&1; then "+
+		"ip6tables -A OUTPUT -o lo -j ACCEPT; "+
+		"ip6tables -A OUTPUT -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT; "+
+		"ip6tables -A OUTPUT -d 2001:db8::1/128 -p tcp --dport 443 -j ACCEPT; "+
+		"ip6tables -A OUTPUT -j REJECT; fi", buildEgressScript(networks))
+}
+
+func TestBuildEgressScriptWithoutDestinations(t *testing.T) {
+	script := buildEgressScript(nil)
+
+	assert.Contains(t, script, "iptables -A OUTPUT -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT; iptables -A OUTPUT -j REJECT")
+	assert.NotContains(t, script, "-d ")
+}
+
+type recordingRunner struct {
+	commands []string
+	outputs  map[string]string
+}
+
+func (r *recordingRunner) Run(cmd string, _ ...bool) (string, error) {
+	r.commands = append(r.commands, cmd)
+
+	for fragment, output := range r.outputs {
+		if strings.Contains(cmd, fragment) {
+			return output, nil
+		}
+	}
+
+	return "", nil
+}
+
+func TestSetupCloneNetwork(t *testing.T) {
+	runner := &recordingRunner{}
+	appConfig := &resources.AppConfig{
+		CloneName: "dblab_clone_6000",
+		Port:      6000,
+		Pool:      resources.Pool{Name: "dblab_pool"},
+		Network:   resources.CloneNetwork{Isolated: true, EgressImage: "iptables:latest", AllowEgress: []string{"10.0.0.0/8"}},
+	}
+
+	require.NoError(t, setupCloneNetwork(runner, appConfig, "dblab_server"))
+	require.Len(t, runner.commands, 6)
+
+	assert.Equal(t, "docker container rm --force dblab_network_holder_dblab_clone_6000", runner.commands[0])
+	assert.Equal(t, "docker network create --driver bridge --label dblab_network_holder --label dblab_pool "+
+		"dblab_network_dblab_clone_6000", runner.commands[2])
+	assert.Contains(t, runner.commands[3], "docker run --name dblab_network_holder_dblab_clone_6000 --detach "+
+		"--network dblab_network_dblab_clone_6000 --network-alias dblab_clone_6000 --publish 6000:6000")
+	assert.Contains(t, runner.commands[4], "docker run --rm --network container:dblab_network_holder_dblab_clone_6000 --cap-add NET_ADMIN")
+	assert.Contains(t, runner.commands[4], "iptables -A OUTPUT -d 10.0.0.0/8 -j ACCEPT")
+	assert.Equal(t, "docker network connect dblab_network_dblab_clone_6000 dblab_server", runner.commands[5])
+}
+
+func TestRemoveCloneNetwork(t *testing.T) {
+	runner := &recordingRunner{outputs: map[string]string{
+		"docker network ls":      "4f2a\n",
+		"docker network inspect": "dblab_server ",
+	}}
+
+	require.NoError(t, RemoveCloneNetwork(runner, "dblab_clone_6000"))
+
+	assert.Equal(t, []string{
+		"docker container rm --force dblab_network_holder_dblab_clone_6000",
+		`docker network ls --filter "name=^dblab_network_dblab_clone_6000$" --quiet`,
+		`docker network inspect --format '{{range .Containers}}{{.Name}} {{end}}' dblab_network_dblab_clone_6000`,
+		"docker network disconnect --force dblab_network_dblab_clone_6000 dblab_server",
+		"docker network rm dblab_network_dblab_clone_6000",
+	}, runner.commands)
+}
@@ -0,0 +1,229 @@
+/*
+2021 © Postgres.ai
+*/
+
+package docker
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/runners"
+)
+
+const (
+	cloneNetworkPrefix = "dblab_network_"
+
+	// networkHolderPrefix defines the prefix of containers holding network namespaces of isolated clones.
+	networkHolderPrefix = "dblab_network_holder_"
+
+	labelNetworkHolder = "dblab_network_holder"
+
+	// networkHolderScript keeps the holder running until it is stopped.
+	networkHolderScript = "trap 'exit 0' TERM; while true; do sleep 3600 & wait $!; done"
+)
+
+// CloneNetworkName returns the name of the network isolating the clone.
+func CloneNetworkName(cloneName string) string {
+	return cloneNetworkPrefix + cloneName
+}
+
+func networkHolderName(cloneName string) string {
+	return networkHolderPrefix + cloneName
+}
+
+// setupCloneNetwork prepares the network namespace of an isolated clone before the clone is started.
+// The namespace is held by a helper container in the network dedicated to the clone, which publishes the port of the clone,
+// and egress rules are applied to it, so Postgres never runs without the rules.
+// The engine container, if defined, joins the network to reach the clone by its name.
+// Resources left by a previous run of the clone are removed first.
+func setupCloneNetwork(r runners.Runner, c *resources.AppConfig, engineContainer string) error {
+	if err := RemoveCloneNetwork(r, c.CloneName); err != nil {
+		return err
+	}
+
+	createCmd := strings.Join([]string{
+		"docker network create",
+		"--driver bridge",
+		"--label", labelNetworkHolder,
+		"--label", c.Pool.Name,
+		CloneNetworkName(c.CloneName),
+	}, " ")
+
+	if _, err := r.Run(createCmd, true); err != nil {
+		return errors.Wrap(err, "failed to create clone network")
+	}
+
+	instancePort := strconv.Itoa(int(c.Port))
+	holderCmd := strings.Join([]string{
+		"docker run",
+		"--name", networkHolderName(c.CloneName),
+		"--detach",
+		"--network", CloneNetworkName(c.CloneName),
+		"--network-alias", c.CloneName,
+		"--publish", fmt.Sprintf("%[1]s:%[1]s", instancePort),
+		"--label", labelNetworkHolder,
+		"--label", c.Pool.Name,
+		"--entrypoint sh",
+		c.Network.EgressImage,
+		"-c", shellQuote(networkHolderScript),
+	}, " ")
+
+	if _, err := r.Run(holderCmd, true); err != nil {
+		return errors.Wrap(err, "failed to start the network holder of the clone")
+	}
+
+	if err := applyEgressRules(r, c); err != nil {
+		return err
+	}
+
+	if engineContainer != "" {
+		connectCmd := strings.Join([]string{"docker network connect", CloneNetworkName(c.CloneName), engineContainer}, " ")
+
+		if _, err := r.Run(connectCmd, true); err != nil {
+			return errors.Wrap(err, "failed to connect the engine to the clone network")
+		}
+	}
+
+	return nil
+}
+
+// RemoveCloneNetwork removes the network holder and the network of the clone if they exist.
+func RemoveCloneNetwork(r runners.Runner, cloneName string) error {
+	holderName := networkHolderName(cloneName)
+
+	if _, err := r.Run("docker container rm --force "+holderName, true); err != nil {
+		const errorPrefix = "Error: No such container:"
+
+		if e, ok := err.(runners.RunnerError); !ok || !strings.HasPrefix(e.Stderr, errorPrefix) {
+			return errors.Wrap(err, "failed to remove the network holder of the clone")
+		}
+	}
+
+	networkName := CloneNetworkName(cloneName)
+
+	out, err := r.Run(fmt.Sprintf(`docker network ls --filter "name=^%s$" --quiet`, networkName), true)
+	if err != nil {
+		return errors.Wrap(err, "failed to list networks")
+	}
+
+	if strings.TrimSpace(out) == "" {
+		return nil
+	}
+
+	// The engine container is disconnected, so the network can be removed.
+	out, err = r.Run(fmt.Sprintf(`docker network inspect --format '{{range .Containers}}{{.Name}} {{end}}' %s`, networkName), true)
+	if err != nil {
+		return errors.Wrap(err, "failed to inspect clone network")
+	}
+
+	for _, containerName := range strings.Fields(out) {
+		if _, err := r.Run(fmt.Sprintf("docker network disconnect --force %s %s", networkName, containerName), true); err != nil {
+			return errors.Wrapf(err, "failed to disconnect %s from clone network", containerName)
+		}
+	}
+
+	if _, err := r.Run("docker network rm "+networkName, true); err != nil {
+		return errors.Wrap(err, "failed to remove clone network")
+	}
+
+	log.Dbg("Clone network has been removed: ", networkName)
+
+	return nil
+}
+
+// applyEgressRules blocks outgoing connections of the clone except for allowed destinations.
+// Rules are added to the network namespace held for the clone by a helper container, so Postgres cannot change them.
+func applyEgressRules(r runners.Runner, c *resources.AppConfig) error {
+	networks, err := resolveEgressNetworks(c.Network.AllowEgress)
+	if err != nil {
+		return err
+	}
+
+	applyCmd := strings.Join([]string{
+		"docker run --rm",
+		"--network container:" + networkHolderName(c.CloneName),
+		"--cap-add NET_ADMIN",
+		"--entrypoint sh",
+		c.Network.EgressImage,
+		"-c", shellQuote(buildEgressScript(networks)),
+	}, " ")
+
+	if _, err := r.Run(applyCmd, true); err != nil {
+		return errors.Wrap(err, "failed to apply egress rules")
+	}
+
+	return nil
+}
+
+type egressNetwork struct {
+	network *net.IPNet
+	port    uint16
+}
+
+func resolveEgressNetworks(allowEgress []string) ([]egressNetwork, error) {
+	networks := make([]egressNetwork, 0, len(allowEgress))
+
+	for _, destination := range allowEgress {
+		rule, err := resources.ParseEgressRule(destination)
+		if err != nil {
+			return nil, err
+		}
+
+		ruleNetworks, err := rule.ResolveNetworks()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ruleNetwork := range ruleNetworks {
+			networks = append(networks, egressNetwork{network: ruleNetwork, port: rule.Port})
+		}
+	}
+
+	return networks, nil
+}
+
+// buildEgressScript builds iptables commands allowing loopback traffic, replies to incoming connections
+// and connections to allowed networks, and rejecting everything else.
+// IPv6 rules are applied only if the network namespace supports IPv6.
+func buildEgressScript(networks []egressNetwork) string {
+	ipv4Rules := baseEgressRules("iptables")
+	ipv6Rules := baseEgressRules("ip6tables")
+
+	for _, egress := range networks {
+		rule := "-A OUTPUT -d " + egress.network.String()
+
+		if egress.port != 0 {
+			rule += fmt.Sprintf(" -p tcp --dport %d", egress.port)
+		}
+
+		if egress.network.IP.To4() != nil {
+			ipv4Rules = append(ipv4Rules, "iptables "+rule+" -j ACCEPT")
+		} else {
+			ipv6Rules = append(ipv6Rules, "ip6tables "+rule+" -j ACCEPT")
+		}
+	}
+
+	ipv4Rules = append(ipv4Rules, "iptables -A OUTPUT -j REJECT")
+	ipv6Rules = append(ipv6Rules, "ip6tables -A OUTPUT -j REJECT")
+
+	return "set -e; " + strings.Join(ipv4Rules, "; ") +
+		"; if ip6tables -L OUTPUT >/dev/null 2>&1; then " + strings.Join(ipv6Rules, "; ") + "; fi"
+}
+
+func baseEgressRules(command string) []string {
+	return []string{
+		command + " -A OUTPUT -o lo -j ACCEPT",
+		command + " -A OUTPUT -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT",
+	}
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,105 @@
+/*
+2021 © Postgres.ai
+*/
+
+package provision
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/databases/postgres"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/docker"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/pool"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/runners"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/util"
+)
+
+const (
+	// exportPrefix distinguishes snapshots which clones are exported from.
+	exportPrefix = "export_"
+
+	// exportDumpDir defines the directory in the clone container where dumps of the directory format are written.
+	exportDumpDir = "/tmp/dblab_export"
+)
+
+// ExportSession writes the data of the clone of the session to the writer.
+// Dumps are made by pg_dump in the clone container: the directory format is written as a tar archive.
+// The "zfs" format is a replication stream of the clone dataset.
+func (p *Provisioner) ExportSession(session *resources.Session, options resources.ExportOptions, w io.Writer) error {
+	fsm, err := p.pm.GetFSManager(session.Pool)
+	if err != nil {
+		return errors.Wrap(err, "failed to find a filesystem manager of this session")
+	}
+
+	cloneName := util.GetCloneName(session.Port)
+	appConfig := p.getAppConfig(fsm.Pool(), cloneName, session.Port)
+
+	switch options.Format {
+	case models.ExportFormatCustom, models.ExportFormatDirectory:
+		streamRunner, ok := p.runner.(runners.StreamRunner)
+		if !ok {
+			return errors.New("the runner does not support streaming")
+		}
+
+		if err := docker.ExecStream(streamRunner, appConfig, dumpCommand(appConfig, options), w); err != nil {
+			return errors.Wrap(err, "failed to dump the clone")
+		}
+
+		return nil
+
+	case models.ExportFormatZFS:
+		return p.sendClone(fsm, appConfig, w)
+	}
+
+	return errors.Errorf("unsupported export format %q", options.Format)
+}
+
+// sendClone writes a replication stream of a temporary snapshot of the clone.
+func (p *Provisioner) sendClone(fsm pool.FSManager, appConfig *resources.AppConfig, w io.Writer) error {
+	// The stream is restored as after a crash of the clone, so the checkpoint shortens the recovery.
+	if _, err := postgres.Query(appConfig, appConfig.DB.DBName, "checkpoint"); err != nil {
+		return errors.Wrap(err, "failed to make a checkpoint")
+	}
+
+	snapshotID, err := fsm.CreateCloneSnapshot(appConfig.CloneName, exportPrefix+time.Now().Format(util.DataStateAtFormat))
+	if err != nil {
+		return errors.Wrap(err, "failed to snapshot the clone")
+	}
+
+	defer func() {
+		if err := fsm.DestroySnapshot(snapshotID); err != nil {
+			log.Err("Failed to destroy the export snapshot: ", err)
+		}
+	}()
+
+	if err := fsm.ExportSnapshot(w, snapshotID, ""); err != nil {
+		return errors.Wrap(err, "failed to send the clone snapshot")
+	}
+
+	return nil
+}
+
+// dumpCommand builds a pg_dump command writing the dump to the standard output.
+func dumpCommand(appConfig *resources.AppConfig, options resources.ExportOptions) string {
+	dumpCmd := fmt.Sprintf("pg_dump --host %s --port %d --username %s --dbname %s",
+		appConfig.Host, appConfig.Port, appConfig.DB.Username, options.DBName)
+
+	if options.Format != models.ExportFormatDirectory {
+		return dumpCmd + " --format custom"
+	}
+
+	jobs := options.Jobs
+	if jobs == 0 {
+		jobs = 1
+	}
+
+	return fmt.Sprintf(`sh -c 'rm -rf %[1]s && %[2]s --format directory --jobs %[3]d --file %[1]s && tar -cf - -C %[1]s .; `+
+		`status=$?; rm -rf %[1]s; exit $status'`, exportDumpDir, dumpCmd, jobs)
+}
@@ -0,0 +1,52 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+)
+
+func TestDumpCommand(t *testing.T) {
+	appConfig := &resources.AppConfig{
+		Host: "/var/lib/dblab/sockets/dblab_clone_6000",
+		Port: 6000,
+		DB:   &resources.DB{Username: "postgres"},
+	}
+
+	const dumpCmd = "pg_dump --host /var/lib/dblab/sockets/dblab_clone_6000 --port 6000 --username postgres --dbname test"
+
+	directoryCmd := func(jobs string) string {
+		return "sh -c 'rm -rf /tmp/dblab_export && " + dumpCmd + " --format directory --jobs " + jobs +
+			" --file /tmp/dblab_export && tar -cf - -C /tmp/dblab_export .; status=$?; rm -rf /tmp/dblab_export; exit $status'"
+	}
+
+	testCases := []struct {
+		options  resources.ExportOptions
+		expected string
+	}{
+		{
+			options:  resources.ExportOptions{Format: models.ExportFormatCustom, DBName: "test"},
+			expected: dumpCmd + " --format custom",
+		},
+		{
+			// Parallel jobs are supported only by the directory format.
+			options:  resources.ExportOptions{Format: models.ExportFormatCustom, DBName: "test", Jobs: 4},
+			expected: dumpCmd + " --format custom",
+		},
+		{
+			options:  resources.ExportOptions{Format: models.ExportFormatDirectory, DBName: "test"},
+			expected: directoryCmd("1"),
+		},
+		{
+			options:  resources.ExportOptions{Format: models.ExportFormatDirectory, DBName: "test", Jobs: 4},
+			expected: directoryCmd("4"),
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, dumpCommand(appConfig, tc.options))
+	}
+}
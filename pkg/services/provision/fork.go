@@ -0,0 +1,94 @@
+/*
+2021 © Postgres.ai
+*/
+
+package provision
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/databases/postgres"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/pool"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/util"
+)
+
+// forkPrefix distinguishes snapshots which forks are created from.
+const forkPrefix = "fork_"
+
+// ForkSession starts a new session with a clone of the current data of the parent session.
+// The fork gets its own port and user, and inherits the configuration of the parent session.
+// The parent clone cannot be destroyed while the fork exists.
+func (p *Provisioner) ForkSession(parent *resources.Session, user resources.EphemeralUser,
+	accessHost string) (*resources.Session, error) {
+	fsm, err := p.pm.GetFSManager(parent.Pool)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find a filesystem manager of the parent session")
+	}
+
+	port, err := p.allocatePort()
+	if err != nil {
+		return nil, errors.New("failed to get a free port")
+	}
+
+	name := util.GetCloneName(port)
+	parentName := util.GetCloneName(parent.Port)
+
+	log.Dbg(fmt.Sprintf("Forking session of clone %s for port: %d.", parentName, port))
+
+	var snapshotID string
+
+	defer func() {
+		if err != nil {
+			p.revertSession(fsm, name)
+
+			if snapshotID != "" {
+				if destroyErr := fsm.DestroySnapshot(snapshotID); destroyErr != nil {
+					log.Err("Revert:", destroyErr)
+				}
+			}
+
+			if portErr := p.freePort(port); portErr != nil {
+				log.Err(portErr)
+			}
+		}
+	}()
+
+	parentConfig := p.getAppConfig(fsm.Pool(), parentName, parent.Port)
+
+	// The fork starts as after a crash of the parent, so the checkpoint shortens its recovery.
+	if _, err = postgres.Query(parentConfig, parentConfig.DB.DBName, "checkpoint"); err != nil {
+		return nil, errors.Wrap(err, "failed to make a checkpoint")
+	}
+
+	snapshotID, err = fsm.CreateCloneSnapshot(parentName, forkPrefix+name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to snapshot the parent clone")
+	}
+
+	session, err := p.startSession(fsm, port, snapshotID, user, parent.ExtraConfig, parent.AllowEgress, accessHost)
+	if err != nil {
+		return nil, err
+	}
+
+	session.ForkOrigin = snapshotID
+
+	return session, nil
+}
+
+// destroyForkOrigin destroys the snapshot of the parent clone if the session is a fork and its clone does not use it anymore.
+func (p *Provisioner) destroyForkOrigin(fsm pool.FSManager, session *resources.Session) {
+	if session.ForkOrigin == "" {
+		return
+	}
+
+	if err := fsm.DestroySnapshot(session.ForkOrigin); err != nil {
+		log.Err("Failed to destroy the origin snapshot of the fork: ", err)
+		return
+	}
+
+	session.ForkOrigin = ""
+}
@@ -7,6 +7,7 @@ package provision
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/csv"
 	"fmt"
 	"io"
@@ -22,6 +23,8 @@ import (
 
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/neutralization"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/certs"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/databases/postgres"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/docker"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/pool"
@@ -34,6 +37,9 @@ import (
 const (
 	maxNumberOfPortsToCheck = 5
 	portCheckingTimeout     = 3 * time.Second
+
+	// proxyCommonName is the common name of the connection proxy certificate.
+	proxyCommonName = "dblab_proxy"
 )
 
 // PortPool describes an available port range for clones.
@@ -44,11 +50,27 @@ type PortPool struct {
 
 // Config defines configuration for provisioning.
 type Config struct {
-	PortPool          PortPool          `yaml:"portPool"`
-	DockerImage       string            `yaml:"dockerImage"`
-	UseSudo           bool              `yaml:"useSudo"`
-	KeepUserPasswords bool              `yaml:"keepUserPasswords"`
-	ContainerConfig   map[string]string `yaml:"containerConfig"`
+	PortPool          PortPool               `yaml:"portPool"`
+	DockerImage       string                 `yaml:"dockerImage"`
+	UseSudo           bool                   `yaml:"useSudo"`
+	KeepUserPasswords bool                   `yaml:"keepUserPasswords"`
+	ContainerConfig   map[string]string      `yaml:"containerConfig"`
+	Neutralization    neutralization.Options `yaml:"neutralization"`
+	NetworkIsolation  NetworkIsolation       `yaml:"networkIsolation"`
+	CloneTLS          CloneTLS               `yaml:"cloneTLS"`
+}
+
+// CloneTLS defines TLS of clone connections with certificates issued by the local certificate authority.
+type CloneTLS struct {
+	Enabled bool   `yaml:"enabled"`
+	CADir   string `yaml:"caDir"`
+}
+
+// NetworkIsolation defines isolation of clones in their own networks with restricted outgoing connections.
+type NetworkIsolation struct {
+	Enabled     bool     `yaml:"enabled"`
+	EgressImage string   `yaml:"egressImage"`
+	AllowEgress []string `yaml:"allowEgress"`
 }
 
 // Provisioner describes a struct for ports and clones management.
@@ -64,6 +86,7 @@ type Provisioner struct {
 	portChecker    portChecker
 	pm             *pool.Manager
 	networkID      string
+	authority      *certs.Authority
 }
 
 // New creates a new Provisioner instance.
@@ -85,6 +108,15 @@ func New(ctx context.Context, cfg *Config, dbCfg *resources.DB, docker *client.C
 		networkID:    networkID,
 	}
 
+	if cfg.CloneTLS.Enabled {
+		authority, err := certs.NewAuthority(cfg.CloneTLS.CADir)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to init the certificate authority")
+		}
+
+		p.authority = authority
+	}
+
 	return p, nil
 }
 
@@ -108,6 +140,20 @@ func isValidConfigModeLocal(config Config) error {
 		return errors.New(`"portPool" must include at least one port`)
 	}
 
+	if config.NetworkIsolation.Enabled && config.NetworkIsolation.EgressImage == "" {
+		return errors.New(`"networkIsolation.egressImage" must be defined to isolate clones`)
+	}
+
+	for _, destination := range config.NetworkIsolation.AllowEgress {
+		if _, err := resources.ParseEgressRule(destination); err != nil {
+			return errors.Wrap(err, `invalid "networkIsolation.allowEgress"`)
+		}
+	}
+
+	if config.CloneTLS.Enabled && config.CloneTLS.CADir == "" {
+		return errors.New(`"cloneTLS.caDir" must be defined to enable TLS of clones`)
+	}
+
 	return nil
 }
 
@@ -144,11 +190,28 @@ func (p *Provisioner) Init() error {
 func (p *Provisioner) Reload(cfg Config, dbCfg resources.DB) {
 	*p.config = cfg
 	*p.dbCfg = dbCfg
+
+	var authority *certs.Authority
+
+	if cfg.CloneTLS.Enabled {
+		var err error
+
+		if authority, err = certs.NewAuthority(cfg.CloneTLS.CADir); err != nil {
+			log.Err("Failed to reload the certificate authority, the current one is kept: ", err)
+			return
+		}
+	}
+
+	p.mu.Lock()
+	p.authority = authority
+	p.mu.Unlock()
 }
 
 // StartSession starts a new session.
+// Outgoing connections to allowEgress destinations are permitted in addition to the configured ones if clones are isolated.
+// The certificate of the clone is issued for accessHost if TLS of clones is enabled.
 func (p *Provisioner) StartSession(snapshotID string, user resources.EphemeralUser,
-	extraConfig map[string]string) (*resources.Session, error) {
+	extraConfig map[string]string, allowEgress []string, accessHost string) (*resources.Session, error) {
 	snapshot, err := p.getSnapshot(snapshotID)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get snapshots")
@@ -166,7 +229,7 @@ func (p *Provisioner) StartSession(snapshotID string, user resources.EphemeralUs
 
 	defer func() {
 		if err != nil {
-			p.revertSession(name)
+			p.revertSession(fsm, name)
 
 			if portErr := p.freePort(port); portErr != nil {
 				log.Err(portErr)
@@ -174,12 +237,35 @@ func (p *Provisioner) StartSession(snapshotID string, user resources.EphemeralUs
 		}
 	}()
 
-	if err := fsm.CreateClone(name, snapshot.ID); err != nil {
+	session, err := p.startSession(fsm, port, snapshot.ID, user, extraConfig, allowEgress, accessHost)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// startSession creates a clone of the snapshot on the allocated port and starts its container.
+func (p *Provisioner) startSession(fsm pool.FSManager, port uint, snapshotID string, user resources.EphemeralUser,
+	extraConfig map[string]string, allowEgress []string, accessHost string) (*resources.Session, error) {
+	name := util.GetCloneName(port)
+
+	if err := fsm.CreateClone(name, snapshotID); err != nil {
 		return nil, errors.Wrap(err, "failed to create clone")
 	}
 
 	appConfig := p.getAppConfig(fsm.Pool(), name, port)
 	appConfig.SetExtraConf(extraConfig)
+	appConfig.Network = p.cloneNetwork(allowEgress)
+
+	authority := p.getAuthority()
+
+	cert, err := issueCertificate(authority, name, accessHost)
+	if err != nil {
+		return nil, err
+	}
+
+	appConfig.TLS = cert
 
 	if err := postgres.Start(p.runner, appConfig); err != nil {
 		return nil, errors.Wrap(err, "failed to start a container")
@@ -199,6 +285,12 @@ func (p *Provisioner) StartSession(snapshotID string, user resources.EphemeralUs
 		SocketHost:    appConfig.Host,
 		EphemeralUser: user,
 		ExtraConfig:   extraConfig,
+		AllowEgress:   allowEgress,
+		AccessHost:    accessHost,
+	}
+
+	if authority != nil {
+		session.CACert = authority.Bundle()
 	}
 
 	return session, nil
@@ -221,6 +313,8 @@ func (p *Provisioner) StopSession(session *resources.Session) error {
 		return errors.Wrap(err, "failed to destroy a clone")
 	}
 
+	p.destroyForkOrigin(fsm, session)
+
 	if err := p.freePort(session.Port); err != nil {
 		return errors.Wrap(err, "failed to unbind a port")
 	}
@@ -246,12 +340,23 @@ func (p *Provisioner) ResetSession(session *resources.Session, snapshotID string
 
 	defer func() {
 		if err != nil {
-			p.revertSession(name)
+			p.revertSession(fsm, name)
 		}
 	}()
 
 	appConfig := p.getAppConfig(fsm.Pool(), name, session.Port)
 	appConfig.SetExtraConf(session.ExtraConfig)
+	appConfig.Network = p.cloneNetwork(session.AllowEgress)
+
+	authority := p.getAuthority()
+
+	// The running clone is kept if the certificate cannot be issued.
+	cert, certErr := issueCertificate(authority, name, session.AccessHost)
+	if certErr != nil {
+		return nil, certErr
+	}
+
+	appConfig.TLS = cert
 
 	if err := postgres.Stop(p.runner, fsm.Pool(), name); err != nil {
 		return nil, errors.Wrap(err, "failed to stop container")
@@ -273,20 +378,97 @@ func (p *Provisioner) ResetSession(session *resources.Session, snapshotID string
 		return nil, errors.Wrap(err, "failed to prepare database")
 	}
 
+	// The clone does not depend on the parent clone anymore.
+	p.destroyForkOrigin(fsm, session)
+
+	session.CACert = ""
+
+	if authority != nil {
+		session.CACert = authority.Bundle()
+	}
+
 	snapshotModel := &models.Snapshot{
 		ID:          snapshot.ID,
 		CreatedAt:   util.FormatTime(snapshot.CreatedAt),
 		DataStateAt: util.FormatTime(snapshot.DataStateAt),
+		Status:      snapshot.Status,
+		Pool:        snapshot.Pool,
 	}
 
 	return snapshotModel, nil
 }
 
+// HibernateSession stops the container of an existing session keeping its clone and port.
+func (p *Provisioner) HibernateSession(session *resources.Session) error {
+	fsm, err := p.pm.GetFSManager(session.Pool)
+	if err != nil {
+		return errors.Wrap(err, "failed to find a filesystem manager of this session")
+	}
+
+	appConfig := p.getAppConfig(fsm.Pool(), util.GetCloneName(session.Port), session.Port)
+
+	// Stop Postgres gracefully, so the clone does not need recovery on resume.
+	if _, err := docker.StopContainer(p.runner, appConfig); err != nil {
+		log.Err("Failed to stop the container gracefully: ", err)
+	}
+
+	if err := postgres.Stop(p.runner, fsm.Pool(), appConfig.CloneName); err != nil {
+		return errors.Wrap(err, "failed to stop a container")
+	}
+
+	return nil
+}
+
+// ResumeSession starts the container of a hibernated session.
+func (p *Provisioner) ResumeSession(session *resources.Session) error {
+	fsm, err := p.pm.GetFSManager(session.Pool)
+	if err != nil {
+		return errors.Wrap(err, "failed to find a filesystem manager of this session")
+	}
+
+	return p.startSessionContainer(fsm, session)
+}
+
+// startSessionContainer starts the container of an existing clone with the options of the session.
+func (p *Provisioner) startSessionContainer(fsm pool.FSManager, session *resources.Session) error {
+	name := util.GetCloneName(session.Port)
+
+	appConfig := p.getAppConfig(fsm.Pool(), name, session.Port)
+	appConfig.SetExtraConf(session.ExtraConfig)
+	appConfig.Network = p.cloneNetwork(session.AllowEgress)
+
+	authority := p.getAuthority()
+
+	cert, err := issueCertificate(authority, name, session.AccessHost)
+	if err != nil {
+		return err
+	}
+
+	appConfig.TLS = cert
+
+	if err := postgres.Start(p.runner, appConfig); err != nil {
+		return errors.Wrap(err, "failed to start a container")
+	}
+
+	session.CACert = ""
+
+	if authority != nil {
+		session.CACert = authority.Bundle()
+	}
+
+	return nil
+}
+
 // GetSnapshots provides a snapshot list.
 func (p *Provisioner) GetSnapshots() ([]resources.Snapshot, error) {
 	return p.pm.Active().GetSnapshots()
 }
 
+// DiffSnapshots returns files changed between two snapshots of the active pool.
+func (p *Provisioner) DiffSnapshots(fromSnapshotID, toSnapshotID string) (*resources.SnapshotFilesDiff, error) {
+	return p.pm.Active().DiffSnapshots(fromSnapshotID, toSnapshotID)
+}
+
 // GetDiskState describes the state of the managed disk.
 func (p *Provisioner) GetDiskState() (*resources.Disk, error) {
 	return p.pm.Active().GetDiskState()
@@ -303,14 +485,14 @@ func (p *Provisioner) GetSessionState(s *resources.Session) (*resources.SessionS
 }
 
 // Other methods.
-func (p *Provisioner) revertSession(name string) {
+func (p *Provisioner) revertSession(fsm pool.FSManager, name string) {
 	log.Dbg(`Reverting start of a session...`)
 
-	if runnerErr := postgres.Stop(p.runner, p.pm.Active().Pool(), name); runnerErr != nil {
+	if runnerErr := postgres.Stop(p.runner, fsm.Pool(), name); runnerErr != nil {
 		log.Err(`Revert:`, runnerErr)
 	}
 
-	if runnerErr := p.pm.Active().DestroyClone(name); runnerErr != nil {
+	if runnerErr := fsm.DestroyClone(name); runnerErr != nil {
 		log.Err(`Revert:`, runnerErr)
 	}
 }
@@ -335,7 +517,14 @@ func (p *Provisioner) getSnapshot(snapshotID string) (*resources.Snapshot, error
 		return nil, errors.Errorf("snapshot %q not found", snapshotID)
 	}
 
-	return &snapshots[0], nil
+	// The latest snapshot must pass validation.
+	for _, snapshot := range snapshots {
+		if snapshot.Status != models.SnapshotQuarantined {
+			return &snapshot, nil
+		}
+	}
+
+	return nil, errors.New("no snapshots available: all snapshots are quarantined")
 }
 
 func (p *Provisioner) initPortPool() error {
@@ -491,11 +680,71 @@ func (p *Provisioner) getAppConfig(pool *resources.Pool, name string, port uint)
 		Pool:          *pool,
 		ContainerConf: p.config.ContainerConfig,
 		NetworkID:     p.networkID,
+
+		Neutralization: p.config.Neutralization,
 	}
 
 	return appConfig
 }
 
+// cloneNetwork builds network isolation options of a clone.
+func (p *Provisioner) cloneNetwork(allowEgress []string) resources.CloneNetwork {
+	if !p.config.NetworkIsolation.Enabled {
+		return resources.CloneNetwork{}
+	}
+
+	destinations := make([]string, 0, len(p.config.NetworkIsolation.AllowEgress)+len(allowEgress))
+	destinations = append(destinations, p.config.NetworkIsolation.AllowEgress...)
+	destinations = append(destinations, allowEgress...)
+
+	return resources.CloneNetwork{
+		Isolated:    true,
+		EgressImage: p.config.NetworkIsolation.EgressImage,
+		AllowEgress: destinations,
+	}
+}
+
+func (p *Provisioner) getAuthority() *certs.Authority {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.authority
+}
+
+// issueCertificate issues the server certificate of a clone. No certificate is issued if TLS of clones is disabled.
+func issueCertificate(authority *certs.Authority, cloneName, accessHost string) (*certs.Certificate, error) {
+	if authority == nil {
+		return nil, nil
+	}
+
+	cert, err := authority.Issue(cloneName, accessHost)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to issue a clone certificate")
+	}
+
+	return cert, nil
+}
+
+// ProxyCertificate issues the server certificate of the connection proxy. Nil is returned if TLS of clones is disabled.
+func (p *Provisioner) ProxyCertificate(accessHost string) (*tls.Certificate, error) {
+	authority := p.getAuthority()
+	if authority == nil {
+		return nil, nil
+	}
+
+	cert, err := authority.Issue(proxyCommonName, accessHost)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to issue a proxy certificate")
+	}
+
+	tlsCert, err := tls.X509KeyPair(cert.CertPEM, cert.KeyPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the proxy certificate")
+	}
+
+	return &tlsCert, nil
+}
+
 // LastSessionActivity returns the time of the last session activity.
 func (p *Provisioner) LastSessionActivity(session *resources.Session, minimumTime time.Time) (*time.Time, error) {
 	fsm, err := p.pm.GetFSManager(session.Pool)
@@ -592,9 +841,35 @@ func (p *Provisioner) prepareDB(pgConf *resources.AppConfig, user resources.Ephe
 		}
 	}
 
+	if p.config.Neutralization.IsEnabled() {
+		if err := p.neutralize(pgConf); err != nil {
+			return errors.Wrap(err, "failed to neutralize the clone")
+		}
+	}
+
 	if err := postgres.CreateUser(pgConf, user); err != nil {
 		return errors.Wrap(err, "failed to create user")
 	}
 
 	return nil
 }
+
+// neutralize disables objects of the clone that can reach other systems.
+func (p *Provisioner) neutralize(pgConf *resources.AppConfig) error {
+	querier := func(_ context.Context, dbName, query string) (string, error) {
+		return postgres.Query(pgConf, dbName, query)
+	}
+
+	report, err := neutralization.NewNeutralizer(querier, pgConf.DB.DBName, p.config.Neutralization).Run(p.ctx)
+	if err != nil {
+		return err
+	}
+
+	if !report.IsEmpty() {
+		log.Msg(fmt.Sprintf("Clone %s neutralized: %d subscriptions, %d replication slots, %d cron jobs, %d foreign servers",
+			pgConf.CloneName, len(report.Subscriptions), len(report.ReplicationSlots), len(report.CronJobs),
+			len(report.ForeignServers)))
+	}
+
+	return nil
+}
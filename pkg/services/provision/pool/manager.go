@@ -7,6 +7,7 @@ package pool
 
 import (
 	"fmt"
+	"io"
 	"os/user"
 
 	"github.com/pkg/errors"
@@ -14,6 +15,7 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/runners"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/thinclones"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/thinclones/lvm"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/thinclones/zfs"
 )
@@ -31,6 +33,9 @@ type Cloner interface {
 	CreateClone(name, snapshotID string) error
 	DestroyClone(name string) error
 	ListClonesNames() ([]string, error)
+	CreateCloneSnapshot(cloneName, snapshotName string) (snapshotID string, err error)
+	RollbackCloneSnapshot(cloneName, snapshotName string) error
+	ListCloneSnapshots(cloneName string) ([]resources.Snapshot, error)
 }
 
 // StateReporter describes methods of state reporting.
@@ -41,10 +46,13 @@ type StateReporter interface {
 
 // Snapshotter describes methods of snapshot management.
 type Snapshotter interface {
-	CreateSnapshot(poolSuffix, dataStateAt string) (snapshotName string, err error)
+	CreateSnapshot(poolSuffix, dataStateAt, status string) (snapshotName string, err error)
 	DestroySnapshot(snapshotName string) (err error)
-	CleanupSnapshots(retentionLimit int) ([]string, error)
+	CleanupSnapshots(policy thinclones.RetentionPolicy, dryRun bool) ([]string, error)
 	GetSnapshots() ([]resources.Snapshot, error)
+	ExportSnapshot(w io.Writer, snapshotID, baseSnapshotID string) error
+	ImportSnapshot(r io.Reader, snapshotID, dataStateAt, status string) error
+	DiffSnapshots(fromSnapshotID, toSnapshotID string) (*resources.SnapshotFilesDiff, error)
 }
 
 // Pooler describes methods for Pool providing.
@@ -6,6 +6,9 @@ package resources
 
 import (
 	"path"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/retrieval/engine/postgres/tools/neutralization"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/certs"
 )
 
 // AppConfig currently stores Postgres configuration (other application in the future too).
@@ -17,6 +20,13 @@ type AppConfig struct {
 	Port        uint
 	DB          *DB
 	NetworkID   string
+	Network     CloneNetwork
+
+	// TLS defines the server certificate of the clone. SSL is not enabled if it is nil.
+	TLS *certs.Certificate
+
+	// Neutralization defines objects of the clone to neutralize. Their activity is disabled before Postgres starts.
+	Neutralization neutralization.Options
 
 	ContainerConf map[string]string
 	pgExtraConf   map[string]string
@@ -0,0 +1,101 @@
+/*
+2021 © Postgres.ai
+*/
+
+package resources
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+var hostnameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9.-]*[a-zA-Z0-9])?$`)
+
+// CloneNetwork describes network isolation of a clone.
+type CloneNetwork struct {
+	Isolated    bool
+	EgressImage string
+	AllowEgress []string
+}
+
+// EgressRule defines a destination that an isolated clone is allowed to connect to.
+// Either Network or Host is defined. Zero port allows all ports of the destination.
+type EgressRule struct {
+	Network *net.IPNet
+	Host    string
+	Port    uint16
+}
+
+// ParseEgressRule parses an allowed destination: an IP address, a CIDR or a hostname with an optional port,
+// e.g. "10.0.0.0/8", "192.168.1.10:5432", "[2001:db8::1]:443" or "api.example.com:443".
+func ParseEgressRule(value string) (EgressRule, error) {
+	if rule, ok := parseEgressDestination(value); ok {
+		return rule, nil
+	}
+
+	host, portValue, err := net.SplitHostPort(value)
+	if err != nil {
+		return EgressRule{}, errors.Errorf("invalid egress destination %q", value)
+	}
+
+	rule, ok := parseEgressDestination(host)
+	if !ok {
+		return EgressRule{}, errors.Errorf("invalid egress destination %q", value)
+	}
+
+	port, err := strconv.ParseUint(portValue, 10, 16)
+	if err != nil || port == 0 {
+		return EgressRule{}, errors.Errorf("invalid port of the egress destination %q", value)
+	}
+
+	rule.Port = uint16(port)
+
+	return rule, nil
+}
+
+func parseEgressDestination(value string) (EgressRule, bool) {
+	if ip := net.ParseIP(value); ip != nil {
+		return EgressRule{Network: hostNetwork(ip)}, true
+	}
+
+	if _, ipNet, err := net.ParseCIDR(value); err == nil {
+		return EgressRule{Network: ipNet}, true
+	}
+
+	if hostnameRegexp.MatchString(value) {
+		return EgressRule{Host: value}, true
+	}
+
+	return EgressRule{}, false
+}
+
+func hostNetwork(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(net.IPv4len*8, net.IPv4len*8)}
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(net.IPv6len*8, net.IPv6len*8)}
+}
+
+// ResolveNetworks resolves the hostname of the rule to networks of its addresses.
+func (r EgressRule) ResolveNetworks() ([]*net.IPNet, error) {
+	if r.Network != nil {
+		return []*net.IPNet{r.Network}, nil
+	}
+
+	ips, err := net.LookupIP(r.Host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve %q", r.Host)
+	}
+
+	networks := make([]*net.IPNet, 0, len(ips))
+
+	for _, ip := range ips {
+		networks = append(networks, hostNetwork(ip))
+	}
+
+	return networks, nil
+}
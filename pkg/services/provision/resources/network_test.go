@@ -0,0 +1,56 @@
+package resources
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEgressRule(t *testing.T) {
+	testCases := []struct {
+		value   string
+		network string
+		host    string
+		port    uint16
+	}{
+		{value: "10.0.0.5", network: "10.0.0.5/32"},
+		{value: "10.0.0.0/8", network: "10.0.0.0/8"},
+		{value: "10.0.0.5:5432", network: "10.0.0.5/32", port: 5432},
+		{value: "10.0.0.0/8:5432", network: "10.0.0.0/8", port: 5432},
+		{value: "2001:db8::1", network: "2001:db8::1/128"},
+		{value: "[2001:db8::1]:443", network: "2001:db8::1/128", port: 443},
+		{value: "api.example.com", host: "api.example.com"},
+		{value: "api.example.com:443", host: "api.example.com", port: 443},
+	}
+
+	for _, tc := range testCases {
+		rule, err := ParseEgressRule(tc.value)
+		require.NoError(t, err, tc.value)
+
+		if tc.network != "" {
+			require.NotNil(t, rule.Network, tc.value)
+			assert.Equal(t, tc.network, rule.Network.String(), tc.value)
+		}
+
+		assert.Equal(t, tc.host, rule.Host, tc.value)
+		assert.Equal(t, tc.port, rule.Port, tc.value)
+	}
+}
+
+func TestParseEgressRuleErrors(t *testing.T) {
+	for _, value := range []string{"", "-host", "10.0.0.5:0", "10.0.0.5:70000", "host name:80", "10.0.0.0/33"} {
+		_, err := ParseEgressRule(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestResolveNetworksOfNetworkRule(t *testing.T) {
+	_, ipNet, err := net.ParseCIDR("192.168.0.0/16")
+	require.NoError(t, err)
+
+	networks, err := EgressRule{Network: ipNet}.ResolveNetworks()
+	require.NoError(t, err)
+	assert.Equal(t, []*net.IPNet{ipNet}, networks)
+}
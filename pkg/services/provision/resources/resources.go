@@ -20,6 +20,14 @@ type Session struct {
 	SocketHost    string
 	EphemeralUser EphemeralUser
 	ExtraConfig   map[string]string
+	AllowEgress   []string
+	AccessHost    string
+
+	// CACert contains the PEM-encoded CA certificate if connections to the clone are encrypted.
+	CACert string
+
+	// ForkOrigin contains the ID of the snapshot of the parent clone if the clone is a fork.
+	ForkOrigin string
 }
 
 // Disk defines disk status.
@@ -45,8 +53,47 @@ type Snapshot struct {
 	ID          string
 	CreatedAt   time.Time
 	DataStateAt time.Time
+	Status      string
+	Pool        string
+}
+
+// Savepoint defines a snapshot of a clone which the clone can be reverted to.
+type Savepoint struct {
+	Name      string
+	CreatedAt time.Time
+}
+
+// ExportOptions defines how the data of a clone is exported.
+type ExportOptions struct {
+	Format string
+	DBName string
+	Jobs   uint
 }
 
+// SnapshotFilesDiff defines files changed between two snapshots.
+// The compared snapshots may differ from the requested ones if the requested snapshots cannot be compared directly.
+type SnapshotFilesDiff struct {
+	From  string
+	To    string
+	Files []FileDiff
+}
+
+// FileDiff defines a regular file changed between two snapshots.
+// Path is relative to the data directory, sizes are zero if the file does not exist in a snapshot.
+type FileDiff struct {
+	Path     string
+	Change   string
+	FromSize int64
+	ToSize   int64
+}
+
+// Changes of files between snapshots.
+const (
+	FileCreated  = "created"
+	FileModified = "modified"
+	FileRemoved  = "removed"
+)
+
 // SessionState defines current state of a Session.
 type SessionState struct {
 	CloneDiffSize uint64
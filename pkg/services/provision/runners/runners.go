@@ -8,6 +8,7 @@ package runners
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
@@ -34,6 +35,11 @@ type Runner interface {
 	Run(string, ...bool) (string, error)
 }
 
+// StreamRunner runs commands streaming their input and output instead of buffering them.
+type StreamRunner interface {
+	Stream(command string, stdin io.Reader, stdout io.Writer) error
+}
+
 type RunnerError struct {
 	Msg        string
 	ExitStatus int
@@ -154,6 +160,39 @@ func (r *LocalRunner) Run(command string, options ...bool) (string, error) {
 	return outFormatted, nil
 }
 
+// Stream runs the command passing stdin to the command input and writing the command output to stdout.
+// A nil stdin means the command gets no input.
+func (r *LocalRunner) Stream(command string, stdin io.Reader, stdout io.Writer) error {
+	command = strings.Trim(command, " \n")
+	if len(command) == 0 {
+		return errors.New("empty command")
+	}
+
+	if runtime.GOOS == "windows" {
+		return errors.New("Windows is not supported")
+	}
+
+	log.Dbg(fmt.Sprintf(`Stream(Local): "%s"`, command))
+
+	if r.UseSudo && !strings.HasPrefix(command, sudoCmd+" ") {
+		command = fmt.Sprintf("%s %s %s", sudoCmd, sudoParams, command)
+	}
+
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("/bin/bash", "-c", command)
+
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return NewRunnerError(command, stderr.String(), err)
+	}
+
+	return nil
+}
+
 // Utils.
 func parseOptions(options ...bool) bool {
 	logsEnabled := LogsEnabledDefault
@@ -0,0 +1,102 @@
+/*
+2021 © Postgres.ai
+*/
+
+package provision
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/databases/postgres"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/util"
+)
+
+// savepointPrefix distinguishes savepoints from other snapshots of clones.
+const savepointPrefix = "savepoint_"
+
+// CreateSavepoint makes a checkpoint in the clone of the session and snapshots the clone,
+// so the recovery is short when the clone is reverted to the savepoint.
+func (p *Provisioner) CreateSavepoint(session *resources.Session, name string) (*resources.Savepoint, error) {
+	fsm, err := p.pm.GetFSManager(session.Pool)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find a filesystem manager of this session")
+	}
+
+	cloneName := util.GetCloneName(session.Port)
+	appConfig := p.getAppConfig(fsm.Pool(), cloneName, session.Port)
+
+	if _, err := postgres.Query(appConfig, appConfig.DB.DBName, "checkpoint"); err != nil {
+		return nil, errors.Wrap(err, "failed to make a checkpoint")
+	}
+
+	if _, err := fsm.CreateCloneSnapshot(cloneName, savepointPrefix+name); err != nil {
+		return nil, errors.Wrap(err, "failed to create a savepoint")
+	}
+
+	return &resources.Savepoint{Name: name, CreatedAt: time.Now()}, nil
+}
+
+// ListSavepoints lists savepoints of the clone of the session ordered from the latest one.
+func (p *Provisioner) ListSavepoints(session *resources.Session) ([]resources.Savepoint, error) {
+	fsm, err := p.pm.GetFSManager(session.Pool)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find a filesystem manager of this session")
+	}
+
+	snapshots, err := fsm.ListCloneSnapshots(util.GetCloneName(session.Port))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list savepoints")
+	}
+
+	return filterSavepoints(snapshots), nil
+}
+
+// filterSavepoints finds savepoints among snapshots of a clone.
+func filterSavepoints(snapshots []resources.Snapshot) []resources.Savepoint {
+	savepoints := []resources.Savepoint{}
+
+	for _, snapshot := range snapshots {
+		separatorIndex := strings.LastIndex(snapshot.ID, "@"+savepointPrefix)
+		if separatorIndex == -1 {
+			continue
+		}
+
+		savepoints = append(savepoints, resources.Savepoint{
+			Name:      snapshot.ID[separatorIndex+len(savepointPrefix)+1:],
+			CreatedAt: snapshot.CreatedAt,
+		})
+	}
+
+	return savepoints
+}
+
+// RevertSavepoint rolls the clone of the session back to the savepoint and restarts its container.
+// Savepoints created after the savepoint are destroyed.
+func (p *Provisioner) RevertSavepoint(session *resources.Session, name string) error {
+	fsm, err := p.pm.GetFSManager(session.Pool)
+	if err != nil {
+		return errors.Wrap(err, "failed to find a filesystem manager of this session")
+	}
+
+	cloneName := util.GetCloneName(session.Port)
+
+	// The current state of the clone is discarded, so there is no need to stop Postgres gracefully.
+	if err := postgres.Stop(p.runner, fsm.Pool(), cloneName); err != nil {
+		return errors.Wrap(err, "failed to stop a container")
+	}
+
+	if err := fsm.RollbackCloneSnapshot(cloneName, savepointPrefix+name); err != nil {
+		if startErr := p.startSessionContainer(fsm, session); startErr != nil {
+			log.Err("Failed to start the container of the clone after a failed revert: ", startErr)
+		}
+
+		return errors.Wrap(err, "failed to revert the clone to the savepoint")
+	}
+
+	return p.startSessionContainer(fsm, session)
+}
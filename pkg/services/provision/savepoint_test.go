@@ -0,0 +1,27 @@
+package provision
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+)
+
+func TestFilterSavepoints(t *testing.T) {
+	createdAt := time.Date(2021, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	snapshots := []resources.Snapshot{
+		{ID: "dblab_pool/dblab_clone_6000@savepoint_before_migration", CreatedAt: createdAt},
+		{ID: "dblab_pool/dblab_clone_6000@fork_20211019120000", CreatedAt: createdAt},
+		{ID: "dblab_pool/dblab_clone_6000@savepoint_20211019115000", CreatedAt: createdAt.Add(-10 * time.Minute)},
+	}
+
+	assert.Equal(t, []resources.Savepoint{
+		{Name: "before_migration", CreatedAt: createdAt},
+		{Name: "20211019115000", CreatedAt: createdAt.Add(-10 * time.Minute)},
+	}, filterSavepoints(snapshots))
+
+	assert.Equal(t, []resources.Savepoint{}, filterSavepoints(nil))
+}
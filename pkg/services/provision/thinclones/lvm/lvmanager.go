@@ -5,6 +5,7 @@
 package lvm
 
 import (
+	"io"
 	"strings"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/runners"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/thinclones"
 )
 
 const (
@@ -84,8 +86,23 @@ func (m *LVManager) parsePool() error {
 	return nil
 }
 
+// CreateCloneSnapshot is not supported in LVM mode.
+func (m *LVManager) CreateCloneSnapshot(_, _ string) (string, error) {
+	return "", errors.New("snapshots of clones are not supported in LVM mode")
+}
+
+// RollbackCloneSnapshot is not supported in LVM mode.
+func (m *LVManager) RollbackCloneSnapshot(_, _ string) error {
+	return errors.New("snapshots of clones are not supported in LVM mode")
+}
+
+// ListCloneSnapshots is not supported in LVM mode.
+func (m *LVManager) ListCloneSnapshots(_ string) ([]resources.Snapshot, error) {
+	return nil, errors.New("snapshots of clones are not supported in LVM mode")
+}
+
 // CreateSnapshot is not supported in LVM mode.
-func (m *LVManager) CreateSnapshot(_, _ string) (string, error) {
+func (m *LVManager) CreateSnapshot(_, _, _ string) (string, error) {
 	log.Msg("Creating a snapshot is not supported in LVM mode. Skip the operation.")
 
 	return "", nil
@@ -99,12 +116,27 @@ func (m *LVManager) DestroySnapshot(_ string) error {
 }
 
 // CleanupSnapshots is not supported in LVM mode.
-func (m *LVManager) CleanupSnapshots(_ int) ([]string, error) {
+func (m *LVManager) CleanupSnapshots(_ thinclones.RetentionPolicy, _ bool) ([]string, error) {
 	log.Msg("Cleanup snapshots is not supported in LVM mode. Skip the operation.")
 
 	return nil, nil
 }
 
+// ExportSnapshot is not supported in LVM mode.
+func (m *LVManager) ExportSnapshot(_ io.Writer, _, _ string) error {
+	return errors.New("exporting snapshots is not supported in LVM mode")
+}
+
+// ImportSnapshot is not supported in LVM mode.
+func (m *LVManager) ImportSnapshot(_ io.Reader, _, _, _ string) error {
+	return errors.New("importing snapshots is not supported in LVM mode")
+}
+
+// DiffSnapshots is not supported in LVM mode.
+func (m *LVManager) DiffSnapshots(_, _ string) (*resources.SnapshotFilesDiff, error) {
+	return nil, errors.New("comparing snapshots is not supported in LVM mode")
+}
+
 // GetSnapshots is not implemented.
 func (m *LVManager) GetSnapshots() ([]resources.Snapshot, error) {
 	// TODO(anatoly): Not supported in LVM mode warning.
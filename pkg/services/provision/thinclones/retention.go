@@ -0,0 +1,164 @@
+/*
+2021 © Postgres.ai
+*/
+
+package thinclones
+
+import (
+	"sort"
+	"time"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+)
+
+// RetentionPolicy defines which snapshots are kept during cleanup.
+//
+// Limit keeps the newest snapshots regardless of their age. Hourly, Daily, Weekly and Monthly define
+// grandfather-father-son rules: the newest snapshot of each hour, day, ISO week or month is kept for the given
+// number of periods counting back from the newest snapshot. Periods are evaluated against dataStateAt in UTC.
+type RetentionPolicy struct {
+	Limit   int
+	Hourly  int
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+// RetentionItem describes a snapshot evaluated by a retention policy.
+type RetentionItem struct {
+	ID          string
+	DataStateAt time.Time
+	Status      string
+}
+
+type retentionRule struct {
+	periods int
+	bucket  func(time.Time) time.Time
+	back    func(t time.Time, periods int) time.Time
+}
+
+// IsDefined checks if the policy keeps anything. An undefined policy does not destroy snapshots.
+func (p RetentionPolicy) IsDefined() bool {
+	return p.Limit > 0 || p.Hourly > 0 || p.Daily > 0 || p.Weekly > 0 || p.Monthly > 0
+}
+
+// Expired returns snapshots that are not kept by the policy ordered from the oldest one.
+// The newest ready snapshot and busy snapshots are always kept. Quarantined snapshots are not counted by the policy
+// and expire first.
+func (p RetentionPolicy) Expired(items []RetentionItem, busy []string) []RetentionItem {
+	if !p.IsDefined() || len(items) == 0 {
+		return nil
+	}
+
+	sorted := make([]RetentionItem, len(items))
+	copy(sorted, items)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].DataStateAt.After(sorted[j].DataStateAt)
+	})
+
+	keep := make(map[string]struct{}, len(sorted))
+
+	for _, id := range busy {
+		keep[id] = struct{}{}
+	}
+
+	ready := make([]RetentionItem, 0, len(sorted))
+
+	for _, item := range sorted {
+		if item.Status != models.SnapshotQuarantined {
+			ready = append(ready, item)
+		}
+	}
+
+	if len(ready) > 0 {
+		p.keepReady(ready, keep)
+	}
+
+	expired := []RetentionItem{}
+	quarantined := []RetentionItem{}
+
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if _, ok := keep[sorted[i].ID]; ok {
+			continue
+		}
+
+		if sorted[i].Status == models.SnapshotQuarantined {
+			quarantined = append(quarantined, sorted[i])
+			continue
+		}
+
+		expired = append(expired, sorted[i])
+	}
+
+	return append(quarantined, expired...)
+}
+
+// keepReady marks snapshots kept by the limit and the period rules. Snapshots must be ordered from the newest one.
+func (p RetentionPolicy) keepReady(sorted []RetentionItem, keep map[string]struct{}) {
+	for i := 0; i < len(sorted) && (i == 0 || i < p.Limit); i++ {
+		keep[sorted[i].ID] = struct{}{}
+	}
+
+	latest := sorted[0].DataStateAt.UTC()
+
+	for _, rule := range p.rules() {
+		if rule.periods <= 0 {
+			continue
+		}
+
+		oldestBucket := rule.back(rule.bucket(latest), rule.periods-1)
+		seen := make(map[time.Time]struct{}, rule.periods)
+
+		for _, item := range sorted {
+			bucket := rule.bucket(item.DataStateAt.UTC())
+
+			if bucket.Before(oldestBucket) {
+				break
+			}
+
+			if _, ok := seen[bucket]; ok {
+				continue
+			}
+
+			seen[bucket] = struct{}{}
+			keep[item.ID] = struct{}{}
+		}
+	}
+}
+
+func (p RetentionPolicy) rules() []retentionRule {
+	return []retentionRule{
+		{
+			periods: p.Hourly,
+			bucket:  func(t time.Time) time.Time { return t.Truncate(time.Hour) },
+			back:    func(t time.Time, n int) time.Time { return t.Add(-time.Duration(n) * time.Hour) },
+		},
+		{
+			periods: p.Daily,
+			bucket:  startOfDay,
+			back:    func(t time.Time, n int) time.Time { return t.AddDate(0, 0, -n) },
+		},
+		{
+			periods: p.Weekly,
+			bucket:  startOfISOWeek,
+			back:    func(t time.Time, n int) time.Time { return t.AddDate(0, 0, -7*n) },
+		},
+		{
+			periods: p.Monthly,
+			bucket:  func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC) },
+			back:    func(t time.Time, n int) time.Time { return t.AddDate(0, -n, 0) },
+		},
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// startOfISOWeek returns the Monday of the ISO week.
+func startOfISOWeek(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+
+	return startOfDay(t).AddDate(0, 0, -daysSinceMonday)
+}
@@ -0,0 +1,157 @@
+package thinclones
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+)
+
+func retentionIDs(items []RetentionItem) []string {
+	ids := []string{}
+
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+
+	return ids
+}
+
+func TestRetentionPolicyExpired(t *testing.T) {
+	items := []RetentionItem{
+		{ID: "h0", DataStateAt: time.Date(2021, 3, 10, 12, 30, 0, 0, time.UTC)},
+		{ID: "h0-old", DataStateAt: time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)},
+		{ID: "h1", DataStateAt: time.Date(2021, 3, 10, 11, 30, 0, 0, time.UTC)},
+		{ID: "h3", DataStateAt: time.Date(2021, 3, 10, 9, 15, 0, 0, time.UTC)},
+		{ID: "d1", DataStateAt: time.Date(2021, 3, 9, 23, 0, 0, 0, time.UTC)},
+		{ID: "d1-old", DataStateAt: time.Date(2021, 3, 9, 1, 0, 0, 0, time.UTC)},
+		{ID: "d3", DataStateAt: time.Date(2021, 3, 7, 10, 0, 0, 0, time.UTC)},
+		{ID: "w2", DataStateAt: time.Date(2021, 2, 24, 10, 0, 0, 0, time.UTC)},
+		{ID: "m1", DataStateAt: time.Date(2021, 2, 1, 10, 0, 0, 0, time.UTC)},
+		{ID: "m4", DataStateAt: time.Date(2020, 11, 15, 10, 0, 0, 0, time.UTC)},
+	}
+
+	testCases := []struct {
+		name    string
+		policy  RetentionPolicy
+		busy    []string
+		expired []string
+	}{
+		{
+			name:    "undefined policy",
+			policy:  RetentionPolicy{},
+			expired: nil,
+		},
+		{
+			name:    "limit",
+			policy:  RetentionPolicy{Limit: 8},
+			expired: []string{"m4", "m1"},
+		},
+		{
+			name:    "hourly",
+			policy:  RetentionPolicy{Hourly: 3},
+			expired: []string{"m4", "m1", "w2", "d3", "d1-old", "d1", "h3", "h0-old"},
+		},
+		{
+			name:    "daily",
+			policy:  RetentionPolicy{Daily: 2},
+			expired: []string{"m4", "m1", "w2", "d3", "d1-old", "h3", "h1", "h0-old"},
+		},
+		{
+			name:    "weekly",
+			policy:  RetentionPolicy{Weekly: 3},
+			expired: []string{"m4", "m1", "d1-old", "d1", "h3", "h1", "h0-old"},
+		},
+		{
+			name:    "monthly",
+			policy:  RetentionPolicy{Monthly: 3},
+			expired: []string{"m4", "m1", "d3", "d1-old", "d1", "h3", "h1", "h0-old"},
+		},
+		{
+			name:    "combined with busy snapshots",
+			policy:  RetentionPolicy{Hourly: 2, Daily: 2, Monthly: 2},
+			busy:    []string{"m4", "h3"},
+			expired: []string{"m1", "d3", "d1-old", "h0-old"},
+		},
+		{
+			name:    "newest snapshot is always kept",
+			policy:  RetentionPolicy{Limit: 1, Hourly: 1},
+			busy:    []string{"unknown"},
+			expired: []string{"m4", "m1", "w2", "d3", "d1-old", "d1", "h3", "h1", "h0-old"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expired := tc.policy.Expired(items, tc.busy)
+
+			if tc.expired == nil {
+				assert.Nil(t, expired)
+				return
+			}
+
+			assert.Equal(t, tc.expired, retentionIDs(expired))
+		})
+	}
+}
+
+func TestRetentionPolicyExpiredQuarantined(t *testing.T) {
+	items := []RetentionItem{
+		{ID: "h0", DataStateAt: time.Date(2021, 3, 10, 12, 30, 0, 0, time.UTC), Status: models.SnapshotQuarantined},
+		{ID: "h1", DataStateAt: time.Date(2021, 3, 10, 11, 30, 0, 0, time.UTC), Status: models.SnapshotReady},
+		{ID: "h2", DataStateAt: time.Date(2021, 3, 10, 10, 30, 0, 0, time.UTC), Status: models.SnapshotQuarantined},
+		{ID: "h3", DataStateAt: time.Date(2021, 3, 10, 9, 30, 0, 0, time.UTC), Status: models.SnapshotNotValidated},
+		{ID: "d1", DataStateAt: time.Date(2021, 3, 9, 9, 30, 0, 0, time.UTC), Status: models.SnapshotLagging},
+	}
+
+	testCases := []struct {
+		name    string
+		policy  RetentionPolicy
+		busy    []string
+		expired []string
+	}{
+		{
+			name:    "limit keeps the newest ready snapshot",
+			policy:  RetentionPolicy{Limit: 1},
+			expired: []string{"h2", "h0", "d1", "h3"},
+		},
+		{
+			name:    "quarantined snapshots do not fill the limit",
+			policy:  RetentionPolicy{Limit: 2},
+			expired: []string{"h2", "h0", "d1"},
+		},
+		{
+			name:    "quarantined snapshots do not fill period buckets",
+			policy:  RetentionPolicy{Hourly: 4, Daily: 2},
+			expired: []string{"h2", "h0"},
+		},
+		{
+			name:    "busy quarantined snapshots are kept",
+			policy:  RetentionPolicy{Limit: 1},
+			busy:    []string{"h0"},
+			expired: []string{"h2", "d1", "h3"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expired := tc.policy.Expired(items, tc.busy)
+
+			if tc.expired == nil {
+				assert.Nil(t, expired)
+				return
+			}
+
+			assert.Equal(t, tc.expired, retentionIDs(expired))
+		})
+	}
+}
+
+func TestStartOfISOWeek(t *testing.T) {
+	assert.Equal(t, time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC),
+		startOfISOWeek(time.Date(2021, 3, 14, 23, 59, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC),
+		startOfISOWeek(time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC)))
+}
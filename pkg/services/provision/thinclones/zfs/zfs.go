@@ -7,7 +7,10 @@ package zfs
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -16,8 +19,10 @@ import (
 	"github.com/pkg/errors"
 
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/runners"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/thinclones"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/util"
 )
 
@@ -25,6 +30,7 @@ const (
 	headerOffset        = 1
 	dataStateAtLabel    = "dblab:datastateat"
 	isRoughStateAtLabel = "dblab:isroughdsa"
+	statusLabel         = "dblab:status"
 )
 
 // ListEntry defines entry of ZFS list command.
@@ -99,6 +105,9 @@ type ListEntry struct {
 
 	// Data state timestamp.
 	DataStateAt time.Time
+
+	// Snapshot validation status.
+	Status string
 }
 
 type setFunc func(s string) error
@@ -242,8 +251,68 @@ func (m *Manager) ListClonesNames() ([]string, error) {
 	return util.Unique(cloneNames), nil
 }
 
-// CreateSnapshot creates a new snapshot.
-func (m *Manager) CreateSnapshot(poolSuffix, dataStateAt string) (string, error) {
+// CreateCloneSnapshot creates a snapshot of the clone dataset and returns its ID.
+func (m *Manager) CreateCloneSnapshot(cloneName, snapshotName string) (string, error) {
+	snapshotID := m.cloneSnapshotID(cloneName, snapshotName)
+
+	if err := m.checkSnapshotName(snapshotID); err != nil {
+		return "", err
+	}
+
+	if _, err := m.runner.Run("zfs snapshot "+snapshotID, true); err != nil {
+		return "", errors.Wrap(err, "failed to create a clone snapshot")
+	}
+
+	return snapshotID, nil
+}
+
+// RollbackCloneSnapshot rolls the clone dataset back to its snapshot. Later snapshots of the clone are destroyed.
+func (m *Manager) RollbackCloneSnapshot(cloneName, snapshotName string) error {
+	snapshotID := m.cloneSnapshotID(cloneName, snapshotName)
+
+	if err := m.checkSnapshotName(snapshotID); err != nil {
+		return err
+	}
+
+	if _, err := m.runner.Run("zfs rollback -r "+snapshotID, true); err != nil {
+		return errors.Wrap(err, "failed to rollback a clone snapshot")
+	}
+
+	return nil
+}
+
+// ListCloneSnapshots lists snapshots of the clone dataset ordered from the latest one.
+func (m *Manager) ListCloneSnapshots(cloneName string) ([]resources.Snapshot, error) {
+	entries, err := m.listSnapshots(m.config.Pool.Name + "/" + cloneName)
+	if err != nil {
+		if _, ok := errors.Cause(err).(*EmptyPoolError); ok {
+			return []resources.Snapshot{}, nil
+		}
+
+		return nil, errors.Wrap(err, "failed to list clone snapshots")
+	}
+
+	snapshots := make([]resources.Snapshot, 0, len(entries))
+
+	for _, entry := range entries {
+		snapshots = append(snapshots, resources.Snapshot{
+			ID:        entry.Name,
+			CreatedAt: entry.Creation,
+			Pool:      m.config.Pool.Name,
+		})
+	}
+
+	return snapshots, nil
+}
+
+// cloneSnapshotID builds the ID of a clone snapshot.
+func (m *Manager) cloneSnapshotID(cloneName, snapshotName string) string {
+	return m.config.Pool.Name + "/" + cloneName + "@" + snapshotName
+}
+
+// CreateSnapshot creates a new snapshot. Its properties are set atomically, so the snapshot is never listed without them.
+// The status is not set if it is empty.
+func (m *Manager) CreateSnapshot(poolSuffix, dataStateAt, status string) (string, error) {
 	poolName := m.config.Pool.Name
 
 	if poolSuffix != "" {
@@ -257,27 +326,248 @@ func (m *Manager) CreateSnapshot(poolSuffix, dataStateAt string) (string, error)
 	}
 
 	snapshotName := getSnapshotName(poolName, dataStateAt)
-	cmd := fmt.Sprintf("zfs snapshot -r %s", snapshotName)
+	cmd := fmt.Sprintf("zfs snapshot -r -o %s=%q", dataStateAtLabel, strings.TrimSuffix(dataStateAt, m.config.PreSnapshotSuffix))
+
+	if originalDSA == "" {
+		cmd += fmt.Sprintf(" -o %s=%q", isRoughStateAtLabel, "1")
+	}
 
-	if _, err := m.runner.Run(cmd, true); err != nil {
+	if status != "" {
+		cmd += fmt.Sprintf(" -o %s=%q", statusLabel, status)
+	}
+
+	if _, err := m.runner.Run(cmd+" "+snapshotName, true); err != nil {
 		return "", errors.Wrap(err, "failed to create snapshot")
 	}
 
-	cmd = fmt.Sprintf("zfs set %s=%q %s", dataStateAtLabel, strings.TrimSuffix(dataStateAt, m.config.PreSnapshotSuffix), snapshotName)
+	return snapshotName, nil
+}
 
-	if _, err := m.runner.Run(cmd, true); err != nil {
-		return "", errors.Wrap(err, "failed to set the dataStateAt option for snapshot")
+// ExportSnapshot writes a replication stream of the snapshot.
+// The stream is incremental if the base snapshot is defined: it must be an earlier snapshot of the same dataset.
+func (m *Manager) ExportSnapshot(w io.Writer, snapshotID, baseSnapshotID string) error {
+	streamRunner, ok := m.runner.(runners.StreamRunner)
+	if !ok {
+		return errors.New("the runner does not support streaming")
 	}
 
-	if originalDSA == "" {
-		cmd = fmt.Sprintf("zfs set %s=%q %s", isRoughStateAtLabel, "1", snapshotName)
+	if err := m.checkSnapshotName(snapshotID); err != nil {
+		return err
+	}
 
-		if _, err := m.runner.Run(cmd, true); err != nil {
-			return "", errors.Wrap(err, "failed to set the rough flag of dataStateAt option for snapshot")
+	cmd := "zfs send "
+
+	if baseSnapshotID != "" {
+		if err := m.checkSnapshotName(baseSnapshotID); err != nil {
+			return err
 		}
+
+		cmd += "-i " + baseSnapshotID + " "
 	}
 
-	return snapshotName, nil
+	if err := streamRunner.Stream(cmd+snapshotID, nil, w); err != nil {
+		return errors.Wrap(err, "failed to send snapshot")
+	}
+
+	return nil
+}
+
+// ImportSnapshot receives a replication stream as the snapshot and sets its dataStateAt and status.
+// The received dataset is not mounted. The properties are set on the received dataset atomically, so the snapshot
+// inherits them once it appears, then they are set on the snapshot itself, so later imports into the dataset do not change them.
+// The snapshot is destroyed if its properties cannot be set.
+func (m *Manager) ImportSnapshot(r io.Reader, snapshotID, dataStateAt, status string) error {
+	streamRunner, ok := m.runner.(runners.StreamRunner)
+	if !ok {
+		return errors.New("the runner does not support streaming")
+	}
+
+	if err := m.checkSnapshotName(snapshotID); err != nil {
+		return err
+	}
+
+	properties := fmt.Sprintf("%s=%q %s=%q", dataStateAtLabel, dataStateAt, statusLabel, status)
+	receiveCmd := fmt.Sprintf("zfs receive -u -o %s=%q -o %s=%q %s", dataStateAtLabel, dataStateAt, statusLabel, status, snapshotID)
+
+	if err := streamRunner.Stream(receiveCmd, r, io.Discard); err != nil {
+		return errors.Wrap(err, "failed to receive snapshot")
+	}
+
+	if _, err := m.runner.Run(fmt.Sprintf("zfs set %s %s", properties, snapshotID), true); err != nil {
+		if destroyErr := m.DestroySnapshot(snapshotID); destroyErr != nil {
+			log.Err(fmt.Sprintf("Failed to destroy the snapshot %q: %v", snapshotID, destroyErr))
+		}
+
+		return errors.Wrap(err, "failed to set properties of the imported snapshot")
+	}
+
+	return nil
+}
+
+// DiffSnapshots returns regular files of the data directory changed between two snapshots with their sizes in both snapshots.
+// Snapshots of different datasets are compared through the origins of their datasets, the compared snapshots are returned.
+func (m *Manager) DiffSnapshots(fromSnapshotID, toSnapshotID string) (*resources.SnapshotFilesDiff, error) {
+	if err := m.checkSnapshotName(fromSnapshotID); err != nil {
+		return nil, err
+	}
+
+	if err := m.checkSnapshotName(toSnapshotID); err != nil {
+		return nil, err
+	}
+
+	filesystems, err := m.listFilesystems(m.config.Pool.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list filesystems")
+	}
+
+	datasets := make(map[string]*ListEntry, len(filesystems))
+
+	for _, entry := range filesystems {
+		datasets[entry.Name] = entry
+	}
+
+	fromSnapshot, toSnapshot, err := resolveDiffSnapshots(fromSnapshotID, toSnapshotID, datasets)
+	if err != nil {
+		return nil, err
+	}
+
+	fromDataset, ok := datasets[snapshotDataset(fromSnapshot)]
+	if !ok {
+		return nil, errors.Errorf("dataset of snapshot %s not found", fromSnapshot)
+	}
+
+	toDataset, ok := datasets[snapshotDataset(toSnapshot)]
+	if !ok {
+		return nil, errors.Errorf("dataset of snapshot %s not found", toSnapshot)
+	}
+
+	out, err := m.runner.Run(fmt.Sprintf("zfs diff -H -F %s %s", fromSnapshot, toSnapshot), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compare snapshots")
+	}
+
+	// Paths are reported relative to the mount point of the newer dataset.
+	diffs := parseDiffOutput(out, path.Join(toDataset.MountPoint, m.config.Pool.DataSubDir))
+
+	for i := range diffs {
+		filePath := path.Join(m.config.Pool.DataSubDir, diffs[i].Path)
+
+		if diffs[i].Change != resources.FileCreated {
+			diffs[i].FromSize = snapshotFileSize(fromDataset.MountPoint, fromSnapshot, filePath)
+		}
+
+		if diffs[i].Change != resources.FileRemoved {
+			diffs[i].ToSize = snapshotFileSize(toDataset.MountPoint, toSnapshot, filePath)
+		}
+	}
+
+	return &resources.SnapshotFilesDiff{From: fromSnapshot, To: toSnapshot, Files: diffs}, nil
+}
+
+// resolveDiffSnapshots returns snapshots of the same dataset that can be compared by "zfs diff".
+// A snapshot of a clone can be compared with the origin of the clone. Otherwise, snapshots of different datasets
+// are replaced with the origins of their datasets, so snapshots of clones are compared through the snapshots they are taken from.
+func resolveDiffSnapshots(fromSnapshot, toSnapshot string, datasets map[string]*ListEntry) (string, string, error) {
+	origin := func(snapshot string) string {
+		if entry, ok := datasets[snapshotDataset(snapshot)]; ok && entry.Origin != "" && entry.Origin != "-" {
+			return entry.Origin
+		}
+
+		return snapshot
+	}
+
+	if snapshotDataset(fromSnapshot) == snapshotDataset(toSnapshot) || origin(toSnapshot) == fromSnapshot {
+		return fromSnapshot, toSnapshot, nil
+	}
+
+	candidates := [][2]string{
+		{origin(fromSnapshot), toSnapshot},
+		{fromSnapshot, origin(toSnapshot)},
+		{origin(fromSnapshot), origin(toSnapshot)},
+	}
+
+	for _, candidate := range candidates {
+		if candidate[0] != candidate[1] && snapshotDataset(candidate[0]) == snapshotDataset(candidate[1]) {
+			return candidate[0], candidate[1], nil
+		}
+	}
+
+	return "", "", errors.Errorf("snapshots %s and %s cannot be compared: they do not share a dataset", fromSnapshot, toSnapshot)
+}
+
+// diffChanges maps change types of "zfs diff" to changes of files.
+var diffChanges = map[string]string{
+	"+": resources.FileCreated,
+	"-": resources.FileRemoved,
+	"M": resources.FileModified,
+}
+
+// parseDiffOutput returns regular files of the directory changed according to the "zfs diff -H -F" output.
+// Paths are relative to the directory. A renamed file is reported as removed by the old path and created by the new one.
+func parseDiffOutput(out, dir string) []resources.FileDiff {
+	diffs := []resources.FileDiff{}
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+
+	addFile := func(filePath, change string) {
+		if relativePath := strings.TrimPrefix(filePath, prefix); relativePath != filePath {
+			diffs = append(diffs, resources.FileDiff{Path: relativePath, Change: change})
+		}
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+
+		// Format: <change type> <file type> <path> [<new path>].
+		if len(fields) < 3 || fields[1] != "F" {
+			continue
+		}
+
+		if fields[0] == "R" && len(fields) == 4 {
+			addFile(fields[2], resources.FileRemoved)
+			addFile(fields[3], resources.FileCreated)
+
+			continue
+		}
+
+		if change, ok := diffChanges[fields[0]]; ok {
+			addFile(fields[2], change)
+		}
+	}
+
+	return diffs
+}
+
+// snapshotFileSize returns the size of the file in the snapshot or zero if the file does not exist.
+func snapshotFileSize(mountPoint, snapshot, filePath string) int64 {
+	snapshotName := snapshot[strings.Index(snapshot, "@")+1:]
+
+	info, err := os.Stat(path.Join(mountPoint, ".zfs", "snapshot", snapshotName, filePath))
+	if err != nil {
+		return 0
+	}
+
+	return info.Size()
+}
+
+func snapshotDataset(snapshot string) string {
+	if idx := strings.Index(snapshot, "@"); idx != -1 {
+		return snapshot[:idx]
+	}
+
+	return snapshot
+}
+
+// snapshotNameRegexp matches valid names of ZFS snapshots.
+var snapshotNameRegexp = regexp.MustCompile(`^[\w.:/-]+@[\w.:-]+$`)
+
+// checkSnapshotName checks that the name is a valid snapshot name of the pool, so it is safe to use it in commands.
+func (m *Manager) checkSnapshotName(snapshotName string) error {
+	if !snapshotNameRegexp.MatchString(snapshotName) ||
+		!(strings.HasPrefix(snapshotName, m.config.Pool.Name+"@") || strings.HasPrefix(snapshotName, m.config.Pool.Name+"/")) {
+		return errors.Errorf("invalid snapshot name %q", snapshotName)
+	}
+
+	return nil
 }
 
 // getSnapshotName builds a snapshot name.
@@ -307,8 +597,14 @@ func (m *Manager) DestroySnapshot(snapshotName string) error {
 	return nil
 }
 
-// CleanupSnapshots destroys old snapshots considering retention limit and related clones.
-func (m *Manager) CleanupSnapshots(retentionLimit int) ([]string, error) {
+// CleanupSnapshots destroys snapshots expired by the retention policy considering related clones.
+// In the dry-run mode, expired snapshots are returned without destroying.
+func (m *Manager) CleanupSnapshots(policy thinclones.RetentionPolicy, dryRun bool) ([]string, error) {
+	if !policy.IsDefined() {
+		log.Msg("Retention policy is not defined. Skip snapshot cleanup")
+		return nil, nil
+	}
+
 	clonesCmd := fmt.Sprintf("zfs list -S clones -o name,origin -H -r %s", m.config.Pool.Name)
 
 	clonesOutput, err := m.runner.Run(clonesCmd)
@@ -316,27 +612,104 @@ func (m *Manager) CleanupSnapshots(retentionLimit int) ([]string, error) {
 		return nil, errors.Wrap(err, "failed to list snapshots")
 	}
 
-	busySnapshots := m.getBusySnapshotList(clonesOutput)
-
-	cleanupCmd := fmt.Sprintf(
-		"zfs list -t snapshot -H -o name -s %s -s creation -r %s | grep -v clone | head -n -%d %s"+
-			"| xargs -n1 --no-run-if-empty zfs destroy -R ",
-		dataStateAtLabel, m.config.Pool.Name, retentionLimit, excludeBusySnapshots(busySnapshots))
-
-	out, err := m.runner.Run(cleanupCmd)
+	entries, err := m.listSnapshots(m.config.Pool.Name)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to clean up snapshots")
+		if _, ok := errors.Cause(err).(*EmptyPoolError); ok {
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, "failed to list snapshots")
 	}
 
-	lines := strings.Split(out, "\n")
+	candidates := m.getRetentionCandidates(entries, parseCloneOrigins(clonesOutput))
+	expired := policy.Expired(candidates, m.getBusySnapshotList(clonesOutput))
+
+	snapshots := make([]string, 0, len(expired))
+
+	for _, snapshot := range expired {
+		if dryRun {
+			log.Msg(fmt.Sprintf("Snapshot %s (dataStateAt: %s) would be destroyed",
+				snapshot.ID, snapshot.DataStateAt.Format(util.DataStateAtFormat)))
 
-	return lines, nil
+			snapshots = append(snapshots, snapshot.ID)
+
+			continue
+		}
+
+		if err := m.DestroySnapshot(snapshot.ID); err != nil {
+			return snapshots, errors.Wrapf(err, "failed to destroy snapshot %s", snapshot.ID)
+		}
+
+		log.Msg("Snapshot has been destroyed: ", snapshot.ID)
+
+		snapshots = append(snapshots, snapshot.ID)
+	}
+
+	return snapshots, nil
 }
 
-func (m *Manager) getBusySnapshotList(clonesOutput string) []string {
-	systemClones, userClones := make(map[string]string), make(map[string]struct{})
+// getRetentionCandidates returns snapshots of the pool that can be destroyed with their dependent clones.
+// A pool snapshot taken before data preparation gets dataStateAt and status of the newest snapshot of its clone.
+func (m *Manager) getRetentionCandidates(entries []*ListEntry, origins map[string]string) []thinclones.RetentionItem {
+	poolPrefix := m.config.Pool.Name + "@"
+	candidates := make(map[string]*thinclones.RetentionItem)
+	ordered := []*thinclones.RetentionItem{}
+	resolved := make(map[string]struct{})
 
-	userClonePrefix := m.config.Pool.Name + "/" + util.ClonePrefix
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name, poolPrefix) {
+			item := &thinclones.RetentionItem{
+				ID:          entry.Name,
+				DataStateAt: entry.DataStateAt,
+				Status:      snapshotStatus(entry.Status),
+			}
+
+			candidates[entry.Name] = item
+			ordered = append(ordered, item)
+		}
+	}
+
+	for _, entry := range entries {
+		idx := strings.Index(entry.Name, "@")
+		if idx == -1 {
+			continue
+		}
+
+		dataset := entry.Name[:idx]
+
+		if strings.HasPrefix(dataset, m.config.Pool.Name+"/"+util.ClonePrefix) {
+			continue
+		}
+
+		origin := origins[dataset]
+
+		item, ok := candidates[origin]
+		if !ok {
+			continue
+		}
+
+		if _, ok := resolved[origin]; ok {
+			continue
+		}
+
+		// Entries are ordered by dataStateAt, so the newest snapshot of the clone is taken.
+		item.DataStateAt = entry.DataStateAt
+		item.Status = snapshotStatus(entry.Status)
+		resolved[origin] = struct{}{}
+	}
+
+	items := make([]thinclones.RetentionItem, 0, len(ordered))
+
+	for _, item := range ordered {
+		items = append(items, *item)
+	}
+
+	return items
+}
+
+// parseCloneOrigins returns origin snapshots of clones.
+func parseCloneOrigins(clonesOutput string) map[string]string {
+	origins := make(map[string]string)
 
 	for _, line := range strings.Split(clonesOutput, "\n") {
 		cloneLine := strings.FieldsFunc(line, unicode.IsSpace)
@@ -345,38 +718,46 @@ func (m *Manager) getBusySnapshotList(clonesOutput string) []string {
 			continue
 		}
 
-		if strings.HasPrefix(cloneLine[0], userClonePrefix) {
-			origin := cloneLine[1]
+		origins[cloneLine[0]] = cloneLine[1]
+	}
 
-			if idx := strings.Index(origin, "@"); idx != -1 {
-				origin = origin[:idx]
-			}
+	return origins
+}
 
-			userClones[origin] = struct{}{}
+// getBusySnapshotList returns pool snapshots used by user clones directly or through system clones.
+func (m *Manager) getBusySnapshotList(clonesOutput string) []string {
+	origins := parseCloneOrigins(clonesOutput)
+	userClonePrefix := m.config.Pool.Name + "/" + util.ClonePrefix
+	busy := make(map[string]struct{})
 
+	for clone, origin := range origins {
+		if !strings.HasPrefix(clone, userClonePrefix) {
 			continue
 		}
 
-		systemClones[cloneLine[0]] = cloneLine[1]
-	}
+		dataset := origin
+
+		if idx := strings.Index(origin, "@"); idx != -1 {
+			dataset = origin[:idx]
+		}
 
-	busySnapshots := make([]string, 0, len(userClones))
+		if dataset == m.config.Pool.Name {
+			busy[origin] = struct{}{}
+			continue
+		}
 
-	for userClone := range userClones {
-		busySnapshots = append(busySnapshots, systemClones[userClone])
+		if systemOrigin, ok := origins[dataset]; ok {
+			busy[systemOrigin] = struct{}{}
+		}
 	}
 
-	return busySnapshots
-}
+	busySnapshots := make([]string, 0, len(busy))
 
-// excludeBusySnapshots excludes snapshots that match a pattern by name.
-// The exclusion logic relies on the fact that snapshots have unique substrings (timestamps).
-func excludeBusySnapshots(busySnapshots []string) string {
-	if len(busySnapshots) == 0 {
-		return ""
+	for snapshot := range busy {
+		busySnapshots = append(busySnapshots, snapshot)
 	}
 
-	return fmt.Sprintf("| grep -Ev '%s' ", strings.Join(busySnapshots, "|"))
+	return busySnapshots
 }
 
 // GetSessionState returns a state of a session.
@@ -460,6 +841,7 @@ func (m *Manager) GetSnapshots() ([]resources.Snapshot, error) {
 	}
 
 	snapshots := make([]resources.Snapshot, 0, len(entries))
+	userClonePrefix := m.config.Pool.Name + "/" + util.ClonePrefix
 
 	for _, entry := range entries {
 		// Filter pre-snapshots, they will not be allowed to be used for cloning.
@@ -467,10 +849,17 @@ func (m *Manager) GetSnapshots() ([]resources.Snapshot, error) {
 			continue
 		}
 
+		// Filter snapshots of user clones, they are managed through their clones.
+		if strings.HasPrefix(entry.Name, userClonePrefix) {
+			continue
+		}
+
 		snapshot := resources.Snapshot{
 			ID:          entry.Name,
 			CreatedAt:   entry.Creation,
 			DataStateAt: entry.DataStateAt,
+			Status:      snapshotStatus(entry.Status),
+			Pool:        m.config.Pool.Name,
 		}
 
 		snapshots = append(snapshots, snapshot)
@@ -479,6 +868,15 @@ func (m *Manager) GetSnapshots() ([]resources.Snapshot, error) {
 	return snapshots, nil
 }
 
+// snapshotStatus reports snapshots without the status property as not validated.
+func snapshotStatus(status string) string {
+	if status == "" || status == "-" {
+		return models.SnapshotNotValidated
+	}
+
+	return status
+}
+
 // ListFilesystems lists ZFS file systems (clones, pools).
 func (m *Manager) listFilesystems(pool string) ([]*ListEntry, error) {
 	return m.listDetails(pool, "filesystem")
@@ -493,9 +891,10 @@ func (m *Manager) listSnapshots(pool string) ([]*ListEntry, error) {
 func (m *Manager) listDetails(pool, dsType string) ([]*ListEntry, error) {
 	// TODO(anatoly): Return map.
 	// TODO(anatoly): Generalize.
-	numberFields := 12
+	numberFields := 13
+	customFields := 2
 	listCmd := "zfs list -po name,used,mountpoint,compressratio,available,type," +
-		"origin,creation,referenced,logicalreferenced,logicalused," + dataStateAtLabel + " " +
+		"origin,creation,referenced,logicalreferenced,logicalused," + dataStateAtLabel + "," + statusLabel + " " +
 		"-S " + dataStateAtLabel + " -S creation " + // Order DESC.
 		"-t " + dsType + " " +
 		"-r " + pool
@@ -521,10 +920,12 @@ func (m *Manager) listDetails(pool, dsType string) ([]*ListEntry, error) {
 		// params it will be just an empty string. Which mean that fields
 		// array contain less elements. It's still bad to not have our
 		// custom variables, but we don't want fail completely in this case.
-		if len(fields) == numberFields-1 {
-			log.Dbg(fmt.Sprintf("Probably %q is not set. Manually check ZFS snapshots.", dataStateAtLabel))
+		if len(fields) >= numberFields-customFields && len(fields) < numberFields {
+			log.Dbg(fmt.Sprintf("Probably %q or %q is not set. Manually check ZFS snapshots.", dataStateAtLabel, statusLabel))
 
-			fields = append(fields, "-")
+			for len(fields) < numberFields {
+				fields = append(fields, "-")
+			}
 		}
 
 		// In other cases something really wrong with output format.
@@ -548,6 +949,7 @@ func (m *Manager) listDetails(pool, dsType string) ([]*ListEntry, error) {
 			{field: fields[9], setFunc: zfsListEntry.setLogicalReferenced},
 			{field: fields[10], setFunc: zfsListEntry.setLogicalUsed},
 			{field: fields[11], setFunc: zfsListEntry.setDataStateAt},
+			{field: fields[12], setFunc: zfsListEntry.setStatus},
 		}
 
 		for _, rule := range setRules {
@@ -657,6 +1059,12 @@ func (z *ListEntry) setDataStateAt(field string) error {
 	return nil
 }
 
+func (z *ListEntry) setStatus(field string) error {
+	z.Status = field
+
+	return nil
+}
+
 // PoolMappings provides a mapping of pool name and mount point directory.
 func PoolMappings(runner runners.Runner, mountDir, preSnapshotSuffix string) (map[string]string, error) {
 	listCmd := "zfs list -Ho name,mountpoint -t filesystem | grep -v " + preSnapshotSuffix
@@ -2,12 +2,17 @@ package zfs
 
 import (
 	"errors"
+	"io"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/thinclones"
 )
 
 type runnerMock struct {
@@ -19,6 +24,27 @@ func (r runnerMock) Run(string, ...bool) (string, error) {
 	return r.cmdOutput, r.err
 }
 
+// recordingRunner records commands and fails the command containing failOn.
+type recordingRunner struct {
+	commands []string
+	failOn   string
+}
+
+func (r *recordingRunner) Run(cmd string, _ ...bool) (string, error) {
+	r.commands = append(r.commands, cmd)
+
+	if r.failOn != "" && strings.Contains(cmd, r.failOn) {
+		return "", errors.New("command failed")
+	}
+
+	return "", nil
+}
+
+func (r *recordingRunner) Stream(cmd string, _ io.Reader, _ io.Writer) error {
+	_, err := r.Run(cmd)
+	return err
+}
+
 func TestListClones(t *testing.T) {
 	const (
 		poolName    = "datastore"
@@ -136,28 +162,66 @@ dblab_pool/dblab_clone_6001	dblab_pool/clone_pre_20210127123000@snapshot_2021012
 	assert.Contains(t, list, expected[1])
 }
 
-func TestExcludingBusySnapshots(t *testing.T) {
-	testCases := []struct {
-		snapshotList []string
-		result       string
-	}{
+func TestBusySnapshotListOfPoolSnapshots(t *testing.T) {
+	m := Manager{config: Config{Pool: &resources.Pool{Name: "dblab_pool"}}}
+
+	out := `dblab_pool	-
+dblab_pool/dblab_clone_6000	dblab_pool@snapshot_20210127133008
+dblab_pool/dblab_clone_6001	dblab_pool/unknown@snapshot_20210127133008
+`
+
+	assert.Equal(t, []string{"dblab_pool@snapshot_20210127133008"}, m.getBusySnapshotList(out))
+}
+
+func TestRetentionCandidates(t *testing.T) {
+	m := Manager{config: Config{Pool: &resources.Pool{Name: "dblab_pool"}}}
+
+	origins := parseCloneOrigins(`dblab_pool	-
+dblab_pool/clone_pre_20210127105215	dblab_pool@snapshot_20210127105215_pre
+dblab_pool/clone_pre_20210127113000	dblab_pool@snapshot_20210127113000_pre
+dblab_pool/dblab_clone_6000	dblab_pool/clone_pre_20210127113000@snapshot_20210127110000
+`)
+
+	entries := []*ListEntry{
 		{
-			snapshotList: []string{},
-			result:       "",
+			Name:        "dblab_pool/dblab_clone_6000@snapshot_20210127120000",
+			DataStateAt: time.Date(2021, 1, 27, 12, 0, 0, 0, time.UTC),
+			Status:      models.SnapshotReady,
 		},
 		{
-			snapshotList: []string{"dblab_pool@snapshot_20210127133000_pre"},
-			result:       "| grep -Ev 'dblab_pool@snapshot_20210127133000_pre' ",
+			Name:        "dblab_pool/clone_pre_20210127113000@snapshot_20210127110000",
+			DataStateAt: time.Date(2021, 1, 27, 11, 0, 0, 0, time.UTC),
+			Status:      models.SnapshotQuarantined,
 		},
 		{
-			snapshotList: []string{"dblab_pool@snapshot_20210127133000_pre", "dblab_pool@snapshot_20210127123000_pre"},
-			result:       "| grep -Ev 'dblab_pool@snapshot_20210127133000_pre|dblab_pool@snapshot_20210127123000_pre' ",
+			Name:        "dblab_pool/clone_pre_20210127105215@snapshot_20210127100000",
+			DataStateAt: time.Date(2021, 1, 27, 10, 0, 0, 0, time.UTC),
+			Status:      models.SnapshotReady,
 		},
+		{Name: "dblab_pool@snapshot_20210127113000_pre", DataStateAt: time.Date(2021, 1, 27, 11, 30, 0, 0, time.UTC), Status: "-"},
+		{Name: "dblab_pool@snapshot_20210127105215_pre", DataStateAt: time.Date(2021, 1, 27, 10, 52, 15, 0, time.UTC), Status: "-"},
+		{Name: "dblab_pool@snapshot_20210126000000", DataStateAt: time.Date(2021, 1, 26, 0, 0, 0, 0, time.UTC), Status: "-"},
 	}
 
-	for _, tc := range testCases {
-		assert.Equal(t, tc.result, excludeBusySnapshots(tc.snapshotList))
+	expected := []thinclones.RetentionItem{
+		{
+			ID:          "dblab_pool@snapshot_20210127113000_pre",
+			DataStateAt: time.Date(2021, 1, 27, 11, 0, 0, 0, time.UTC),
+			Status:      models.SnapshotQuarantined,
+		},
+		{
+			ID:          "dblab_pool@snapshot_20210127105215_pre",
+			DataStateAt: time.Date(2021, 1, 27, 10, 0, 0, 0, time.UTC),
+			Status:      models.SnapshotReady,
+		},
+		{
+			ID:          "dblab_pool@snapshot_20210126000000",
+			DataStateAt: time.Date(2021, 1, 26, 0, 0, 0, 0, time.UTC),
+			Status:      models.SnapshotNotValidated,
+		},
 	}
+
+	assert.Equal(t, expected, m.getRetentionCandidates(entries, origins))
 }
 
 func TestProcessingMappingOutput(t *testing.T) {
@@ -184,3 +248,182 @@ poolnext 							/var/lib/postgresql/pools/pool5`
 	assert.Equal(t, len(expected), len(poolMappings))
 	assert.Equal(t, expected, poolMappings)
 }
+
+func TestCheckSnapshotName(t *testing.T) {
+	m := Manager{config: Config{Pool: &resources.Pool{Name: "dblab_pool"}}}
+
+	assert.NoError(t, m.checkSnapshotName("dblab_pool@snapshot_20210127133008"))
+	assert.NoError(t, m.checkSnapshotName("dblab_pool/clone_pre_20210127123000@snapshot_20210127133008"))
+	assert.Error(t, m.checkSnapshotName("dblab_pool/clone_pre_20210127123000"))
+	assert.Error(t, m.checkSnapshotName("other_pool@snapshot_20210127133008"))
+	assert.Error(t, m.checkSnapshotName("dblab_pool@snapshot_1; rm -rf /"))
+}
+
+func TestResolveDiffSnapshots(t *testing.T) {
+	datasets := map[string]*ListEntry{
+		"pool":                    {Name: "pool", Origin: "-"},
+		"pool/clone_pre_1":        {Name: "pool/clone_pre_1", Origin: "pool@snapshot_1_pre"},
+		"pool/clone_pre_2":        {Name: "pool/clone_pre_2", Origin: "pool@snapshot_2_pre"},
+		"pool/imported_clone_pre": {Name: "pool/imported_clone_pre", Origin: "-"},
+	}
+
+	testCases := []struct {
+		from, to         string
+		expFrom, expTo   string
+		expectedErrorMsg string
+	}{
+		{from: "pool@snapshot_1", to: "pool@snapshot_2", expFrom: "pool@snapshot_1", expTo: "pool@snapshot_2"},
+		{from: "pool@snapshot_1_pre", to: "pool/clone_pre_1@snapshot_1", expFrom: "pool@snapshot_1_pre", expTo: "pool/clone_pre_1@snapshot_1"},
+		{from: "pool/clone_pre_1@snapshot_1", to: "pool/clone_pre_2@snapshot_2", expFrom: "pool@snapshot_1_pre", expTo: "pool@snapshot_2_pre"},
+		{from: "pool@snapshot_1", to: "pool/clone_pre_2@snapshot_2", expFrom: "pool@snapshot_1", expTo: "pool@snapshot_2_pre"},
+		{
+			from: "pool@snapshot_1", to: "pool/imported_clone_pre@snapshot_2",
+			expectedErrorMsg: "snapshots pool@snapshot_1 and pool/imported_clone_pre@snapshot_2 cannot be compared: they do not share a dataset",
+		},
+	}
+
+	for _, tc := range testCases {
+		from, to, err := resolveDiffSnapshots(tc.from, tc.to, datasets)
+
+		if tc.expectedErrorMsg != "" {
+			assert.EqualError(t, err, tc.expectedErrorMsg)
+			continue
+		}
+
+		require.NoError(t, err)
+		assert.Equal(t, tc.expFrom, from)
+		assert.Equal(t, tc.expTo, to)
+	}
+}
+
+func TestParseDiffOutput(t *testing.T) {
+	out := "M\t/\t/var/lib/dblab/pool/clone_pre_1/data\n" +
+		"M\tF\t/var/lib/dblab/pool/clone_pre_1/data/base/16384/16385\n" +
+		"+\tF\t/var/lib/dblab/pool/clone_pre_1/data/base/16384/16390\n" +
+		"-\tF\t/var/lib/dblab/pool/clone_pre_1/data/base/16384/16391_fsm\n" +
+		"R\tF\t/var/lib/dblab/pool/clone_pre_1/data/global/pg_internal.init.1\t/var/lib/dblab/pool/clone_pre_1/data/global/pg_internal.init\n" +
+		"M\tF\t/var/lib/dblab/pool/clone_pre_1/.dblab/dbmarker\n"
+
+	expected := []resources.FileDiff{
+		{Path: "base/16384/16385", Change: resources.FileModified},
+		{Path: "base/16384/16390", Change: resources.FileCreated},
+		{Path: "base/16384/16391_fsm", Change: resources.FileRemoved},
+		{Path: "global/pg_internal.init.1", Change: resources.FileRemoved},
+		{Path: "global/pg_internal.init", Change: resources.FileCreated},
+	}
+
+	assert.Equal(t, expected, parseDiffOutput(out, "/var/lib/dblab/pool/clone_pre_1/data"))
+	assert.Equal(t, []resources.FileDiff{}, parseDiffOutput("", "/var/lib/dblab/pool/clone_pre_1/data"))
+}
+
+func TestCreateCloneSnapshot(t *testing.T) {
+	m := Manager{config: Config{Pool: &resources.Pool{Name: "dblab_pool"}}, runner: runnerMock{}}
+
+	snapshotID, err := m.CreateCloneSnapshot("dblab_clone_6000", "savepoint_before_migration")
+	require.NoError(t, err)
+	assert.Equal(t, "dblab_pool/dblab_clone_6000@savepoint_before_migration", snapshotID)
+
+	_, err = m.CreateCloneSnapshot("dblab_clone_6000", "savepoint_1; rm -rf /")
+	assert.Error(t, err)
+
+	assert.Error(t, m.RollbackCloneSnapshot("dblab_clone_6000", "savepoint_1 && reboot"))
+}
+
+func TestSavepointCommands(t *testing.T) {
+	runner := &recordingRunner{}
+	m := Manager{config: Config{Pool: &resources.Pool{Name: "dblab_pool"}}, runner: runner}
+
+	snapshotID, err := m.CreateCloneSnapshot("dblab_clone_6000", "savepoint_before_migration")
+	require.NoError(t, err)
+	require.NoError(t, m.RollbackCloneSnapshot("dblab_clone_6000", "savepoint_before_migration"))
+
+	assert.Equal(t, []string{
+		"zfs snapshot " + snapshotID,
+		"zfs rollback -r dblab_pool/dblab_clone_6000@savepoint_before_migration",
+	}, runner.commands)
+
+	// Failures are reported, and invalid names are rejected without running commands.
+	runner = &recordingRunner{failOn: "zfs snapshot"}
+	m.runner = runner
+
+	_, err = m.CreateCloneSnapshot("dblab_clone_6000", "savepoint_after_migration")
+	assert.Error(t, err)
+
+	err = m.RollbackCloneSnapshot("dblab_clone_6000", "savepoint_1 && reboot")
+	assert.Error(t, err)
+	assert.Equal(t, []string{"zfs snapshot dblab_pool/dblab_clone_6000@savepoint_after_migration"}, runner.commands)
+}
+
+func TestForkCommands(t *testing.T) {
+	runner := &recordingRunner{}
+	m := Manager{config: Config{Pool: &resources.Pool{Name: "dblab_pool"}, OSUsername: "postgres"}, runner: runner}
+
+	snapshotID, err := m.CreateCloneSnapshot("dblab_clone_6000", "fork_dblab_clone_6001")
+	require.NoError(t, err)
+	require.NoError(t, m.CreateClone("dblab_clone_6001", snapshotID))
+	require.NoError(t, m.DestroySnapshot(snapshotID))
+
+	assert.Equal(t, []string{
+		"zfs snapshot dblab_pool/dblab_clone_6000@fork_dblab_clone_6001",
+		"zfs list",
+		"zfs clone -o mountpoint=" + m.config.Pool.ClonesDir() + "/dblab_clone_6001 dblab_pool/dblab_clone_6000@fork_dblab_clone_6001 " +
+			"dblab_pool/dblab_clone_6001 && chown -R postgres " + m.config.Pool.ClonesDir() + "/dblab_clone_6001",
+		"zfs destroy -R dblab_pool/dblab_clone_6000@fork_dblab_clone_6001",
+	}, runner.commands)
+}
+
+func TestGetSnapshotsSkipsCloneSnapshots(t *testing.T) {
+	out := "NAME\tUSED\tMOUNTPOINT\tCOMPRESSRATIO\tAVAIL\tTYPE\tORIGIN\tCREATION\tREFER\tLREFER\tLUSED\tDATASTATEAT\tSTATUS\n" +
+		"dblab_pool@snapshot_20211019120000\t0\t-\t1.00\t-\tsnapshot\t-\t1634644800\t0\t0\t0\t20211019120000\tready\n" +
+		"dblab_pool@snapshot_20211018120000\t0\t-\t1.00\t-\tsnapshot\t-\t1634558400\t0\t0\t0\t20211018120000\t-\n" +
+		"dblab_pool/dblab_clone_6000@savepoint_before_migration\t0\t-\t1.00\t-\tsnapshot\t-\t1634648400\t0\t0\t0\t-\t-"
+
+	m := Manager{config: Config{Pool: &resources.Pool{Name: "dblab_pool"}, PreSnapshotSuffix: "_pre"}, runner: runnerMock{cmdOutput: out}}
+
+	snapshots, err := m.GetSnapshots()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, "dblab_pool@snapshot_20211019120000", snapshots[0].ID)
+	assert.Equal(t, models.SnapshotReady, snapshots[0].Status)
+	assert.Equal(t, "dblab_pool@snapshot_20211018120000", snapshots[1].ID)
+	assert.Equal(t, models.SnapshotNotValidated, snapshots[1].Status)
+}
+
+func TestCreateSnapshotSetsPropertiesAtomically(t *testing.T) {
+	runner := &recordingRunner{}
+	m := Manager{config: Config{Pool: &resources.Pool{Name: "dblab_pool"}, PreSnapshotSuffix: "_pre"}, runner: runner}
+
+	snapshotName, err := m.CreateSnapshot("clone_pre_20211019120000", "20211019115500", models.SnapshotQuarantined)
+	require.NoError(t, err)
+	assert.Equal(t, "dblab_pool/clone_pre_20211019120000@snapshot_20211019115500", snapshotName)
+	assert.Equal(t, []string{`zfs snapshot -r -o dblab:datastateat="20211019115500" -o dblab:status="quarantined" ` +
+		`dblab_pool/clone_pre_20211019120000@snapshot_20211019115500`}, runner.commands)
+
+	runner.commands = nil
+
+	_, err = m.CreateSnapshot("", "20211019120000_pre", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{`zfs snapshot -r -o dblab:datastateat="20211019120000" dblab_pool@snapshot_20211019120000_pre`},
+		runner.commands)
+}
+
+func TestImportSnapshot(t *testing.T) {
+	runner := &recordingRunner{}
+	m := Manager{config: Config{Pool: &resources.Pool{Name: "dblab_pool"}}, runner: runner}
+
+	err := m.ImportSnapshot(nil, "dblab_pool/imported_main@snapshot_20211019120000", "20211019120000", models.SnapshotReady)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`zfs receive -u -o dblab:datastateat="20211019120000" -o dblab:status="ready" dblab_pool/imported_main@snapshot_20211019120000`,
+		`zfs set dblab:datastateat="20211019120000" dblab:status="ready" dblab_pool/imported_main@snapshot_20211019120000`,
+	}, runner.commands)
+
+	// The snapshot is destroyed if its properties cannot be set.
+	runner = &recordingRunner{failOn: "zfs set"}
+	m.runner = runner
+
+	err = m.ImportSnapshot(nil, "dblab_pool/imported_main@snapshot_20211019120000", "20211019120000", models.SnapshotReady)
+	require.Error(t, err)
+	require.Len(t, runner.commands, 3)
+	assert.Equal(t, "zfs destroy -R dblab_pool/imported_main@snapshot_20211019120000", runner.commands[2])
+}
@@ -0,0 +1,158 @@
+/*
+2021 © Postgres.ai
+*/
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	protocolVersion3 = 196608
+	sslRequestCode   = 80877103
+	gssEncRequest    = 80877104
+	cancelRequest    = 80877102
+
+	// maxStartupMessageLength limits the startup message in the same way as Postgres does.
+	maxStartupMessageLength = 10000
+
+	cancelRequestLength  = 16
+	backendKeyDataLength = 13
+
+	backendKeyData  = 'K'
+	readyForQuery   = 'Z'
+	errorResponse   = 'E'
+	maxMessageBytes = 1 << 24
+)
+
+// startupMessage describes a message opening a connection.
+type startupMessage struct {
+	code    uint32
+	payload []byte
+}
+
+// parameter describes a run-time parameter of the startup message.
+type parameter struct {
+	name  string
+	value string
+}
+
+// readStartupMessage reads a message sent by a client opening a connection.
+func readStartupMessage(r io.Reader) (*startupMessage, error) {
+	header := make([]byte, 8)
+
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errors.Wrap(err, "failed to read the startup message")
+	}
+
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < uint32(len(header)) || length > maxStartupMessageLength {
+		return nil, errors.Errorf("invalid length of the startup message: %d", length)
+	}
+
+	payload := make([]byte, length-uint32(len(header)))
+
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, errors.Wrap(err, "failed to read the startup message")
+	}
+
+	return &startupMessage{code: binary.BigEndian.Uint32(header[4:]), payload: payload}, nil
+}
+
+// parameters parses run-time parameters of the startup message.
+func (m *startupMessage) parameters() ([]parameter, error) {
+	fields := bytes.Split(m.payload, []byte{0})
+
+	// The list of parameters is terminated by an empty name.
+	if len(fields) < 2 || len(fields[len(fields)-1]) != 0 || len(fields[len(fields)-2]) != 0 {
+		return nil, errors.New("malformed parameters of the startup message")
+	}
+
+	fields = fields[:len(fields)-2]
+
+	if len(fields)%2 != 0 {
+		return nil, errors.New("malformed parameters of the startup message")
+	}
+
+	params := make([]parameter, 0, len(fields)/2)
+
+	for i := 0; i < len(fields); i += 2 {
+		params = append(params, parameter{name: string(fields[i]), value: string(fields[i+1])})
+	}
+
+	return params, nil
+}
+
+// buildStartupMessage encodes the startup message of protocol version 3.
+func buildStartupMessage(params []parameter) []byte {
+	buf := bytes.NewBuffer(make([]byte, 8))
+
+	for _, param := range params {
+		buf.WriteString(param.name)
+		buf.WriteByte(0)
+		buf.WriteString(param.value)
+		buf.WriteByte(0)
+	}
+
+	buf.WriteByte(0)
+
+	message := buf.Bytes()
+	binary.BigEndian.PutUint32(message[:4], uint32(len(message)))
+	binary.BigEndian.PutUint32(message[4:8], protocolVersion3)
+
+	return message
+}
+
+// buildErrorResponse encodes a fatal error sent to a client.
+func buildErrorResponse(code, message string) []byte {
+	buf := bytes.NewBuffer([]byte{errorResponse, 0, 0, 0, 0})
+
+	for _, field := range []struct {
+		kind  byte
+		value string
+	}{
+		{kind: 'S', value: "FATAL"},
+		{kind: 'V', value: "FATAL"},
+		{kind: 'C', value: code},
+		{kind: 'M', value: message},
+	} {
+		buf.WriteByte(field.kind)
+		buf.WriteString(field.value)
+		buf.WriteByte(0)
+	}
+
+	buf.WriteByte(0)
+
+	response := buf.Bytes()
+	binary.BigEndian.PutUint32(response[1:5], uint32(len(response)-1))
+
+	return response
+}
+
+// readMessage reads a typed message sent by a server.
+func readMessage(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[1:])
+	if length < 4 || length > maxMessageBytes {
+		return 0, nil, errors.Errorf("invalid length of the message: %d", length)
+	}
+
+	message := make([]byte, len(header)+int(length)-4)
+	copy(message, header)
+
+	if _, err := io.ReadFull(r, message[len(header):]); err != nil {
+		return 0, nil, err
+	}
+
+	return header[0], message, nil
+}
@@ -0,0 +1,426 @@
+/*
+2021 © Postgres.ai
+*/
+
+// Package proxy provides a Postgres wire protocol proxy routing connections to clones through a single port.
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+)
+
+const (
+	startupTimeout = 30 * time.Second
+	dialTimeout    = 10 * time.Second
+	acceptDelay    = 100 * time.Millisecond
+
+	// certRefreshInterval defines how long the proxy certificate is reused, so a reloaded authority is picked up.
+	certRefreshInterval = time.Hour
+
+	// maxNegotiationRequests limits encryption requests a client may send before the startup message.
+	maxNegotiationRequests = 2
+
+	cloneSeparator = "@"
+
+	codeRejectedConnection = "08004"
+	codeConnectionFailure  = "08006"
+	codeProtocolViolation  = "08P01"
+)
+
+// Config defines configuration of the connection proxy.
+type Config struct {
+	Enabled bool   `yaml:"enabled"`
+	Host    string `yaml:"host"`
+	Port    uint   `yaml:"port"`
+}
+
+// Resolver finds clones to route connections to.
+type Resolver interface {
+	// CloneAddress returns the TCP address of the running clone.
+	CloneAddress(cloneID string) (string, error)
+}
+
+// CertificateIssuer issues the server certificate of the proxy.
+type CertificateIssuer interface {
+	// ProxyCertificate returns the certificate signed by the clone CA or nil if TLS of clones is disabled.
+	ProxyCertificate() (*tls.Certificate, error)
+}
+
+// Proxy routes client connections to clones by user or database names like "user@cloneid".
+// Connections are routed to TCP ports of clones rather than to their Unix sockets
+// because clones trust local connections and the proxy must not bypass password authentication.
+// TLS of client connections is terminated by the proxy with a certificate signed by the clone CA.
+type Proxy struct {
+	config       Config
+	resolver     Resolver
+	certificates CertificateIssuer
+
+	certMu       sync.Mutex
+	cert         *tls.Certificate
+	certIssuedAt time.Time
+
+	cancelMu   sync.Mutex
+	cancelKeys map[[8]byte]string
+}
+
+// New creates a new connection proxy.
+func New(cfg Config, resolver Resolver, certificates CertificateIssuer) *Proxy {
+	return &Proxy{
+		config:       cfg,
+		resolver:     resolver,
+		certificates: certificates,
+		cancelKeys:   make(map[[8]byte]string),
+	}
+}
+
+// Run listens on the configured port and routes connections until the context is canceled.
+func (p *Proxy) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", net.JoinHostPort(p.config.Host, strconv.FormatUint(uint64(p.config.Port), 10)))
+	if err != nil {
+		return errors.Wrap(err, "failed to start the connection proxy")
+	}
+
+	log.Msg("Connection proxy is listening on ", listener.Addr().String())
+
+	return p.serve(ctx, listener)
+}
+
+func (p *Proxy) serve(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			log.Err("Connection proxy failed to accept a connection: ", err)
+			time.Sleep(acceptDelay)
+
+			continue
+		}
+
+		go p.handleConnection(ctx, conn)
+	}
+}
+
+func (p *Proxy) handleConnection(ctx context.Context, conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	if err := conn.SetDeadline(time.Now().Add(startupTimeout)); err != nil {
+		log.Err("Failed to set a deadline of the proxy connection: ", err)
+		return
+	}
+
+	conn, message, err := p.readClientStartup(conn)
+	if err != nil {
+		log.Dbg("Proxy connection rejected: ", err.Error())
+		_, _ = conn.Write(buildErrorResponse(codeProtocolViolation, err.Error()))
+
+		return
+	}
+
+	if message.code == cancelRequest {
+		p.forwardCancel(ctx, message)
+		return
+	}
+
+	params, err := message.parameters()
+	if err != nil {
+		_, _ = conn.Write(buildErrorResponse(codeProtocolViolation, err.Error()))
+		return
+	}
+
+	cloneID, params, err := route(params)
+	if err != nil {
+		_, _ = conn.Write(buildErrorResponse(codeRejectedConnection, err.Error()))
+		return
+	}
+
+	address, err := p.resolver.CloneAddress(cloneID)
+	if err != nil {
+		_, _ = conn.Write(buildErrorResponse(codeRejectedConnection, err.Error()))
+		return
+	}
+
+	backend, err := (&net.Dialer{Timeout: dialTimeout}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		log.Err("Proxy failed to connect to the clone: ", err)
+		_, _ = conn.Write(buildErrorResponse(codeConnectionFailure, "failed to connect to the clone "+cloneID))
+
+		return
+	}
+
+	defer func() { _ = backend.Close() }()
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		log.Err("Failed to reset a deadline of the proxy connection: ", err)
+		return
+	}
+
+	if _, err := backend.Write(buildStartupMessage(params)); err != nil {
+		log.Err("Proxy failed to send the startup message to the clone: ", err)
+		return
+	}
+
+	p.relay(conn, backend, address)
+}
+
+// readClientStartup reads the startup message and returns the connection to continue with.
+// SSL requests are accepted if the proxy has a certificate, so the startup message is read over TLS.
+// GSSAPI encryption is declined, so clients continue without it.
+func (p *Proxy) readClientStartup(conn net.Conn) (net.Conn, *startupMessage, error) {
+	encrypted := false
+
+	for i := 0; i <= maxNegotiationRequests; i++ {
+		message, err := readStartupMessage(conn)
+		if err != nil {
+			return conn, nil, err
+		}
+
+		switch message.code {
+		case sslRequestCode:
+			if encrypted {
+				return conn, nil, errors.New("unexpected SSL request over an encrypted connection")
+			}
+
+			tlsConn, err := p.acceptTLS(conn)
+			if err != nil {
+				return conn, nil, err
+			}
+
+			if tlsConn != nil {
+				conn, encrypted = tlsConn, true
+			}
+
+		case gssEncRequest:
+			if _, err := conn.Write([]byte{'N'}); err != nil {
+				return conn, nil, errors.Wrap(err, "failed to decline encryption")
+			}
+
+		case protocolVersion3:
+			return conn, message, nil
+
+		case cancelRequest:
+			if len(message.payload) != cancelRequestLength-8 {
+				return conn, nil, errors.New("malformed cancel request")
+			}
+
+			return conn, message, nil
+
+		default:
+			return conn, nil, errors.Errorf("unsupported protocol version: %d", message.code)
+		}
+	}
+
+	return conn, nil, errors.New("too many encryption requests")
+}
+
+// acceptTLS answers the SSL request and performs the TLS handshake.
+// Nil is returned without an error if the request is declined because the proxy has no certificate.
+func (p *Proxy) acceptTLS(conn net.Conn) (*tls.Conn, error) {
+	cert, err := p.certificate()
+	if err != nil {
+		log.Err("Proxy failed to issue a certificate: ", err)
+	}
+
+	if cert == nil {
+		if _, err := conn.Write([]byte{'N'}); err != nil {
+			return nil, errors.Wrap(err, "failed to decline encryption")
+		}
+
+		return nil, nil
+	}
+
+	if _, err := conn.Write([]byte{'S'}); err != nil {
+		return nil, errors.Wrap(err, "failed to accept encryption")
+	}
+
+	tlsConn := tls.Server(conn, &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		MinVersion:   tls.VersionTLS12,
+	})
+
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, errors.Wrap(err, "TLS handshake failed")
+	}
+
+	return tlsConn, nil
+}
+
+// certificate returns the server certificate of the proxy. It is reissued periodically to follow changes of the clone CA.
+func (p *Proxy) certificate() (*tls.Certificate, error) {
+	if p.certificates == nil {
+		return nil, nil
+	}
+
+	p.certMu.Lock()
+	defer p.certMu.Unlock()
+
+	if p.cert != nil && time.Since(p.certIssuedAt) < certRefreshInterval {
+		return p.cert, nil
+	}
+
+	cert, err := p.certificates.ProxyCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	p.cert, p.certIssuedAt = cert, time.Now()
+
+	return cert, nil
+}
+
+// route finds the clone in user or database names like "name@cloneid" and removes it from the parameters.
+func route(params []parameter) (string, []parameter, error) {
+	cloneID := ""
+	routed := make([]parameter, len(params))
+	copy(routed, params)
+
+	for i, param := range routed {
+		if param.name != "user" && param.name != "database" {
+			continue
+		}
+
+		separatorIndex := strings.LastIndex(param.value, cloneSeparator)
+		if separatorIndex == -1 {
+			continue
+		}
+
+		name, id := param.value[:separatorIndex], param.value[separatorIndex+len(cloneSeparator):]
+		if name == "" || id == "" {
+			return "", nil, errors.Errorf("invalid %s %q: expected name@cloneid", param.name, param.value)
+		}
+
+		if cloneID != "" && cloneID != id {
+			return "", nil, errors.New("user and database refer to different clones")
+		}
+
+		cloneID = id
+		routed[i].value = name
+	}
+
+	if cloneID == "" {
+		return "", nil, errors.New(`clone is not defined: use "user@cloneid" as the user or "database@cloneid" as the database`)
+	}
+
+	return cloneID, routed, nil
+}
+
+// relay passes traffic between the client and the clone.
+// Messages of the clone are inspected until the connection is ready, so cancel requests can be routed later.
+func (p *Proxy) relay(client, backend net.Conn, address string) {
+	done := make(chan struct{}, 2)
+
+	go func() {
+		_, _ = io.Copy(backend, client)
+		_ = backend.Close()
+		done <- struct{}{}
+	}()
+
+	go func() {
+		p.relayBackend(client, backend, address)
+		_ = client.Close()
+		done <- struct{}{}
+	}()
+
+	<-done
+	<-done
+}
+
+func (p *Proxy) relayBackend(client, backend net.Conn, address string) {
+	var cancelKeys [][8]byte
+
+	defer func() {
+		for _, key := range cancelKeys {
+			p.removeCancelKey(key)
+		}
+	}()
+
+	for {
+		messageType, message, err := readMessage(backend)
+		if err != nil {
+			return
+		}
+
+		if messageType == backendKeyData && len(message) == backendKeyDataLength {
+			var key [8]byte
+
+			copy(key[:], message[5:])
+			p.addCancelKey(key, address)
+
+			cancelKeys = append(cancelKeys, key)
+		}
+
+		if _, err := client.Write(message); err != nil {
+			return
+		}
+
+		if messageType == readyForQuery || messageType == errorResponse {
+			break
+		}
+	}
+
+	_, _ = io.Copy(client, backend)
+}
+
+func (p *Proxy) addCancelKey(key [8]byte, address string) {
+	p.cancelMu.Lock()
+	p.cancelKeys[key] = address
+	p.cancelMu.Unlock()
+}
+
+func (p *Proxy) removeCancelKey(key [8]byte) {
+	p.cancelMu.Lock()
+	delete(p.cancelKeys, key)
+	p.cancelMu.Unlock()
+}
+
+// forwardCancel passes the cancel request to the clone serving the connection to cancel.
+func (p *Proxy) forwardCancel(ctx context.Context, message *startupMessage) {
+	var key [8]byte
+
+	copy(key[:], message.payload)
+
+	p.cancelMu.Lock()
+	address, ok := p.cancelKeys[key]
+	p.cancelMu.Unlock()
+
+	if !ok {
+		log.Dbg("Proxy received a cancel request for an unknown connection")
+		return
+	}
+
+	backend, err := (&net.Dialer{Timeout: dialTimeout}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		log.Err("Proxy failed to forward a cancel request: ", err)
+		return
+	}
+
+	defer func() { _ = backend.Close() }()
+
+	request := make([]byte, cancelRequestLength)
+	binary.BigEndian.PutUint32(request[:4], cancelRequestLength)
+	binary.BigEndian.PutUint32(request[4:8], cancelRequest)
+	copy(request[8:], message.payload)
+
+	if _, err := backend.Write(request); err != nil {
+		log.Err("Proxy failed to forward a cancel request: ", err)
+	}
+}
@@ -0,0 +1,266 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/certs"
+)
+
+type testResolver map[string]string
+
+func (r testResolver) CloneAddress(cloneID string) (string, error) {
+	address, ok := r[cloneID]
+	if !ok {
+		return "", errors.Errorf("clone %q not found", cloneID)
+	}
+
+	return address, nil
+}
+
+func buildMessage(messageType byte, payload []byte) []byte {
+	message := make([]byte, 5, 5+len(payload))
+	message[0] = messageType
+	binary.BigEndian.PutUint32(message[1:], uint32(4+len(payload)))
+
+	return append(message, payload...)
+}
+
+func buildRequest(code uint32, payload []byte) []byte {
+	request := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(request[:4], uint32(8+len(payload)))
+	binary.BigEndian.PutUint32(request[4:], code)
+
+	return append(request, payload...)
+}
+
+// startBackend starts a fake clone which accepts the startup message, sends the cancel key and echoes data.
+func startBackend(t *testing.T, startupCh chan<- []parameter, cancelCh chan<- []byte) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer func() { _ = conn.Close() }()
+
+				message, err := readStartupMessage(conn)
+				if err != nil {
+					return
+				}
+
+				if message.code == cancelRequest {
+					cancelCh <- message.payload
+					return
+				}
+
+				params, err := message.parameters()
+				if err != nil {
+					return
+				}
+
+				startupCh <- params
+
+				_, _ = conn.Write(buildMessage('R', []byte{0, 0, 0, 0}))
+				_, _ = conn.Write(buildMessage(backendKeyData, []byte{0, 0, 0, 1, 0, 0, 0, 2}))
+				_, _ = conn.Write(buildMessage(readyForQuery, []byte{'I'}))
+				_, _ = io.Copy(conn, conn)
+			}(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+type testIssuer struct {
+	authority *certs.Authority
+}
+
+func (i testIssuer) ProxyCertificate() (*tls.Certificate, error) {
+	cert, err := i.authority.Issue("dblab_proxy", "127.0.0.1")
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCert, err := tls.X509KeyPair(cert.CertPEM, cert.KeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tlsCert, nil
+}
+
+func startProxy(t *testing.T, resolver Resolver, certificates CertificateIssuer) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go func() { _ = New(Config{}, resolver, certificates).serve(ctx, listener) }()
+
+	return listener.Addr().String()
+}
+
+func TestProxyRoutesConnections(t *testing.T) {
+	startupCh := make(chan []parameter, 1)
+	cancelCh := make(chan []byte, 1)
+	backendAddress := startBackend(t, startupCh, cancelCh)
+	proxyAddress := startProxy(t, testResolver{"clone1": backendAddress}, nil)
+
+	conn, err := net.Dial("tcp", proxyAddress)
+	require.NoError(t, err)
+
+	defer func() { _ = conn.Close() }()
+
+	// Encryption is declined without a certificate.
+	_, err = conn.Write(buildRequest(sslRequestCode, nil))
+	require.NoError(t, err)
+
+	response := make([]byte, 1)
+	_, err = io.ReadFull(conn, response)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{'N'}, response)
+
+	_, err = conn.Write(buildStartupMessage([]parameter{{name: "user", value: "alice@clone1"}, {name: "database", value: "app"}}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []parameter{{name: "user", value: "alice"}, {name: "database", value: "app"}}, <-startupCh)
+
+	for _, expectedType := range []byte{'R', backendKeyData, readyForQuery} {
+		messageType, _, err := readMessage(conn)
+		require.NoError(t, err)
+		assert.Equal(t, expectedType, messageType)
+	}
+
+	query := buildMessage('Q', []byte("select 1\x00"))
+	_, err = conn.Write(query)
+	require.NoError(t, err)
+
+	_, echo, err := readMessage(conn)
+	require.NoError(t, err)
+	assert.Equal(t, query, echo)
+
+	// Cancel requests are routed by the key of the connection.
+	cancelConn, err := net.Dial("tcp", proxyAddress)
+	require.NoError(t, err)
+
+	defer func() { _ = cancelConn.Close() }()
+
+	_, err = cancelConn.Write(buildRequest(cancelRequest, []byte{0, 0, 0, 1, 0, 0, 0, 2}))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 1, 0, 0, 0, 2}, <-cancelCh)
+}
+
+func TestProxyTerminatesTLS(t *testing.T) {
+	caDir := t.TempDir()
+	authority, err := certs.NewAuthority(caDir)
+	require.NoError(t, err)
+
+	startupCh := make(chan []parameter, 1)
+	backendAddress := startBackend(t, startupCh, make(chan []byte, 1))
+	proxyAddress := startProxy(t, testResolver{"clone1": backendAddress}, testIssuer{authority: authority})
+
+	host, port, err := net.SplitHostPort(proxyAddress)
+	require.NoError(t, err)
+
+	for _, sslMode := range []string{"require", "verify-full"} {
+		connStr := fmt.Sprintf("host=%s port=%s user=alice@clone1 dbname=app sslmode=%s sslrootcert=%s",
+			host, port, sslMode, path.Join(caDir, "ca.crt"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		conn, err := pgconn.Connect(ctx, connStr)
+		cancel()
+		require.NoError(t, err, sslMode)
+
+		_, encrypted := conn.Conn().(*tls.Conn)
+		assert.True(t, encrypted, sslMode)
+		assert.Equal(t, []parameter{{name: "user", value: "alice"}, {name: "database", value: "app"}}, <-startupCh)
+
+		_ = conn.Close(context.Background())
+	}
+}
+
+func TestProxyRejectsUnknownClones(t *testing.T) {
+	proxyAddress := startProxy(t, testResolver{}, nil)
+
+	conn, err := net.Dial("tcp", proxyAddress)
+	require.NoError(t, err)
+
+	defer func() { _ = conn.Close() }()
+
+	_, err = conn.Write(buildStartupMessage([]parameter{{name: "user", value: "alice@missing"}}))
+	require.NoError(t, err)
+
+	messageType, message, err := readMessage(conn)
+	require.NoError(t, err)
+	assert.Equal(t, byte(errorResponse), messageType)
+	assert.Contains(t, string(message), `clone "missing" not found`)
+}
+
+func TestRoute(t *testing.T) {
+	testCases := []struct {
+		params   []parameter
+		cloneID  string
+		expected []parameter
+		err      bool
+	}{
+		{
+			params:   []parameter{{name: "user", value: "john@example.com@clone1"}, {name: "database", value: "app"}},
+			cloneID:  "clone1",
+			expected: []parameter{{name: "user", value: "john@example.com"}, {name: "database", value: "app"}},
+		},
+		{
+			params:   []parameter{{name: "user", value: "alice"}, {name: "database", value: "app@clone1"}},
+			cloneID:  "clone1",
+			expected: []parameter{{name: "user", value: "alice"}, {name: "database", value: "app"}},
+		},
+		{
+			params:   []parameter{{name: "user", value: "alice@clone1"}, {name: "database", value: "alice@clone1"}},
+			cloneID:  "clone1",
+			expected: []parameter{{name: "user", value: "alice"}, {name: "database", value: "alice"}},
+		},
+		{
+			params: []parameter{{name: "user", value: "alice@clone1"}, {name: "database", value: "app@clone2"}},
+			err:    true,
+		},
+		{
+			params: []parameter{{name: "user", value: "alice"}, {name: "application_name", value: "psql@clone1"}},
+			err:    true,
+		},
+		{
+			params: []parameter{{name: "user", value: "alice@"}},
+			err:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		cloneID, params, err := route(tc.params)
+		if tc.err {
+			assert.Error(t, err)
+			continue
+		}
+
+		require.NoError(t, err)
+		assert.Equal(t, tc.cloneID, cloneID)
+		assert.Equal(t, tc.expected, params)
+	}
+}
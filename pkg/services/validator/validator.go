@@ -9,6 +9,7 @@ import (
 	"github.com/pkg/errors"
 
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
 )
 
 // Service provides a validation service.
@@ -17,15 +18,34 @@ type Service struct {
 
 // ValidateCloneRequest validates a clone request.
 func (v Service) ValidateCloneRequest(cloneRequest *types.CloneCreateRequest) error {
-	if cloneRequest.DB == nil {
+	if err := validateDatabaseRequest(cloneRequest.DB); err != nil {
+		return err
+	}
+
+	for _, destination := range cloneRequest.AllowEgress {
+		if _, err := resources.ParseEgressRule(destination); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateForkRequest validates a fork request.
+func (v Service) ValidateForkRequest(forkRequest *types.CloneForkRequest) error {
+	return validateDatabaseRequest(forkRequest.DB)
+}
+
+func validateDatabaseRequest(db *types.DatabaseRequest) error {
+	if db == nil {
 		return errors.New("missing both DB username and password")
 	}
 
-	if cloneRequest.DB.Username == "" {
+	if db.Username == "" {
 		return errors.New("missing DB username")
 	}
 
-	if cloneRequest.DB.Password == "" {
+	if db.Password == "" {
 		return errors.New("missing DB password")
 	}
 
@@ -43,6 +43,13 @@ func TestValidationCloneRequestErrors(t *testing.T) {
 			createRequest: types.CloneCreateRequest{DB: &types.DatabaseRequest{Password: "password"}},
 			error:         "missing DB username",
 		},
+		{
+			createRequest: types.CloneCreateRequest{
+				DB:          &types.DatabaseRequest{Username: "user", Password: "password"},
+				AllowEgress: []string{"10.0.0.5:http"},
+			},
+			error: `invalid port of the egress destination "10.0.0.5:http"`,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -51,3 +58,16 @@ func TestValidationCloneRequestErrors(t *testing.T) {
 		assert.EqualError(t, err, tc.error)
 	}
 }
+
+func TestValidationForkRequest(t *testing.T) {
+	validator := Service{}
+
+	assert.NoError(t, validator.ValidateForkRequest(&types.CloneForkRequest{
+		DB: &types.DatabaseRequest{Username: "username", Password: "password"},
+	}))
+
+	assert.EqualError(t, validator.ValidateForkRequest(&types.CloneForkRequest{ID: "fork"}), "missing both DB username and password")
+	assert.EqualError(t, validator.ValidateForkRequest(&types.CloneForkRequest{
+		DB: &types.DatabaseRequest{Username: "username"},
+	}), "missing DB password")
+}
@@ -0,0 +1,89 @@
+/*
+2021 © Postgres.ai
+*/
+
+package srv
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/srv/api"
+)
+
+// metricsContentType defines the content type of the Prometheus text exposition format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// syncStatuses lists sync status codes exposed as labels.
+var syncStatuses = []models.SyncStatusCode{models.SyncStatusActive, models.SyncStatusDegraded, models.SyncStatusDown}
+
+func (s *Server) getMetrics(w http.ResponseWriter, r *http.Request) {
+	status, err := s.Cloning.GetInstanceState()
+	if err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	var retrieving *models.Retrieving
+
+	if s.retrieval != nil {
+		retrieving = s.retrieval.Status(r.Context())
+	}
+
+	w.Header().Set("Content-Type", metricsContentType)
+
+	if err := writeMetrics(w, status, retrieving); err != nil {
+		log.Err("Failed to write metrics: ", err)
+	}
+}
+
+type metricSample struct {
+	labels string
+	value  float64
+}
+
+// writeMetrics writes the instance state in the Prometheus text exposition format.
+func writeMetrics(w io.Writer, status *models.InstanceStatus, retrieving *models.Retrieving) error {
+	metrics := &strings.Builder{}
+
+	writeGauge(metrics, "dblab_clones", "Number of clones.", metricSample{value: float64(status.NumClones)})
+
+	if retrieving != nil && retrieving.Sync != nil {
+		syncState := retrieving.Sync
+		statusSamples := make([]metricSample, 0, len(syncStatuses))
+
+		for _, syncStatus := range syncStatuses {
+			sample := metricSample{labels: fmt.Sprintf(`{status=%q}`, syncStatus)}
+			if syncState.Status == syncStatus {
+				sample.value = 1
+			}
+
+			statusSamples = append(statusSamples, sample)
+		}
+
+		writeGauge(metrics, "dblab_sync_status", "Status of the synchronization with the source.", statusSamples...)
+		writeGauge(metrics, "dblab_sync_lag_seconds", "Replication lag of the sync instance in seconds, -1 if unknown.",
+			metricSample{value: float64(syncState.LagSeconds)})
+
+		if syncState.ReplayLSN != "" {
+			writeGauge(metrics, "dblab_sync_lag_bytes", "WAL received but not replayed by the sync instance, -1 if unknown.",
+				metricSample{value: float64(syncState.LagBytes)})
+		}
+	}
+
+	_, err := io.WriteString(w, metrics.String())
+
+	return err
+}
+
+func writeGauge(metrics *strings.Builder, name, help string, samples ...metricSample) {
+	metrics.WriteString(fmt.Sprintf("# HELP %s %s\n# TYPE %s gauge\n", name, help, name))
+
+	for _, sample := range samples {
+		metrics.WriteString(fmt.Sprintf("%s%s %v\n", name, sample.labels, sample.value))
+	}
+}
@@ -0,0 +1,44 @@
+package srv
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	require.NoError(t, writeMetrics(buf, &models.InstanceStatus{NumClones: 2}, nil))
+	assert.Equal(t, "# HELP dblab_clones Number of clones.\n# TYPE dblab_clones gauge\ndblab_clones 2\n", buf.String())
+
+	buf.Reset()
+
+	require.NoError(t, writeMetrics(buf, &models.InstanceStatus{}, &models.Retrieving{Sync: &models.Sync{
+		Status:     models.SyncStatusDegraded,
+		LagSeconds: 120,
+		LagBytes:   232,
+		ReplayLSN:  "0/3000060",
+	}}))
+
+	expected := `# HELP dblab_clones Number of clones.
+# TYPE dblab_clones gauge
+dblab_clones 0
+# HELP dblab_sync_status Status of the synchronization with the source.
+# TYPE dblab_sync_status gauge
+dblab_sync_status{status="ACTIVE"} 0
+dblab_sync_status{status="DEGRADED"} 1
+dblab_sync_status{status="DOWN"} 0
+# HELP dblab_sync_lag_seconds Replication lag of the sync instance in seconds, -1 if unknown.
+# TYPE dblab_sync_lag_seconds gauge
+dblab_sync_lag_seconds 120
+# HELP dblab_sync_lag_bytes WAL received but not replayed by the sync instance, -1 if unknown.
+# TYPE dblab_sync_lag_bytes gauge
+dblab_sync_lag_bytes 232
+`
+	assert.Equal(t, expected, buf.String())
+}
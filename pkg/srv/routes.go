@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"os"
 	"strconv"
@@ -20,6 +22,7 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/observer"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/provision/resources"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/srv/api"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/util"
 	"gitlab.com/postgres-ai/database-lab/v2/version"
@@ -32,6 +35,13 @@ func (s *Server) getInstanceStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.retrieval != nil {
+		// Copy the shared instance state before extending it.
+		instanceStatus := *status
+		instanceStatus.Retrieving = s.retrieval.Status(r.Context())
+		status = &instanceStatus
+	}
+
 	if err = api.WriteJSON(w, http.StatusOK, status); err != nil {
 		api.SendError(w, r, err)
 		return
@@ -51,6 +61,104 @@ func (s *Server) getSnapshots(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) getSnapshotsDiff(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	fromSnapshotID := values.Get("from")
+	toSnapshotID := values.Get("to")
+
+	if fromSnapshotID == "" || toSnapshotID == "" {
+		api.SendBadRequestError(w, r, "from and to must not be empty")
+		return
+	}
+
+	diff, err := s.Cloning.DiffSnapshots(r.Context(), fromSnapshotID, toSnapshotID)
+	if err != nil {
+		api.SendError(w, r, errors.Wrap(err, "failed to diff snapshots"))
+		return
+	}
+
+	if err := api.WriteJSON(w, http.StatusOK, diff); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+}
+
+func (s *Server) exportSnapshot(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	snapshotID := values.Get("snapshot_id")
+	baseSnapshotID := values.Get("base_snapshot_id")
+
+	if snapshotID == "" {
+		api.SendBadRequestError(w, r, "snapshot_id must not be empty")
+		return
+	}
+
+	if snapshotID == baseSnapshotID {
+		api.SendBadRequestError(w, r, "base_snapshot_id must differ from snapshot_id")
+		return
+	}
+
+	fsm := s.pm.Active()
+	if fsm == nil {
+		api.SendError(w, r, errors.New("no available pools"))
+		return
+	}
+
+	snapshots, err := fsm.GetSnapshots()
+	if err != nil {
+		api.SendError(w, r, errors.Wrap(err, "failed to get snapshots"))
+		return
+	}
+
+	if !hasSnapshot(snapshots, snapshotID) || (baseSnapshotID != "" && !hasSnapshot(snapshots, baseSnapshotID)) {
+		api.SendNotFoundError(w, r)
+		return
+	}
+
+	stream := &snapshotStream{w: w}
+
+	if err := fsm.ExportSnapshot(stream, snapshotID, baseSnapshotID); err != nil {
+		if !stream.started {
+			api.SendError(w, r, errors.Wrap(err, "failed to export snapshot"))
+			return
+		}
+
+		// The response has already been started, so the receiver detects the broken stream by itself.
+		log.Err(fmt.Sprintf("Failed to export snapshot %s: %v", snapshotID, err))
+
+		return
+	}
+
+	log.Msg(fmt.Sprintf("Snapshot %s has been exported", snapshotID))
+}
+
+func hasSnapshot(snapshots []resources.Snapshot, snapshotID string) bool {
+	for _, snapshot := range snapshots {
+		if snapshot.ID == snapshotID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// snapshotStream writes response headers on the first write, so an error can still be sent before streaming starts.
+type snapshotStream struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+// Write implements io.Writer.
+func (s *snapshotStream) Write(p []byte) (int, error) {
+	if !s.started {
+		s.w.Header().Set("Content-Type", "application/octet-stream")
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+
+	return s.w.Write(p)
+}
+
 func (s *Server) createClone(w http.ResponseWriter, r *http.Request) {
 	var cloneRequest *types.CloneCreateRequest
 	if err := api.ReadJSON(r, &cloneRequest); err != nil {
@@ -77,6 +185,39 @@ func (s *Server) createClone(w http.ResponseWriter, r *http.Request) {
 	log.Dbg(fmt.Sprintf("Clone ID=%s is being created", newClone.ID))
 }
 
+func (s *Server) forkClone(w http.ResponseWriter, r *http.Request) {
+	cloneID := mux.Vars(r)["id"]
+
+	if cloneID == "" {
+		api.SendBadRequestError(w, r, "ID must not be empty")
+		return
+	}
+
+	var forkRequest types.CloneForkRequest
+	if err := api.ReadJSON(r, &forkRequest); err != nil {
+		api.SendBadRequestError(w, r, err.Error())
+		return
+	}
+
+	if err := s.validator.ValidateForkRequest(&forkRequest); err != nil {
+		api.SendBadRequestError(w, r, err.Error())
+		return
+	}
+
+	fork, err := s.Cloning.ForkClone(cloneID, &forkRequest)
+	if err != nil {
+		api.SendError(w, r, errors.Wrap(err, "failed to fork clone"))
+		return
+	}
+
+	if err := api.WriteJSON(w, http.StatusCreated, fork); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	log.Dbg(fmt.Sprintf("Clone ID=%s is being forked from clone ID=%s", fork.ID, cloneID))
+}
+
 func (s *Server) destroyClone(w http.ResponseWriter, r *http.Request) {
 	cloneID := mux.Vars(r)["id"]
 
@@ -168,6 +309,245 @@ func (s *Server) resetClone(w http.ResponseWriter, r *http.Request) {
 	log.Dbg(fmt.Sprintf("Clone ID=%s is being reset", cloneID))
 }
 
+func (s *Server) hibernateClone(w http.ResponseWriter, r *http.Request) {
+	cloneID := mux.Vars(r)["id"]
+
+	if cloneID == "" {
+		api.SendBadRequestError(w, r, "ID must not be empty")
+		return
+	}
+
+	if err := s.Cloning.HibernateClone(cloneID); err != nil {
+		api.SendError(w, r, errors.Wrap(err, "failed to hibernate clone"))
+		return
+	}
+
+	log.Dbg(fmt.Sprintf("Clone ID=%s is being hibernated", cloneID))
+}
+
+func (s *Server) resumeClone(w http.ResponseWriter, r *http.Request) {
+	cloneID := mux.Vars(r)["id"]
+
+	if cloneID == "" {
+		api.SendBadRequestError(w, r, "ID must not be empty")
+		return
+	}
+
+	if err := s.Cloning.ResumeClone(cloneID); err != nil {
+		api.SendError(w, r, errors.Wrap(err, "failed to resume clone"))
+		return
+	}
+
+	log.Dbg(fmt.Sprintf("Clone ID=%s is being resumed", cloneID))
+}
+
+func (s *Server) createSavepoint(w http.ResponseWriter, r *http.Request) {
+	cloneID := mux.Vars(r)["id"]
+
+	if cloneID == "" {
+		api.SendBadRequestError(w, r, "ID must not be empty")
+		return
+	}
+
+	var savepointRequest types.SavepointCreateRequest
+
+	// The request body is optional: the name of the savepoint is generated if it is not defined.
+	if err := json.NewDecoder(r.Body).Decode(&savepointRequest); err != nil && err != io.EOF {
+		api.SendError(w, r, errors.Wrap(err, "failed to parse request parameters"))
+		return
+	}
+
+	savepoint, err := s.Cloning.CreateSavepoint(cloneID, savepointRequest.Name)
+	if err != nil {
+		api.SendError(w, r, errors.Wrap(err, "failed to create savepoint"))
+		return
+	}
+
+	if err := api.WriteJSON(w, http.StatusCreated, savepoint); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	log.Dbg(fmt.Sprintf("Savepoint %q of clone ID=%s has been created", savepoint.Name, cloneID))
+}
+
+func (s *Server) getSavepoints(w http.ResponseWriter, r *http.Request) {
+	cloneID := mux.Vars(r)["id"]
+
+	if cloneID == "" {
+		api.SendBadRequestError(w, r, "ID must not be empty")
+		return
+	}
+
+	savepoints, err := s.Cloning.GetSavepoints(cloneID)
+	if err != nil {
+		api.SendError(w, r, errors.Wrap(err, "failed to get savepoints"))
+		return
+	}
+
+	if err := api.WriteJSON(w, http.StatusOK, savepoints); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+}
+
+func (s *Server) revertSavepoint(w http.ResponseWriter, r *http.Request) {
+	cloneID, name := mux.Vars(r)["id"], mux.Vars(r)["name"]
+
+	if cloneID == "" || name == "" {
+		api.SendBadRequestError(w, r, "ID and savepoint name must not be empty")
+		return
+	}
+
+	if err := s.Cloning.RevertSavepoint(cloneID, name); err != nil {
+		api.SendError(w, r, errors.Wrap(err, "failed to revert clone to savepoint"))
+		return
+	}
+
+	log.Dbg(fmt.Sprintf("Clone ID=%s is being reverted to savepoint %q", cloneID, name))
+}
+
+func (s *Server) exportClone(w http.ResponseWriter, r *http.Request) {
+	cloneID := mux.Vars(r)["id"]
+
+	if cloneID == "" {
+		api.SendBadRequestError(w, r, "ID must not be empty")
+		return
+	}
+
+	var exportRequest types.CloneExportRequest
+
+	// The request body is optional: the clone is exported in the custom format of pg_dump by default.
+	if err := json.NewDecoder(r.Body).Decode(&exportRequest); err != nil && err != io.EOF {
+		api.SendError(w, r, errors.Wrap(err, "failed to parse request parameters"))
+		return
+	}
+
+	export, err := s.Cloning.ExportClone(cloneID, exportRequest)
+	if err != nil {
+		api.SendError(w, r, errors.Wrap(err, "failed to export clone"))
+		return
+	}
+
+	if err := api.WriteJSON(w, http.StatusAccepted, export); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	log.Dbg(fmt.Sprintf("Clone ID=%s is being exported in the %s format", cloneID, export.Format))
+}
+
+func (s *Server) getCloneExport(w http.ResponseWriter, r *http.Request) {
+	cloneID := mux.Vars(r)["id"]
+
+	if cloneID == "" {
+		api.SendBadRequestError(w, r, "ID must not be empty")
+		return
+	}
+
+	export, err := s.Cloning.GetCloneExport(cloneID)
+	if err != nil {
+		api.SendError(w, r, errors.Wrap(err, "failed to get clone export"))
+		return
+	}
+
+	if err := api.WriteJSON(w, http.StatusOK, export); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+}
+
+func (s *Server) downloadCloneExport(w http.ResponseWriter, r *http.Request) {
+	cloneID := mux.Vars(r)["id"]
+
+	if cloneID == "" {
+		api.SendBadRequestError(w, r, "ID must not be empty")
+		return
+	}
+
+	filePath, fileName, err := s.Cloning.GetCloneExportFile(cloneID)
+	if err != nil {
+		api.SendError(w, r, errors.Wrap(err, "failed to download clone export"))
+		return
+	}
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeFile(w, r, filePath)
+}
+
+func (s *Server) getCloneSchemaDiff(w http.ResponseWriter, r *http.Request) {
+	cloneID := mux.Vars(r)["id"]
+
+	if cloneID == "" {
+		api.SendBadRequestError(w, r, "ID must not be empty")
+		return
+	}
+
+	diff, err := s.Cloning.DiffCloneSchema(r.Context(), cloneID, r.URL.Query().Get("db_name"))
+	if err != nil {
+		api.SendError(w, r, errors.Wrap(err, "failed to diff clone schema"))
+		return
+	}
+
+	if err := api.WriteJSON(w, http.StatusOK, diff); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+}
+
+func (s *Server) runCloneQuery(w http.ResponseWriter, r *http.Request) {
+	cloneID := mux.Vars(r)["id"]
+
+	if cloneID == "" {
+		api.SendBadRequestError(w, r, "ID must not be empty")
+		return
+	}
+
+	var queryRequest types.QueryRequest
+	if err := api.ReadJSON(r, &queryRequest); err != nil {
+		api.SendBadRequestError(w, r, err.Error())
+		return
+	}
+
+	result, err := s.Cloning.RunQuery(r.Context(), cloneID, queryRequest, s.Estimator.Config())
+	if err != nil {
+		api.SendError(w, r, errors.Wrap(err, "failed to run query"))
+		return
+	}
+
+	if err := api.WriteJSON(w, http.StatusOK, result); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+}
+
+func (s *Server) adviseCloneIndexes(w http.ResponseWriter, r *http.Request) {
+	cloneID := mux.Vars(r)["id"]
+
+	if cloneID == "" {
+		api.SendBadRequestError(w, r, "ID must not be empty")
+		return
+	}
+
+	var adviceRequest types.IndexAdviceRequest
+	if err := api.ReadJSON(r, &adviceRequest); err != nil {
+		api.SendBadRequestError(w, r, err.Error())
+		return
+	}
+
+	advice, err := s.Cloning.AdviseIndexes(r.Context(), cloneID, adviceRequest)
+	if err != nil {
+		api.SendError(w, r, errors.Wrap(err, "failed to advise indexes"))
+		return
+	}
+
+	if err := api.WriteJSON(w, http.StatusOK, advice); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+}
+
 func (s *Server) startEstimator(w http.ResponseWriter, r *http.Request) {
 	values := r.URL.Query()
 	cloneID := values.Get("clone_id")
@@ -17,6 +17,7 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/config/global"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/estimator"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v2/pkg/models"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/observer"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/cloning"
 	"gitlab.com/postgres-ai/database-lab/v2/pkg/services/platform"
@@ -31,9 +32,15 @@ import (
 
 // Config provides configuration for an HTTP server of the Database Lab.
 type Config struct {
-	VerificationToken string `yaml:"verificationToken"`
-	Host              string `yaml:"host"`
-	Port              uint   `yaml:"port"`
+	VerificationToken string    `yaml:"verificationToken"`
+	Host              string    `yaml:"host"`
+	Port              uint      `yaml:"port"`
+	TLS               TLSConfig `yaml:"tls"`
+}
+
+// RetrievalStatus provides the state of data retrieval.
+type RetrievalStatus interface {
+	Status(ctx context.Context) *models.Retrieving
 }
 
 // Server defines an HTTP server of the Database Lab.
@@ -49,11 +56,14 @@ type Server struct {
 	httpSrv   *http.Server
 	docker    *client.Client
 	pm        *pool.Manager
+	retrieval RetrievalStatus
+	tls       *tlsProvider
 }
 
 // NewServer initializes a new Server instance with provided configuration.
 func NewServer(cfg *Config, globalCfg *global.Config, observer *observer.Observer, cloning *cloning.Base,
-	platform *platform.Service, dockerClient *client.Client, estimator *estimator.Estimator, pm *pool.Manager) *Server {
+	platform *platform.Service, dockerClient *client.Client, estimator *estimator.Estimator, pm *pool.Manager,
+	retrieval RetrievalStatus) *Server {
 	// TODO(anatoly): Stop using mock data.
 	server := &Server{
 		Config:    cfg,
@@ -65,6 +75,8 @@ func NewServer(cfg *Config, globalCfg *global.Config, observer *observer.Observe
 		upgrader:  websocket.Upgrader{},
 		docker:    dockerClient,
 		pm:        pm,
+		retrieval: retrieval,
+		tls:       &tlsProvider{},
 	}
 
 	return server
@@ -95,8 +107,29 @@ func attachAPI(r *mux.Router) error {
 }
 
 // Reload reloads server configuration.
+// TLS certificates are reloaded if the server serves TLS. Enabling or disabling TLS requires a restart.
 func (s *Server) Reload(cfg Config) {
 	*s.Config = cfg
+
+	if !s.tls.isLoaded() {
+		if cfg.TLS.IsEnabled() {
+			log.Msg("Restart the server to enable TLS")
+		}
+
+		return
+	}
+
+	if !cfg.TLS.IsEnabled() {
+		log.Msg("Restart the server to disable TLS")
+		return
+	}
+
+	if err := s.tls.load(cfg.TLS); err != nil {
+		log.Err("Failed to reload TLS certificates: ", err)
+		return
+	}
+
+	log.Msg("TLS certificates have been reloaded")
 }
 
 // InitHandlers initializes handler functions of the HTTP server.
@@ -106,12 +139,27 @@ func (s *Server) InitHandlers() {
 	authMW := mw.NewAuth(s.Config.VerificationToken, s.Platform)
 
 	r.HandleFunc("/status", authMW.Authorized(s.getInstanceStatus)).Methods(http.MethodGet)
+	r.HandleFunc("/metrics", authMW.Authorized(s.getMetrics)).Methods(http.MethodGet)
 	r.HandleFunc("/snapshots", authMW.Authorized(s.getSnapshots)).Methods(http.MethodGet)
+	r.HandleFunc("/snapshots/diff", authMW.Authorized(s.getSnapshotsDiff)).Methods(http.MethodGet)
+	r.HandleFunc("/snapshots/export", authMW.Authorized(s.exportSnapshot)).Methods(http.MethodGet)
 	r.HandleFunc("/clone", authMW.Authorized(s.createClone)).Methods(http.MethodPost)
 	r.HandleFunc("/clone/{id}", authMW.Authorized(s.destroyClone)).Methods(http.MethodDelete)
 	r.HandleFunc("/clone/{id}", authMW.Authorized(s.patchClone)).Methods(http.MethodPatch)
 	r.HandleFunc("/clone/{id}", authMW.Authorized(s.getClone)).Methods(http.MethodGet)
 	r.HandleFunc("/clone/{id}/reset", authMW.Authorized(s.resetClone)).Methods(http.MethodPost)
+	r.HandleFunc("/clone/{id}/fork", authMW.Authorized(s.forkClone)).Methods(http.MethodPost)
+	r.HandleFunc("/clone/{id}/hibernate", authMW.Authorized(s.hibernateClone)).Methods(http.MethodPost)
+	r.HandleFunc("/clone/{id}/resume", authMW.Authorized(s.resumeClone)).Methods(http.MethodPost)
+	r.HandleFunc("/clone/{id}/savepoints", authMW.Authorized(s.createSavepoint)).Methods(http.MethodPost)
+	r.HandleFunc("/clone/{id}/savepoints", authMW.Authorized(s.getSavepoints)).Methods(http.MethodGet)
+	r.HandleFunc("/clone/{id}/savepoints/{name}/revert", authMW.Authorized(s.revertSavepoint)).Methods(http.MethodPost)
+	r.HandleFunc("/clone/{id}/export", authMW.Authorized(s.exportClone)).Methods(http.MethodPost)
+	r.HandleFunc("/clone/{id}/export", authMW.Authorized(s.getCloneExport)).Methods(http.MethodGet)
+	r.HandleFunc("/clone/{id}/export/download", authMW.Authorized(s.downloadCloneExport)).Methods(http.MethodGet)
+	r.HandleFunc("/clone/{id}/schema-diff", authMW.Authorized(s.getCloneSchemaDiff)).Methods(http.MethodGet)
+	r.HandleFunc("/clone/{id}/query", authMW.Authorized(s.runCloneQuery)).Methods(http.MethodPost)
+	r.HandleFunc("/clone/{id}/index-advice", authMW.Authorized(s.adviseCloneIndexes)).Methods(http.MethodPost)
 	r.HandleFunc("/clone/{id}", authMW.Authorized(s.getClone)).Methods(http.MethodGet)
 	r.HandleFunc("/observation/start", authMW.Authorized(s.startObservation)).Methods(http.MethodPost)
 	r.HandleFunc("/observation/stop", authMW.Authorized(s.stopObservation)).Methods(http.MethodPost)
@@ -140,7 +188,20 @@ func (s *Server) InitHandlers() {
 
 // Run starts HTTP server on specified port in configuration.
 func (s *Server) Run() error {
+	if s.Config.TLS.IsEnabled() {
+		if err := s.tls.load(s.Config.TLS); err != nil {
+			return err
+		}
+
+		s.httpSrv.TLSConfig = s.tls.tlsConfig()
+
+		log.Msg(fmt.Sprintf("Server started listening on %s:%d with TLS.", s.Config.Host, s.Config.Port))
+
+		return s.httpSrv.ListenAndServeTLS("", "")
+	}
+
 	log.Msg(fmt.Sprintf("Server started listening on %s:%d.", s.Config.Host, s.Config.Port))
+
 	return s.httpSrv.ListenAndServe()
 }
 
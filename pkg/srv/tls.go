@@ -0,0 +1,118 @@
+/*
+2021 © Postgres.ai
+*/
+
+package srv
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// TLSConfig provides TLS configuration of the HTTP server.
+type TLSConfig struct {
+	CertFile     string `yaml:"certFile"`
+	KeyFile      string `yaml:"keyFile"`
+	ClientCAFile string `yaml:"clientCAFile"`
+}
+
+// IsEnabled checks if the HTTP server has to serve TLS.
+func (c TLSConfig) IsEnabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// tlsProvider keeps the current certificates, so they can be replaced without restarting the listener.
+type tlsProvider struct {
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+}
+
+// load reads certificates. The current certificates are kept if any file cannot be loaded.
+func (p *tlsProvider) load(cfg TLSConfig) error {
+	certificate, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to load the server certificate")
+	}
+
+	var clientCAs *x509.CertPool
+
+	if cfg.ClientCAFile != "" {
+		clientCAs, err = loadCertPool(cfg.ClientCAFile)
+		if err != nil {
+			return errors.Wrap(err, "failed to load client CA certificates")
+		}
+	}
+
+	p.mu.Lock()
+	p.certificate = &certificate
+	p.clientCAs = clientCAs
+	p.mu.Unlock()
+
+	return nil
+}
+
+func (p *tlsProvider) isLoaded() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.certificate != nil
+}
+
+// tlsConfig builds a TLS configuration using the current certificates for every connection.
+// Client certificates are required and verified if client CAs are configured.
+func (p *tlsProvider) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: p.getCertificate,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			p.mu.RLock()
+			defer p.mu.RUnlock()
+
+			if p.certificate == nil {
+				return nil, errors.New("server certificate is not loaded")
+			}
+
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*p.certificate},
+			}
+
+			if p.clientCAs != nil {
+				cfg.ClientCAs = p.clientCAs
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+
+			return cfg, nil
+		},
+	}
+}
+
+func (p *tlsProvider) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.certificate == nil {
+		return nil, errors.New("server certificate is not loaded")
+	}
+
+	return p.certificate, nil
+}
+
+func loadCertPool(filename string) (*x509.CertPool, error) {
+	pemCerts, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemCerts) {
+		return nil, errors.Errorf("no certificates found in %s", filename)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,158 @@
+package srv
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCertificate struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// issueTestCertificate issues a certificate signed by the parent or a self-signed CA certificate if the parent is nil.
+func issueTestCertificate(t *testing.T, dir, name string, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serialNumber, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	parentCert, parentKey := template, key
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile := path.Join(dir, name+".crt"), path.Join(dir, name+".key")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	return &testCertificate{cert: cert, key: key, certFile: certFile, keyFile: keyFile}
+}
+
+func startTLSServer(t *testing.T, provider *tlsProvider) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	server.TLS = provider.tlsConfig()
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func testClient(ca *testCertificate, clientCert *testCertificate) *http.Client {
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(ca.cert)
+
+	tlsConfig := &tls.Config{RootCAs: rootCAs}
+
+	if clientCert != nil {
+		tlsConfig.Certificates = []tls.Certificate{{
+			Certificate: [][]byte{clientCert.cert.Raw},
+			PrivateKey:  clientCert.key,
+		}}
+	}
+
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+}
+
+func TestTLSConfigIsEnabled(t *testing.T) {
+	assert.False(t, TLSConfig{}.IsEnabled())
+	assert.False(t, TLSConfig{ClientCAFile: "ca.crt"}.IsEnabled())
+	assert.True(t, TLSConfig{CertFile: "server.crt", KeyFile: "server.key"}.IsEnabled())
+}
+
+func TestTLSProviderClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueTestCertificate(t, dir, "ca", nil)
+	serverCert := issueTestCertificate(t, dir, "server", ca)
+	clientCert := issueTestCertificate(t, dir, "client", ca)
+
+	provider := &tlsProvider{}
+	require.NoError(t, provider.load(TLSConfig{CertFile: serverCert.certFile, KeyFile: serverCert.keyFile, ClientCAFile: ca.certFile}))
+
+	server := startTLSServer(t, provider)
+
+	_, err := testClient(ca, nil).Get(server.URL)
+	assert.Error(t, err)
+
+	response, err := testClient(ca, clientCert).Get(server.URL)
+	require.NoError(t, err)
+	_ = response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+func TestTLSProviderReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueTestCertificate(t, dir, "ca", nil)
+	serverCert := issueTestCertificate(t, dir, "server", ca)
+	renewedCert := issueTestCertificate(t, dir, "renewed", ca)
+
+	provider := &tlsProvider{}
+	require.NoError(t, provider.load(TLSConfig{CertFile: serverCert.certFile, KeyFile: serverCert.keyFile}))
+
+	server := startTLSServer(t, provider)
+
+	assertServerCertificate := func(expected string) {
+		client := testClient(ca, nil)
+		client.Transport.(*http.Transport).DisableKeepAlives = true
+
+		response, err := client.Get(server.URL)
+		require.NoError(t, err)
+		_ = response.Body.Close()
+		assert.Equal(t, expected, response.TLS.PeerCertificates[0].Subject.CommonName)
+	}
+
+	assertServerCertificate("server")
+
+	require.NoError(t, provider.load(TLSConfig{CertFile: renewedCert.certFile, KeyFile: renewedCert.keyFile}))
+	assertServerCertificate("renewed")
+
+	// Broken files keep the current certificate.
+	assert.Error(t, provider.load(TLSConfig{CertFile: path.Join(dir, "missing.crt"), KeyFile: renewedCert.keyFile}))
+	assert.Error(t, provider.load(TLSConfig{CertFile: serverCert.certFile, KeyFile: serverCert.keyFile, ClientCAFile: serverCert.keyFile}))
+	assertServerCertificate("renewed")
+}
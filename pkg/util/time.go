@@ -63,6 +63,11 @@ func FormatTime(t time.Time) string {
 		f.Day(), f.Hour(), f.Minute(), f.Second())
 }
 
+// ParseTime returns time parsed from string in the format of FormatTime.
+func ParseTime(str string) (time.Time, error) {
+	return time.Parse("2006-01-02 15:04:05 UTC", str)
+}
+
 // ParseUnixTime returns time parsed from unix timestamp integer.
 func ParseUnixTime(str string) (time.Time, error) {
 	timeInt, err := strconv.ParseInt(str, 10, 64)
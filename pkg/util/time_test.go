@@ -94,3 +94,16 @@ func TestFormatTime(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestParseTime(t *testing.T) {
+	expected := time.Date(2019, time.December, 10, 23, 0, 10, 0, time.UTC)
+
+	actual, err := ParseTime(FormatTime(expected))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !actual.Equal(expected) {
+		t.Errorf("Got different result than expected: %v, %v", expected, actual)
+	}
+}